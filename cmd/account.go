@@ -0,0 +1,110 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/users"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// The account as printed by `account --json`.
+type jsonAccount struct {
+	AccountID   string    `json:"account_id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	AccountType string    `json:"account_type"`
+	Team        string    `json:"team,omitempty"`
+	Usage       jsonUsage `json:"usage"`
+	Percent     float64   `json:"percent_used"`
+}
+
+// The share of `q` that's used, as a percentage.
+func (q quota) percent() float64 {
+	if q.Allocated == 0 {
+		return 0
+	}
+	return float64(q.Used) / float64(q.Allocated) * 100
+}
+
+func newJSONAccount(a *users.FullAccount, usage *users.SpaceUsage) jsonAccount {
+	out := jsonAccount{
+		AccountID: a.AccountId,
+		Email:     a.Email,
+		Usage:     newJSONUsage(usage),
+		Percent:   quotaOf(usage).percent(),
+	}
+	if a.Name != nil {
+		out.Name = a.Name.DisplayName
+	}
+	if a.AccountType != nil {
+		out.AccountType = a.AccountType.Tag
+	}
+	if a.Team != nil {
+		out.Team = a.Team.Name
+	}
+	return out
+}
+
+func account(cmd *cobra.Command, args []string) (err error) {
+	a, err := newUsersClient(cmdCtx).GetCurrentAccount()
+	if err != nil {
+		return
+	}
+	usage, err := fetchSpaceUsage()
+	if err != nil {
+		return
+	}
+
+	out := newJSONAccount(a, usage)
+	if jsonMode(cmd) {
+		return printJSON(out)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", out.Name)
+	fmt.Fprintf(w, "Email:\t%s\n", out.Email)
+	fmt.Fprintf(w, "Account type:\t%s\n", out.AccountType)
+	if out.Team != "" {
+		fmt.Fprintf(w, "Team:\t%s\n", out.Team)
+	}
+	q := quotaOf(usage)
+	fmt.Fprintf(w, "Space used:\t%s of %s (%.1f%%)\n", humanize.IBytes(q.Used), humanize.IBytes(q.Allocated), out.Percent)
+	if out.Usage.TeamUsed != nil {
+		fmt.Fprintf(w, "Used by you:\t%s\n", humanize.IBytes(usage.Used))
+	}
+	return w.Flush()
+}
+
+// accountCmd represents the account command
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Display the current account and its space usage",
+	Long: `Display the name, email and account type of the account dbxcli is
+signed in as, and how much of its space is used. For members of a team the
+space is the team's, shared by everyone on it.`,
+	Example: `  dbxcli account
+  dbxcli account --json`,
+	RunE: account,
+}
+
+func init() {
+	RootCmd.AddCommand(accountCmd)
+}
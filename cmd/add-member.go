@@ -26,20 +26,28 @@ func addMember(cmd *cobra.Command, args []string) (err error) {
 	if len(args) != 3 {
 		return errors.New("`add-member` requires `email`, `first`, and `last` arguments")
 	}
-	dbx := team.New(config)
+	dbx := newTeamClient(cmdCtx)
 
 	email := args[0]
 	firstName := args[1]
 	lastName := args[2]
 	member := team.NewMemberAddArg(email, firstName, lastName)
+	if noWelcome, _ := cmd.Flags().GetBool("no-welcome-email"); noWelcome {
+		member.SendWelcomeEmail = false
+	}
 	arg := team.NewMembersAddArg([]*team.MemberAddArg{member})
 	res, err := dbx.MembersAdd(arg)
 	if err != nil {
 		return err
 	}
-	if res.Tag == "complete" {
-		fmt.Printf("User successfully added to the team.\n")
+	if res.Tag != "complete" || len(res.Complete) != 1 {
+		return fmt.Errorf("adding %s didn't complete (%s)", email, res.Tag)
+	}
+	// The request succeeds even when adding the member fails.
+	if result := res.Complete[0]; result.Tag != "success" {
+		return fmt.Errorf("couldn't add %s: %s", email, result.Tag)
 	}
+	fmt.Printf("User successfully added to the team.\n")
 	return
 }
 
@@ -52,4 +60,6 @@ var addMemberCmd = &cobra.Command{
 
 func init() {
 	teamCmd.AddCommand(addMemberCmd)
+
+	addMemberCmd.Flags().Bool("no-welcome-email", false, "Don't send the new member a welcome email")
 }
@@ -0,0 +1,423 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dbxcli/keyring"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// Access tokens are refreshed this long before they expire, so that a
+// request doesn't set off with a token which runs out on the way.
+const tokenRefreshMargin = time.Minute
+
+// A saved token. Tokens from the current authorization flow are short-lived
+// and come with a refresh token; those from before are long-lived and saved
+// as a plain string, which is how they're written back too.
+type savedToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	// Set, in place of everything else, in the auth file entry of a token
+	// which is kept in the OS keyring.
+	Keyring bool `json:"keyring,omitempty"`
+	// Set on a token kept in the auth file because the OS keyring wouldn't
+	// store it, so that it isn't moved there again on every run.
+	KeyringFailed bool `json:"keyring_failed,omitempty"`
+
+	// Whether the token was read from the auth file itself.
+	inFile bool
+}
+
+func (t *savedToken) UnmarshalJSON(b []byte) error {
+	var legacy string
+	if json.Unmarshal(b, &legacy) == nil {
+		*t = savedToken{AccessToken: legacy}
+		return nil
+	}
+	type plain savedToken
+	return json.Unmarshal(b, (*plain)(t))
+}
+
+func (t savedToken) MarshalJSON() ([]byte, error) {
+	if t.Keyring {
+		return []byte(`{"keyring":true}`), nil
+	}
+	if t.RefreshToken == "" && !t.KeyringFailed {
+		return json.Marshal(t.AccessToken)
+	}
+	type plain savedToken
+	return json.Marshal(plain(t))
+}
+
+// Tokens are kept in the OS keyring where there is one, under this service
+// name, and the auth file only notes which ones there are. Where there's no
+// keyring they're kept in the auth file itself.
+const keyringService = "dbxcli"
+
+// The keyring is reached through these variables so that tests can use a
+// fake one.
+var (
+	keyringGet    = keyring.Get
+	keyringSet    = keyring.Set
+	keyringDelete = keyring.Delete
+)
+
+// Names a token in the keyring by the auth file it belongs to, so that each
+// profile has its own.
+func keyringUser(filePath string, domain string, tokType string) string {
+	if domain != "" {
+		tokType += "@" + domain
+	}
+	return filePath + ":" + tokType
+}
+
+// Replaces the entries of `tokens` which point into the keyring with the
+// tokens kept there. Tokens which can't be found are dropped, so that
+// dbxcli authorizes again.
+func loadKeyringTokens(filePath string, tokMap TokenMap) {
+	for domain, tokens := range tokMap {
+		for tokType, token := range tokens {
+			if token == nil {
+				continue
+			}
+			if !token.Keyring {
+				token.inFile = true
+				continue
+			}
+			var kept savedToken
+			secret, err := keyringGet(keyringService, keyringUser(filePath, domain, tokType))
+			if err == nil {
+				err = json.Unmarshal([]byte(secret), &kept)
+			}
+			if err != nil {
+				logger.Warn("couldn't read token from keyring", "profile", filePath, "token", tokType, "error", err)
+				delete(tokens, tokType)
+				continue
+			}
+			tokens[tokType] = &kept
+		}
+	}
+}
+
+// Moves the tokens into the keyring if possible, and returns what's left to
+// write to the auth file. The first time the keyring refuses a token it's
+// reported on stderr; after that only the log hears about it.
+func storeKeyringTokens(filePath string, tokMap TokenMap) TokenMap {
+	stored := make(TokenMap, len(tokMap))
+	for domain, tokens := range tokMap {
+		stored[domain] = make(map[string]*savedToken, len(tokens))
+		for tokType, token := range tokens {
+			stored[domain][tokType] = token
+			if token == nil {
+				continue
+			}
+			kept := *token
+			kept.KeyringFailed = false
+			b, err := json.Marshal(kept)
+			if err == nil {
+				err = keyringSet(keyringService, keyringUser(filePath, domain, tokType), string(b))
+			}
+			if err != nil {
+				if err != keyring.ErrUnsupported {
+					logger.Warn("couldn't store token in keyring, keeping it in the auth file", "profile", filePath, "token", tokType, "error", err)
+					if !token.KeyringFailed {
+						fmt.Fprintf(os.Stderr, "Warning: couldn't store the %s token in the OS keyring, so it's kept in %s: %v\n", tokType, filePath, err)
+						token.KeyringFailed = true
+					}
+				}
+				continue
+			}
+			token.inFile, token.KeyringFailed = false, false
+			stored[domain][tokType] = &savedToken{Keyring: true}
+		}
+	}
+	return stored
+}
+
+// Removes the tokens of an auth file from the keyring.
+func forgetKeyringTokens(filePath string, tokMap TokenMap) {
+	for domain, tokens := range tokMap {
+		for tokType := range tokens {
+			keyringDelete(keyringService, keyringUser(filePath, domain, tokType))
+		}
+	}
+}
+
+// Reports whether the token can't be used as it is any more.
+func (t *savedToken) stale(now time.Time) bool {
+	return t.RefreshToken != "" && !t.Expiry.IsZero() && now.Add(tokenRefreshMargin).After(t.Expiry)
+}
+
+// Returns a PKCE code verifier and its S256 challenge.
+func newCodeVerifier() (verifier string, challenge string, err error) {
+	b := make([]byte, 48)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Walks the user through authorizing dbxcli in their browser, and returns
+// the tokens for the code they paste back. The code is bound to a PKCE
+// verifier instead of an app secret, and asks for a refresh token.
+func authorize(conf *oauth2.Config) (*savedToken, error) {
+	verifier, challenge, err := newCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+	url := conf.AuthCodeURL("state",
+		oauth2.SetAuthURLParam("code_challenge", challenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		oauth2.SetAuthURLParam("token_access_type", "offline"))
+
+	fmt.Printf("1. Go to %v\n", url)
+	fmt.Printf("2. Click \"Allow\" (you might have to log in first).\n")
+	fmt.Printf("3. Copy the authorization code.\n")
+	fmt.Printf("Enter the authorization code here: ")
+
+	var code string
+	if _, err := fmt.Scan(&code); err != nil {
+		return nil, err
+	}
+	return exchangeCode(conf, code, verifier)
+}
+
+// Exchanges an authorization code for tokens. The vendored oauth2 package
+// can't send the PKCE verifier, so the request is made here.
+func exchangeCode(conf *oauth2.Config, code string, verifier string) (*savedToken, error) {
+	resp, err := http.PostForm(conf.Endpoint.TokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {conf.ClientID},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		return nil, fmt.Errorf("couldn't exchange the authorization code: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+
+	var res struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	t := &savedToken{AccessToken: res.AccessToken, RefreshToken: res.RefreshToken}
+	if res.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// The SDK's endpoints are the original, versioned ones, which predate PKCE
+// and refresh tokens.
+func oauthEndpoint(domain string) oauth2.Endpoint {
+	e := dropbox.OAuthEndpoint(domain)
+	e.AuthURL = strings.Replace(e.AuthURL, "/1/oauth2/", "/oauth2/", 1)
+	e.TokenURL = strings.Replace(e.TokenURL, "/1/oauth2/", "/oauth2/", 1)
+	return e
+}
+
+// Gets a new access token for `t`'s refresh token.
+func refreshToken(conf *oauth2.Config, t *savedToken) (*savedToken, error) {
+	token, err := conf.TokenSource(oauth2.NoContext, &oauth2.Token{RefreshToken: t.RefreshToken}).Token()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't refresh the access token, run `dbxcli logout` and sign in again: %v", err)
+	}
+	return &savedToken{AccessToken: token.AccessToken, RefreshToken: t.RefreshToken, Expiry: token.Expiry}, nil
+}
+
+// Hands out the current access token, refreshing it when it's about to
+// expire or the API has rejected it. Each new token is passed to `save`, so
+// that the next command starts with it.
+type tokenSource struct {
+	conf *oauth2.Config
+	save func(*savedToken)
+
+	mu    sync.Mutex
+	token *savedToken
+}
+
+func (s *tokenSource) accessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.stale(time.Now()) {
+		if err := s.refresh(); err != nil {
+			return "", err
+		}
+	}
+	return s.token.AccessToken, nil
+}
+
+// Called when `rejected` got a 401. Reports whether there's a different
+// token to try instead.
+func (s *tokenSource) reject(rejected string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.AccessToken != rejected {
+		// Another request has refreshed it already.
+		return true
+	}
+	if s.token.RefreshToken == "" {
+		return false
+	}
+	return s.refresh() == nil
+}
+
+func (s *tokenSource) refresh() error {
+	token, err := refreshToken(s.conf, s.token)
+	if err != nil {
+		return err
+	}
+	s.token = token
+	if s.save != nil {
+		s.save(token)
+	}
+	return nil
+}
+
+// An access token to use instead of a saved one, for CI jobs and containers
+// which can't go through the authorization flow.
+const tokenEnvVar = "DBXCLI_TOKEN"
+
+// Returns the tokens `cmd` runs with: the one given by --token or
+// DBXCLI_TOKEN if there is one, or else the saved one of the current
+// profile, authorizing dbxcli first if there's none yet. An explicit
+// --profile wins over DBXCLI_TOKEN.
+func initTokens(cmd *cobra.Command, domain string) (*tokenSource, error) {
+	token, _ := cmd.Flags().GetString("token")
+	if token != "" && cmd.Flags().Changed("profile") {
+		return nil, errors.New("`--token` can't be used with `--profile`")
+	}
+	if token == "" && !cmd.Flags().Changed("profile") {
+		token = os.Getenv(tokenEnvVar)
+	}
+	if token != "" {
+		// Given tokens are used as they are, and never saved.
+		return &tokenSource{token: &savedToken{AccessToken: token}}, nil
+	}
+
+	profile, _ := cmd.Flags().GetString("profile")
+	filePath, err := authFilePath(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkProfileExists(profile, filePath); err != nil {
+		return nil, err
+	}
+	tokType := tokenType(cmd)
+	conf := oauthConfig(tokType, domain)
+
+	tokenMap, err := readTokens(filePath)
+	if tokenMap == nil {
+		tokenMap = make(TokenMap)
+	}
+	if tokenMap[domain] == nil {
+		tokenMap[domain] = make(map[string]*savedToken)
+	}
+	tokens := tokenMap[domain]
+
+	if err != nil || tokens[tokType] == nil || tokens[tokType].AccessToken == "" {
+		if tokens[tokType], err = authorize(conf); err != nil {
+			return nil, err
+		}
+		writeTokens(filePath, tokenMap)
+	} else if tokens[tokType].inFile && !tokens[tokType].KeyringFailed {
+		// Saved before tokens went into the keyring; move them there.
+		writeTokens(filePath, tokenMap)
+	}
+	return &tokenSource{conf: conf, token: tokens[tokType], save: func(t *savedToken) {
+		tokens[tokType] = t
+		writeTokens(filePath, tokenMap)
+	}}, nil
+}
+
+// The token source of the current command, set up by initDbx.
+var activeTokens *tokenSource
+
+// Authorizes requests with the current access token, and sends a request
+// again once if its token turns out to have expired.
+type tokenTransport struct {
+	tokens *tokenSource
+	base   http.RoundTripper
+}
+
+func (t tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(withToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	if !t.tokens.reject(token) {
+		return resp, nil
+	}
+	if token, err = t.tokens.accessToken(); err != nil {
+		return resp, nil
+	}
+
+	retry := withToken(req, token)
+	if req.Body != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// Returns a copy of `req` authorized with `token`.
+func withToken(req *http.Request, token string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
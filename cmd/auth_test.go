@@ -0,0 +1,217 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/keyring"
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+// Replaces the OS keyring with a map until the test ends. With `setErr`,
+// storing secrets fails with it.
+func useFakeKeyring(t *testing.T, setErr error) map[string]string {
+	secrets := make(map[string]string)
+	savedGet, savedSet, savedDelete := keyringGet, keyringSet, keyringDelete
+	keyringGet = func(service, user string) (string, error) {
+		if s, ok := secrets[service+"/"+user]; ok {
+			return s, nil
+		}
+		return "", keyring.ErrNotFound
+	}
+	keyringSet = func(service, user, secret string) error {
+		if setErr != nil {
+			return setErr
+		}
+		secrets[service+"/"+user] = secret
+		return nil
+	}
+	keyringDelete = func(service, user string) error {
+		delete(secrets, service+"/"+user)
+		return nil
+	}
+	t.Cleanup(func() { keyringGet, keyringSet, keyringDelete = savedGet, savedSet, savedDelete })
+	return secrets
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestInitTokensEnv(t *testing.T) {
+	t.Setenv(tokenEnvVar, "env-token")
+	ts, err := initTokens(lsCmd, "")
+	if err != nil || ts.token.AccessToken != "env-token" {
+		t.Fatalf("initTokens = %+v, %v", ts, err)
+	}
+
+	// An explicit profile is used instead, so a missing one is an error.
+	setFlags(t, lsCmd, map[string]string{"profile": "work"})
+	if _, err = initTokens(lsCmd, ""); err == nil || !strings.Contains(err.Error(), `there's no profile "work"`) {
+		t.Errorf("initTokens with --profile = %v", err)
+	}
+}
+
+func TestKeyringTokens(t *testing.T) {
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		setErr error
+		// Whether the token ends up in the keyring rather than the file.
+		inKeyring bool
+		warning   string
+	}{
+		{name: "keyring", inKeyring: true},
+		{name: "no keyring", setErr: keyring.ErrUnsupported},
+		{name: "keyring fails", setErr: errors.New("locked"), warning: "couldn't store the personal token in the OS keyring"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets := useFakeKeyring(t, tt.setErr)
+			filePath := filepath.Join(t.TempDir(), "auth.json")
+			tokens := TokenMap{"": {tokenPersonal: {AccessToken: "access", RefreshToken: "refresh", Expiry: expiry}}}
+			_, stderr, _ := testutil.Capture(func() error {
+				writeTokens(filePath, tokens)
+				return nil
+			})
+			if !strings.Contains(stderr, tt.warning) || (tt.warning == "" && stderr != "") {
+				t.Errorf("printed %q, want %q", stderr, tt.warning)
+			}
+
+			inFile := strings.Contains(readFile(t, filePath), "access")
+			if inFile == tt.inKeyring || (len(secrets) == 1) != tt.inKeyring {
+				t.Errorf("auth file %s, keyring %v", readFile(t, filePath), secrets)
+			}
+			read, err := readTokens(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := read[""][tokenPersonal]; got == nil || got.AccessToken != "access" || got.RefreshToken != "refresh" || !got.Expiry.Equal(expiry) {
+				t.Errorf("read back %+v", got)
+			}
+		})
+	}
+}
+
+// A token the keyring has lost is dropped, so dbxcli authorizes again.
+func TestKeyringTokenMissing(t *testing.T) {
+	useFakeKeyring(t, nil)
+	filePath := filepath.Join(t.TempDir(), "auth.json")
+	if err := ioutil.WriteFile(filePath, []byte(`{"": {"personal": {"keyring": true}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readTokens(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := read[""][tokenPersonal]; ok {
+		t.Errorf("read %+v", read)
+	}
+}
+
+// An auth file that isn't JSON is reported, rather than read as empty.
+func TestReadTokensCorrupt(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "auth.json")
+	if err := ioutil.WriteFile(filePath, []byte(`{"": {"personal"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := readTokens(filePath); err == nil || read != nil {
+		t.Errorf("readTokens = %v, %v, want an error", read, err)
+	}
+}
+
+// Tokens saved in the auth file before the keyring was used are moved there.
+func TestMigrateLegacyTokens(t *testing.T) {
+	secrets := useFakeKeyring(t, nil)
+	t.Setenv(tokenEnvVar, "")
+	filePath, err := authFilePath(defaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(filePath) })
+	if err = ioutil.WriteFile(filePath, []byte(`{"": {"personal": "legacy-token"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := initTokens(lsCmd, "")
+	if err != nil || ts.token.AccessToken != "legacy-token" {
+		t.Fatalf("initTokens = %+v, %v", ts, err)
+	}
+	if got := readFile(t, filePath); got != `{"":{"personal":{"keyring":true}}}` {
+		t.Errorf("auth file is %s", got)
+	}
+	if secret := secrets[keyringService+"/"+keyringUser(filePath, "", tokenPersonal)]; secret != `"legacy-token"` {
+		t.Errorf("keyring has %q", secret)
+	}
+}
+
+// A keyring which won't store tokens is only tried, and complained about,
+// once.
+func TestMigrateTokensOnce(t *testing.T) {
+	useFakeKeyring(t, errors.New("locked"))
+	sets := 0
+	failingSet := keyringSet
+	keyringSet = func(service, user, secret string) error {
+		sets++
+		return failingSet(service, user, secret)
+	}
+	t.Setenv(tokenEnvVar, "")
+	filePath, err := authFilePath(defaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(filePath) })
+	if err = ioutil.WriteFile(filePath, []byte(`{"": {"personal": "legacy-token"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for run, warning := range []string{"couldn't store the personal token in the OS keyring", ""} {
+		var ts *tokenSource
+		_, stderr, err := testutil.Capture(func() (err error) {
+			ts, err = initTokens(lsCmd, "")
+			return
+		})
+		if err != nil || ts.token.AccessToken != "legacy-token" {
+			t.Fatalf("run %d: initTokens = %+v, %v", run, ts, err)
+		}
+		if !strings.Contains(stderr, warning) || (warning == "" && stderr != "") {
+			t.Errorf("run %d printed %q, want %q", run, stderr, warning)
+		}
+	}
+	if sets != 1 {
+		t.Errorf("tried the keyring %d times, want once", sets)
+	}
+	if got := readFile(t, filePath); !strings.Contains(got, `"access_token":"legacy-token"`) || !strings.Contains(got, `"keyring_failed":true`) {
+		t.Errorf("auth file is %s", got)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+)
+
+// The most entries the API accepts in a single relocation batch.
+const maxBatchEntries = 1000
+
+// Polling schedule for asynchronous batch jobs. It's unbounded; `--timeout`
+// is what limits the wait.
+var batchPollPolicy = retry.Exponential{Initial: 500 * time.Millisecond, Max: 5 * time.Second, Jitter: 0.2}
+
+// The vendored SDK predates the *_batch_v2 routes, so these mirror the API
+// types and are sent with rpc.
+type relocationPath struct {
+	FromPath string `json:"from_path"`
+	ToPath   string `json:"to_path"`
+}
+
+type relocationBatchArg struct {
+	Entries    []relocationPath `json:"entries"`
+	Autorename bool             `json:"autorename"`
+}
+
+// One entry of a finished batch. Relocation batches put the metadata of a
+// successful entry in "success" and delete batches in "metadata".
+type batchResultEntry struct {
+	dropbox.Tagged
+	Success  json.RawMessage `json:"success,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Failure  json.RawMessage `json:"failure,omitempty"`
+}
+
+// Covers both the launch result and the job status of a batch, which share
+// their "complete" variant.
+type batchStatus struct {
+	dropbox.Tagged
+	AsyncJobID string             `json:"async_job_id,omitempty"`
+	Entries    []batchResultEntry `json:"entries,omitempty"`
+}
+
+// The path_display of the metadata in a successful entry, if any.
+func (e batchResultEntry) pathDisplay() string {
+	raw := e.Success
+	if len(raw) == 0 {
+		raw = e.Metadata
+	}
+	var md struct {
+		PathDisplay string `json:"path_display"`
+	}
+	json.Unmarshal(raw, &md)
+	return md.PathDisplay
+}
+
+type asyncJobIDArg struct {
+	AsyncJobID string `json:"async_job_id"`
+}
+
+// Outcome of one entry of a relocation batch.
+type relocationResult struct {
+	relocationPath
+	// The path the entry ended up at, which differs from ToPath when it was
+	// autorenamed.
+	PathDisplay string
+	Err         error
+}
+
+// Joins the nested tags of a tagged union into a single reason, such as
+// "relocation_error/to/conflict/file".
+func describeTagged(raw json.RawMessage) string {
+	var tags []string
+	for len(raw) > 0 {
+		var v map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &v); err != nil {
+			break
+		}
+		var tag string
+		if err := json.Unmarshal(v[".tag"], &tag); err != nil || tag == "" {
+			break
+		}
+		tags = append(tags, tag)
+		raw = v[tag]
+	}
+	if len(tags) == 0 {
+		return "unknown error"
+	}
+	return strings.Join(tags, "/")
+}
+
+// Launches a batch job with `launch` and, if it runs asynchronously, polls
+// `check` until it's done. Returns the entries of the finished job, which
+// must match the `n` entries of the request one for one.
+func runBatch(ctx context.Context, launch string, check string, arg interface{}, n int) ([]batchResultEntry, error) {
+	status := new(batchStatus)
+	if err := rpc(ctx, "files", launch, arg, status); err != nil {
+		return nil, err
+	}
+
+	if status.Tag == "async_job_id" {
+		jobID := asyncJobIDArg{status.AsyncJobID}
+		err := retry.PollUntil(ctx, batchPollPolicy, func() (bool, error) {
+			status = new(batchStatus)
+			if err := rpc(ctx, "files", check, jobID, status); err != nil {
+				return false, err
+			}
+			return status.Tag != "in_progress", nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if status.Tag != "complete" {
+		return nil, fmt.Errorf("%s ended with unexpected status %q", launch, status.Tag)
+	}
+	if len(status.Entries) != n {
+		return nil, fmt.Errorf("%s returned %d results for %d entries", launch, len(status.Entries), n)
+	}
+	return status.Entries, nil
+}
+
+// Runs a copy or move batch (`op` is "copy" or "move") and waits for it to
+// finish. Results are returned in the order of `entries`.
+func relocationBatch(ctx context.Context, op string, entries []relocationPath, autorename bool) ([]relocationResult, error) {
+	arg := relocationBatchArg{Entries: entries, Autorename: autorename}
+	status, err := runBatch(ctx, op+"_batch_v2", op+"_batch/check_v2", arg, len(entries))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]relocationResult, len(entries))
+	for i, e := range status {
+		results[i].relocationPath = entries[i]
+		if e.Tag != "success" {
+			results[i].Err = fmt.Errorf("%s failed: %s", op, describeTagged(e.Failure))
+			continue
+		}
+		results[i].PathDisplay = e.pathDisplay()
+		if results[i].PathDisplay == "" {
+			results[i].PathDisplay = entries[i].ToPath
+		}
+	}
+	return results, nil
+}
+
+type deleteBatchArg struct {
+	Entries []deleteBatchEntry `json:"entries"`
+}
+
+type deleteBatchEntry struct {
+	Path string `json:"path"`
+}
+
+// Deletes `paths` in one batch and waits for it to finish. The returned
+// errors line up with `paths`; nil means the path was deleted.
+func deleteBatch(ctx context.Context, paths []string) ([]error, error) {
+	arg := deleteBatchArg{}
+	for _, p := range paths {
+		arg.Entries = append(arg.Entries, deleteBatchEntry{p})
+	}
+	status, err := runBatch(ctx, "delete_batch", "delete_batch/check", arg, len(paths))
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(paths))
+	for i, e := range status {
+		if e.Tag != "success" {
+			errs[i] = fmt.Errorf("delete failed: %s", describeTagged(e.Failure))
+		}
+	}
+	return errs, nil
+}
@@ -0,0 +1,130 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/spf13/cobra"
+)
+
+// A token bucket shared by every transfer, so --bwlimit bounds the total rate
+// however many files are moving at once.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	burst := int(bytesPerSecond)
+	if burst > 1<<20 {
+		burst = 1 << 20
+	}
+	return &rateLimiter{rate: float64(bytesPerSecond), burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// Takes `n` bytes' worth of tokens, waiting until they've accumulated.
+// Tokens are reserved first, so concurrent callers queue up fairly.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	return retry.Sleep(ctx, delay)
+}
+
+// Set from --bwlimit; nil means transfers aren't limited.
+var bandwidthLimit *rateLimiter
+
+func initBandwidthLimit(cmd *cobra.Command) error {
+	bandwidthLimit = nil
+	limit, _ := cmd.Flags().GetString("bwlimit")
+	if limit == "" || limit == "0" {
+		return nil
+	}
+	rate, err := parseByteSize(limit)
+	if err != nil {
+		return err
+	}
+	bandwidthLimit = newRateLimiter(rate)
+	return nil
+}
+
+// An io.ReadCloser which reads no faster than its limiter allows.
+type limitedBody struct {
+	ctx context.Context
+	l   *rateLimiter
+	rc  io.ReadCloser
+}
+
+func (b limitedBody) Read(p []byte) (int, error) {
+	if len(p) > b.l.burst {
+		p = p[:b.l.burst]
+	}
+	n, err := b.rc.Read(p)
+	if n > 0 {
+		if werr := b.l.wait(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (b limitedBody) Close() error {
+	return b.rc.Close()
+}
+
+// Wraps `send` so the bodies of requests and responses, and with them every
+// upload and download, are limited to --bwlimit.
+func throttled(ctx context.Context, send func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	l := bandwidthLimit
+	if l == nil {
+		return send
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil && req.Body != http.NoBody {
+			limited := new(http.Request)
+			*limited = *req
+			limited.Body = limitedBody{ctx, l, req.Body}
+			req = limited
+		}
+		resp, err := send(req)
+		if resp != nil && resp.Body != nil {
+			resp.Body = limitedBody{ctx, l, resp.Body}
+		}
+		return resp, err
+	}
+}
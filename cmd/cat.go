@@ -0,0 +1,81 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+func cat(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`cat` requires a `file` argument")
+	}
+
+	dec, err := newDecryptOptions(cmd)
+	if err != nil {
+		return
+	}
+
+	dbx := newFilesClient(cmdCtx)
+	for _, arg := range args {
+		matches, err := resolveArgs(cmd, dbx, arg)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			if _, isFolder := m.md.(*files.FolderMetadata); isFolder {
+				return fmt.Errorf("`cat`: %s is a folder", m.path)
+			}
+			res, contents, err := dbx.Download(files.NewDownloadArg(m.path))
+			if err != nil {
+				return err
+			}
+			err = writeDownload(cmdCtx, stdoutDestination, contents, res.Size, dec)
+			contents.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return
+}
+
+// catCmd represents the cat command
+var catCmd = &cobra.Command{
+	Use:   "cat [flags] <file>...",
+	Short: "Print the contents of files",
+	Long: `Print the contents of files to standard output, one after another, so
+they can be piped into other tools.
+
+A <file> may be a glob pattern such as "/logs/*.log", in which case every
+file that matches is printed in order of name. With --decrypt, files
+uploaded with "put --encrypt" are decrypted on the way; see "dbxcli get".`,
+	Example: `  dbxcli cat /logs/app.log | grep ERROR
+  dbxcli cat '/logs/2016-*.log' | wc -l
+  dbxcli cat --decrypt /notes.txt.dbxenc`,
+	RunE: cat,
+}
+
+func init() {
+	RootCmd.AddCommand(catCmd)
+	catCmd.Flags().Bool("missing-ok", false, "Don't fail if a <file> doesn't exist or matches nothing")
+	catCmd.Flags().Bool("decrypt", false, "Decrypt files uploaded with put --encrypt")
+	catCmd.Flags().String("encrypt-suffix", defaultEncryptSuffix, "Suffix of encrypted files")
+	catCmd.Flags().String("identity", "", "Key file to decrypt with (default $"+identityEnv+")")
+}
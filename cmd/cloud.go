@@ -0,0 +1,163 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// Cloud documents (Paper docs, shortcuts, Google files) are listed like other
+// files but can't be downloaded, only exported. The vendored SDK's
+// FileMetadata predates the fields that say so, so they're decoded alongside
+// it from the raw metadata.
+type exportInfo struct {
+	ExportAs      string   `json:"export_as,omitempty"`
+	ExportOptions []string `json:"export_options,omitempty"`
+}
+
+type fileExtras struct {
+	IsDownloadable *bool       `json:"is_downloadable,omitempty"`
+	ExportInfo     *exportInfo `json:"export_info,omitempty"`
+	// See contentHash.
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// Files that predate the field are downloadable.
+func (x fileExtras) downloadable() bool {
+	return x.IsDownloadable == nil || *x.IsDownloadable
+}
+
+// Describes a file that can't be downloaded for `ls -l`.
+func (x fileExtras) marker() string {
+	switch {
+	case x.downloadable():
+		return ""
+	case x.ExportInfo != nil && x.ExportInfo.ExportAs != "":
+		return " (cloud document, exports as " + x.ExportInfo.ExportAs + ")"
+	}
+	return " (not downloadable)"
+}
+
+// Checks that a file can be exported as `format`, where "default" stands for
+// its default export format, and returns the format to ask for ("" for the
+// default).
+func (x fileExtras) exportFormat(p string, format string) (string, error) {
+	if x.ExportInfo == nil {
+		return "", fmt.Errorf("%s isn't a cloud document and can't be exported; get it without `--format`", p)
+	}
+	if format == "default" || format == x.ExportInfo.ExportAs {
+		return "", nil
+	}
+	for _, f := range x.ExportInfo.ExportOptions {
+		if f == format {
+			return format, nil
+		}
+	}
+	formats := []string{x.ExportInfo.ExportAs}
+	for _, f := range x.ExportInfo.ExportOptions {
+		if f != x.ExportInfo.ExportAs {
+			formats = append(formats, f)
+		}
+	}
+	return "", fmt.Errorf("%s can't be exported as %s; it can be exported as %s", p, format, strings.Join(formats, ", "))
+}
+
+// Decodes one raw metadata object the way the SDK would.
+func decodeMetadata(raw json.RawMessage) (md files.IsMetadata, x fileExtras, err error) {
+	var res files.ListFolderResult
+	if err = json.Unmarshal([]byte(`{"entries":[`+string(raw)+`]}`), &res); err != nil {
+		return
+	}
+	if len(res.Entries) == 0 || res.Entries[0] == nil {
+		return nil, x, fmt.Errorf("unexpected metadata %s", raw)
+	}
+	md = res.Entries[0]
+	err = json.Unmarshal(raw, &x)
+	return
+}
+
+// Like getFileMetadata, but also returns the fields the SDK drops and the raw
+// response.
+func getMetadataExtras(ctx context.Context, p string) (md files.IsMetadata, x fileExtras, raw json.RawMessage, err error) {
+	if err = rpc(ctx, "files", "get_metadata", files.NewGetMetadataArg(p), &raw); err != nil {
+		return
+	}
+	md, x, err = decodeMetadata(raw)
+	return
+}
+
+type rawListFolderResult struct {
+	Entries []json.RawMessage `json:"entries"`
+	Cursor  string            `json:"cursor"`
+	HasMore bool              `json:"has_more"`
+}
+
+// Like listFolder, but also returns the fields the SDK drops, keyed by the
+// entries' lower-case paths. With `recursive` everything beneath `p` is
+// listed.
+func listFolderExtras(ctx context.Context, p string, recursive bool) (entries []files.IsMetadata, extras map[string]fileExtras, err error) {
+	arg := files.NewListFolderArg(p)
+	arg.Recursive = recursive
+	return listFolderArgExtras(ctx, arg)
+}
+
+// Like listFolderExtras, with the listing described by `arg`, for instance to
+// include deleted entries.
+func listFolderArgExtras(ctx context.Context, arg *files.ListFolderArg) (entries []files.IsMetadata, extras map[string]fileExtras, err error) {
+	p := arg.Path
+	extras = make(map[string]fileExtras)
+	var res rawListFolderResult
+	err = rpc(ctx, "files", "list_folder", arg, &res)
+	if e, ok := err.(rpcError); ok && strings.HasPrefix(e.ErrorSummary, "path/not_folder") {
+		// Like `ls`, list a file as itself.
+		var md files.IsMetadata
+		var x fileExtras
+		if md, x, _, err = getMetadataExtras(ctx, p); err != nil {
+			return
+		}
+		if f, ok := md.(*files.FileMetadata); ok {
+			extras[f.PathLower] = x
+		}
+		return []files.IsMetadata{md}, extras, nil
+	}
+
+	for err == nil {
+		for _, raw := range res.Entries {
+			md, x, err := decodeMetadata(raw)
+			if err != nil {
+				return nil, nil, err
+			}
+			if f, ok := md.(*files.FileMetadata); ok {
+				extras[f.PathLower] = x
+			}
+			entries = append(entries, md)
+		}
+		if !res.HasMore {
+			return
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		cursor := res.Cursor
+		res = rawListFolderResult{}
+		err = rpc(ctx, "files", "list_folder/continue", files.NewListFolderContinueArg(cursor), &res)
+	}
+	return
+}
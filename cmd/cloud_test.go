@@ -0,0 +1,159 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+const paperMetadata = `{
+	".tag": "file",
+	"name": "notes.paper",
+	"id": "id:paper",
+	"path_lower": "/notes.paper",
+	"path_display": "/notes.paper",
+	"rev": "015f0000000000000001",
+	"size": 1024,
+	"client_modified": "2016-06-01T12:00:00Z",
+	"server_modified": "2016-06-01T12:00:00Z",
+	"is_downloadable": false,
+	"export_info": {"export_as": "html", "export_options": ["html", "markdown"]}
+}`
+
+func TestFileExtras(t *testing.T) {
+	no := false
+	yes := true
+	paper := &exportInfo{ExportAs: "html", ExportOptions: []string{"html", "markdown"}}
+	tests := []struct {
+		name         string
+		x            fileExtras
+		downloadable bool
+		marker       string
+	}{
+		{"old metadata", fileExtras{}, true, ""},
+		{"downloadable", fileExtras{IsDownloadable: &yes}, true, ""},
+		{"paper", fileExtras{IsDownloadable: &no, ExportInfo: paper}, false, " (cloud document, exports as html)"},
+		{"no export", fileExtras{IsDownloadable: &no}, false, " (not downloadable)"},
+	}
+	for _, tt := range tests {
+		if got := tt.x.downloadable(); got != tt.downloadable {
+			t.Errorf("%s: downloadable() = %v", tt.name, got)
+		}
+		if got := tt.x.marker(); got != tt.marker {
+			t.Errorf("%s: marker() = %q, want %q", tt.name, got, tt.marker)
+		}
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	paper := fileExtras{ExportInfo: &exportInfo{ExportAs: "html", ExportOptions: []string{"html", "markdown"}}}
+	tests := []struct {
+		x      fileExtras
+		format string
+		want   string
+		err    string
+	}{
+		{paper, "default", "", ""},
+		{paper, "html", "", ""},
+		{paper, "markdown", "markdown", ""},
+		{paper, "pdf", "", "/notes.paper can't be exported as pdf; it can be exported as html, markdown"},
+		{fileExtras{}, "default", "", "/notes.paper isn't a cloud document"},
+	}
+	for _, tt := range tests {
+		got, err := tt.x.exportFormat("/notes.paper", tt.format)
+		if got != tt.want || (err == nil) != (tt.err == "") || err != nil && !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("exportFormat(%q) = %q, %v; want %q, %q", tt.format, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	md, x, err := decodeMetadata(json.RawMessage(paperMetadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := md.(*files.FileMetadata); !ok || f.PathDisplay != "/notes.paper" || f.Size != 1024 {
+		t.Errorf("decoded %#v", md)
+	}
+	if x.downloadable() || x.ExportInfo == nil || x.ExportInfo.ExportAs != "html" {
+		t.Errorf("extras = %+v", x)
+	}
+
+	md, _, err = decodeMetadata(json.RawMessage(`{".tag": "folder", "name": "a", "path_lower": "/a", "path_display": "/a", "id": "id:a"}`))
+	if _, ok := md.(*files.FolderMetadata); err != nil || !ok {
+		t.Errorf("decoded a folder as %#v, %v", md, err)
+	}
+	if _, _, err = decodeMetadata(json.RawMessage(`[]`)); err == nil {
+		t.Error("decoded a non-object")
+	}
+}
+
+func TestListFolderExtras(t *testing.T) {
+	api := useFakeAPI(t)
+	api.Respond("files/list_folder", 200, `{"entries": [`+paperMetadata+`], "cursor": "c1", "has_more": true}`)
+	api.Respond("files/list_folder/continue", 200, `{"entries": [{
+		".tag": "file", "name": "a.txt", "id": "id:a", "path_lower": "/a.txt", "path_display": "/a.txt",
+		"rev": "015f0000000000000002", "size": 1, "client_modified": "2016-06-01T12:00:00Z",
+		"server_modified": "2016-06-01T12:00:00Z"
+	}], "cursor": "c2", "has_more": false}`)
+
+	entries, extras, err := listFolderExtras(cmdCtx, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || extras["/notes.paper"].downloadable() || !extras["/a.txt"].downloadable() {
+		t.Errorf("listed %d entries with extras %+v", len(entries), extras)
+	}
+	if calls := api.Calls(); !strings.Contains(calls[1].Arg, `"c1"`) {
+		t.Errorf("continued with %s", calls[1].Arg)
+	}
+}
+
+func TestGetCloudDocument(t *testing.T) {
+	dir := t.TempDir()
+
+	api := useFakeAPI(t)
+	api.Respond("files/get_metadata", 200, paperMetadata)
+	err := getFile(cmdCtx, getCmd, "/notes.paper", []string{"/notes.paper", dir}, decryptOptions{})
+	if err == nil || !strings.Contains(err.Error(), "use `get --format html`") {
+		t.Errorf("downloading a Paper doc: %v", err)
+	}
+	if got := api.Routes(); len(got) != 1 {
+		t.Errorf("calls = %v, want only the metadata lookup", got)
+	}
+
+	setFlags(t, getCmd, map[string]string{"format": "markdown"})
+	api.Respond("files/get_metadata", 200, paperMetadata)
+	api.RespondContent("files/export", `{"export_metadata": {"name": "notes.md", "size": 7}}`, []byte("# Notes"))
+	_, _, err = testutil.Capture(func() error {
+		return getFile(cmdCtx, getCmd, "/notes.paper", []string{"/notes.paper", dir}, decryptOptions{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "notes.md")); err != nil || string(b) != "# Notes" {
+		t.Errorf("exported %q, %v", b, err)
+	}
+	if arg := api.Calls()[2].Arg; !strings.Contains(arg, `"export_format":"markdown"`) {
+		t.Errorf("exported with %s", arg)
+	}
+}
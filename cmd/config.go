@@ -0,0 +1,215 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func configSet(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("`config set` requires a `key` and a `value` argument")
+	}
+	key, value := args[0], args[1]
+
+	flag := lookupFlagKey(cmd.Root(), key)
+	if flag == nil {
+		return fmt.Errorf("unknown setting %q; keys look like `put.strict` or `timeout`", key)
+	}
+	if err = flag.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for setting %q: %v", value, key, err)
+	}
+
+	s, err := readSettings()
+	if err != nil {
+		return
+	}
+	if s.Flags == nil {
+		s.Flags = make(map[string]string)
+	}
+	s.Flags[key] = value
+	return writeSettings(s)
+}
+
+func configGet(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`config get` requires a `key` argument")
+	}
+
+	s, err := readSettings()
+	if err != nil {
+		return
+	}
+	value, ok := s.Flags[args[0]]
+	if !ok {
+		return fmt.Errorf("%s is not set", args[0])
+	}
+	fmt.Println(value)
+	return
+}
+
+func configList(cmd *cobra.Command, args []string) (err error) {
+	s, err := readSettings()
+	if err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(s.Flags))
+	for key := range s.Flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	for _, key := range keys {
+		note := ""
+		if lookupFlagKey(cmd.Root(), key) == nil {
+			note = "\t(unknown)"
+		}
+		fmt.Fprintf(w, "%s\t%s%s\n", key, s.Flags[key], note)
+	}
+	return w.Flush()
+}
+
+// Lists each preset with the flag values it gives each command, after
+// filling in the config file's defaults for flags the preset leaves out.
+func configPresets(cmd *cobra.Command, args []string) (err error) {
+	s, err := readSettings()
+	if err != nil {
+		return
+	}
+	if len(s.Presets) == 0 {
+		fmt.Fprintln(os.Stderr, "No presets are defined in the config file")
+		return
+	}
+
+	root := cmd.Root()
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Preset\tCommand\tFlag\tValue\tFrom\n")
+	for _, preset := range presetNames(s) {
+		for _, command := range presetCommands {
+			effective := make(map[string]string)
+			from := make(map[string]string)
+			for key, value := range s.Flags {
+				name := key
+				if i := strings.LastIndex(key, "."); i >= 0 {
+					if key[:i] != command {
+						continue
+					}
+					name = key[i+1:]
+				}
+				if lookupFlagKey(root, command+"."+name) != nil || root.PersistentFlags().Lookup(name) != nil {
+					effective[name], from[name] = value, "config"
+				}
+			}
+			for name, value := range s.Presets[preset] {
+				if lookupFlagKey(root, command+"."+name) != nil || root.PersistentFlags().Lookup(name) != nil {
+					effective[name], from[name] = value, "preset"
+				}
+			}
+
+			names := make([]string, 0, len(effective))
+			for name := range effective {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(w, "%s\t%s\t--%s\t%s\t%s\n", preset, strings.Replace(command, ".", " ", -1), name, effective[name], from[name])
+			}
+		}
+		for name := range s.Presets[preset] {
+			if !presetKeyKnown(root, name) {
+				fmt.Fprintf(w, "%s\t-\t--%s\t%s\t(unknown)\n", preset, name, s.Presets[preset][name])
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage default flag values",
+	Long: `Manage default flag values kept in ~/.config/dbxcli/config.json.
+
+Keys name a command and one of its flags, separated by dots, such as
+"put.strict" or "team.list-members.long". Flags which apply to every command,
+like --timeout, are named by themselves. Flags given on the command line
+always win over the config file, and --no-config ignores it altogether.
+
+The config file can also hold named presets of flag values for put, get and
+the sync commands, which --preset chooses between. Edit the file to define them, e.g.
+
+  "presets": {
+    "lan": {"transfers": "16", "progress": "plain"},
+    "trickle": {"transfers": "1", "progress": "none"}
+  }
+
+Each command takes the values of flags it has and ignores the rest, so one
+preset can serve them all. Preset values win over other defaults from the
+config file, and flags given on the command line win over both.`,
+	Example: `  dbxcli config set put.strict true
+  dbxcli config set timeout 5m
+  dbxcli config get timeout
+  dbxcli config list
+  dbxcli config presets`,
+	// Managing settings doesn't need a Dropbox account.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+// configSetCmd represents the config set command
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a default flag value",
+	RunE:  configSet,
+}
+
+// configGetCmd represents the config get command
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a default flag value",
+	RunE:  configGet,
+}
+
+// configListCmd represents the config list command
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List default flag values",
+	RunE:  configList,
+}
+
+// configPresetsCmd represents the config presets command
+var configPresetsCmd = &cobra.Command{
+	Use:   "presets",
+	Short: "List presets and the flag values they choose",
+	RunE:  configPresets,
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configPresetsCmd)
+}
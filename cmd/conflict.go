@@ -0,0 +1,211 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Strategies for a destination that already exists, chosen with
+// `--on-conflict`.
+const (
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictRename    = "rename"
+	conflictFail      = "fail"
+	conflictNewer     = "newer"
+)
+
+// Outcomes of a conflict, as counted in the summary. Uploads which didn't
+// conflict with anything have no outcome.
+const (
+	outcomeOverwritten = "overwritten"
+	outcomeSkipped     = "skipped"
+	outcomeRenamed     = "renamed"
+	outcomeFailed      = "failed"
+)
+
+// Outcomes of uploads left out by `--skip-existing` or `--update-only`,
+// which aren't conflicts.
+const (
+	outcomeUnchanged = "unchanged"
+	outcomeAbsent    = "absent"
+)
+
+// What to do about one upload.
+type conflictDecision struct {
+	// Whether to upload at all, and if so with which write mode.
+	upload     bool
+	mode       string
+	autorename bool
+	// The revision an "update" replaces.
+	rev string
+	// Set when the decision alone settles the outcome. A "rename" decision
+	// only knows whether it renamed once the upload is done.
+	outcome string
+	err     error
+}
+
+// Decides what happens to an upload of a file last modified at `localModified`
+// when `remote` already exists at its destination. `remote` is nil when the
+// destination is free, and every strategy then simply uploads; overwrite
+// does so in overwrite mode, so a file that turns up in the meantime is still
+// replaced.
+func decideConflict(strategy string, remote files.IsMetadata, localModified time.Time) conflictDecision {
+	if remote == nil {
+		if strategy == conflictOverwrite {
+			return conflictDecision{upload: true, mode: "overwrite"}
+		}
+		return conflictDecision{upload: true, mode: "add"}
+	}
+
+	var remoteFile *files.FileMetadata
+	var remotePath string
+	switch m := remote.(type) {
+	case *files.FileMetadata:
+		remoteFile, remotePath = m, m.PathDisplay
+	case *files.FolderMetadata:
+		remotePath = m.PathDisplay
+	case *files.DeletedMetadata:
+		return conflictDecision{upload: true, mode: "add"}
+	}
+
+	switch strategy {
+	case conflictSkip:
+		return conflictDecision{outcome: outcomeSkipped}
+	case conflictRename:
+		return conflictDecision{upload: true, mode: "add", autorename: true}
+	}
+
+	if remoteFile == nil {
+		// Nothing but renaming or skipping gets past a folder.
+		return conflictDecision{outcome: outcomeFailed, err: fmt.Errorf("a folder already exists at %s", remotePath)}
+	}
+
+	switch strategy {
+	case conflictOverwrite:
+		return conflictDecision{upload: true, mode: "overwrite", outcome: outcomeOverwritten}
+	case conflictNewer:
+		if localModified.After(remoteFile.ServerModified) {
+			return conflictDecision{upload: true, mode: "overwrite", outcome: outcomeOverwritten}
+		}
+		return conflictDecision{outcome: outcomeSkipped}
+	}
+	return conflictDecision{outcome: outcomeFailed, err: fmt.Errorf("%s already exists", remotePath)}
+}
+
+// Decides an upload which may only replace `rev`, the revision its
+// destination had when it was last looked at, or a free destination if
+// `rev` is empty. If the destination changed in the meantime, Dropbox keeps
+// the upload next to it under a new name, such as "notes (conflicted
+// copy).txt", and the outcome is "renamed".
+func decideUpdate(rev string) conflictDecision {
+	if rev == "" {
+		return conflictDecision{upload: true, mode: "add", autorename: true}
+	}
+	return conflictDecision{upload: true, mode: "update", rev: rev, autorename: true}
+}
+
+// Old flags which each picked one conflict strategy.
+var conflictAliases = []struct {
+	flag     string
+	strategy string
+}{
+	{"force", conflictOverwrite},
+	{"autorename", conflictRename},
+	{"update", conflictNewer},
+}
+
+// The API's write modes, which `--mode` accepts, and the strategies that
+// upload with them.
+var writeModeStrategies = map[string]string{
+	"add":        conflictFail,
+	"overwrite":  conflictOverwrite,
+	"autorename": conflictRename,
+}
+
+// Works out the conflict strategy from `--on-conflict` and the deprecated
+// flags it replaces. When both kinds are given `--on-conflict` wins, and the
+// old flags are reported as ignored. `--mode` names a strategy by its write
+// mode instead; it wins over the old flags the same way, and can't
+// contradict `--on-conflict`.
+func conflictStrategy(cmd *cobra.Command) (strategy string, err error) {
+	strategy, _ = cmd.Flags().GetString("on-conflict")
+	switch strategy {
+	case conflictOverwrite, conflictSkip, conflictRename, conflictFail, conflictNewer:
+	default:
+		return "", fmt.Errorf("invalid `--on-conflict` %q: use overwrite, skip, rename, fail or newer", strategy)
+	}
+
+	var chosenBy string
+	if cmd.Flags().Changed("on-conflict") {
+		chosenBy = "--on-conflict " + strategy
+	}
+	if cmd.Flags().Changed("mode") {
+		mode, _ := cmd.Flags().GetString("mode")
+		modeStrategy, ok := writeModeStrategies[mode]
+		switch {
+		case !ok:
+			return "", fmt.Errorf("invalid `--mode` %q: use add, overwrite or autorename", mode)
+		case chosenBy != "" && modeStrategy != strategy:
+			return "", fmt.Errorf("`--mode %s` contradicts `--on-conflict %s`", mode, strategy)
+		case chosenBy == "":
+			chosenBy = "--mode " + mode
+		}
+		strategy = modeStrategy
+	}
+
+	var old []string
+	var oldStrategy string
+	for _, alias := range conflictAliases {
+		if set, _ := cmd.Flags().GetBool(alias.flag); set && cmd.Flags().Changed(alias.flag) {
+			old = append(old, "--"+alias.flag)
+			oldStrategy = alias.strategy
+		}
+	}
+	switch {
+	case len(old) == 0:
+	case chosenBy != "":
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s in favor of %s\n", strings.Join(old, ", "), chosenBy)
+	case len(old) > 1:
+		return "", fmt.Errorf("%s can't be combined; use --on-conflict", strings.Join(old, " and "))
+	default:
+		strategy = oldStrategy
+	}
+	return
+}
+
+// Formats conflict outcome counts for the summary, e.g.
+// "2 overwritten, 1 skipped".
+func formatConflictCounts(counts map[string]int) string {
+	var outcomes []string
+	for outcome := range counts {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+
+	var parts []string
+	for _, outcome := range outcomes {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[outcome], outcome))
+	}
+	return strings.Join(parts, ", ")
+}
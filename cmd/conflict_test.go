@@ -0,0 +1,142 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestDecideConflict(t *testing.T) {
+	remoteFile := fileMetadata("/report.txt", 10)
+	remoteFile.ServerModified = time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	older := remoteFile.ServerModified.Add(-time.Hour)
+	newer := remoteFile.ServerModified.Add(time.Hour)
+	deleted := files.NewDeletedMetadata("report.txt")
+	folder := folderMetadata("/report.txt")
+
+	tests := []struct {
+		strategy string
+		remote   files.IsMetadata
+		modified time.Time
+		want     conflictDecision
+		// A substring of the decision's error, if it has one.
+		err string
+	}{
+		{conflictOverwrite, nil, newer, conflictDecision{upload: true, mode: "overwrite"}, ""},
+		{conflictOverwrite, remoteFile, older, conflictDecision{upload: true, mode: "overwrite", outcome: outcomeOverwritten}, ""},
+		{conflictOverwrite, folder, newer, conflictDecision{outcome: outcomeFailed}, "a folder already exists at /report.txt"},
+		{conflictOverwrite, deleted, newer, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictSkip, nil, newer, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictSkip, remoteFile, newer, conflictDecision{outcome: outcomeSkipped}, ""},
+		{conflictSkip, folder, newer, conflictDecision{outcome: outcomeSkipped}, ""},
+		{conflictSkip, deleted, newer, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictRename, nil, newer, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictRename, remoteFile, newer, conflictDecision{upload: true, mode: "add", autorename: true}, ""},
+		{conflictRename, folder, newer, conflictDecision{upload: true, mode: "add", autorename: true}, ""},
+		{conflictFail, nil, newer, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictFail, remoteFile, newer, conflictDecision{outcome: outcomeFailed}, "/report.txt already exists"},
+		{conflictFail, folder, newer, conflictDecision{outcome: outcomeFailed}, "a folder already exists at /report.txt"},
+		{conflictFail, deleted, newer, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictNewer, nil, older, conflictDecision{upload: true, mode: "add"}, ""},
+		{conflictNewer, remoteFile, newer, conflictDecision{upload: true, mode: "overwrite", outcome: outcomeOverwritten}, ""},
+		{conflictNewer, remoteFile, older, conflictDecision{outcome: outcomeSkipped}, ""},
+		{conflictNewer, remoteFile, remoteFile.ServerModified, conflictDecision{outcome: outcomeSkipped}, ""},
+		{conflictNewer, folder, newer, conflictDecision{outcome: outcomeFailed}, "a folder already exists at /report.txt"},
+	}
+	for _, tt := range tests {
+		got := decideConflict(tt.strategy, tt.remote, tt.modified)
+		err := got.err
+		got.err = nil
+		if got != tt.want {
+			t.Errorf("decideConflict(%s, %T) = %+v, want %+v", tt.strategy, tt.remote, got, tt.want)
+		}
+		if tt.err == "" && err != nil {
+			t.Errorf("decideConflict(%s, %T) failed: %v", tt.strategy, tt.remote, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("decideConflict(%s, %T) error = %v, want %q", tt.strategy, tt.remote, err, tt.err)
+		}
+	}
+}
+
+func TestConflictStrategy(t *testing.T) {
+	tests := []struct {
+		flags map[string]string
+		want  string
+		// Substrings of the error, or of the warning printed.
+		err     string
+		warning string
+	}{
+		{flags: nil, want: conflictOverwrite},
+		{flags: map[string]string{"on-conflict": "newer"}, want: conflictNewer},
+		{flags: map[string]string{"on-conflict": "sometimes"}, err: "invalid `--on-conflict` \"sometimes\""},
+		{flags: map[string]string{"force": "true"}, want: conflictOverwrite},
+		{flags: map[string]string{"autorename": "true"}, want: conflictRename},
+		{flags: map[string]string{"update": "true"}, want: conflictNewer},
+		{flags: map[string]string{"update": "false"}, want: conflictOverwrite},
+		{flags: map[string]string{"autorename": "true", "update": "true"}, err: "--autorename and --update can't be combined"},
+		{
+			flags:   map[string]string{"on-conflict": "skip", "autorename": "true"},
+			want:    conflictSkip,
+			warning: "ignoring --autorename in favor of --on-conflict skip",
+		},
+		{
+			flags:   map[string]string{"on-conflict": "overwrite", "force": "true", "update": "true"},
+			want:    conflictOverwrite,
+			warning: "ignoring --force, --update in favor of --on-conflict overwrite",
+		},
+		{flags: map[string]string{"on-conflict": "overwrite", "update": "false"}, want: conflictOverwrite},
+		{flags: map[string]string{"mode": "add"}, want: conflictFail},
+		{flags: map[string]string{"mode": "overwrite"}, want: conflictOverwrite},
+		{flags: map[string]string{"mode": "autorename"}, want: conflictRename},
+		{flags: map[string]string{"mode": "append"}, err: "invalid `--mode` \"append\""},
+		{flags: map[string]string{"mode": "autorename", "on-conflict": "rename"}, want: conflictRename},
+		{flags: map[string]string{"mode": "add", "on-conflict": "skip"}, err: "`--mode add` contradicts `--on-conflict skip`"},
+		{
+			flags:   map[string]string{"mode": "add", "update": "true"},
+			want:    conflictFail,
+			warning: "ignoring --update in favor of --mode add",
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			// Keep pflag's notes about the deprecated flags out of the
+			// test output.
+			testutil.Capture(func() error {
+				setFlags(t, putCmd, tt.flags)
+				return nil
+			})
+			var got string
+			_, stderr, err := testutil.Capture(func() (err error) {
+				got, err = conflictStrategy(putCmd)
+				return
+			})
+			if tt.err == "" && (err != nil || got != tt.want) {
+				t.Errorf("conflictStrategy with %v = %q, %v, want %q", tt.flags, got, err, tt.want)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("conflictStrategy with %v = %v, want error containing %q", tt.flags, err, tt.err)
+			}
+			if !strings.Contains(stderr, tt.warning) || (tt.warning == "" && stderr != "") {
+				t.Errorf("conflictStrategy with %v printed %q, want %q", tt.flags, stderr, tt.warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Dropbox hashes files in blocks of this size.
+const contentHashBlockSize = 4 << 20
+
+// Computes the Dropbox content hash of a local file: the SHA-256 of the
+// concatenated SHA-256 hashes of each 4 MiB block. It matches the
+// content_hash in the metadata of a remote file with the same contents.
+func contentHash(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	overall := sha256.New()
+	block := sha256.New()
+	for {
+		block.Reset()
+		n, err := io.CopyN(block, f, contentHashBlockSize)
+		if n > 0 {
+			overall.Write(block.Sum(nil))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(overall.Sum(nil)), nil
+}
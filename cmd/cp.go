@@ -15,15 +15,169 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/spf13/cobra"
 )
 
+// Fan-out copies are sent in batches of this size so progress can be
+// reported between them.
+const fanOutBatchSize = 100
+
+// Reads destinations from a file, one per line, skipping blank lines and
+// lines starting with "#".
+func readDestFile(name string) (dests []string, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dests = append(dests, line)
+	}
+	err = scanner.Err()
+	return
+}
+
+// Builds one batch entry per destination folder, and fails if two of them
+// would write to the same path. Dropbox paths are case-insensitive, so the
+// comparison is too.
+func fanOutEntries(source string, dests []string) ([]relocationPath, error) {
+	from, err := validatePath(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []relocationPath
+	var collisions []string
+	seen := make(map[string]string)
+	for _, dest := range dests {
+		dir, err := validatePath(dest)
+		if err != nil {
+			return nil, err
+		}
+		to := dir + "/" + path.Base(from)
+		key := strings.ToLower(to)
+		if prev, ok := seen[key]; ok {
+			collisions = append(collisions, fmt.Sprintf("%s and %s both copy to %s", prev, dest, to))
+			continue
+		}
+		seen[key] = dest
+		entries = append(entries, relocationPath{FromPath: from, ToPath: to})
+	}
+	if len(collisions) > 0 {
+		return nil, fmt.Errorf("destinations collide:\n  %s", strings.Join(collisions, "\n  "))
+	}
+	return entries, nil
+}
+
+// One line of JSON output for a fan-out copy. Path is where the copy ended
+// up, which differs from Dst when it was autorenamed.
+type copyRecord struct {
+	Src   string           `json:"src"`
+	Dst   string           `json:"dst"`
+	Path  string           `json:"path,omitempty"`
+	Error *jsonErrorFields `json:"error,omitempty"`
+}
+
+// Copies one source into many folders using copy batches.
+func cpFanOut(cmd *cobra.Command, args []string) (err error) {
+	if len(args) < 1 {
+		return errors.New("`cp --fan-out` requires a source")
+	}
+	dests := args[1:]
+
+	destFile, err := cmd.Flags().GetString("dest-file")
+	if err != nil {
+		return
+	}
+	if destFile != "" {
+		var fromFile []string
+		if fromFile, err = readDestFile(destFile); err != nil {
+			return
+		}
+		dests = append(dests, fromFile...)
+	}
+	if len(dests) == 0 {
+		return errors.New("`cp --fan-out` requires at least one destination")
+	}
+
+	autorename, err := cmd.Flags().GetBool("autorename")
+	if err != nil {
+		return
+	}
+
+	entries, err := fanOutEntries(args[0], dests)
+	if err != nil {
+		return
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		for _, e := range entries {
+			fmt.Printf("copy\t%s -> %s\n", e.FromPath, e.ToPath)
+		}
+		return
+	}
+
+	asJSON := jsonMode(cmd)
+	failed := 0
+	for start := 0; start < len(entries); start += fanOutBatchSize {
+		end := start + fanOutBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		results, err := relocationBatch(cmdCtx, "copy", entries[start:end], autorename)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				failed++
+				if asJSON {
+					f := errorFields(r.Err)
+					printJSONLine(copyRecord{Src: r.FromPath, Dst: r.ToPath, Error: &f})
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", r.ToPath, r.Err)
+				continue
+			}
+			if asJSON {
+				printJSONLine(copyRecord{Src: r.FromPath, Dst: r.ToPath, Path: r.PathDisplay})
+				continue
+			}
+			fmt.Printf("%s -> %s\n", r.FromPath, r.PathDisplay)
+		}
+		if len(entries) > fanOutBatchSize {
+			fmt.Fprintf(os.Stderr, "Copied %d/%d\n", end, len(entries))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d copies failed", failed, len(entries))
+	}
+	return
+}
+
 func cp(cmd *cobra.Command, args []string) error {
+	fanOut, err := cmd.Flags().GetBool("fan-out")
+	if err != nil {
+		return err
+	}
+	if fanOut || cmd.Flags().Changed("dest-file") {
+		return cpFanOut(cmd, args)
+	}
+
 	var destination string
 	var argsToCopy []string
 
@@ -37,21 +191,31 @@ func cp(cmd *cobra.Command, args []string) error {
 		return errors.New("cp requires a source and a destination")
 	}
 
+	dbx := newFilesClient(cmdCtx)
+	sources, err := expandSources(cmd, dbx, argsToCopy)
+	if err != nil {
+		return err
+	}
+
 	cpErrors := []error{}
 	relocationArgs := []*files.RelocationArg{}
 
-	for _, argument := range argsToCopy {
-		arg, err := makeRelocationArg(argument, destination+"/"+argument)
+	for _, source := range sources {
+		arg, err := makeRelocationArg(source.path, destination+"/"+source.name)
 		if err != nil {
-			relocationError := fmt.Errorf("Error validating copy for %s to %s: %v", argument, destination, err)
+			relocationError := fmt.Errorf("Error validating copy for %s to %s: %v", source.path, destination, err)
 			cpErrors = append(cpErrors, relocationError)
 		} else {
 			relocationArgs = append(relocationArgs, arg)
 		}
 	}
 
-	dbx := files.New(config)
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
 	for _, arg := range relocationArgs {
+		if dryRun {
+			fmt.Printf("copy\t%s -> %s\n", arg.FromPath, arg.ToPath)
+			continue
+		}
 		if _, err := dbx.Copy(arg); err != nil {
 			copyError := fmt.Errorf("Copy error: %v", arg)
 			cpErrors = append(cpErrors, copyError)
@@ -69,9 +233,29 @@ func cp(cmd *cobra.Command, args []string) error {
 var cpCmd = &cobra.Command{
 	Use:   "cp [flags] <source> <target>",
 	Short: "Copy files",
-	RunE:  cp,
+	Long: `Copy files.
+
+A <source> may be a pattern such as '/reports/2024-*.csv', quoted so the
+shell leaves it alone; what it matches is copied into <target> by name.
+
+With --fan-out, the first argument is a single source which is copied into
+each of the remaining arguments, which name folders. Destinations can also be
+read from a file with --dest-file, one per line. The copies are made on the
+server in batches, and a result is printed for each destination.
+
+With --dry-run, cp only lists the copies it would make.`,
+	Example: `  dbxcli cp /report.pdf /Archive
+  dbxcli cp '/reports/2024-*.csv' /Archive
+  dbxcli cp --fan-out /report.pdf /Clients/Acme /Clients/Globex
+  dbxcli cp --dest-file clients.txt --autorename /report.pdf`,
+	RunE: cp,
 }
 
 func init() {
 	RootCmd.AddCommand(cpCmd)
+
+	cpCmd.Flags().Bool("dry-run", false, "Only list what would be copied")
+	cpCmd.Flags().Bool("fan-out", false, "Copy the first argument into each of the other arguments")
+	cpCmd.Flags().String("dest-file", "", "Read fan-out destinations from `file`, one per line")
+	cpCmd.Flags().Bool("autorename", false, "Rename fan-out copies which conflict with existing files")
 }
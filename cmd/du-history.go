@@ -0,0 +1,371 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// Version of the records written by `du --record`. Readers ignore fields they
+// don't know, so fields can be added without bumping it; it only changes if
+// an existing field changes meaning.
+const usageRecordVersion = 1
+
+type usageRecord struct {
+	Version   int       `json:"version"`
+	Time      time.Time `json:"time"`
+	Used      uint64    `json:"used"`
+	Allocated uint64    `json:"allocated"`
+	// Size of each top-level folder, with --top-folders.
+	Folders map[string]uint64 `json:"folders,omitempty"`
+}
+
+func appendUsageRecord(name string, rec usageRecord) (err error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// Reads the history file, skipping (with a warning) lines that can't be
+// parsed and records from versions this dbxcli doesn't understand. The
+// records are returned oldest first.
+func readUsageHistory(name string) (records []usageRecord, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec usageRecord
+		switch err := json.Unmarshal([]byte(line), &rec); {
+		case err != nil || rec.Version < 1 || rec.Time.IsZero():
+			fmt.Fprintf(os.Stderr, "%s:%d: skipping corrupt record\n", name, n)
+			continue
+		case rec.Version > usageRecordVersion:
+			fmt.Fprintf(os.Stderr, "%s:%d: skipping record of unknown version %d\n", name, n, rec.Version)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return
+}
+
+// Returns the least-squares slope of used space over time, in bytes per
+// second. It's 0 unless there are records at two different times.
+func growthRate(records []usageRecord) float64 {
+	if len(records) < 2 {
+		return 0
+	}
+	t0 := records[0].Time
+	var sx, sy float64
+	for _, r := range records {
+		sx += r.Time.Sub(t0).Seconds()
+		sy += float64(r.Used)
+	}
+	n := float64(len(records))
+	mx, my := sx/n, sy/n
+
+	var sxy, sxx float64
+	for _, r := range records {
+		dx := r.Time.Sub(t0).Seconds() - mx
+		sxy += dx * (float64(r.Used) - my)
+		sxx += dx * dx
+	}
+	if sxx == 0 {
+		return 0
+	}
+	return sxy / sxx
+}
+
+// Returns how long until `used` reaches `allocated` at `rate` bytes per
+// second, and false if it never will.
+func timeUntilFull(used, allocated uint64, rate float64) (time.Duration, bool) {
+	if rate <= 0 || allocated == 0 {
+		return 0, false
+	}
+	if used >= allocated {
+		return 0, true
+	}
+	secs := float64(allocated-used) / rate
+	if secs > float64(math.MaxInt64)/float64(time.Second) {
+		return 0, false
+	}
+	return time.Duration(secs * float64(time.Second)), true
+}
+
+const week = 7 * 24 * time.Hour
+
+// Growth can only be estimated from records at two different times.
+func enoughHistory(records []usageRecord) bool {
+	return len(records) >= 2 && records[len(records)-1].Time.After(records[0].Time)
+}
+
+// Describes the growth rate and projection, like "+2.3 GiB/week, full in ~14
+// weeks at this rate".
+func describeGrowth(records []usageRecord) string {
+	if !enoughHistory(records) {
+		return "Not enough history to estimate growth"
+	}
+	rate := growthRate(records)
+	perWeek := rate * week.Seconds()
+	if math.Abs(perWeek) < 1 {
+		return "No growth"
+	}
+
+	sign := "+"
+	if perWeek < 0 {
+		sign = "-"
+	}
+	s := fmt.Sprintf("%s%s/week", sign, humanize.IBytes(uint64(math.Abs(perWeek))))
+
+	last := records[len(records)-1]
+	switch d, ok := timeUntilFull(last.Used, last.Allocated, rate); {
+	case perWeek < 0:
+		s += ", shrinking"
+	case !ok:
+	case d == 0:
+		s += ", already full"
+	case d < week:
+		s += fmt.Sprintf(", full in ~%d days at this rate", int(math.Ceil(d.Hours()/24)))
+	default:
+		s += fmt.Sprintf(", full in ~%d weeks at this rate", int(math.Round(float64(d)/float64(week))))
+	}
+	return s
+}
+
+func signedBytes(d int64) string {
+	if d < 0 {
+		return "-" + humanize.IBytes(uint64(-d))
+	}
+	return "+" + humanize.IBytes(uint64(d))
+}
+
+// Adds up the size of each top-level folder with a single recursive listing.
+// Files directly in the root are counted under "/".
+func topFolderSizes() (sizes map[string]uint64, err error) {
+	sizes = make(map[string]uint64)
+	names := make(map[string]string)
+	dbx := newFilesClient(cmdCtx)
+	err = walkFolder(cmdCtx, dbx, "", "", 0, nil, func(entries []files.IsMetadata) {
+		for _, entry := range entries {
+			switch e := entry.(type) {
+			case *files.FolderMetadata:
+				if strings.Count(e.PathLower, "/") == 1 {
+					names[e.PathLower] = e.PathDisplay
+				}
+			case *files.FileMetadata:
+				top := "/"
+				if i := strings.Index(e.PathLower[1:], "/"); i >= 0 {
+					top = e.PathLower[:i+1]
+				}
+				sizes[top] += e.Size
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+
+	// Report folders under their display names.
+	for lower, display := range names {
+		if size, ok := sizes[lower]; ok {
+			delete(sizes, lower)
+			sizes[display] = size
+		}
+	}
+	return
+}
+
+func duHistory(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`du history` requires a `file` argument")
+	}
+
+	since, _ := cmd.Flags().GetString("since")
+	age, err := parseAge(since)
+	if err != nil {
+		return
+	}
+
+	all, err := readUsageHistory(args[0])
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-age)
+	var records []usageRecord
+	for _, r := range all {
+		if !r.Time.Before(cutoff) {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records in %s since %s", args[0], cutoff.Format("2006-01-02"))
+	}
+
+	from, folders := folderGrowth(records)
+	if jsonMode(cmd) {
+		return printJSON(newJSONUsageHistory(records, folders))
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Time\tUsed\tAllocated\tChange\n")
+	for i, r := range records {
+		change := "-"
+		if i > 0 {
+			change = signedBytes(int64(r.Used) - int64(records[i-1].Used))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Time.Local().Format("2006-01-02 15:04"),
+			humanize.IBytes(r.Used), humanize.IBytes(r.Allocated), change)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Println(describeGrowth(records))
+
+	if folders == nil {
+		return
+	}
+	fmt.Println()
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Folder\tSize\tChange since %s\n", from.Local().Format("2006-01-02"))
+	for _, f := range folders {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Name, humanize.IBytes(f.Size), signedBytes(f.Change))
+	}
+	return w.Flush()
+}
+
+// How much a top-level folder grew over the history.
+type folderChange struct {
+	Name   string `json:"name"`
+	Size   uint64 `json:"size"`
+	Change int64  `json:"change"`
+}
+
+// Compares the folder sizes in the last record that has them with those in
+// the first, returning the time of the first. `folders` is nil if no record
+// has folder sizes.
+func folderGrowth(records []usageRecord) (since time.Time, folders []folderChange) {
+	var first, last *usageRecord
+	for i := range records {
+		if records[i].Folders != nil {
+			if first == nil {
+				first = &records[i]
+			}
+			last = &records[i]
+		}
+	}
+	if first == nil {
+		return
+	}
+	var names []string
+	for name := range last.Folders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	folders = []folderChange{}
+	for _, name := range names {
+		size := last.Folders[name]
+		folders = append(folders, folderChange{name, size, int64(size) - int64(first.Folders[name])})
+	}
+	return first.Time, folders
+}
+
+// The output of `du history --json`. The growth rate, in bytes per week, is
+// left out without enough history to estimate it, and the time the account
+// will be full unless it's growing.
+type jsonUsageHistory struct {
+	Records       []usageRecord  `json:"records"`
+	GrowthPerWeek *float64       `json:"growth_per_week,omitempty"`
+	FullAt        *time.Time     `json:"full_at,omitempty"`
+	Folders       []folderChange `json:"folders,omitempty"`
+}
+
+func newJSONUsageHistory(records []usageRecord, folders []folderChange) jsonUsageHistory {
+	h := jsonUsageHistory{Records: records, Folders: folders}
+	if !enoughHistory(records) {
+		return h
+	}
+	rate := growthRate(records)
+	perWeek := math.Round(rate * week.Seconds())
+	h.GrowthPerWeek = &perWeek
+	last := records[len(records)-1]
+	if d, ok := timeUntilFull(last.Used, last.Allocated, rate); ok {
+		full := last.Time.Add(d).UTC().Truncate(time.Second)
+		h.FullAt = &full
+	}
+	return h
+}
+
+// duHistoryCmd represents the du history command
+var duHistoryCmd = &cobra.Command{
+	Use:   "history [flags] <file>",
+	Short: "Show how space usage changed over time",
+	Long: `Show the space usage recorded in <file> by "du --record", and estimate how
+fast it's growing and when the account will be full at that rate. The rate
+is a least-squares fit over the records since --since.
+
+Lines of <file> that can't be read are skipped with a warning.
+
+With --json, a single object is printed with the fields "records" (as
+recorded), "growth_per_week" in bytes, "full_at" if the account is filling
+up, and "folders", each with its "name", "size" and "change", if folder
+sizes were recorded.`,
+	Example: `  dbxcli du --record ~/dropbox-usage.jsonl
+  dbxcli du history ~/dropbox-usage.jsonl
+  dbxcli du history --since 1y ~/dropbox-usage.jsonl`,
+	// Reading the history doesn't need a Dropbox account.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE:              duHistory,
+}
+
+func init() {
+	duCmd.AddCommand(duHistoryCmd)
+	duHistoryCmd.Flags().String("since", "90d", "Only use records newer than this, like 90d, 12w or 1y")
+}
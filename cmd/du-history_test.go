@@ -0,0 +1,192 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+const gib = 1 << 30
+
+// Returns a record per day starting at start, with used going through the
+// given sizes and 100 GiB allocated.
+func dailyUsage(start time.Time, used ...uint64) (records []usageRecord) {
+	for i, u := range used {
+		records = append(records, usageRecord{
+			Version:   usageRecordVersion,
+			Time:      start.Add(time.Duration(i) * 24 * time.Hour),
+			Used:      u,
+			Allocated: 100 * gib,
+		})
+	}
+	return
+}
+
+func TestGrowthRate(t *testing.T) {
+	start := time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC)
+	perDay := float64(gib) / (24 * 60 * 60)
+	tests := []struct {
+		name    string
+		records []usageRecord
+		want    float64
+	}{
+		{"none", nil, 0},
+		{"one", dailyUsage(start, gib), 0},
+		{"steady", dailyUsage(start, 10*gib, 11*gib, 12*gib, 13*gib), perDay},
+		{"shrinking", dailyUsage(start, 13*gib, 12*gib, 11*gib), -perDay},
+		{"flat", dailyUsage(start, gib, gib, gib), 0},
+		// Noise around a line of 1 GiB a day is fitted to that line.
+		{"noisy", dailyUsage(start, 10*gib, 12*gib, 11*gib, 13*gib, 14*gib), 0.9 * perDay},
+		{"same time", []usageRecord{{Time: start, Used: gib}, {Time: start, Used: 2 * gib}}, 0},
+	}
+	for _, tt := range tests {
+		if got := growthRate(tt.records); got < tt.want-1 || got > tt.want+1 {
+			t.Errorf("%s: growthRate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTimeUntilFull(t *testing.T) {
+	tests := []struct {
+		used, allocated uint64
+		rate            float64
+		want            time.Duration
+		ok              bool
+	}{
+		{50, 100, 1, 50 * time.Second, true},
+		{100, 100, 1, 0, true},
+		{150, 100, 1, 0, true},
+		{50, 100, 0, 0, false},
+		{50, 100, -1, 0, false},
+		{50, 0, 1, 0, false},
+		// Too far off to fit in a time.Duration.
+		{0, 1 << 62, 1e-9, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := timeUntilFull(tt.used, tt.allocated, tt.rate)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("timeUntilFull(%d, %d, %v) = %v, %v; want %v, %v", tt.used, tt.allocated, tt.rate, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDescribeGrowth(t *testing.T) {
+	start := time.Date(2016, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		records []usageRecord
+		want    string
+	}{
+		{"one record", dailyUsage(start, gib), "Not enough history to estimate growth"},
+		{"same time", []usageRecord{{Time: start}, {Time: start}}, "Not enough history to estimate growth"},
+		{"flat", dailyUsage(start, gib, gib), "No growth"},
+		{"shrinking", dailyUsage(start, 3*gib, 2*gib), "-7.0 GiB/week, shrinking"},
+		{"full", dailyUsage(start, 99*gib, 100*gib), "+7.0 GiB/week, already full"},
+		{"days", dailyUsage(start, 96*gib, 97*gib), "+7.0 GiB/week, full in ~3 days at this rate"},
+		{"weeks", dailyUsage(start, 29*gib, 30*gib), "+7.0 GiB/week, full in ~10 weeks at this rate"},
+	}
+	for _, tt := range tests {
+		if got := describeGrowth(tt.records); got != tt.want {
+			t.Errorf("%s: describeGrowth = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadUsageHistory(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "usage.jsonl")
+	history := `{"version":1,"time":"2016-04-03T00:00:00Z","used":3,"allocated":10}
+
+not json
+{"version":1,"time":"2016-04-01T00:00:00Z","used":1,"allocated":10,"folders":{"/Photos":1}}
+{"version":2,"time":"2016-04-02T00:00:00Z","used":2,"allocated":10}
+{"version":1,"used":2,"allocated":10}
+`
+	if err := ioutil.WriteFile(name, []byte(history), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var records []usageRecord
+	_, stderr, err := testutil.Capture(func() (err error) {
+		records, err = readUsageHistory(name)
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Used != 1 || records[1].Used != 3 {
+		t.Fatalf("read %+v, want the records using 1 and 3 bytes, oldest first", records)
+	}
+	if records[0].Folders["/Photos"] != 1 {
+		t.Errorf("folders = %v", records[0].Folders)
+	}
+	for _, want := range []string{":3: skipping corrupt record", ":5: skipping record of unknown version 2", ":6: skipping corrupt record"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr %q doesn't mention %q", stderr, want)
+		}
+	}
+
+	// Records appended by du --record read back.
+	rec := usageRecord{Version: usageRecordVersion, Time: time.Date(2016, 4, 4, 0, 0, 0, 0, time.UTC), Used: 4, Allocated: 10}
+	if err = appendUsageRecord(name, rec); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Capture(func() (err error) {
+		records, err = readUsageHistory(name)
+		return
+	})
+	if len(records) != 3 || !records[2].Time.Equal(rec.Time) {
+		t.Errorf("after appending: %+v, %v", records, err)
+	}
+	os.Remove(name)
+	if _, err = readUsageHistory(name); !os.IsNotExist(err) {
+		t.Errorf("reading a missing file: %v", err)
+	}
+}
+
+func TestJSONUsageHistory(t *testing.T) {
+	start := time.Date(2016, 8, 1, 0, 0, 0, 0, time.UTC)
+	// 1 GiB a day, with 90 GiB to go after the last record.
+	records := dailyUsage(start, 8*gib, 9*gib, 10*gib)
+	records[0].Folders = map[string]uint64{"/Photos": 5 * gib}
+	records[2].Folders = map[string]uint64{"/Photos": 6 * gib, "/Work": gib}
+
+	since, folders := folderGrowth(records)
+	if !since.Equal(start) {
+		t.Errorf("folder changes since %v, want %v", since, start)
+	}
+	h := newJSONUsageHistory(records, folders)
+	if h.GrowthPerWeek == nil || *h.GrowthPerWeek != 7*gib {
+		t.Errorf("growth_per_week = %v, want %d", h.GrowthPerWeek, uint64(7*gib))
+	}
+	if want := start.Add(92 * 24 * time.Hour); h.FullAt == nil || !h.FullAt.Equal(want) {
+		t.Errorf("full_at = %v, want %v", h.FullAt, want)
+	}
+	want := []folderChange{{"/Photos", 6 * gib, gib}, {"/Work", gib, gib}}
+	if !reflect.DeepEqual(h.Folders, want) {
+		t.Errorf("folders = %+v, want %+v", h.Folders, want)
+	}
+
+	h = newJSONUsageHistory(records[:1], nil)
+	if h.GrowthPerWeek != nil || h.FullAt != nil {
+		t.Errorf("estimated growth from one record: %+v", h)
+	}
+}
@@ -15,44 +15,290 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/users"
 	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
-func du(cmd *cobra.Command, args []string) (err error) {
-	dbx := users.New(config)
-	usage, err := dbx.GetSpaceUsage()
-	if err != nil {
+// Running totals of a `du <path>` walk. They're saved in the state file, so
+// the fields are exported.
+type duTotals struct {
+	Bytes   uint64 `json:"bytes"`
+	Files   uint64 `json:"files"`
+	Folders uint64 `json:"folders"`
+	// With --depth, the totals of each folder down to that depth, keyed by
+	// lower-case path.
+	Depth     int                  `json:"depth"`
+	Breakdown map[string]*duFolder `json:"breakdown,omitempty"`
+}
+
+// The totals of one subfolder in `du --depth`.
+type duFolder struct {
+	Path  string `json:"path"`
+	Bytes uint64 `json:"bytes"`
+	Files uint64 `json:"files"`
+}
+
+// Counts `entry` towards each of its folders down to totals.Depth beneath
+// `root`.
+func (t *duTotals) addToBreakdown(root string, entry files.IsMetadata) {
+	display := metadataPath(entry)
+	prefix := strings.ToLower(root) + "/"
+	lower := metadataPathLower(entry)
+	if t.Depth == 0 || !strings.HasPrefix(lower, prefix) || len(display) != len(lower) {
 		return
 	}
+	segments := strings.Split(display[len(prefix):], "/")
+	f, isFile := entry.(*files.FileMetadata)
+	if isFile {
+		// A file counts towards the folders it's in, not itself.
+		segments = segments[:len(segments)-1]
+	}
+	end := len(prefix)
+	for depth, segment := range segments {
+		if depth == t.Depth {
+			break
+		}
+		end += len(segment)
+		folder, ok := t.Breakdown[lower[:end]]
+		if !ok {
+			folder = &duFolder{Path: display[:end]}
+			t.Breakdown[lower[:end]] = folder
+		}
+		if isFile {
+			folder.Bytes += f.Size
+			folder.Files++
+		}
+		end++
+	}
+}
 
-	fmt.Printf("Used: %s\n", humanize.IBytes(usage.Used))
-	fmt.Printf("Type: %s\n", usage.Allocation.Tag)
+// The subfolders of a breakdown, largest first.
+func (t *duTotals) largestFolders() []duFolder {
+	folders := make([]duFolder, 0, len(t.Breakdown))
+	for _, f := range t.Breakdown {
+		folders = append(folders, *f)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		if folders[i].Bytes != folders[j].Bytes {
+			return folders[i].Bytes > folders[j].Bytes
+		}
+		return folders[i].Path < folders[j].Path
+	})
+	return folders
+}
 
-	allocation := usage.Allocation
+// The account's usage as printed by `du --json`. TeamUsed is the space used
+// by the whole team, for accounts on a team plan.
+type jsonUsage struct {
+	Used      uint64  `json:"used"`
+	Type      string  `json:"type"`
+	Allocated uint64  `json:"allocated"`
+	TeamUsed  *uint64 `json:"team_used,omitempty"`
+}
+
+func fetchSpaceUsage() (*users.SpaceUsage, error) {
+	return newUsersClient(cmdCtx).GetSpaceUsage()
+}
 
-	switch allocation.Tag {
+// The space an account can fill. Members of a team share the team's
+// allocation, so for them it's the team's usage that counts.
+type quota struct {
+	Used      uint64
+	Allocated uint64
+}
+
+func quotaOf(usage *users.SpaceUsage) (q quota) {
+	q.Used = usage.Used
+	switch a := usage.Allocation; a.Tag {
 	case "individual":
-		fmt.Printf("Allocated: %s\n", humanize.IBytes(allocation.Individual.Allocated))
+		q.Allocated = a.Individual.Allocated
 	case "team":
-		fmt.Printf("Allocated: %s (Used: %s)\n",
-			humanize.IBytes(allocation.Team.Allocated),
-			humanize.IBytes(allocation.Team.Used))
+		q.Used, q.Allocated = a.Team.Used, a.Team.Allocated
+	}
+	return
+}
+
+func newJSONUsage(usage *users.SpaceUsage) jsonUsage {
+	q := quotaOf(usage)
+	out := jsonUsage{Used: usage.Used, Type: usage.Allocation.Tag, Allocated: q.Allocated}
+	if usage.Allocation.Tag == "team" {
+		out.TeamUsed = &q.Used
+	}
+	return out
+}
+
+func (q quota) available() uint64 {
+	if q.Used >= q.Allocated {
+		return 0
+	}
+	return q.Allocated - q.Used
+}
+
+// Adds up the size of everything beneath `path`.
+func duPath(cmd *cobra.Command, path string) (err error) {
+	stateFile, _ := cmd.Flags().GetString("state")
+	every, _ := cmd.Flags().GetInt("checkpoint-every")
+
+	depth, _ := cmd.Flags().GetInt("depth")
+	if depth < 0 {
+		return fmt.Errorf("`--depth` must be 0 or more, not %d", depth)
+	}
+
+	// A walk saved with another --depth can't be resumed; say so before
+	// walking rather than after.
+	if stateFile != "" {
+		state, err := readWalkState(stateFile)
+		if err != nil {
+			return err
+		}
+		var saved duTotals
+		if state != nil && json.Unmarshal(state.Totals, &saved) == nil && saved.Depth != depth {
+			return fmt.Errorf("%s was saved with --depth %d; use that or delete the file", stateFile, saved.Depth)
+		}
 	}
 
+	totals := duTotals{Depth: depth, Breakdown: make(map[string]*duFolder)}
+	dbx := newFilesClient(cmdCtx)
+	err = walkFolder(cmdCtx, dbx, path, stateFile, every, &totals, func(entries []files.IsMetadata) {
+		for _, entry := range entries {
+			totals.addToBreakdown(path, entry)
+			switch e := entry.(type) {
+			case *files.FileMetadata:
+				totals.Bytes += e.Size
+				totals.Files++
+			case *files.FolderMetadata:
+				totals.Folders++
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+	folders := totals.largestFolders()
+
+	if jsonMode(cmd) {
+		totals.Breakdown = nil
+		return printJSON(struct {
+			duTotals
+			Subfolders []duFolder `json:"subfolders,omitempty"`
+		}{totals, folders})
+	}
+	if len(folders) > 0 {
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+		fmt.Fprintf(w, "Size\tFiles\tFolder\n")
+		for _, f := range folders {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", humanize.IBytes(f.Bytes), f.Files, f.Path)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+	fmt.Printf("Size: %s (%d bytes)\n", humanize.IBytes(totals.Bytes), totals.Bytes)
+	fmt.Printf("Files: %d\n", totals.Files)
+	fmt.Printf("Folders: %d\n", totals.Folders)
 	return
 }
 
+func du(cmd *cobra.Command, args []string) (err error) {
+	record, _ := cmd.Flags().GetString("record")
+	topFolders, _ := cmd.Flags().GetBool("top-folders")
+	if len(args) > 0 && (record != "" || topFolders) {
+		return errors.New("`--record` and `--top-folders` apply to the whole account, not a `path`")
+	}
+	if topFolders && record == "" {
+		return errors.New("`--top-folders` requires `--record`")
+	}
+
+	if len(args) > 0 {
+		path, err := validatePath(args[0])
+		if err != nil {
+			return err
+		}
+		return duPath(cmd, path)
+	}
+
+	usage, err := fetchSpaceUsage()
+	if err != nil {
+		return
+	}
+
+	allocation := usage.Allocation
+	out := newJSONUsage(usage)
+	rec := usageRecord{Version: usageRecordVersion, Time: time.Now().UTC(), Used: usage.Used, Allocated: out.Allocated}
+
+	if jsonMode(cmd) {
+		if err = printJSON(out); err != nil {
+			return
+		}
+	} else {
+		fmt.Printf("Used: %s\n", humanize.IBytes(usage.Used))
+		fmt.Printf("Type: %s\n", allocation.Tag)
+		switch allocation.Tag {
+		case "individual":
+			fmt.Printf("Allocated: %s\n", humanize.IBytes(allocation.Individual.Allocated))
+		case "team":
+			fmt.Printf("Allocated: %s (Used: %s)\n",
+				humanize.IBytes(allocation.Team.Allocated),
+				humanize.IBytes(allocation.Team.Used))
+		}
+	}
+
+	if record == "" {
+		return
+	}
+	if topFolders {
+		if rec.Folders, err = topFolderSizes(); err != nil {
+			return
+		}
+	}
+	return appendUsageRecord(record, rec)
+}
+
 // duCmd represents the du command
 var duCmd = &cobra.Command{
-	Use:   "du",
+	Use:   "du [flags] [<path>]",
 	Short: "Display usage information",
-	RunE:  du,
+	Long: `Display usage information.
+
+Without a <path>, du shows the space used by and allocated to your account.
+With one, it adds up the size of everything beneath <path>. Walking a large
+folder can take a long time; with --state the walk is saved every
+--checkpoint-every pages, and running the same command again resumes it.
+
+With --depth N, the size and number of files of each folder down to N
+levels beneath <path> is listed too, largest first, to show what's taking
+up the space. Each folder's figures include everything beneath it.
+
+--record appends the account's usage to a history file, one JSON record per
+line; run it regularly, say from cron, and "du history" shows the trend. With
+--top-folders the size of each top-level folder is recorded too, which means
+listing the whole account.
+
+To add up a folder called "history", spell it "/history".`,
+	Example: `  dbxcli du
+  dbxcli du --state du.state /Photos
+  dbxcli du --depth 2 /Projects
+  dbxcli du --record ~/dropbox-usage.jsonl --top-folders
+  dbxcli du history --since 30d ~/dropbox-usage.jsonl`,
+	RunE: du,
 }
 
 func init() {
 	RootCmd.AddCommand(duCmd)
+	duCmd.Flags().String("state", "", "Save progress to `file` and resume from it")
+	duCmd.Flags().Int("depth", 0, "With a <path>, also show the size of each folder down to this many levels")
+	duCmd.Flags().Int("checkpoint-every", 10, "Pages of results between saves to the --state file")
+	duCmd.Flags().String("record", "", "Append the account's usage to this history `file`")
+	duCmd.Flags().Bool("top-folders", false, "With --record, also record the size of each top-level folder")
 }
@@ -0,0 +1,86 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/users"
+)
+
+// A saved walk with another --depth is refused before anything is listed.
+func TestDuStateDepth(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "du.state")
+	saved := `{"version":1,"path":"/photos","cursor":"c1","pages":3,"totals":{"bytes":10,"files":1,"folders":0,"depth":1}}`
+	if err := ioutil.WriteFile(state, []byte(saved), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fake := useFakeFiles(t)
+	setFlags(t, duCmd, map[string]string{"state": state, "depth": "2"})
+	resetFlagsLater(t, duCmd, "state", "depth")
+
+	err := duPath(duCmd, "/photos")
+	if err == nil || !strings.Contains(err.Error(), "was saved with --depth 1") {
+		t.Errorf("duPath = %v", err)
+	}
+	if calls := fake.Methods(); len(calls) != 0 {
+		t.Errorf("made calls %v", calls)
+	}
+}
+
+// du, account and the quota checks all count a team member's usage against
+// the team's allocation.
+func TestNewJSONUsage(t *testing.T) {
+	teamUsed := uint64(60)
+	tests := []struct {
+		name  string
+		usage *users.SpaceUsage
+		want  jsonUsage
+	}{
+		{
+			name: "individual",
+			usage: users.NewSpaceUsage(10, &users.SpaceAllocation{
+				Tagged:     dropbox.Tagged{Tag: "individual"},
+				Individual: users.NewIndividualSpaceAllocation(100),
+			}),
+			want: jsonUsage{Used: 10, Type: "individual", Allocated: 100},
+		},
+		{
+			name: "team",
+			usage: users.NewSpaceUsage(10, &users.SpaceAllocation{
+				Tagged: dropbox.Tagged{Tag: "team"},
+				Team:   users.NewTeamSpaceAllocation(teamUsed, 200),
+			}),
+			want: jsonUsage{Used: 10, Type: "team", Allocated: 200, TeamUsed: &teamUsed},
+		},
+	}
+	for _, tt := range tests {
+		if got := newJSONUsage(tt.usage); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: newJSONUsage = %+v, want %+v", tt.name, got, tt.want)
+		}
+		q := quotaOf(tt.usage)
+		if tt.want.TeamUsed != nil && q.Used != *tt.want.TeamUsed {
+			t.Errorf("%s: quota used = %d, want the team's %d", tt.name, q.Used, *tt.want.TeamUsed)
+		}
+		if q.Allocated != tt.want.Allocated {
+			t.Errorf("%s: quota allocated = %d, want %d", tt.name, q.Allocated, tt.want.Allocated)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/dropbox/dbxcli/crypt"
+	"github.com/spf13/cobra"
+)
+
+// Key material is never taken from the command line, where it would end up
+// in shell history and the process list.
+const (
+	identityEnv   = "DBXCLI_IDENTITY"
+	passphraseEnv = "DBXCLI_PASSPHRASE"
+
+	defaultEncryptSuffix = ".dbxenc"
+)
+
+// Returns the key file named by --identity, falling back to $DBXCLI_IDENTITY.
+func keyFilePath(cmd *cobra.Command) string {
+	if p, _ := cmd.Flags().GetString("identity"); p != "" {
+		return p
+	}
+	return os.Getenv(identityEnv)
+}
+
+func readKeyFile(p string) (ids []crypt.Identity, rcpts []crypt.Recipient, err error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if ids, rcpts, err = crypt.ParseKeyFile(f); err != nil {
+		err = errors.New(p + ": " + err.Error())
+	}
+	return
+}
+
+var errNoKey = errors.New("encryption needs a key file, given with `--identity` or $" + identityEnv +
+	", or a passphrase in $" + passphraseEnv)
+
+// Returns who `put --encrypt` encrypts to: every key in the key file, or else
+// the passphrase.
+func encryptionRecipients(cmd *cobra.Command) ([]crypt.Recipient, error) {
+	if p := keyFilePath(cmd); p != "" {
+		_, rcpts, err := readKeyFile(p)
+		return rcpts, err
+	}
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		r, err := crypt.NewScryptRecipient(pass)
+		if err != nil {
+			return nil, err
+		}
+		return []crypt.Recipient{r}, nil
+	}
+	return nil, errNoKey
+}
+
+// Returns the keys `get --decrypt` tries: the secret keys in the key file and
+// the passphrase, if there is one.
+func decryptionIdentities(cmd *cobra.Command) (ids []crypt.Identity, err error) {
+	if p := keyFilePath(cmd); p != "" {
+		if ids, _, err = readKeyFile(p); err != nil {
+			return
+		}
+	}
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		id, err := crypt.NewScryptIdentity(pass)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		err = errNoKey
+	}
+	return
+}
@@ -0,0 +1,295 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/spf13/cobra"
+)
+
+// exitStatus ends a command with the given exit status and no error message,
+// for commands like `put --check` whose status is their answer.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// A human explanation for an API error tag, and what to do about it.
+type errorExplanation struct {
+	tag     string
+	message string
+	hint    string
+}
+
+// Checked in order; the first tag found in an error summary wins, so more
+// specific tags come first.
+var errorExplanations = []errorExplanation{
+	{"restricted_content", "This file is restricted, usually because of a copyright claim or a violation of Dropbox's terms.",
+		"It can't be downloaded or shared; contact Dropbox support if you think this is a mistake."},
+	{"too_many_write_operations", "Dropbox is asking us to slow down: too many changes are being made to the same folder at once.",
+		"Wait a moment and run the command again, or lower `--transfers`."},
+	{"too_many_requests", "Dropbox is asking us to slow down: too many requests were made in a short time.",
+		"Wait a moment and run the command again, or raise `--retries`."},
+	{"too_many_files", "The operation involves too many files to be done at once.",
+		"Split it into smaller operations."},
+	{"too_large", "The folder is too large to download as a zip archive.",
+		"Download it with `get --recursive` instead."},
+	{"insufficient_space", "There isn't enough space left in the Dropbox.",
+		"Free up some space or upgrade the account, then try again."},
+	{"insufficient_quota", "There isn't enough space left in the Dropbox.",
+		"Free up some space or upgrade the account, then try again."},
+	{"invalid_access_token", "The saved login for this account is no longer valid.",
+		"Run `dbxcli logout` and then any command to log in again."},
+	{"expired_access_token", "The saved login for this account has expired.",
+		"Run `dbxcli logout` and then any command to log in again."},
+	{"missing_scope", "The login doesn't grant permission for this operation.",
+		"Run `dbxcli logout` and then any command to log in again with the required permissions."},
+	{"email_not_verified", "The account's email address hasn't been verified.",
+		"Verify it from the Dropbox website and try again."},
+	{"no_write_permission", "You don't have permission to make changes there.",
+		"Ask the owner of the shared folder for edit access."},
+	{"disallowed_name", "Dropbox doesn't allow that name.",
+		"Rename the file; names like desktop.ini or .DS_Store are never synced."},
+	{"malformed_path", "The path isn't valid.",
+		"Check it for characters Dropbox doesn't allow and make sure it starts with \"/\"."},
+	{"conflict", "Something already exists at the destination.",
+		"Choose another name, or with `put` use `--on-conflict`."},
+	{"not_found", "Nothing exists at that path.",
+		"Check the spelling with `dbxcli ls`; paths start at the root of your Dropbox."},
+	{"not_file", "That path is a folder, but a file was expected.", ""},
+	{"not_folder", "That path is a file, but a folder was expected.", ""},
+	{"shared_link_already_exists", "The file or folder already has a shared link.",
+		"Use `dbxcli share list link` to see it."},
+	{"access_denied", "You don't have access to that.",
+		"Check that you're logged in to the right account, or use `--as-member` for team members."},
+}
+
+// Dropbox support can look a request up by its id, so errors quote it. The
+// SDK's error types have nowhere to keep it but the error summary, which is
+// where tagRequestID puts it, after this marker.
+const requestIDMarker = " [request id "
+
+// Appends the request id of an error response to the error summary in its
+// body, so the error decoded from it carries the id of its own request.
+func tagRequestID(resp *http.Response) {
+	id := resp.Header.Get("X-Dropbox-Request-Id")
+	if resp.StatusCode < 400 || id == "" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	defer func() {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Del("Content-Length")
+	}()
+	if err != nil {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	var summary string
+	if json.Unmarshal(body, &fields) != nil || json.Unmarshal(fields["error_summary"], &summary) != nil {
+		return
+	}
+	fields["error_summary"], _ = json.Marshal(summary + requestIDMarker + id + "]")
+	if tagged, err := json.Marshal(fields); err == nil {
+		body = tagged
+	}
+}
+
+// Splits an error summary into the API's own summary and the request id
+// tagRequestID appended to it, if any.
+func splitRequestID(summary string) (string, string) {
+	i := strings.Index(summary, requestIDMarker)
+	if i < 0 {
+		return summary, ""
+	}
+	return summary[:i], strings.TrimSuffix(summary[i+len(requestIDMarker):], "]")
+}
+
+// Returns the id of the request an API error came from, or "" for other
+// errors.
+func requestIDOf(err error) string {
+	summary, ok := taggedErrorSummary(err)
+	if !ok {
+		return ""
+	}
+	_, id := splitRequestID(summary)
+	return id
+}
+
+var apiErrorType = reflect.TypeOf(dropbox.APIError{})
+
+// Returns the error summary of an API error: a dropbox.APIError itself, or
+// one of the endpoint-specific error types, which all embed it. The request
+// id is left out.
+func apiErrorSummary(err error) (string, bool) {
+	summary, ok := taggedErrorSummary(err)
+	summary, _ = splitRequestID(summary)
+	return summary, ok
+}
+
+// Like apiErrorSummary, but with the request id still in the summary.
+func taggedErrorSummary(err error) (string, bool) {
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", false
+	}
+	if v.Type() == apiErrorType {
+		return v.Interface().(dropbox.APIError).ErrorSummary, true
+	}
+	f := v.FieldByName("APIError")
+	if !f.IsValid() || f.Type() != apiErrorType {
+		return "", false
+	}
+	return f.Interface().(dropbox.APIError).ErrorSummary, true
+}
+
+// Finds the explanation for an error summary such as
+// "path/restricted_content/.", matching whole tags.
+func findExplanation(summary string) (errorExplanation, bool) {
+	tags := strings.Split(strings.TrimRight(summary, "./"), "/")
+	for _, e := range errorExplanations {
+		for _, tag := range tags {
+			if strings.TrimSpace(tag) == e.tag {
+				return e, true
+			}
+		}
+	}
+	return errorExplanation{}, false
+}
+
+// Turns an error into the text shown to the user. API errors with a known tag
+// get an explanation and a suggestion; the rest are shown as they are. API
+// errors are followed by the id of the request they came from.
+func explainError(err error) string {
+	summary, ok := apiErrorSummary(err)
+	if !ok {
+		return err.Error()
+	}
+
+	details := summary
+	if id := requestIDOf(err); id != "" {
+		details += " (request id " + id + ")"
+	}
+
+	e, ok := findExplanation(summary)
+	if !ok {
+		return details
+	}
+	text := e.message
+	if e.hint != "" {
+		text += "\n" + e.hint
+	}
+	return text + "\nDetails: " + details
+}
+
+// The fields of an error as printed in JSON mode. Tag is the API error
+// summary, such as "path/not_found", and Path is only known for local files.
+type jsonErrorFields struct {
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Path      string `json:"path,omitempty"`
+}
+
+type jsonError struct {
+	Error jsonErrorFields `json:"error"`
+}
+
+// Describes an error for JSON output, using the same explanations as
+// explainError.
+func errorFields(err error) jsonErrorFields {
+	f := jsonErrorFields{Message: err.Error()}
+	if e, ok := err.(*os.PathError); ok {
+		f.Path = e.Path
+	}
+
+	summary, ok := apiErrorSummary(err)
+	if !ok {
+		return f
+	}
+	f.Tag = strings.TrimRight(summary, "./")
+	f.RequestID = requestIDOf(err)
+	if e, ok := findExplanation(summary); ok {
+		f.Message, f.Hint = e.message, e.hint
+	}
+	return f
+}
+
+// Reports whether `cmd` was asked to print JSON.
+func jsonMode(cmd *cobra.Command) bool {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	return asJSON
+}
+
+// Exit statuses of commands run with --json, so that scripts can tell the
+// common failures apart without parsing the error. 1 is left to `--check`,
+// which uses it to say there are differences, so any failure is above 1.
+const (
+	exitDifferences = 1
+	exitFailed      = 2 // anything not listed below
+	exitNotFound    = 3
+	exitAuth        = 4
+	exitRateLimited = 5
+	exitTimedOut    = 6
+)
+
+// Returns the exit status of a command run with --json that failed with err.
+func jsonExitStatus(err error, timedOut bool) int {
+	if timedOut {
+		return exitTimedOut
+	}
+	summary, ok := apiErrorSummary(err)
+	if !ok {
+		return exitFailed
+	}
+	for _, tag := range strings.Split(strings.TrimRight(summary, "./"), "/") {
+		switch tag {
+		case "not_found":
+			return exitNotFound
+		case "invalid_access_token", "expired_access_token":
+			return exitAuth
+		case "too_many_requests", "too_many_write_operations":
+			return exitRateLimited
+		}
+	}
+	return exitFailed
+}
+
+// Writes err to w as a single line of JSON, for commands run with --json.
+func writeJSONError(w io.Writer, err error, hint string) error {
+	f := errorFields(err)
+	if f.Hint == "" {
+		f.Hint = hint
+	}
+	return json.NewEncoder(w).Encode(jsonError{f})
+}
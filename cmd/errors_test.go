@@ -0,0 +1,315 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestFindExplanation(t *testing.T) {
+	tests := []struct {
+		summary string
+		// The tag of the explanation expected, or "" for none.
+		tag string
+	}{
+		{"path/not_found/..", "not_found"},
+		{"path/not_found/", "not_found"},
+		{"path_lookup/not_found/.", "not_found"},
+		{"path/restricted_content/...", "restricted_content"},
+		{"path/conflict/file/..", "conflict"},
+		{"to/conflict/folder/.", "conflict"},
+		{"too_many_write_operations/..", "too_many_write_operations"},
+		{"path/insufficient_space/.", "insufficient_space"},
+		{"invalid_access_token/", "invalid_access_token"},
+		{"expired_access_token/..", "expired_access_token"},
+		{"path/malformed_path/", "malformed_path"},
+		{"shared_link_already_exists/metadata/..", "shared_link_already_exists"},
+		// Tags match whole, not as substrings.
+		{"path/not_found_anywhere/..", ""},
+		{"path/unsupported_file/..", ""},
+		{"", ""},
+		// Earlier entries of the table win over later ones.
+		{"path/not_found/restricted_content/..", "restricted_content"},
+		{"too_many_requests/too_many_write_operations", "too_many_write_operations"},
+	}
+	for _, tt := range tests {
+		e, ok := findExplanation(tt.summary)
+		if ok != (tt.tag != "") || e.tag != tt.tag {
+			t.Errorf("findExplanation(%q) = %q, %t, want %q", tt.summary, e.tag, ok, tt.tag)
+		}
+	}
+}
+
+// Decodes an error body the way the SDK does for get_metadata.
+func getMetadataError(t *testing.T, body string) error {
+	var e files.GetMetadataAPIError
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExplainError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "other errors are shown as they are",
+			err:  errors.New("disk full"),
+			want: "disk full",
+		},
+		{
+			name: "known tags are explained",
+			err:  dropbox.APIError{ErrorSummary: "path/insufficient_space/.."},
+			want: "There isn't enough space left in the Dropbox.\nFree up some space or upgrade the account, then try again.\nDetails: path/insufficient_space/..",
+		},
+		{
+			name: "explanations without a hint",
+			err:  rpcError{APIError: dropbox.APIError{ErrorSummary: "path/not_file/"}},
+			want: "That path is a folder, but a file was expected.\nDetails: path/not_file/",
+		},
+		{
+			name: "unknown tags are shown with the request id",
+			err:  getMetadataError(t, `{"error_summary": "path/unsupported_file/.. [request id abc123]", "error": {".tag": "path", "path": {".tag": "unsupported_file"}}}`),
+			want: "path/unsupported_file/.. (request id abc123)",
+		},
+		{
+			name: "endpoint errors are explained",
+			err:  getMetadataError(t, `{"error_summary": "path/not_found/. [request id abc123]", "error": {".tag": "path", "path": {".tag": "not_found"}}}`),
+			want: "Nothing exists at that path.\nCheck the spelling with `dbxcli ls`; paths start at the root of your Dropbox.\nDetails: path/not_found/. (request id abc123)",
+		},
+		{
+			name: "pointers to errors",
+			err:  &dropbox.APIError{ErrorSummary: "too_many_requests/"},
+			want: "Dropbox is asking us to slow down: too many requests were made in a short time.\nWait a moment and run the command again, or raise `--retries`.\nDetails: too_many_requests/",
+		},
+	}
+	for _, tt := range tests {
+		if got := explainError(tt.err); got != tt.want {
+			t.Errorf("%s: explainError = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorFields(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want jsonErrorFields
+	}{
+		{
+			name: "local files",
+			err:  &os.PathError{Op: "open", Path: "notes.txt", Err: os.ErrNotExist},
+			want: jsonErrorFields{Message: "open notes.txt: file does not exist", Path: "notes.txt"},
+		},
+		{
+			name: "API errors",
+			err:  getMetadataError(t, `{"error_summary": "path/not_found/.. [request id abc123]", "error": {".tag": "path", "path": {".tag": "not_found"}}}`),
+			want: jsonErrorFields{
+				Message:   "Nothing exists at that path.",
+				Hint:      "Check the spelling with `dbxcli ls`; paths start at the root of your Dropbox.",
+				Tag:       "path/not_found",
+				RequestID: "abc123",
+			},
+		},
+		{
+			name: "unknown API errors",
+			err:  dropbox.APIError{ErrorSummary: "other/"},
+			want: jsonErrorFields{Message: "other/", Tag: "other"},
+		},
+	}
+	for _, tt := range tests {
+		if got := errorFields(tt.err); got != tt.want {
+			t.Errorf("%s: errorFields = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTagRequestID(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "endpoint errors",
+			status:      http.StatusConflict,
+			contentType: "application/json",
+			body:        `{"error_summary": "path/not_found/..", "error": {".tag": "path"}}`,
+			want:        `{"error":{".tag":"path"},"error_summary":"path/not_found/.. [request id abc123]"}`,
+		},
+		{
+			name:        "successful responses",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"name": "a"}`,
+			want:        `{"name": "a"}`,
+		},
+		{
+			name:        "plain text errors",
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			body:        "Error in call to API function",
+			want:        "Error in call to API function",
+		},
+		{
+			name:        "JSON without a summary",
+			status:      http.StatusInternalServerError,
+			contentType: "application/json",
+			body:        `{"error": "oops"}`,
+			want:        `{"error": "oops"}`,
+		},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: tt.status,
+			Header:     http.Header{"Content-Type": {tt.contentType}, "X-Dropbox-Request-Id": {"abc123"}},
+			Body:       ioutil.NopCloser(strings.NewReader(tt.body)),
+		}
+		tagRequestID(resp)
+		got, _ := ioutil.ReadAll(resp.Body)
+		if string(got) != tt.want {
+			t.Errorf("%s: body = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Errors from concurrent requests each keep their own request id.
+func TestRequestIDPerError(t *testing.T) {
+	api := useFakeAPI(t)
+	api.Respond("files/get_metadata", http.StatusConflict, `{"error_summary": "path/not_found/..", "error": {".tag": "path", "path": {".tag": "not_found"}}}`)
+	api.Respond("files/get_metadata", http.StatusConflict, `{"error_summary": "path/malformed_path/..", "error": {".tag": "path", "path": {".tag": "malformed_path"}}}`)
+	api.SetHeader("X-Dropbox-Request-Id", "first")
+
+	first := rpc(cmdCtx, "files", "get_metadata", files.NewGetMetadataArg("/a"), nil)
+	api.SetHeader("X-Dropbox-Request-Id", "second")
+	second := rpc(cmdCtx, "files", "get_metadata", files.NewGetMetadataArg("/b"), nil)
+
+	if id := requestIDOf(first); id != "first" {
+		t.Errorf("first error has request id %q", id)
+	}
+	if id := requestIDOf(second); id != "second" {
+		t.Errorf("second error has request id %q", id)
+	}
+	if summary, _ := apiErrorSummary(first); summary != "path/not_found/.." {
+		t.Errorf("first error has summary %q", summary)
+	}
+	if !strings.HasPrefix(first.(rpcError).ErrorSummary, "path/not_found") {
+		t.Errorf("prefix checks on the summary break: %q", first.(rpcError).ErrorSummary)
+	}
+}
+
+// isMissing goes by the error summary, so it works for every endpoint's
+// error type and for errors from rpc alike.
+func TestIsMissing(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{notFoundError(), true},
+		{files.DownloadAPIError{APIError: dropbox.APIError{ErrorSummary: "path/not_found/.."}}, true},
+		{rpcError{APIError: dropbox.APIError{ErrorSummary: "path/not_found/ [request id abc]"}}, true},
+		{rpcError{APIError: dropbox.APIError{ErrorSummary: "path/not_file/.."}}, false},
+		{files.UploadAPIError{APIError: dropbox.APIError{ErrorSummary: "path/conflict/file/.."}}, false},
+		{errors.New("path/not_found"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isMissing(tt.err); got != tt.want {
+			t.Errorf("isMissing(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestJSONExitStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		timedOut bool
+		want     int
+	}{
+		{errors.New("boom"), false, exitFailed},
+		{context.DeadlineExceeded, true, exitTimedOut},
+		{rpcError{APIError: dropbox.APIError{ErrorSummary: "path/not_found/.."}}, false, exitNotFound},
+		{dropbox.APIError{ErrorSummary: "from_lookup/not_found/"}, false, exitNotFound},
+		{dropbox.APIError{ErrorSummary: "expired_access_token/"}, false, exitAuth},
+		{dropbox.APIError{ErrorSummary: "invalid_access_token/ [request id abc]"}, false, exitAuth},
+		{rateLimitError{APIError: dropbox.APIError{ErrorSummary: "too_many_requests/"}}, false, exitRateLimited},
+		{rpcError{APIError: dropbox.APIError{ErrorSummary: "path/conflict/file/"}}, false, exitFailed},
+	}
+	for _, tt := range tests {
+		got := jsonExitStatus(tt.err, tt.timedOut)
+		if got != tt.want {
+			t.Errorf("jsonExitStatus(%v, %v) = %d, want %d", tt.err, tt.timedOut, got, tt.want)
+		}
+		// `--check` says "there are differences" with 1.
+		if got <= exitDifferences {
+			t.Errorf("jsonExitStatus(%v, %v) = %d, which --check uses for differences", tt.err, tt.timedOut, got)
+		}
+	}
+}
+
+// A failing command run with --json prints nothing on stdout, and its error
+// goes to stderr as one line of JSON.
+func TestJSONErrorKeepsStdoutClean(t *testing.T) {
+	api := useFakeAPI(t)
+	api.SetHeader("X-Dropbox-Request-Id", "req-1")
+	api.Respond("files/get_metadata", 409, `{"error_summary": "path/not_found/..", "error": {".tag": "path", "path": {".tag": "not_found"}}}`)
+	setFlags(t, statCmd, map[string]string{"json": "true"})
+
+	stdout, stderr, err := testutil.Capture(func() error {
+		err := stat(statCmd, []string{"/missing.txt"})
+		if err != nil {
+			writeJSONError(os.Stderr, err, "")
+		}
+		return err
+	})
+	if err == nil {
+		t.Fatal("stat of a missing file succeeded")
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+	if lines := strings.Split(strings.TrimSuffix(stderr, "\n"), "\n"); len(lines) != 1 {
+		t.Errorf("stderr has %d lines: %q", len(lines), stderr)
+	}
+	var got jsonError
+	dec := json.NewDecoder(bytes.NewReader([]byte(stderr)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("stderr %q isn't a JSON error: %v", stderr, err)
+	}
+	if got.Error.Tag != "path/not_found" || got.Error.RequestID != "req-1" || got.Error.Message == "" {
+		t.Errorf("error = %+v", got.Error)
+	}
+	if status := jsonExitStatus(err, false); status != exitNotFound {
+		t.Errorf("exit status %d, want %d", status, exitNotFound)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/spf13/cobra"
+)
+
+// How long after the deadline uploads are still accepted.
+var lateUploadPolicies = []string{"one_day", "two_days", "seven_days", "thirty_days", "always"}
+
+// The vendored SDK has no file_requests namespace.
+type fileRequestDeadline struct {
+	Deadline         string          `json:"deadline"`
+	AllowLateUploads *dropbox.Tagged `json:"allow_late_uploads,omitempty"`
+}
+
+type fileRequest struct {
+	ID          string               `json:"id"`
+	URL         string               `json:"url"`
+	Title       string               `json:"title"`
+	Destination string               `json:"destination,omitempty"`
+	Created     string               `json:"created"`
+	Deadline    *fileRequestDeadline `json:"deadline,omitempty"`
+	IsOpen      bool                 `json:"is_open"`
+	FileCount   int64                `json:"file_count"`
+	Description string               `json:"description,omitempty"`
+}
+
+type createFileRequestArg struct {
+	Title       string               `json:"title"`
+	Destination string               `json:"destination"`
+	Deadline    *fileRequestDeadline `json:"deadline,omitempty"`
+	Open        bool                 `json:"open"`
+	Description string               `json:"description,omitempty"`
+}
+
+type listFileRequestsArg struct {
+	Limit uint64 `json:"limit"`
+}
+
+type listFileRequestsContinueArg struct {
+	Cursor string `json:"cursor"`
+}
+
+type listFileRequestsResult struct {
+	FileRequests []fileRequest `json:"file_requests"`
+	Cursor       string        `json:"cursor"`
+	HasMore      bool          `json:"has_more"`
+}
+
+type closeFileRequestArg struct {
+	ID   string `json:"id"`
+	Open bool   `json:"open"`
+}
+
+// Reads `--deadline` and `--allow-late-uploads`; nil means no deadline.
+func newFileRequestDeadline(cmd *cobra.Command) (*fileRequestDeadline, error) {
+	deadline, _ := cmd.Flags().GetString("deadline")
+	late, _ := cmd.Flags().GetString("allow-late-uploads")
+	if deadline == "" {
+		if late != "" {
+			return nil, errors.New("`--allow-late-uploads` requires `--deadline`")
+		}
+		return nil, nil
+	}
+	t, err := parseRelativeTime(deadline, fromNow)
+	if err != nil {
+		return nil, fmt.Errorf("`--deadline`: %v", err)
+	}
+	if !t.After(time.Now()) {
+		return nil, fmt.Errorf("`--deadline` %s is in the past", deadline)
+	}
+	d := &fileRequestDeadline{Deadline: t.UTC().Format("2006-01-02T15:04:05Z")}
+	if late != "" {
+		valid := false
+		for _, p := range lateUploadPolicies {
+			valid = valid || p == late
+		}
+		if !valid {
+			return nil, fmt.Errorf("`--allow-late-uploads` must be one of %s, not %q", strings.Join(lateUploadPolicies, ", "), late)
+		}
+		d.AllowLateUploads = &dropbox.Tagged{Tag: late}
+	}
+	return d, nil
+}
+
+func fileRequestCreate(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`filerequest create` requires a `folder` argument")
+	}
+	dst, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	arg := createFileRequestArg{Destination: dst, Open: true}
+	arg.Title, _ = cmd.Flags().GetString("title")
+	if arg.Title == "" {
+		arg.Title = path.Base(dst)
+	}
+	arg.Description, _ = cmd.Flags().GetString("description")
+	if arg.Deadline, err = newFileRequestDeadline(cmd); err != nil {
+		return
+	}
+
+	var res fileRequest
+	if err = rpc(cmdCtx, "file_requests", "create", &arg, &res); err != nil {
+		return
+	}
+	if jsonMode(cmd) {
+		return printJSON(res)
+	}
+	fmt.Println(res.URL)
+	return
+}
+
+func fileRequestList(cmd *cobra.Command, args []string) (err error) {
+	var res listFileRequestsResult
+	if err = rpc(cmdCtx, "file_requests", "list_v2", &listFileRequestsArg{Limit: 1000}, &res); err != nil {
+		return
+	}
+	requests := res.FileRequests
+	for res.HasMore {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		cursor := res.Cursor
+		res = listFileRequestsResult{}
+		if err = rpc(cmdCtx, "file_requests", "list/continue", &listFileRequestsContinueArg{cursor}, &res); err != nil {
+			return
+		}
+		requests = append(requests, res.FileRequests...)
+	}
+
+	if all, _ := cmd.Flags().GetBool("all"); !all {
+		var open []fileRequest
+		for _, r := range requests {
+			if r.IsOpen {
+				open = append(open, r)
+			}
+		}
+		requests = open
+	}
+
+	if jsonMode(cmd) {
+		if requests == nil {
+			requests = []fileRequest{}
+		}
+		return printJSON(requests)
+	}
+	if len(requests) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmtStr := "%s\t%s\t%s\t%s\t%d\t%s\t%s\n"
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", "ID", "Title", "Destination", "Deadline", "Files", "Status", "URL")
+	for _, r := range requests {
+		deadline := "-"
+		if r.Deadline != nil {
+			if t, err := time.Parse(time.RFC3339, r.Deadline.Deadline); err == nil {
+				deadline = t.Local().Format("2006-01-02 15:04")
+			}
+		}
+		destination := r.Destination
+		if destination == "" {
+			destination = "-"
+		}
+		status := "open"
+		if !r.IsOpen {
+			status = "closed"
+		}
+		fmt.Fprintf(w, fmtStr, r.ID, r.Title, destination, deadline, r.FileCount, status, r.URL)
+	}
+	return w.Flush()
+}
+
+func fileRequestClose(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`filerequest close` requires an `id` argument")
+	}
+
+	failed := 0
+	for _, id := range args {
+		if err = rpc(cmdCtx, "file_requests", "update", &closeFileRequestArg{ID: id}, nil); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", id, explainError(err))
+			failed++
+			continue
+		}
+		fmt.Printf("Closed %s\n", id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file requests couldn't be closed", failed, len(args))
+	}
+	return nil
+}
+
+// fileRequestCmd represents the filerequest command
+var fileRequestCmd = &cobra.Command{
+	Use:   "filerequest",
+	Short: "Collect files from others with file requests",
+	Long: `File requests let anyone with the link upload files into a folder of your
+Dropbox, without being able to see what's already there.`,
+}
+
+// fileRequestCreateCmd represents the filerequest create command
+var fileRequestCreateCmd = &cobra.Command{
+	Use:   "create <folder>",
+	Short: "Create a file request and print its link",
+	Long: `Create a file request whose uploads go into <folder>, which is created if
+it doesn't exist, and print the link to send to people. The title defaults to
+the folder's name.
+
+--deadline takes a date, an RFC 3339 time or a duration from now such as 7d.
+With --allow-late-uploads, uploads are still accepted for that long after the
+deadline: one_day, two_days, seven_days, thirty_days or always. Deadlines
+need a Dropbox plan that allows them.`,
+	Example: `  dbxcli filerequest create /Submissions/2025 --title "Conference talks"
+  dbxcli filerequest create /Receipts --deadline 14d --allow-late-uploads one_day`,
+	RunE: fileRequestCreate,
+}
+
+// fileRequestListCmd represents the filerequest list command
+var fileRequestListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List file requests",
+	Long:  `List your open file requests, or all of them with --all.`,
+	Example: `  dbxcli filerequest list
+  dbxcli filerequest list --all --json`,
+	RunE: fileRequestList,
+}
+
+// fileRequestCloseCmd represents the filerequest close command
+var fileRequestCloseCmd = &cobra.Command{
+	Use:   "close <id>...",
+	Short: "Close file requests",
+	Long: `Close file requests so they stop accepting uploads. Files already
+uploaded stay where they are. The ids are listed by "dbxcli filerequest list".`,
+	Example: `  dbxcli filerequest close oaCAVmEyrqYnkZX9955Y`,
+	RunE:    fileRequestClose,
+}
+
+func init() {
+	RootCmd.AddCommand(fileRequestCmd)
+	fileRequestCmd.AddCommand(fileRequestCreateCmd)
+	fileRequestCmd.AddCommand(fileRequestListCmd)
+	fileRequestCmd.AddCommand(fileRequestCloseCmd)
+
+	fileRequestCreateCmd.Flags().String("title", "", "Title shown to uploaders (default: the folder's name)")
+	fileRequestCreateCmd.Flags().String("description", "", "Description shown to uploaders")
+	fileRequestCreateCmd.Flags().String("deadline", "", "Stop accepting uploads after this date or duration")
+	fileRequestCreateCmd.Flags().String("allow-late-uploads", "", "Accept uploads this long after the deadline")
+	fileRequestListCmd.Flags().BoolP("all", "a", false, "Include closed file requests")
+}
@@ -0,0 +1,158 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// The file at the local root of a recursive transfer which lists patterns to
+// exclude, one per line.
+const ignoreFileName = ".dbxignore"
+
+// Decides which files a recursive transfer leaves out, from --exclude,
+// --include and .dbxignore files.
+//
+// A pattern without a slash matches a name at any depth, such as "*.o" or
+// "node_modules"; one with a slash matches the path from the root of the
+// transfer, such as "build/*.log". A trailing slash limits a pattern to
+// directories. Everything beneath an excluded directory is excluded too.
+// When there are include patterns only files which match one are
+// transferred, but every directory is still searched.
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+func newPathFilter(cmd *cobra.Command) (*pathFilter, error) {
+	f := &pathFilter{}
+	f.include, _ = cmd.Flags().GetStringSlice("include")
+	f.exclude, _ = cmd.Flags().GetStringSlice("exclude")
+	for _, p := range append(append([]string(nil), f.include...), f.exclude...) {
+		if err := checkPattern(p); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func checkPattern(p string) error {
+	if _, err := path.Match(strings.Trim(p, "/"), ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", p, err)
+	}
+	return nil
+}
+
+// Returns a copy of the filter which also excludes the patterns in the
+// .dbxignore file of the local directory `dir`, if it has one. Blank lines
+// and lines starting with "#" are ignored.
+func (f *pathFilter) withIgnoreFile(dir string) (*pathFilter, error) {
+	name := filepath.Join(dir, ignoreFileName)
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g := &pathFilter{include: f.include, exclude: append([]string(nil), f.exclude...)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err = checkPattern(line); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		g.exclude = append(g.exclude, line)
+	}
+	return g, scanner.Err()
+}
+
+// Whether `pattern` matches the file or directory at `rel`, a slash-separated
+// path relative to the root of the transfer.
+func matchPattern(pattern string, rel string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(rel))
+		return matched
+	}
+	matched, _ := path.Match(strings.TrimPrefix(pattern, "/"), rel)
+	return matched
+}
+
+// Whether the file or directory at `rel` is left out of the transfer. A nil
+// filter leaves nothing out.
+func (f *pathFilter) excluded(rel string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		prefixIsDir := isDir || i < len(segments)-1
+		for _, p := range f.exclude {
+			if matchPattern(p, prefix, prefixIsDir) {
+				return true
+			}
+		}
+	}
+
+	if isDir || len(f.include) == 0 {
+		return false
+	}
+	for _, p := range f.include {
+		if matchPattern(p, rel, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes what `f` excludes from both sides of a sync, so it's neither
+// transferred nor deleted.
+func filterSyncTrees(f *pathFilter, local map[string]localEntry, remote map[string]remoteEntry) {
+	for key, e := range local {
+		if f.excluded(e.rel, e.info.IsDir()) {
+			delete(local, key)
+		}
+	}
+	for key, e := range remote {
+		if f.excluded(e.rel, e.isDir()) {
+			delete(remote, key)
+		}
+	}
+}
+
+// Adds the filter flags to a command which transfers folders.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("exclude", nil, "Skip files and directories matching this pattern (repeatable)")
+	cmd.Flags().StringSlice("include", nil, "Only transfer files matching this pattern (repeatable)")
+}
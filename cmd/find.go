@@ -0,0 +1,218 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// What `find` looks for. Zero values don't filter anything; the size and
+// time filters only match files.
+type findFilter struct {
+	name           string
+	typ            string
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+}
+
+// Which way from now a duration given as a time points: back for ages like
+// `find --modified-after 30d`, forward for expiries like `share link
+// --expires 7d`.
+type timeDirection int
+
+const (
+	ago     timeDirection = -1
+	fromNow timeDirection = 1
+)
+
+// Parses a time given to a flag such as `find --modified-after`: a date such
+// as 2024-01-01 (midnight local time), an RFC 3339 time, or a duration like
+// 30d or 12h, counted from now in direction `dir`.
+func parseRelativeTime(s string, dir timeDirection) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use a date like 2024-01-01, an RFC 3339 time or a duration like 30d", s)
+	}
+	return time.Now().Add(time.Duration(dir) * d), nil
+}
+
+func newFindFilter(cmd *cobra.Command) (f findFilter, err error) {
+	f.name, _ = cmd.Flags().GetString("name")
+	if _, err = path.Match(f.name, ""); err != nil {
+		return f, fmt.Errorf("invalid `--name` %q: %v", f.name, err)
+	}
+	f.name = strings.ToLower(f.name)
+
+	switch typ, _ := cmd.Flags().GetString("type"); typ {
+	case "":
+	case "file", "f":
+		f.typ = "file"
+	case "folder", "d":
+		f.typ = "folder"
+	default:
+		return f, fmt.Errorf("invalid `--type` %q: use file (f) or folder (d)", typ)
+	}
+
+	for _, s := range []struct {
+		flag string
+		n    *int64
+	}{{"min-size", &f.minSize}, {"max-size", &f.maxSize}} {
+		if v, _ := cmd.Flags().GetString(s.flag); v != "" {
+			if *s.n, err = parseByteSize(v); err != nil {
+				return f, fmt.Errorf("invalid `--%s` %q: %v", s.flag, v, err)
+			}
+		}
+	}
+	for _, t := range []struct {
+		flag string
+		t    *time.Time
+	}{{"modified-after", &f.modifiedAfter}, {"modified-before", &f.modifiedBefore}} {
+		if v, _ := cmd.Flags().GetString(t.flag); v != "" {
+			if *t.t, err = parseRelativeTime(v, ago); err != nil {
+				return f, fmt.Errorf("`--%s`: %v", t.flag, err)
+			}
+		}
+	}
+	return
+}
+
+// Whether the size or time filters are in use, which rule out folders.
+func (f findFilter) filesOnly() bool {
+	return f.minSize > 0 || f.maxSize > 0 || !f.modifiedAfter.IsZero() || !f.modifiedBefore.IsZero()
+}
+
+func (f findFilter) match(md files.IsMetadata) bool {
+	var name string
+	switch e := md.(type) {
+	case *files.FileMetadata:
+		if f.typ == "folder" {
+			return false
+		}
+		size := int64(e.Size)
+		switch {
+		case f.minSize > 0 && size < f.minSize,
+			f.maxSize > 0 && size > f.maxSize,
+			!f.modifiedAfter.IsZero() && !e.ServerModified.After(f.modifiedAfter),
+			!f.modifiedBefore.IsZero() && !e.ServerModified.Before(f.modifiedBefore):
+			return false
+		}
+		name = e.Name
+	case *files.FolderMetadata:
+		if f.typ == "file" || f.filesOnly() {
+			return false
+		}
+		name = e.Name
+	default:
+		return false
+	}
+	if f.name == "" {
+		return true
+	}
+	matched, _ := path.Match(f.name, strings.ToLower(name))
+	return matched
+}
+
+func find(cmd *cobra.Command, args []string) (err error) {
+	if len(args) > 1 {
+		return errors.New("`find` takes at most one `path` argument")
+	}
+	root := ""
+	if len(args) == 1 {
+		if root, err = validatePath(args[0]); err != nil {
+			return
+		}
+	}
+	filter, err := newFindFilter(cmd)
+	if err != nil {
+		return
+	}
+	print0, _ := cmd.Flags().GetBool("print0")
+	asJSON := jsonMode(cmd)
+
+	dbx := newFilesClient(cmdCtx)
+	var printErr error
+	err = walkFolder(cmdCtx, dbx, root, "", 0, nil, func(page []files.IsMetadata) {
+		for _, md := range page {
+			if printErr != nil {
+				return
+			}
+			// The listing includes the folder itself.
+			if strings.EqualFold(metadataPath(md), root) || !filter.match(md) {
+				continue
+			}
+			switch {
+			case asJSON:
+				printErr = printJSONLine(newJSONEntry(md, fileExtras{}))
+			case print0:
+				_, printErr = fmt.Fprintf(os.Stdout, "%s\x00", metadataPath(md))
+			default:
+				_, printErr = fmt.Println(metadataPath(md))
+			}
+		}
+	})
+	if err == nil {
+		err = printErr
+	}
+	return
+}
+
+// findCmd represents the find command
+var findCmd = &cobra.Command{
+	Use:   "find [flags] [<path>]",
+	Short: "Find files and folders by name, size or time",
+	Long: `Find files and folders by name, size or time.
+
+Everything beneath <path>, or your whole Dropbox, is listed and the paths
+of the entries that pass every filter are printed, one per line, as they're
+found. With --json each is printed as a line of JSON instead.
+
+--name matches the entry's name (not its path) against a glob pattern,
+ignoring case. Sizes such as --min-size 10M are in bytes unless they end in
+K, M or G. --modified-after and --modified-before take a date such as
+2024-01-01, an RFC 3339 time, or an age such as 30d, and are compared with
+the time the file last changed on Dropbox. The size and time filters only
+ever match files.`,
+	Example: `  dbxcli find /reports --name '*.pdf'
+  dbxcli find /backups --type file --min-size 10M --modified-before 90d
+  dbxcli find /photos --modified-after 2024-01-01 --print0 | xargs -0 -n1 dbxcli rm`,
+	RunE: find,
+}
+
+func init() {
+	RootCmd.AddCommand(findCmd)
+	findCmd.Flags().String("name", "", "Only entries whose name matches this glob pattern")
+	findCmd.Flags().String("type", "", "Only entries of this type: file (f) or folder (d)")
+	findCmd.Flags().String("min-size", "", "Only files at least this large")
+	findCmd.Flags().String("max-size", "", "Only files at most this large")
+	findCmd.Flags().String("modified-after", "", "Only files changed after this date, time or age")
+	findCmd.Flags().String("modified-before", "", "Only files changed before this date, time or age")
+	findCmd.Flags().Bool("print0", false, "End each path with a NUL byte instead of a newline, for xargs -0")
+}
@@ -0,0 +1,52 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRelativeTime(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	tests := []struct {
+		in   string
+		dir  timeDirection
+		want time.Time
+	}{
+		{"2024-01-01", ago, time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"2024-01-01", fromNow, time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"2024-01-01T12:00:00Z", ago, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"30d", ago, now.Add(-30 * day)},
+		{"7d", fromNow, now.Add(7 * day)},
+		{"12h", fromNow, now.Add(12 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := parseRelativeTime(tt.in, tt.dir)
+		if err != nil {
+			t.Errorf("parseRelativeTime(%q, %d): %v", tt.in, tt.dir, err)
+			continue
+		}
+		if d := got.Sub(tt.want); d < -time.Minute || d > time.Minute {
+			t.Errorf("parseRelativeTime(%q, %d) = %v, want %v", tt.in, tt.dir, got, tt.want)
+		}
+	}
+
+	if _, err := parseRelativeTime("soon", fromNow); err == nil || !strings.Contains(err.Error(), `invalid time "soon"`) {
+		t.Errorf("parseRelativeTime(soon) = %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// How many files get downloads at once by default.
+const defaultDownloads = 4
+
+// The outcome of the download numbered `index` in a downloadAll.
+type downloadResult struct {
+	index int
+	err   error
+}
+
+func downloadParallelism(cmd *cobra.Command) (int, error) {
+	parallelism, _ := cmd.Flags().GetInt("parallel")
+	if parallelism < 0 {
+		return 0, fmt.Errorf("`--parallel` must be 0 or more, not %d", parallelism)
+	}
+	return parallelism, nil
+}
+
+// Runs `fetch` for each of `n` downloads with `parallelism` workers, or all at
+// once if it's 0, and returns the results as they finish, like uploadAll.
+// The downloads' progress is added up in `t`, which is drawn until the
+// channel is closed.
+func downloadAll(ctx context.Context, n int, parallelism int, t *transferTally, fetch func(ctx context.Context, i int) error) <-chan downloadResult {
+	if parallelism <= 0 || parallelism > n {
+		parallelism = n
+	}
+	ctx = withTally(ctx, t)
+	stop := t.draw()
+
+	queue := make(chan int)
+	results := make(chan downloadResult)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				err := fetch(ctx, i)
+				t.fileDone()
+				results <- downloadResult{i, err}
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			queue <- i
+		}
+		close(queue)
+	}()
+	go func() {
+		wg.Wait()
+		stop()
+		close(results)
+	}()
+	return results
+}
+
+// Calls `f` with each of `results` in the order the downloads were numbered,
+// holding back those that finish early, as printOrderedResults does. The
+// results are drained even if `f` fails, and its first error is returned.
+func inDownloadOrder(results <-chan downloadResult, f func(downloadResult) error) (err error) {
+	pending := make(map[int]downloadResult)
+	next := 0
+	for r := range results {
+		pending[r.index] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err == nil {
+				err = f(r)
+			}
+		}
+	}
+	return
+}
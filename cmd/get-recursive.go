@@ -0,0 +1,225 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Sorts a recursive listing so that folders come before what's in them.
+func sortByDepth(entries []files.IsMetadata) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := metadataPathLower(entries[i]), metadataPathLower(entries[j])
+		if da, db := strings.Count(a, "/"), strings.Count(b, "/"); da != db {
+			return da < db
+		}
+		return a < b
+	})
+}
+
+func metadataPathLower(md files.IsMetadata) string {
+	switch m := md.(type) {
+	case *files.FileMetadata:
+		return m.PathLower
+	case *files.FolderMetadata:
+		return m.PathLower
+	case *files.DeletedMetadata:
+		return m.PathLower
+	}
+	return ""
+}
+
+// Implements `get --recursive`: mirrors the remote folder `src` into the
+// local directory named by the second argument, or after the folder. Every
+// folder is created, empty ones included, and unless --no-dir-times is given
+// each local directory's modification time is set afterwards to the newest
+// server_modified time of anything inside it. Up to --parallel files are
+// downloaded at once.
+func getRecursive(cmd *cobra.Command, src string, args []string, dec decryptOptions) (err error) {
+	if src == "" {
+		return errors.New("`get --recursive` won't download your entire Dropbox; name a folder")
+	}
+	if len(args) == 2 && args[1] == stdoutDestination {
+		return errors.New("`get --recursive` can't write a folder to standard output")
+	}
+	dbx := newFilesClient(cmdCtx)
+	md, err := getFileMetadata(dbx, src)
+	if err != nil {
+		return
+	}
+	root, ok := md.(*files.FolderMetadata)
+	if !ok {
+		return fmt.Errorf("`get --recursive`: %s is not a folder", src)
+	}
+
+	var entries []files.IsMetadata
+	err = walkFolder(cmdCtx, dbx, src, "", 0, nil, func(page []files.IsMetadata) {
+		entries = append(entries, page...)
+	})
+	if err != nil {
+		return
+	}
+	sortByDepth(entries)
+
+	localRoot := path.Base(root.PathDisplay)
+	if len(args) == 2 {
+		localRoot = args[1]
+	}
+	filter, err := newPathFilter(cmd)
+	if err == nil {
+		filter, err = filter.withIgnoreFile(localRoot)
+	}
+	if err != nil {
+		return
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		p := metadataPath(entry)
+		_, isDir := entry.(*files.FolderMetadata)
+		if len(p) > len(root.PathDisplay) && filter.excluded(p[len(root.PathDisplay)+1:], isDir) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	entries = kept
+
+	m := newGetNameMapper(cmd)
+	var remotes []string
+	for _, entry := range entries {
+		p := metadataPath(entry)
+		if p == "" || metadataPathLower(entry) == root.PathLower {
+			continue
+		}
+		if _, isFile := entry.(*files.FileMetadata); isFile {
+			// Decrypted files lose their suffix, which may make them clash.
+			p = dec.localName(p)
+		}
+		remotes = append(remotes, p)
+	}
+	if err = m.check(remotes); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(localRoot, 0755); err != nil {
+		return
+	}
+	folders := folderMirror{root.PathLower: {local: localRoot}}
+
+	// Folders are made first, then the files are downloaded into them.
+	type fileJob struct {
+		entry *files.FileMetadata
+		local string
+	}
+	var jobs []fileJob
+	tally := &transferTally{label: "Downloading"}
+	for _, entry := range entries {
+		lower := metadataPathLower(entry)
+		parent, ok := folders[path.Dir(lower)]
+		if lower == root.PathLower || !ok {
+			continue
+		}
+
+		switch e := entry.(type) {
+		case *files.FolderMetadata:
+			if err = folders.add(lower, m.localPath(parent.local, e.PathDisplay)); err != nil {
+				return
+			}
+		case *files.FileMetadata:
+			jobs = append(jobs, fileJob{e, m.localPath(parent.local, dec.localName(e.PathDisplay))})
+			tally.files++
+			tally.size += int64(e.Size)
+		}
+	}
+
+	parallelism, err := downloadParallelism(cmd)
+	if err != nil {
+		return
+	}
+	var sizes []uint64
+	for _, job := range jobs {
+		sizes = append(sizes, job.entry.Size)
+	}
+	parallelism = tuneDownloads(cmd, sizes, parallelism)
+	results := downloadAll(cmdCtx, len(jobs), parallelism, tally, func(ctx context.Context, i int) error {
+		job := jobs[i]
+		res, contents, err := dbx.Download(files.NewDownloadArg(job.entry.PathLower))
+		if err != nil {
+			return err
+		}
+		err = writeDownload(ctx, job.local, contents, res.Size, dec)
+		contents.Close()
+		if err != nil {
+			return err
+		}
+		if verify, _ := cmd.Flags().GetBool("verify"); verify {
+			if err = verifyTransfer(ctx, job.local, job.entry.PathLower, res.Rev); err != nil {
+				return err
+			}
+		}
+		return preserveMtime(cmd, job.local, res.ClientModified)
+	})
+
+	downloaded, failed := 0, 0
+	err = inDownloadOrder(results, func(r downloadResult) error {
+		e, local := jobs[r.index].entry, jobs[r.index].local
+		if r.err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			failed++
+			if jsonMode(cmd) {
+				f := errorFields(r.err)
+				return printJSONLine(downloadRecord{Src: e.PathDisplay, Dst: local, Size: e.Size, Error: &f})
+			}
+			fmt.Fprintf(os.Stderr, "%s: download failed: %s\n", e.PathDisplay, explainError(r.err))
+			return nil
+		}
+		if err := printDownloaded(cmd, e.PathDisplay, local, e.Size); err != nil {
+			return err
+		}
+		downloaded++
+		folders.fileDownloaded(e.PathLower, e.ServerModified)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	mapFile, _ := cmd.Flags().GetString("name-map")
+	if err = m.writeRenames(mapFile); err != nil {
+		return
+	}
+
+	if noDirTimes, _ := cmd.Flags().GetBool("no-dir-times"); !noDirTimes {
+		if err = folders.setTimes(); err != nil {
+			return
+		}
+	}
+
+	printDownloadCounts(cmd, downloadCounts{Downloaded: downloaded, Failed: failed}, localRoot)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, downloaded+failed)
+	}
+	return
+}
@@ -0,0 +1,111 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Rejects --continue where there's no partial file it could pick up from.
+func checkContinue(cmd *cobra.Command, args []string, dec decryptOptions) error {
+	if cont, _ := cmd.Flags().GetBool("continue"); !cont {
+		return nil
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	zip, _ := cmd.Flags().GetBool("zip")
+	link, _ := cmd.Flags().GetString("link")
+	format, _ := cmd.Flags().GetString("format")
+	switch {
+	case dec.ids != nil:
+		return errors.New("`--continue` can't be combined with `--decrypt`")
+	case recursive || zip || link != "" || isSharedLink(args[0]) || format != "":
+		return errors.New("`--continue` only resumes downloads of single files from your Dropbox")
+	case len(args) > 1 && args[len(args)-1] == stdoutDestination:
+		return errors.New("`--continue` needs a local file to continue")
+	}
+	return nil
+}
+
+// Downloads `f` to `dst`, continuing from the end of what's already there
+// with a Range request. The download is pinned to the file's revision, and
+// the finished file is checked against `hash`, its content hash, since the
+// partial file may have come from a different version. A local file that's
+// already larger than `f` is downloaded again from the start.
+func resumeDownload(ctx context.Context, f *files.FileMetadata, hash string, dst string) error {
+	var offset int64
+	info, err := os.Stat(dst)
+	switch {
+	case err == nil && info.Mode().IsRegular() && uint64(info.Size()) <= f.Size:
+		offset = info.Size()
+	case err != nil && !os.IsNotExist(err):
+		return err
+	}
+
+	if uint64(offset) < f.Size {
+		header := http.Header{}
+		if offset > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := contentDownload(ctx, "files", "download", files.NewDownloadArg("rev:"+f.Rev), header)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK:
+			// The whole file came back after all.
+			flags, offset = os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0
+		default:
+			return fmt.Errorf("can't continue downloading %s: %s", f.PathDisplay, resp.Status)
+		}
+		out, err := os.OpenFile(dst, flags, 0666)
+		if err != nil {
+			return err
+		}
+		r := newProgressReader(ctx, resp.Body, int64(f.Size)-offset, "Downloading", dst)
+		n, err := io.Copy(out, r)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			logger.Error("download failed", "dst", dst, "error", err)
+			return err
+		}
+		logger.Info("download", "dst", dst, "bytes", n, "resumed_at", offset)
+	}
+
+	if hash == "" {
+		return nil
+	}
+	local, err := contentHash(dst)
+	if err != nil {
+		return err
+	}
+	if local != hash {
+		return fmt.Errorf("%s doesn't match %s after continuing the download, probably because the partial file was of another version; remove it and run get again", dst, f.PathDisplay)
+	}
+	return nil
+}
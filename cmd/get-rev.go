@@ -0,0 +1,66 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Rejects get --rev with flags that pick something other than a single file
+// of your own.
+func checkRev(cmd *cobra.Command, args []string) error {
+	for _, flag := range []string{"recursive", "zip", "continue", "verify"} {
+		if set, _ := cmd.Flags().GetBool(flag); set {
+			return errors.New("`--rev` can't be combined with `--" + flag + "`")
+		}
+	}
+	link, _ := cmd.Flags().GetString("link")
+	format, _ := cmd.Flags().GetString("format")
+	switch {
+	case link != "" || isSharedLink(args[0]):
+		return errors.New("`--rev` can't be used with shared links")
+	case format != "":
+		return errors.New("`--rev` can't be combined with `--format`")
+	case len(args) > 2 || hasGlob(args[0]):
+		return errors.New("`--rev` downloads a single file; give just its path")
+	}
+	return nil
+}
+
+// Downloads revision `rev` of the file `src`, which is saved under the file's
+// name as it would be by get, whether or not the file still exists.
+func getRevision(cmd *cobra.Command, src string, rev string, args []string, dec decryptOptions) (err error) {
+	dbx := newFilesClient(cmdCtx)
+	res, contents, err := dbx.Download(files.NewDownloadArg("rev:" + rev))
+	if err != nil {
+		return
+	}
+	defer contents.Close()
+
+	dst, err := localDestination(cmd, newGetNameMapper(cmd), dec.localName(src), args)
+	if err != nil {
+		return
+	}
+	if err = writeDownload(cmdCtx, dst, contents, res.Size, dec); err != nil {
+		return
+	}
+	if err = preserveMtime(cmd, dst, res.ClientModified); err != nil {
+		return
+	}
+	return printDownloaded(cmd, src, dst, res.Size)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/spf13/cobra"
+)
+
+type downloadZipArg struct {
+	Path string `json:"path"`
+}
+
+type downloadZipResult struct {
+	Metadata struct {
+		Name        string `json:"name"`
+		PathDisplay string `json:"path_display"`
+	} `json:"metadata"`
+}
+
+// Implements `get --zip`: downloads the remote folder `src` as one zip archive
+// made by Dropbox, named after the folder unless <target> says otherwise.
+func getZip(cmd *cobra.Command, src string, args []string) (err error) {
+	if src == "" {
+		return errors.New("`get --zip` won't download your entire Dropbox; name a folder")
+	}
+
+	resp, err := contentDownload(cmdCtx, "files", "download_zip", &downloadZipArg{src}, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var res downloadZipResult
+	if err = json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &res); err != nil {
+		return
+	}
+	remote := src
+	if res.Metadata.PathDisplay != "" {
+		remote = res.Metadata.PathDisplay
+	} else if res.Metadata.Name != "" {
+		remote = path.Join(path.Dir(src), res.Metadata.Name)
+	}
+
+	dst, err := localDestination(cmd, newGetNameMapper(cmd), remote+".zip", args)
+	if err != nil {
+		return
+	}
+	// The archive is made as it's sent, so its size isn't known up front.
+	if err = writeDownload(cmdCtx, dst, resp.Body, 0, decryptOptions{}); err != nil {
+		return
+	}
+	var size uint64
+	if info, err := os.Stat(dst); err == nil {
+		size = uint64(info.Size())
+	}
+	return printDownloaded(cmd, remote, dst, size)
+}
+
+// The part of a shared link's metadata that `get --zip` needs.
+type sharedLinkZipResult struct {
+	Tag  string `json:".tag"`
+	Name string `json:"name"`
+}
+
+// Implements `get --zip` for a folder link: Dropbox sends the shared folder,
+// or the folder `subpath` within it, as one zip archive, which is named after
+// the folder unless <target> says otherwise.
+func getSharedLinkZip(cmd *cobra.Command, url string, subpath string, args []string) (err error) {
+	arg := sharing.NewGetSharedLinkMetadataArg(strings.TrimSpace(url))
+	arg.Path = subpath
+	arg.LinkPassword, _ = cmd.Flags().GetString("password")
+
+	resp, err := contentDownload(cmdCtx, "sharing", "get_shared_link_file", arg, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var res sharedLinkZipResult
+	if err = json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &res); err != nil {
+		return
+	}
+	if res.Tag != "folder" {
+		return fmt.Errorf("`get --zip`: %s is a link to a file, not a folder; leave out `--zip`", arg.Url)
+	}
+
+	dst, err := localDestination(cmd, newGetNameMapper(cmd), res.Name+".zip", args)
+	if err != nil {
+		return
+	}
+	if err = writeDownload(cmdCtx, dst, resp.Body, 0, decryptOptions{}); err != nil {
+		return
+	}
+	var size uint64
+	if info, err := os.Stat(dst); err == nil {
+		size = uint64(info.Size())
+	}
+	return printDownloaded(cmd, arg.Url, dst, size)
+}
@@ -15,76 +15,683 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/dropbox/dbxcli/crypt"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
-	"github.com/dustin/go-humanize"
-	"github.com/mitchellh/ioprogress"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
 	"github.com/spf13/cobra"
 )
 
-func get(cmd *cobra.Command, args []string) (err error) {
-	if len(args) == 0 || len(args) > 2 {
-		return errors.New("`get` requires `src` and/or `dst` arguments")
+// Matches both the old (/s/..., /sh/...) and the newer (/scl/fi/..., /scl/fo/...)
+// forms of Dropbox shared link URLs.
+var sharedLinkRegexp = regexp.MustCompile(`^https?://(www\.)?dropbox\.com/(s|sh|scl/fi|scl/fo)/`)
+
+func isSharedLink(s string) bool {
+	return sharedLinkRegexp.MatchString(s)
+}
+
+func newGetNameMapper(cmd *cobra.Command) *nameMapper {
+	sanitize, _ := cmd.Flags().GetBool("sanitize-names")
+	replacement, _ := cmd.Flags().GetString("replacement")
+	return newNameMapper(sanitize, replacement)
+}
+
+// Resolves the local path the remote file `remote` should be written to,
+// honoring an optional `target` argument which may be a file or a directory.
+// Names derived from the remote file go through `m`; an explicit file target
+// is used as given.
+func localDestination(cmd *cobra.Command, m *nameMapper, remote string, args []string) (dst string, err error) {
+	dir := ""
+	if len(args) == 2 && args[1] == stdoutDestination {
+		return stdoutDestination, nil
+	}
+	if len(args) == 2 {
+		// If `dst` is a directory, append the source filename.
+		if f, err := os.Stat(args[1]); err != nil || !f.IsDir() {
+			return args[1], nil
+		}
+		dir = args[1]
+	}
+
+	if err = m.check([]string{remote}); err != nil {
+		return
 	}
+	dst = m.localPath(dir, remote)
 
-	src, err := validatePath(args[0])
+	mapFile, _ := cmd.Flags().GetString("name-map")
+	err = m.writeRenames(mapFile)
+	return
+}
+
+// Settings for decrypting downloads; `ids` is nil unless --decrypt is given.
+type decryptOptions struct {
+	ids    []crypt.Identity
+	suffix string
+}
+
+func newDecryptOptions(cmd *cobra.Command) (opts decryptOptions, err error) {
+	if decrypt, _ := cmd.Flags().GetBool("decrypt"); !decrypt {
+		return
+	}
+	opts.suffix, _ = cmd.Flags().GetString("encrypt-suffix")
+	opts.ids, err = decryptionIdentities(cmd)
+	return
+}
+
+// Returns the name to save `remote` under, which loses the encryption suffix
+// when it's decrypted.
+func (o decryptOptions) localName(remote string) string {
+	if o.ids != nil {
+		return strings.TrimSuffix(remote, o.suffix)
+	}
+	return remote
+}
+
+// The local destination that stands for standard output.
+const stdoutDestination = "-"
+
+// Writes a download to the local file `dst`, or to standard output if it's
+// stdoutDestination.
+func writeDownload(ctx context.Context, dst string, contents io.Reader, size uint64, dec decryptOptions) (err error) {
+	if dst == stdoutDestination {
+		r := newProgressReader(ctx, contents, int64(size), "Downloading", "to standard output")
+		if dec.ids != nil {
+			if r, err = crypt.NewReader(r, dec.ids...); err != nil {
+				return
+			}
+		}
+		_, err = io.Copy(os.Stdout, r)
+		return
+	}
+
+	f, err := os.Create(dst)
 	if err != nil {
 		return
 	}
+	defer f.Close()
 
-	// Default `dst` to the base segment of the source path; use the second argument if provided.
-	dst := path.Base(src)
-	if len(args) == 2 {
-		dst = args[1]
+	var r io.Reader = newProgressReader(ctx, contents, int64(size), "Downloading", dst)
+	if dec.ids != nil {
+		// Don't leave a partial file behind if the download turns out to
+		// have been tampered with.
+		defer func() {
+			if err != nil {
+				f.Close()
+				os.Remove(dst)
+			}
+		}()
+		if r, err = crypt.NewReader(r, dec.ids...); err != nil {
+			return
+		}
+	}
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		logger.Error("download failed", "dst", dst, "error", err)
+	} else {
+		logger.Info("download", "dst", dst, "bytes", n)
 	}
-	// If `dst` is a directory, append the source filename.
-	if f, err := os.Stat(dst); err == nil && f.IsDir() {
-		dst = path.Join(dst, path.Base(src))
+	return
+}
+
+// Downloads the file behind a shared link. For folder links, `subpath` selects
+// a file beneath the shared folder.
+func getSharedLink(cmd *cobra.Command, url string, subpath string, args []string, dec decryptOptions) (err error) {
+	arg := sharing.NewGetSharedLinkMetadataArg(strings.TrimSpace(url))
+	arg.Path = subpath
+	arg.LinkPassword, _ = cmd.Flags().GetString("password")
+
+	dbx := newSharingClient(cmdCtx)
+	meta, err := dbx.GetSharedLinkMetadata(arg)
+	if err != nil {
+		return
 	}
 
-	arg := files.NewDownloadArg(src)
+	var name string
+	var size uint64
+	var modified time.Time
+	switch m := meta.(type) {
+	case *sharing.FileLinkMetadata:
+		name, size, modified = m.Name, m.Size, m.ClientModified
+	case *sharing.FolderLinkMetadata:
+		return fmt.Errorf("`get`: %s is a folder link; download it as a zip archive with `get --zip`", arg.Url)
+	default:
+		return fmt.Errorf("`get`: unsupported shared link type for %s", arg.Url)
+	}
 
-	dbx := files.New(config)
-	res, contents, err := dbx.Download(arg)
+	dst, err := localDestination(cmd, newGetNameMapper(cmd), dec.localName(name), args)
+	if err != nil {
+		return
+	}
+
+	_, contents, err := dbx.GetSharedLinkFile(arg)
+	if err != nil {
+		return
+	}
 	defer contents.Close()
+
+	if err = writeDownload(cmdCtx, dst, contents, size, dec); err != nil {
+		return
+	}
+	if err = preserveMtime(cmd, dst, modified); err != nil {
+		return
+	}
+	return printDownloaded(cmd, arg.Url, dst, size)
+}
+
+type exportArg struct {
+	Path         string `json:"path"`
+	ExportFormat string `json:"export_format,omitempty"`
+}
+
+type exportResult struct {
+	ExportMetadata struct {
+		Name string `json:"name"`
+		Size uint64 `json:"size"`
+	} `json:"export_metadata"`
+}
+
+// Exports a cloud document as `format` and saves it under the name Dropbox
+// gives the export, which carries the format's extension.
+func exportFile(ctx context.Context, cmd *cobra.Command, src string, remote string, format string, x fileExtras, args []string, dec decryptOptions) (err error) {
+	exportFormat, err := x.exportFormat(remote, format)
 	if err != nil {
 		return
 	}
 
-	f, err := os.Create(dst)
-	defer f.Close()
+	resp, err := contentDownload(ctx, "files", "export", &exportArg{src, exportFormat}, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var res exportResult
+	if err = json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &res); err != nil {
+		return
+	}
+	name := remote
+	if res.ExportMetadata.Name != "" {
+		name = path.Join(path.Dir(remote), res.ExportMetadata.Name)
+	}
+
+	dst, err := localDestination(cmd, newGetNameMapper(cmd), name, args)
 	if err != nil {
 		return
 	}
+	if err = writeDownload(ctx, dst, resp.Body, res.ExportMetadata.Size, dec); err != nil {
+		return
+	}
+	return printDownloaded(cmd, remote, dst, res.ExportMetadata.Size)
+}
 
-	progressbar := &ioprogress.Reader{
-		Reader: contents,
-		DrawFunc: ioprogress.DrawTerminalf(os.Stderr, func(progress, total int64) string {
-			return fmt.Sprintf("Downloading %s/%s",
-				humanize.IBytes(uint64(progress)), humanize.IBytes(uint64(total)))
-		}),
-		Size: int64(res.Size),
+func get(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`get` requires `src` and/or `dst` arguments")
+	}
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		if len(args) > 1 {
+			return errors.New("`--output` can't be combined with a <target> argument")
+		}
+		args = append(args, output)
 	}
 
-	if _, err = io.Copy(f, progressbar); err != nil {
+	dec, err := newDecryptOptions(cmd)
+	if err != nil {
+		return
+	}
+	if err = checkContinue(cmd, args, dec); err != nil {
 		return
 	}
+	if err = checkVerify(cmd, args, dec); err != nil {
+		return
+	}
+	if rev, _ := cmd.Flags().GetString("rev"); rev != "" {
+		if err = checkRev(cmd, args); err != nil {
+			return
+		}
+		src, err := validatePath(args[0])
+		if err != nil {
+			return err
+		}
+		return getRevision(cmd, src, rev, args, dec)
+	}
 
+	// With more than two arguments, the last is the folder they all go in.
+	sources, target := args[:1], args[1:]
+	if len(args) > 2 {
+		sources, target = args[:len(args)-1], args[len(args)-1:]
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		link, _ := cmd.Flags().GetString("link")
+		zip, _ := cmd.Flags().GetBool("zip")
+		if recursive || zip || link != "" {
+			return errors.New("`get` takes a single <source> with `--recursive`, `--zip` or `--link`")
+		}
+		for _, src := range sources {
+			if isSharedLink(src) {
+				return errors.New("`get` takes a single <source> when it's a shared link")
+			}
+		}
+		if info, err := os.Stat(target[0]); err != nil || !info.IsDir() {
+			return fmt.Errorf("`get`: with several sources, <target> must be an existing folder")
+		}
+		args = []string{sources[0], target[0]}
+	}
+
+	if zip, _ := cmd.Flags().GetBool("zip"); zip {
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		link, _ := cmd.Flags().GetString("link")
+		format, _ := cmd.Flags().GetString("format")
+		switch {
+		case recursive || format != "" || dec.ids != nil:
+			return errors.New("`--zip` can't be combined with `--recursive`, `--format` or `--decrypt`")
+		case link != "":
+			subpath, err := validatePath(args[0])
+			if err != nil {
+				return err
+			}
+			return getSharedLinkZip(cmd, link, subpath, args)
+		case isSharedLink(args[0]):
+			return getSharedLinkZip(cmd, args[0], "", args)
+		}
+		src, err := validatePath(args[0])
+		if err != nil {
+			return err
+		}
+		return getZip(cmd, src, args)
+	}
+
+	if recursive, _ := cmd.Flags().GetBool("recursive"); recursive {
+		if link, _ := cmd.Flags().GetString("link"); link != "" || isSharedLink(args[0]) {
+			return errors.New("`--recursive` can't be used with shared links")
+		}
+		src, err := validatePath(args[0])
+		if err != nil {
+			return err
+		}
+		return getRecursive(cmd, src, args, dec)
+	}
+
+	if link, _ := cmd.Flags().GetString("link"); link != "" {
+		subpath, err := validatePath(args[0])
+		if err != nil {
+			return err
+		}
+		return getSharedLink(cmd, link, subpath, args, dec)
+	}
+
+	if isSharedLink(args[0]) {
+		return getSharedLink(cmd, args[0], "", args, dec)
+	}
+
+	parallelism, err := downloadParallelism(cmd)
+	if err != nil {
+		return
+	}
+
+	dbx := newFilesClient(cmdCtx)
+	var matches []remoteMatch
+	for _, src := range sources {
+		m, err := resolveArgs(cmd, dbx, src)
+		if err != nil {
+			return err
+		}
+		matches = append(matches, m...)
+	}
+	if len(sources) == 1 && len(matches) > 1 && len(target) == 1 {
+		if info, err := os.Stat(target[0]); err != nil || !info.IsDir() {
+			return fmt.Errorf("`get`: %s matches %d files, so <target> must be an existing folder", args[0], len(matches))
+		}
+	}
+
+	var paths []string
+	folders := 0
+	tally := &transferTally{label: "Downloading"}
+	for _, m := range matches {
+		if _, isFolder := m.md.(*files.FolderMetadata); isFolder && len(matches) > 1 {
+			if !jsonMode(cmd) {
+				fmt.Fprintf(os.Stderr, "get: skipping folder %s\n", m.path)
+			}
+			folders++
+			continue
+		}
+		if f, ok := m.md.(*files.FileMetadata); ok {
+			tally.size += int64(f.Size)
+		}
+		paths = append(paths, m.path)
+	}
+	if len(matches) <= 1 {
+		if len(paths) == 1 {
+			err = getFile(cmdCtx, cmd, paths[0], append(paths[:1:1], target...), dec)
+		}
+		return
+	}
+
+	if err = checkLocalDestinations(cmd, matches, target, dec); err != nil {
+		return
+	}
+
+	tally.files = int64(len(paths))
+	var sizes []uint64
+	for _, m := range matches {
+		if f, ok := m.md.(*files.FileMetadata); ok {
+			sizes = append(sizes, f.Size)
+		}
+	}
+	parallelism = tuneDownloads(cmd, sizes, parallelism)
+	results := downloadAll(cmdCtx, len(paths), parallelism, tally, func(ctx context.Context, i int) error {
+		return getFile(ctx, cmd, paths[i], append([]string{paths[i]}, target...), dec)
+	})
+	failed := 0
+	err = inDownloadOrder(results, func(r downloadResult) error {
+		if r.err == nil {
+			return nil
+		}
+		if cmdCtx.Err() != nil {
+			return cmdCtx.Err()
+		}
+		failed++
+		if jsonMode(cmd) {
+			f := errorFields(r.err)
+			return printJSONLine(downloadRecord{Src: paths[r.index], Error: &f})
+		}
+		fmt.Fprintf(os.Stderr, "%s: download failed: %s\n", paths[r.index], explainError(r.err))
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	printDownloadCounts(cmd, downloadCounts{Downloaded: len(paths) - failed, Failed: failed, SkippedFolders: folders}, "")
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(paths))
+	}
 	return
 }
 
+// The summary of a get of several files, as a line of JSON on stderr in JSON
+// mode.
+type downloadCounts struct {
+	Downloaded     int `json:"downloaded"`
+	Failed         int `json:"failed"`
+	SkippedFolders int `json:"skipped_folders"`
+}
+
+// Prints the summary of a get on stderr. A recursive get names the folder
+// it downloaded `into` instead of counting skipped folders.
+func printDownloadCounts(cmd *cobra.Command, counts downloadCounts, into string) {
+	if jsonMode(cmd) {
+		printJSONSummary(counts)
+		return
+	}
+	if into != "" {
+		fmt.Fprintf(os.Stderr, "Downloaded %d files into %s", counts.Downloaded, into)
+	} else {
+		fmt.Fprintf(os.Stderr, "Downloaded %d, skipped %d folders", counts.Downloaded, counts.SkippedFolders)
+	}
+	if counts.Failed > 0 {
+		fmt.Fprintf(os.Stderr, ", %d failed", counts.Failed)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// Fails if two of the files in `matches` would be saved to the same local
+// path, as files with the same name in different folders would be: they're
+// downloaded at once and would overwrite each other.
+func checkLocalDestinations(cmd *cobra.Command, matches []remoteMatch, target []string, dec decryptOptions) error {
+	dir := ""
+	if len(target) == 1 {
+		if target[0] == stdoutDestination {
+			return nil
+		}
+		dir = target[0]
+	}
+	seen := make(map[string]string)
+	for _, m := range matches {
+		f, ok := m.md.(*files.FileMetadata)
+		if !ok {
+			continue
+		}
+		remote := m.path
+		if f.PathDisplay != "" {
+			remote = f.PathDisplay
+		}
+		// Each download names its file with a mapper of its own.
+		dst := newGetNameMapper(cmd).localPath(dir, dec.localName(remote))
+		key := dst
+		if localNamesFoldCase {
+			key = strings.ToLower(dst)
+		}
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("`get`: %s and %s would both be saved as %s", other, remote, dst)
+		}
+		seen[key] = remote
+	}
+	return nil
+}
+
+// Downloads the single file `src`.
+func getFile(ctx context.Context, cmd *cobra.Command, src string, args []string, dec decryptOptions) (err error) {
+	md, x, _, err := getMetadataExtras(ctx, src)
+	if err != nil {
+		return
+	}
+	f, ok := md.(*files.FileMetadata)
+	if !ok {
+		return fmt.Errorf("`get`: %s is not a file", src)
+	}
+	// Name the local file after the remote one even when it's given by id.
+	remote := src
+	if f.PathDisplay != "" {
+		remote = f.PathDisplay
+	}
+
+	// Cloud documents can only be exported; check before the download fails
+	// with a less helpful error.
+	if format, _ := cmd.Flags().GetString("format"); format != "" {
+		return exportFile(ctx, cmd, src, remote, format, x, args, dec)
+	}
+	if !x.downloadable() {
+		format := "default"
+		if x.ExportInfo != nil && x.ExportInfo.ExportAs != "" {
+			format = x.ExportInfo.ExportAs
+		}
+		return fmt.Errorf("%s is a cloud document; use `get --format %s` to export it", remote, format)
+	}
+
+	dst, err := localDestination(cmd, newGetNameMapper(cmd), dec.localName(remote), args)
+	if err != nil {
+		return
+	}
+
+	if cont, _ := cmd.Flags().GetBool("continue"); cont {
+		if err = resumeDownload(ctx, f, x.ContentHash, dst); err != nil {
+			return
+		}
+		if err = preserveMtime(cmd, dst, f.ClientModified); err != nil {
+			return
+		}
+		return printDownloaded(cmd, remote, dst, f.Size)
+	}
+
+	arg := files.NewDownloadArg(src)
+
+	dbx := newFilesClient(ctx)
+	res, contents, err := dbx.Download(arg)
+	if err != nil {
+		return
+	}
+	defer contents.Close()
+
+	if err = writeDownload(ctx, dst, contents, res.Size, dec); err != nil {
+		return
+	}
+	if verify, _ := cmd.Flags().GetBool("verify"); verify {
+		if err = verifyTransfer(ctx, dst, src, res.Rev); err != nil {
+			return
+		}
+	}
+	if err = preserveMtime(cmd, dst, res.ClientModified); err != nil {
+		return
+	}
+	return printDownloaded(cmd, remote, dst, res.Size)
+}
+
+// Sets the modification time of the downloaded file `dst` to `modified`, the
+// time the file was last modified according to Dropbox, unless
+// --preserve-mtime=false was given.
+func preserveMtime(cmd *cobra.Command, dst string, modified time.Time) error {
+	if preserve, _ := cmd.Flags().GetBool("preserve-mtime"); !preserve || dst == stdoutDestination || modified.IsZero() {
+		return nil
+	}
+	return os.Chtimes(dst, modified, modified)
+}
+
+// One line of JSON output for a download.
+type downloadRecord struct {
+	Src   string           `json:"src"`
+	Dst   string           `json:"dst"`
+	Size  uint64           `json:"size"`
+	Error *jsonErrorFields `json:"error,omitempty"`
+}
+
+// Reports a finished download in JSON mode; otherwise get prints nothing
+// for each file.
+func printDownloaded(cmd *cobra.Command, src string, dst string, size uint64) error {
+	if !jsonMode(cmd) || dst == stdoutDestination {
+		return nil
+	}
+	return printJSONLine(downloadRecord{Src: src, Dst: dst, Size: size})
+}
+
 // getCmd represents the get command
 var getCmd = &cobra.Command{
-	Use:   "get [flags] <source> [<target>]",
+	Use:   "get [flags] <source>... [<target>]",
 	Short: "Download a file",
-	RunE:  get,
+	Long: `Download a file from your Dropbox, or from a Dropbox shared link.
+
+When <source> is a shared link (https://www.dropbox.com/s/... or
+https://www.dropbox.com/scl/fi/...) the file is saved under the name recorded
+in the link's metadata. To fetch a single file beneath a shared folder, pass
+the folder's link with --link and the file's path within the folder as
+<source>.
+
+Dropbox allows characters in names that some filesystems (notably Windows)
+don't. Such names, and names that only differ by case where the local
+filesystem ignores case (as on Windows and macOS), are rejected before
+anything is downloaded unless --sanitize-names is given, in which case
+they're replaced or numbered and each rename is appended to the --name-map
+file. The --replacement can't contain reserved characters itself.
+
+Cloud documents, such as Paper docs and Google files, can't be downloaded as
+they are. Use --format to export one instead: "default" picks the format
+Dropbox suggests, and "dbxcli stat" lists the others.
+
+--decrypt reverses "put --encrypt": the file is decrypted as it's downloaded
+and saved without its --encrypt-suffix. Every chunk is authenticated before
+it's written, and if the file turns out to be corrupt or tampered with the
+command fails and the partial download is removed. The keys tried are the
+secret keys in the file given with --identity or $DBXCLI_IDENTITY and the
+passphrase in $DBXCLI_PASSPHRASE.
+
+With --recursive, <source> is a folder that's downloaded with everything in
+it into the directory <target>, which defaults to the folder's name. Empty
+folders are created too, and once everything is downloaded each directory's
+modification time is set to that of the newest file inside it, unless
+--no-dir-times is given. Downloads slowed down by Dropbox are retried.
+--exclude and --include choose what's left out, as for "put --recursive",
+and so do the patterns in a .dbxignore file in <target>.
+
+<source> may be a glob pattern such as "/reports/2016-*.pdf" to download
+every file that matches into the folder <target>. Several sources can be
+given too, in which case the last argument is the folder they go in. Patterns are matched
+ignoring case, like Dropbox names. If nothing matches, or <source> doesn't
+exist, get fails unless --missing-ok is given, in which case it only notes
+the path as skipped.
+
+Downloaded files get the modification time recorded on Dropbox, which is the
+time they were last modified where they were uploaded from, unless
+--preserve-mtime=false is given.
+
+With --continue, an existing local file is taken to be an interrupted
+download of <source>, and only the rest of it is downloaded; the finished
+file is checked against the content hash Dropbox reports. It works for
+files in your Dropbox, but not with --recursive, --decrypt, --format or
+shared links.
+
+With --zip, <source> is a folder that Dropbox sends as a single zip archive,
+saved as <target> or after the folder with ".zip" added. This is much faster
+than --recursive for folders of many small files, but the folder must be
+under 20 GB with fewer than 10,000 files in it, and the archive can't be
+filtered. <source> may also be a folder link, or with --link a folder
+within one, which is saved after the shared folder's name.
+
+When there's more than one file to download, including with --recursive,
+up to --parallel of them are downloaded at once, and their progress is
+shown together as a single line. With --auto-tune, the number is chosen from
+the sizes of the files and the latency of the connection instead; use
+--verbose to see the choice.
+
+With --verify, the Dropbox content hash of each downloaded file is
+compared with that of the local copy, and the download fails if they
+differ. Downloads with --continue are always checked this way. It can't be
+used with --decrypt, --format, --zip, shared links or standard output.
+
+--rev downloads an earlier revision of <source>, as listed by "dbxcli revs",
+without restoring it on Dropbox. The file doesn't need to exist anymore.
+
+<target> may also be given with --output (-o).
+
+A <target> of "-" writes the file to standard output instead, like "dbxcli
+cat".`,
+	Example: `  dbxcli get /some-file.pdf
+  dbxcli get /some-file.pdf ./local-copy.pdf
+  dbxcli get /notes.txt --rev a1c10ce0dd78 -o notes.old.txt
+  dbxcli get /logs/app.log - | tail
+  dbxcli get id:a4ayc_80_OEAAAAAAAAAXw
+  dbxcli get --missing-ok '/exports/*.csv' ./exports
+  dbxcli get --recursive /Photos/2016 ./photos-2016
+  dbxcli get --zip /Photos/2016 ./photos-2016.zip
+  dbxcli get --zip 'https://www.dropbox.com/sh/abc123/xyz?dl=0'
+  dbxcli get --parallel 8 /a.iso /b.iso /c.iso ./isos
+  dbxcli get 'https://www.dropbox.com/s/abc123/report.pdf?dl=0' ./downloads
+  dbxcli get --password secret 'https://www.dropbox.com/scl/fi/xyz/notes.txt?rlkey=...'
+  dbxcli get --link 'https://www.dropbox.com/sh/abc123/xyz?dl=0' /some-subfolder/file.txt
+  dbxcli get --format default /notes.paper
+  dbxcli get --decrypt --identity ~/.config/dbxcli/key.txt /taxes.pdf.dbxenc`,
+	RunE: get,
 }
 
 func init() {
 	RootCmd.AddCommand(getCmd)
+	getCmd.Flags().String("preset", "", "Use the flag values of this preset from the config file")
+	getCmd.Flags().StringP("output", "o", "", "Save to this path, as if it were given as <target>")
+	getCmd.Flags().String("rev", "", "Download this revision of <source>")
+	getCmd.Flags().BoolP("recursive", "r", false, "Download a folder and everything in it")
+	getCmd.Flags().Bool("zip", false, "Download a folder as a zip archive")
+	getCmd.Flags().Int("parallel", defaultDownloads, "Number of files to download at once (0 means all of them)")
+	getCmd.Flags().Bool("auto-tune", false, "Pick --parallel from the files and the connection")
+	getCmd.Flags().Bool("verify", false, "Check each download's content hash against Dropbox's")
+	getCmd.Flags().BoolP("continue", "c", false, "Continue a partial download of <target> instead of starting over")
+	getCmd.Flags().Bool("preserve-mtime", true, "Set the files' modification times from Dropbox (use --preserve-mtime=false for the download time)")
+	addFilterFlags(getCmd)
+	getCmd.Flags().Bool("no-dir-times", false, "With --recursive, leave directory modification times alone")
+	getCmd.Flags().Bool("missing-ok", false, "Don't fail if <source> doesn't exist or matches nothing")
+	getCmd.Flags().String("link", "", "Download <source> from beneath this shared folder link")
+	getCmd.Flags().String("password", "", "Password for a protected shared link")
+	getCmd.Flags().Bool("sanitize-names", false, "Replace characters that aren't valid in local file names and resolve case collisions")
+	getCmd.Flags().String("replacement", "_", "Replacement for invalid characters with --sanitize-names")
+	getCmd.Flags().String("name-map", ".dbxcli-renames", "File that records names changed by --sanitize-names")
+	getCmd.Flags().String("format", "", "Export a cloud document in this format (\"default\" for its usual one)")
+	getCmd.Flags().Bool("decrypt", false, "Decrypt a file uploaded with put --encrypt")
+	getCmd.Flags().String("encrypt-suffix", defaultEncryptSuffix, "Suffix removed from the names of decrypted files")
+	getCmd.Flags().String("identity", "", "Key file to decrypt with (default $"+identityEnv+")")
 }
@@ -0,0 +1,192 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestCheckLocalDestinations(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		matches []remoteMatch
+		target  []string
+		err     string
+	}{
+		{
+			name:    "distinct names",
+			matches: []remoteMatch{{"/a/x.txt", fileMetadata("/a/x.txt", 1)}, {"/b/y.txt", fileMetadata("/b/y.txt", 1)}},
+			target:  []string{dir},
+		},
+		{
+			name:    "same name",
+			matches: []remoteMatch{{"/a/x.txt", fileMetadata("/a/x.txt", 1)}, {"/b/x.txt", fileMetadata("/b/x.txt", 1)}},
+			target:  []string{dir},
+			err:     "/a/x.txt and /b/x.txt would both be saved as " + filepath.Join(dir, "x.txt"),
+		},
+		{
+			name:    "by id",
+			matches: []remoteMatch{{"id:1", fileMetadata("/a/x.txt", 1)}, {"/b/x.txt", fileMetadata("/b/x.txt", 1)}},
+			err:     "/a/x.txt and /b/x.txt would both be saved as x.txt",
+		},
+		{
+			name:    "folders are skipped",
+			matches: []remoteMatch{{"/a/x.txt", fileMetadata("/a/x.txt", 1)}, {"/b/x.txt", folderMetadata("/b/x.txt")}},
+		},
+		{
+			name:    "standard output",
+			matches: []remoteMatch{{"/a/x.txt", fileMetadata("/a/x.txt", 1)}, {"/b/x.txt", fileMetadata("/b/x.txt", 1)}},
+			target:  []string{stdoutDestination},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLocalDestinations(getCmd, tt.matches, tt.target, decryptOptions{})
+			if tt.err == "" && err != nil {
+				t.Errorf("checkLocalDestinations: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("checkLocalDestinations = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// Downloads of an old revision are retried like any other download.
+func TestGetRevisionRetries(t *testing.T) {
+	api := useFakeAPI(t)
+	useRequestRetries(t)
+	api.Respond("files/download", http.StatusTooManyRequests, `{"error_summary": "too_many_requests/..", "error": {"reason": {".tag": "too_many_requests"}}}`)
+	api.RespondContent("files/download", `{".tag": "file", "name": "a.txt", "id": "id:a", "client_modified": "2020-01-02T03:04:05Z", "server_modified": "2020-01-02T03:04:05Z", "rev": "0123456789abc", "size": 5}`, []byte("hello"))
+
+	dst := filepath.Join(t.TempDir(), "a.txt")
+	_, _, err := testutil.Capture(func() error {
+		return getRevision(getCmd, "/a.txt", "0123456789abc", []string{"/a.txt", dst}, decryptOptions{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(dst); string(got) != "hello" {
+		t.Errorf("downloaded %q", got)
+	}
+	if routes := api.Routes(); len(routes) != 2 {
+		t.Errorf("sent %v", routes)
+	}
+}
+
+// A folder link is downloaded as the zip archive Dropbox makes of it.
+func TestGetSharedLinkZip(t *testing.T) {
+	api := useFakeAPI(t)
+	api.RespondContent("sharing/get_shared_link_file", `{".tag": "folder", "name": "Slides", "url": "https://www.dropbox.com/sh/abc/xyz"}`, []byte("PK"))
+	api.RespondContent("sharing/get_shared_link_file", `{".tag": "file", "name": "a.pdf", "url": "https://www.dropbox.com/s/abc/a.pdf"}`, []byte("%PDF"))
+	setFlags(t, getCmd, map[string]string{"zip": "true"})
+	dir := t.TempDir()
+
+	url := "https://www.dropbox.com/sh/abc/xyz?dl=0"
+	_, stderr, err := testutil.Capture(func() error { return get(getCmd, []string{url, dir}) })
+	if err != nil {
+		t.Fatalf("get = %v\n%s", err, stderr)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(dir, "Slides.zip")); string(got) != "PK" {
+		t.Errorf("downloaded %q", got)
+	}
+	if calls := api.Calls(); len(calls) != 1 || !strings.Contains(calls[0].Arg, `"url":"`+url+`"`) {
+		t.Errorf("calls = %+v", calls)
+	}
+
+	_, _, err = testutil.Capture(func() error {
+		return get(getCmd, []string{"https://www.dropbox.com/s/abc/a.pdf?dl=0", dir})
+	})
+	if err == nil || !strings.Contains(err.Error(), "is a link to a file") {
+		t.Errorf("get --zip of a file link = %v", err)
+	}
+}
+
+// A listing of /photos with empty folders at several depths.
+func scriptPhotosListing(fake *testutil.FakeFiles, modified map[string]time.Time) {
+	entries := []files.IsMetadata{
+		folderMetadata("/photos"),
+		folderMetadata("/photos/2019"),
+		folderMetadata("/photos/2019/empty"),
+		folderMetadata("/photos/2020"),
+		folderMetadata("/photos/2020/raw"),
+		folderMetadata("/photos/2020/raw/empty"),
+		folderMetadata("/photos/empty"),
+	}
+	for _, p := range []string{"/photos/notes.txt", "/photos/2019/a.jpg", "/photos/2020/raw/b.cr2"} {
+		md := fileMetadata(p, 2)
+		md.ServerModified = modified[p]
+		entries = append(entries, md)
+		fake.RespondContent("Download", md, []byte("hi"), nil)
+	}
+	fake.Respond("GetMetadata", folderMetadata("/photos"), nil)
+	fake.Respond("ListFolder", &files.ListFolderResult{Entries: entries}, nil)
+}
+
+func TestGetRecursiveMirrorsFolders(t *testing.T) {
+	modified := map[string]time.Time{
+		"/photos/notes.txt":      time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		"/photos/2019/a.jpg":     time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		"/photos/2020/raw/b.cr2": time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, noDirTimes := range []bool{false, true} {
+		fake := useFakeFiles(t)
+		scriptPhotosListing(fake, modified)
+		setFlags(t, getCmd, map[string]string{"recursive": "true", "parallel": "1"})
+		if noDirTimes {
+			setFlags(t, getCmd, map[string]string{"no-dir-times": "true"})
+		}
+		dst := filepath.Join(t.TempDir(), "photos")
+
+		if _, stderr, err := testutil.Capture(func() error {
+			return getRecursive(getCmd, "/photos", []string{"/photos", dst}, decryptOptions{})
+		}); err != nil {
+			t.Fatalf("get --recursive failed: %v\n%s", err, stderr)
+		}
+
+		for _, dir := range []string{"empty", "2019/empty", "2020/raw/empty"} {
+			if info, err := os.Stat(filepath.Join(dst, dir)); err != nil || !info.IsDir() {
+				t.Errorf("empty folder %s wasn't created: %v", dir, err)
+			}
+		}
+		for dir, want := range map[string]time.Time{
+			"":         modified["/photos/2020/raw/b.cr2"],
+			"2019":     modified["/photos/2019/a.jpg"],
+			"2020":     modified["/photos/2020/raw/b.cr2"],
+			"2020/raw": modified["/photos/2020/raw/b.cr2"],
+		} {
+			info, err := os.Stat(filepath.Join(dst, dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.ModTime(); got.Equal(want) == noDirTimes {
+				t.Errorf("with --no-dir-times=%v, %s was modified at %v; newest file is from %v", noDirTimes, filepath.Join(dst, dir), got, want)
+			}
+		}
+		if info, err := os.Stat(filepath.Join(dst, "2019/empty")); err == nil && info.ModTime().Before(modified["/photos/2020/raw/b.cr2"]) {
+			t.Errorf("the empty folder 2019/empty got the time %v", info.ModTime())
+		}
+	}
+}
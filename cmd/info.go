@@ -19,17 +19,20 @@ import (
 	"os"
 	"text/tabwriter"
 
-	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
 	"github.com/spf13/cobra"
 )
 
 func info(cmd *cobra.Command, args []string) (err error) {
-	dbx := team.New(config)
+	dbx := newTeamClient(cmdCtx)
 	res, err := dbx.GetInfo()
 	if err != nil {
 		return err
 	}
 
+	if jsonMode(cmd) {
+		return printJSON(res)
+	}
+
 	w := new(tabwriter.Writer)
 	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
 	fmt.Fprintf(w, "Name:\t%s\n", res.Name)
@@ -0,0 +1,82 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// A file or folder as printed by commands run with --json. Fields which don't
+// apply, such as the size of a folder, are left out.
+type jsonEntry struct {
+	Type           string     `json:"type"`
+	Name           string     `json:"name"`
+	Path           string     `json:"path"`
+	ID             string     `json:"id,omitempty"`
+	Size           *uint64    `json:"size,omitempty"`
+	Rev            string     `json:"rev,omitempty"`
+	ClientModified *time.Time `json:"client_modified,omitempty"`
+	ServerModified *time.Time `json:"server_modified,omitempty"`
+	ContentHash    string     `json:"content_hash,omitempty"`
+	Downloadable   *bool      `json:"downloadable,omitempty"`
+}
+
+// `x` holds the fields the SDK drops, if they were fetched.
+func newJSONEntry(md files.IsMetadata, x fileExtras) jsonEntry {
+	switch m := md.(type) {
+	case *files.FileMetadata:
+		downloadable := x.downloadable()
+		return jsonEntry{
+			Type:           "file",
+			Name:           m.Name,
+			Path:           m.PathDisplay,
+			ID:             m.Id,
+			Size:           &m.Size,
+			Rev:            m.Rev,
+			ClientModified: &m.ClientModified,
+			ServerModified: &m.ServerModified,
+			ContentHash:    x.ContentHash,
+			Downloadable:   &downloadable,
+		}
+	case *files.FolderMetadata:
+		return jsonEntry{Type: "folder", Name: m.Name, Path: m.PathDisplay, ID: m.Id}
+	case *files.DeletedMetadata:
+		return jsonEntry{Type: "deleted", Name: m.Name, Path: m.PathDisplay}
+	}
+	return jsonEntry{}
+}
+
+// Prints `v` to stdout as a single, indented JSON document.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Prints `v` to stdout as one line of JSON, for commands which print a
+// record per file as they go.
+func printJSONLine(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// Prints `v` to stderr as one line of JSON: the summary of a command run with
+// --json, which stays out of the records on stdout.
+func printJSONSummary(v interface{}) error {
+	return json.NewEncoder(os.Stderr).Encode(v)
+}
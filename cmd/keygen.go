@@ -0,0 +1,71 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dropbox/dbxcli/crypt"
+	"github.com/spf13/cobra"
+)
+
+func keygen(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`keygen` requires a `file` argument")
+	}
+
+	id, err := crypt.GenerateX25519Identity()
+	if err != nil {
+		return
+	}
+
+	// Never overwrite a key: files encrypted to it would be lost.
+	f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return
+	}
+	if _, err = id.WriteTo(f); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+
+	fmt.Println(id.Recipient())
+	return
+}
+
+// keygenCmd represents the keygen command
+var keygenCmd = &cobra.Command{
+	Use:   "keygen <file>",
+	Short: "Create a key for encrypted uploads",
+	Long: `Create a secret key for put --encrypt and get --decrypt and write it to
+<file>, which must not exist yet. The matching public key is printed.
+
+To let a machine upload encrypted files it can't read back, give it a key file
+holding just the public key.`,
+	Example: `  dbxcli keygen ~/.config/dbxcli/key.txt
+  dbxcli put --encrypt --identity ~/.config/dbxcli/key.txt taxes.pdf`,
+	// Creating a key doesn't need a Dropbox account.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE:              keygen,
+}
+
+func init() {
+	RootCmd.AddCommand(keygenCmd)
+}
@@ -23,14 +23,35 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Lists every group on the team, following the cursor until there are no
+// more.
+func allGroups(dbx team.Client) (res *team.GroupsListResult, err error) {
+	if res, err = dbx.GroupsList(team.NewGroupsListArg()); err != nil {
+		return
+	}
+	for cursor := res.Cursor; res.HasMore; cursor = res.Cursor {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		groups := res.Groups
+		if res, err = dbx.GroupsListContinue(team.NewGroupsListContinueArg(cursor)); err != nil {
+			return
+		}
+		res.Groups = append(groups, res.Groups...)
+	}
+	return
+}
+
 func listGroups(cmd *cobra.Command, args []string) (err error) {
-	dbx := team.New(config)
-	arg := team.NewGroupsListArg()
-	res, err := dbx.GroupsList(arg)
+	res, err := allGroups(newTeamClient(cmdCtx))
 	if err != nil {
 		return err
 	}
 
+	if jsonMode(cmd) {
+		return printJSON(res)
+	}
+
 	if len(res.Groups) == 0 {
 		return
 	}
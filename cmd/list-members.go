@@ -24,12 +24,26 @@ import (
 )
 
 func listMembers(cmd *cobra.Command, args []string) (err error) {
-	dbx := team.New(config)
+	dbx := newTeamClient(cmdCtx)
 	arg := team.NewMembersListArg()
 	res, err := dbx.MembersList(arg)
 	if err != nil {
 		return err
 	}
+	for cursor := res.Cursor; res.HasMore; cursor = res.Cursor {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		members := res.Members
+		if res, err = dbx.MembersListContinue(team.NewMembersListContinueArg(cursor)); err != nil {
+			return
+		}
+		res.Members = append(members, res.Members...)
+	}
+
+	if jsonMode(cmd) {
+		return printJSON(res)
+	}
 
 	if len(res.Members) == 0 {
 		return
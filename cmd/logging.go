@@ -0,0 +1,176 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/spf13/cobra"
+)
+
+// logger records what a command did to the --log-file, separately from what
+// it prints. Without --log-file it discards everything.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+var (
+	logOutput    *rotatingFile
+	commandStart time.Time
+)
+
+// rotatingFile is a log file that's rotated once it reaches maxSize: "x.log"
+// becomes "x.log.1", "x.log.1" becomes "x.log.2" and so on, keeping at most
+// `keep` old files. Every write goes straight to the file, so nothing is lost
+// if the process dies.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.keep > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.keep))
+		for i := r.keep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err = r.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = r.f.Write(p)
+	r.size += int64(n)
+	return
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	r.f.Sync()
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func initLogging(cmd *cobra.Command, args []string) (err error) {
+	commandStart = time.Now()
+	name, _ := cmd.Flags().GetString("log-file")
+	if name == "" {
+		return
+	}
+
+	var level slog.Level
+	levelName, _ := cmd.Flags().GetString("log-level")
+	if err = level.UnmarshalText([]byte(levelName)); err != nil {
+		return fmt.Errorf("invalid `--log-level` %q: use debug, info, warn or error", levelName)
+	}
+	maxSize, _ := cmd.Flags().GetInt64("log-max-size")
+	keep, _ := cmd.Flags().GetInt("log-keep")
+	if logOutput, err = openRotatingFile(name, maxSize<<20, keep); err != nil {
+		return
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch format, _ := cmd.Flags().GetString("log-format"); format {
+	case "text":
+		h = slog.NewTextHandler(logOutput, opts)
+	case "json":
+		h = slog.NewJSONHandler(logOutput, opts)
+	default:
+		return fmt.Errorf("invalid `--log-format` %q: use text or json", format)
+	}
+	logger = slog.New(h).With("pid", os.Getpid())
+
+	// Flags are left out since some hold passwords.
+	logger.Info("command started", "command", cmd.CommandPath(), "args", strings.Join(args, " "))
+	return
+}
+
+// Reports every retry the retry package makes under ctx.
+func withRetryLogging(ctx context.Context) context.Context {
+	return retry.WithNotify(ctx, func(attempt int, err error, wait time.Duration) {
+		logger.Warn("retrying", "attempt", attempt, "wait", wait, "error", err, "request_id", requestIDOf(err))
+	})
+}
+
+// Logs how the command ended and closes the log file. It's called on every
+// way out: a normal return, an error, a panic and a forced exit.
+func finishLogging(err error) {
+	if logOutput == nil {
+		return
+	}
+	elapsed := time.Since(commandStart).Round(time.Millisecond)
+	if err != nil {
+		logger.Error("command failed", "duration", elapsed, "error", err, "request_id", requestIDOf(err))
+	} else {
+		logger.Info("command finished", "duration", elapsed)
+	}
+	logOutput.Close()
+}
@@ -16,37 +16,50 @@ package cmd
 
 import (
 	"os"
-	"path"
+	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/auth"
-	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 )
 
-// Command logout revokes all saved API tokens and deletes auth.json.
+// Revokes every token in `tokMap`. Revoking an access token revokes its
+// refresh token too, but it has to be one that hasn't expired.
+func revokeTokens(tokMap TokenMap) {
+	for domain, tokens := range tokMap {
+		for tokType, token := range tokens {
+			if token == nil {
+				continue
+			}
+			if token.stale(time.Now()) {
+				fresh, err := refreshToken(oauthConfig(tokType, domain), token)
+				if err != nil {
+					continue
+				}
+				token = fresh
+			}
+			config := dropbox.Config{Token: token.AccessToken, Domain: domain}
+			client := auth.New(config)
+			client.TokenRevoke()
+		}
+	}
+}
+
+// Command logout revokes all saved API tokens of the current profile and
+// deletes its auth file.
 func logout(cmd *cobra.Command, args []string) error {
-	dir, err := homedir.Dir()
+	profile, _ := cmd.Flags().GetString("profile")
+	filePath, err := authFilePath(profile)
 	if err != nil {
 		return err
 	}
-	filePath := path.Join(dir, ".config", "dbxcli", configFileName)
 
 	tokMap, err := readTokens(filePath)
 	if err != nil {
 		return err
 	}
-
-	for domain, tokens := range tokMap {
-		for _, token := range tokens {
-			config := dropbox.Config{token, false, "", domain}
-			client := auth.New(config)
-			client.TokenRevoke()
-			if err != nil {
-				return err
-			}
-		}
-	}
+	revokeTokens(tokMap)
+	forgetKeyringTokens(filePath, tokMap)
 
 	err = os.Remove(filePath)
 	if err != nil {
@@ -15,16 +15,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/dustin/go-humanize"
 	"github.com/grantseltzer/golumns"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // Sends a get_metadata request for a given path and returns the response
@@ -39,83 +44,339 @@ func getFileMetadata(c files.Client, path string) (files.IsMetadata, error) {
 	return res, nil
 }
 
-func printFolderMetadata(w io.Writer, e *files.FolderMetadata, longFormat bool) {
-	if longFormat {
-		fmt.Fprintf(w, "-\t-\t-\t")
+// Whether `ls -l` shows an entry as shared: "shared", "read-only" for shared
+// entries you can't change, or "-".
+func sharedStatus(entry files.IsMetadata) string {
+	var info *files.SharingInfo
+	switch e := entry.(type) {
+	case *files.FileMetadata:
+		if e.SharingInfo != nil {
+			info = &e.SharingInfo.SharingInfo
+		} else if e.HasExplicitSharedMembers {
+			return "shared"
+		}
+	case *files.FolderMetadata:
+		if e.SharingInfo != nil {
+			info = &e.SharingInfo.SharingInfo
+		} else if e.SharedFolderId != "" {
+			return "shared"
+		}
 	}
-	fmt.Fprintf(w, "%s\n", e.Name)
+	switch {
+	case info == nil:
+		return "-"
+	case info.ReadOnly:
+		return "read-only"
+	}
+	return "shared"
 }
 
-func printFileMetadata(w io.Writer, e *files.FileMetadata, longFormat bool) {
-	if longFormat {
-		fmt.Fprintf(w, "%s\t%s\t%s\t", e.Rev, humanize.IBytes(e.Size), humanize.Time(e.ServerModified))
+// Prints a row of `ls -l`, whose columns are described by lsLongHeader.
+func printLongEntry(w io.Writer, entry files.IsMetadata, x fileExtras) {
+	switch e := entry.(type) {
+	case *files.FileMetadata:
+		hash := x.ContentHash
+		if hash == "" {
+			hash = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s%s\n", e.Rev, humanize.IBytes(e.Size), humanize.Time(e.ServerModified),
+			hash, sharedStatus(e), e.Name, x.marker())
+	case *files.FolderMetadata:
+		fmt.Fprintf(w, "-\t-\t-\t-\t%s\t%s\n", sharedStatus(e), e.Name)
+	case *files.DeletedMetadata:
+		fmt.Fprintf(w, "-\t-\t-\t-\t-\t%s%s\n", e.Name, deletedMarker)
 	}
-	fmt.Fprintf(w, "%s\n", e.Name)
 }
 
-func ls(cmd *cobra.Command, args []string) (err error) {
-	path := ""
-	if len(args) > 0 {
-		if path, err = validatePath(args[0]); err != nil {
-			return err
-		}
+// Flags deleted entries listed by `ls --deleted`.
+const deletedMarker = " (deleted)"
+
+const lsLongHeader = "Revision\tSize\tLast modified\tContent hash\tShared\tPath\n"
+
+// Orders of `ls --sort`. As in GNU ls, the largest and newest come first.
+var lsSortKeys = map[string]func(a, b files.IsMetadata) bool{
+	"name": func(a, b files.IsMetadata) bool {
+		return entryName(a) < entryName(b)
+	},
+	"size": func(a, b files.IsMetadata) bool {
+		return entrySize(a) > entrySize(b)
+	},
+	"time": func(a, b files.IsMetadata) bool {
+		return entryTime(a).After(entryTime(b))
+	},
+}
+
+func entryName(entry files.IsMetadata) string {
+	switch e := entry.(type) {
+	case *files.FileMetadata:
+		return e.Name
+	case *files.FolderMetadata:
+		return e.Name
+	case *files.DeletedMetadata:
+		return e.Name
+	}
+	return ""
+}
+
+// Folders have no size or modification time of their own.
+func entrySize(entry files.IsMetadata) uint64 {
+	if f, ok := entry.(*files.FileMetadata); ok {
+		return f.Size
+	}
+	return 0
+}
+
+func entryTime(entry files.IsMetadata) time.Time {
+	if f, ok := entry.(*files.FileMetadata); ok {
+		return f.ServerModified
 	}
-	dbx := files.New(config)
+	return time.Time{}
+}
 
+// Sorts `entries` by `key`, one of lsSortKeys, breaking ties by name.
+func sortEntries(entries []files.IsMetadata, key string, reverse bool) error {
+	less, ok := lsSortKeys[key]
+	if !ok {
+		return fmt.Errorf("invalid `--sort` %q: use name, size or time", key)
+	}
+	byName := lsSortKeys["name"]
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if reverse {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		return !less(b, a) && byName(a, b)
+	})
+	return nil
+}
+
+// Lists the contents of `path`, following cursors until all entries have
+// been fetched.
+func listFolder(ctx context.Context, dbx files.Client, path string) (entries []files.IsMetadata, err error) {
 	arg := files.NewListFolderArg(path)
 
 	res, err := dbx.ListFolder(arg)
-	var entries []files.IsMetadata
 	if err != nil {
 		switch e := err.(type) {
 		case files.ListFolderAPIError:
 			// Don't treat a "not_folder" error as fatal; recover by sending a
 			// get_metadata request for the same path and using that response instead.
-			if e.EndpointError.Path.Tag == files.LookupErrorNotFolder {
+			if e.EndpointError.Path != nil && e.EndpointError.Path.Tag == files.LookupErrorNotFolder {
 				var metaRes files.IsMetadata
 				metaRes, err = getFileMetadata(dbx, path)
 				entries = []files.IsMetadata{metaRes}
-			} else {
-				return err
 			}
-		default:
-			return err
 		}
 
 		// Return if there's an error other than "not_folder" or if the follow-up
 		// metadata request fails.
+		return
+	}
+
+	return listFolderContinue(ctx, dbx, res)
+}
+
+func listFolderContinue(ctx context.Context, dbx files.Client, res *files.ListFolderResult) (entries []files.IsMetadata, err error) {
+	entries = res.Entries
+
+	for res.HasMore {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		arg := files.NewListFolderContinueArg(res.Cursor)
+
+		res, err = dbx.ListFolderContinue(arg)
 		if err != nil {
-			return err
+			return
 		}
-	} else {
-		entries = res.Entries
 
-		for res.HasMore {
-			arg := files.NewListFolderContinueArg(res.Cursor)
+		entries = append(entries, res.Entries...)
+	}
+
+	return
+}
 
-			res, err = dbx.ListFolderContinue(arg)
-			if err != nil {
-				return err
+// Renames the entries of a recursive listing of `root` after their paths
+// beneath it, so `ls -R` shows where each one is. The entry for the folder
+// itself is dropped.
+func relativeEntries(entries []files.IsMetadata, root string) (rel []files.IsMetadata) {
+	prefix := strings.ToLower(root) + "/"
+	for _, entry := range entries {
+		lower, display := metadataPathLower(entry), metadataPath(entry)
+		if !strings.HasPrefix(lower, prefix) {
+			if _, isFile := entry.(*files.FileMetadata); isFile {
+				rel = append(rel, entry)
 			}
+			continue
+		}
+		name := lower[len(prefix):]
+		if len(display) == len(lower) {
+			name = display[len(prefix):]
+		}
+		switch e := entry.(type) {
+		case *files.FileMetadata:
+			f := *e
+			f.Name = name
+			rel = append(rel, &f)
+		case *files.FolderMetadata:
+			f := *e
+			f.Name = name
+			rel = append(rel, &f)
+		case *files.DeletedMetadata:
+			d := *e
+			d.Name = name
+			rel = append(rel, &d)
+		}
+	}
+	return
+}
+
+type sharedLinkArg struct {
+	Url      string `json:"url"`
+	Password string `json:"password,omitempty"`
+}
 
-			entries = append(entries, res.Entries...)
+// The vendored SDK's ListFolderArg predates the `shared_link` field.
+type listFolderSharedLinkArg struct {
+	files.ListFolderArg
+	SharedLink *sharedLinkArg `json:"shared_link"`
+}
+
+// Lists the contents of `path` relative to the root of the folder behind a
+// shared link, without requiring the folder to be mounted.
+func listSharedLink(ctx context.Context, dbx files.Client, url string, password string, path string) (entries []files.IsMetadata, err error) {
+	arg := &listFolderSharedLinkArg{
+		ListFolderArg: *files.NewListFolderArg(path),
+		SharedLink:    &sharedLinkArg{Url: url, Password: password},
+	}
+
+	var res files.ListFolderResult
+	if err = rpc(ctx, "files", "list_folder", arg, &res); err != nil {
+		if e, ok := err.(rpcError); ok {
+			return nil, fmt.Errorf("cannot list shared link %s: it is invalid, has expired or needs a `--password` (%s)", url, e.ErrorSummary)
 		}
+		return
 	}
 
+	return listFolderContinue(ctx, dbx, &res)
+}
+
+func ls(cmd *cobra.Command, args []string) (err error) {
+	path := ""
+	if len(args) > 0 {
+		if path, err = validatePath(args[0]); err != nil {
+			return err
+		}
+	}
+	dbx := newFilesClient(cmdCtx)
+	link, _ := cmd.Flags().GetString("link")
+
+	// A glob lists what it matches rather than the contents of folders.
+	var matched []files.IsMetadata
+	if len(args) > 0 && link == "" {
+		matches, err := resolveArgs(cmd, dbx, args[0])
+		if err != nil || len(matches) == 0 {
+			return err
+		}
+		if len(matches) > 1 || !strings.EqualFold(matches[0].path, path) {
+			for _, m := range matches {
+				matched = append(matched, m.md)
+			}
+		}
+	}
+
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	deleted, _ := cmd.Flags().GetBool("deleted")
+	if (recursive || deleted) && link != "" {
+		return errors.New("`ls --recursive` and `--deleted` can't list shared links")
+	}
 	long, _ := cmd.Flags().GetBool("long")
+	owners, _ := cmd.Flags().GetBool("owners")
+	long = long || owners
+	asJSON := jsonMode(cmd)
+	var entries []files.IsMetadata
+	var extras map[string]fileExtras
+	if link != "" {
+		password, _ := cmd.Flags().GetString("password")
+		entries, err = listSharedLink(cmdCtx, dbx, link, password, path)
+	} else if matched != nil {
+		entries = matched
+	} else if long || asJSON || recursive || deleted {
+		// The long listing flags files that can't be downloaded.
+		arg := files.NewListFolderArg(path)
+		arg.Recursive, arg.IncludeDeleted = recursive, deleted
+		entries, extras, err = listFolderArgExtras(cmdCtx, arg)
+	} else {
+		entries, err = listFolder(cmdCtx, dbx, path)
+	}
+	if err != nil {
+		return err
+	}
+	if recursive && matched == nil {
+		entries = relativeEntries(entries, path)
+	}
+	sortKey, _ := cmd.Flags().GetString("sort")
+	reverse, _ := cmd.Flags().GetBool("reverse")
+	if err = sortEntries(entries, sortKey, reverse); err != nil {
+		return err
+	}
+
+	if asJSON {
+		out := make([]jsonEntry, len(entries))
+		for i, entry := range entries {
+			var x fileExtras
+			if f, ok := entry.(*files.FileMetadata); ok {
+				x = extras[f.PathLower]
+			}
+			out[i] = newJSONEntry(entry, x)
+			// Recursive listings are renamed after their relative paths.
+			if p := metadataPath(entry); p != "" {
+				out[i].Name = p[strings.LastIndex(p, "/")+1:]
+			}
+		}
+		return printJSON(out)
+	}
+
 	if long {
+		var names *accountNames
+		if owners {
+			names = newAccountNames(newUsersClient(cmdCtx))
+			ids := make([]string, len(entries))
+			for i, entry := range entries {
+				ids[i] = modifiedBy(entry)
+			}
+			if err = names.resolve(ids); err != nil {
+				return err
+			}
+		}
+
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 4, 8, 1, ' ', 0)
-		fmt.Fprintf(w, "Revision\tSize\tLast modified\tPath\n")
+		if owners {
+			fmt.Fprintf(w, "Modified by\t")
+		}
+		fmt.Fprint(w, lsLongHeader)
 		for _, entry := range entries {
-			switch f := entry.(type) {
-			case *files.FileMetadata:
-				printFileMetadata(w, f, long)
-			case *files.FolderMetadata:
-				printFolderMetadata(w, f, long)
+			if owners {
+				fmt.Fprintf(w, "%s\t", names.name(modifiedBy(entry)))
+			}
+			var x fileExtras
+			if f, ok := entry.(*files.FileMetadata); ok {
+				x = extras[f.PathLower]
 			}
+			printLongEntry(w, entry, x)
 		}
 		w.Flush()
+	} else if recursive || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		// Paths don't suit columns, and neither do pipes: like ls(1), print
+		// one name per line when the output isn't a terminal.
+		for _, name := range listOfEntryNames(entries) {
+			fmt.Println(name)
+		}
 	} else {
 		entryNames := listOfEntryNames(entries)
 		golumns.Display(entryNames)
@@ -124,6 +385,7 @@ func ls(cmd *cobra.Command, args []string) (err error) {
 	return err
 }
 
+// Returns the names of `entries` in the order they're in.
 func listOfEntryNames(entries []files.IsMetadata) []string {
 	listOfEntryNames := []string{}
 
@@ -133,10 +395,11 @@ func listOfEntryNames(entries []files.IsMetadata) []string {
 			listOfEntryNames = append(listOfEntryNames, entry.(*files.FolderMetadata).Name)
 		case *files.FileMetadata:
 			listOfEntryNames = append(listOfEntryNames, entry.(*files.FileMetadata).Name)
+		case *files.DeletedMetadata:
+			listOfEntryNames = append(listOfEntryNames, entry.(*files.DeletedMetadata).Name+deletedMarker)
 		}
 	}
 
-	sort.Strings(listOfEntryNames)
 	return listOfEntryNames
 }
 
@@ -144,10 +407,29 @@ func listOfEntryNames(entries []files.IsMetadata) []string {
 var lsCmd = &cobra.Command{
 	Use:   "ls [flags] [<path>]",
 	Short: "List files and folders",
+	Long: `List files and folders.
+
+With --recursive, everything beneath <path> is listed, each under its path
+relative to <path>. Listings are fetched a page at a time until Dropbox says
+there's no more, so folders of any size are listed in full.
+
+The long listing (-l) shows each file's revision, size, when it was last
+changed on Dropbox, its content hash (see "dbxcli put --skip-existing") and
+whether it's shared, and "read-only" if it's shared without edit access.
+Entries are sorted by name, or with --sort by size or time, largest and
+newest first; --reverse reverses the order.
+
+--deleted lists deleted files and folders too, marked "(deleted)"; "dbxcli
+undelete" brings deleted files back.`,
 	Example: `  dbxcli ls / # Or just 'ls'
   dbxcli ls /some-folder # Or 'ls some-folder'
   dbxcli ls /some-folder/some-file.pdf
-  dbxcli ls -l`,
+  dbxcli ls -l
+  dbxcli ls -R /some-folder
+  dbxcli ls -l --sort size --reverse /some-folder
+  dbxcli ls --owners /team-folder
+  dbxcli ls -l '/some-folder/*.pdf'
+  dbxcli ls --link 'https://www.dropbox.com/sh/abc123/xyz?dl=0' /some-subfolder`,
 	RunE: ls,
 }
 
@@ -155,4 +437,12 @@ func init() {
 	RootCmd.AddCommand(lsCmd)
 
 	lsCmd.Flags().BoolP("long", "l", false, "Long listing")
+	lsCmd.Flags().BoolP("recursive", "R", false, "List everything beneath <path>")
+	lsCmd.Flags().Bool("deleted", false, "Include deleted files and folders")
+	lsCmd.Flags().String("sort", "name", "Sort by name, size or time")
+	lsCmd.Flags().BoolP("reverse", "r", false, "Reverse the sort order")
+	lsCmd.Flags().Bool("owners", false, "Long listing which also shows who last modified files in shared folders")
+	lsCmd.Flags().String("link", "", "List the contents of a shared link instead of your Dropbox")
+	lsCmd.Flags().Bool("missing-ok", false, "Don't fail if <path> doesn't exist or matches nothing")
+	lsCmd.Flags().String("password", "", "Password for a protected shared link")
 }
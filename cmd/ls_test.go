@@ -0,0 +1,53 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestLsPagination(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("ListFolder", &files.ListFolderResult{
+		Entries: []files.IsMetadata{fileMetadata("/docs/a.txt", 1), folderMetadata("/docs/b")},
+		Cursor:  "c1",
+		HasMore: true,
+	}, nil)
+	fake.Respond("ListFolderContinue", &files.ListFolderResult{
+		Entries: []files.IsMetadata{fileMetadata("/docs/c.txt", 1)},
+		Cursor:  "c2",
+	}, nil)
+
+	stdout, _, err := testutil.Capture(func() error {
+		return ls(lsCmd, []string{"/docs"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fake.Methods(), []string{"ListFolder", "ListFolderContinue"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if cont := fake.Calls()[1].Arg.(*files.ListFolderContinueArg); cont.Cursor != "c1" {
+		t.Errorf("continued from cursor %q, want c1", cont.Cursor)
+	}
+	if got := strings.Fields(stdout); !reflect.DeepEqual(got, []string{"a.txt", "b", "c.txt"}) {
+		t.Errorf("printed %q", stdout)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestMain(m *testing.M) {
+	// Keep settings, sessions and tokens out of the real home directory.
+	home, err := ioutil.TempDir("", "dbxcli-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("USERPROFILE", home)
+	homedir.DisableCache = true
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// instantClock lets backoffs and polling run without waiting.
+type instantClock struct{}
+
+func (instantClock) Now() time.Time { return time.Now() }
+
+func (instantClock) After(d time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+// Runs commands under a context whose backoffs don't wait, until the test
+// ends.
+func useInstantRetries(t *testing.T) {
+	saved := cmdCtx
+	cmdCtx = retry.WithClock(context.Background(), instantClock{})
+	t.Cleanup(func() { cmdCtx = saved })
+}
+
+// Makes commands use a scripted files client until the test ends.
+func useFakeFiles(t *testing.T) *testutil.FakeFiles {
+	fake := testutil.NewFakeFiles()
+	saved := newFilesClient
+	newFilesClient = func(context.Context) files.Client { return fake }
+	t.Cleanup(func() { newFilesClient = saved })
+	useInstantRetries(t)
+	return fake
+}
+
+// Answers the requests commands send with rpc and friends from a script
+// until the test ends.
+func useFakeAPI(t *testing.T) *testutil.FakeAPI {
+	fake := testutil.NewFakeAPI()
+	saved, savedConfig := http.DefaultTransport, config
+	http.DefaultTransport = fake
+	config.Token = "test-token"
+	t.Cleanup(func() { http.DefaultTransport, config = saved, savedConfig })
+	useInstantRetries(t)
+	return fake
+}
+
+// Sets flags of `cmd` for the rest of the test. Slice flags can't be reset
+// afterwards, so they aren't supported.
+func setFlags(t *testing.T, cmd *cobra.Command, flags map[string]string) {
+	// Cobra only adds the root command's flags, like --json, to cmd's once
+	// they're asked for.
+	cmd.InheritedFlags()
+	for name, value := range flags {
+		f := cmd.Flags().Lookup(name)
+		if f == nil {
+			t.Fatalf("%s has no flag --%s", cmd.Name(), name)
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resetFlag(f) })
+	}
+}
+
+// Makes `input` the standard input of commands until the test ends.
+func useStdin(t *testing.T, input string) {
+	f, err := ioutil.TempFile(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString(input); err == nil {
+		_, err = f.Seek(0, 0)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, savedLines := os.Stdin, stdinLines
+	os.Stdin, stdinLines = f, bufio.NewReader(f)
+	t.Cleanup(func() {
+		os.Stdin, stdinLines = saved, savedLines
+		f.Close()
+	})
+}
+
+func resetFlag(f *pflag.Flag) {
+	f.Value.Set(f.DefValue)
+	f.Changed = false
+}
+
+func fileMetadata(p string, size uint64) *files.FileMetadata {
+	md := files.NewFileMetadata(path.Base(p), "id:"+p, time.Unix(0, 0).UTC(), time.Unix(0, 0).UTC(), "015", size)
+	md.PathDisplay = p
+	md.PathLower = strings.ToLower(p)
+	return md
+}
+
+func folderMetadata(p string) *files.FolderMetadata {
+	md := files.NewFolderMetadata(path.Base(p), "id:"+p)
+	md.PathDisplay = p
+	md.PathLower = strings.ToLower(p)
+	return md
+}
+
+// The error the SDK returns for a path that doesn't exist.
+func notFoundError() error {
+	return files.GetMetadataAPIError{
+		APIError:      dropbox.APIError{ErrorSummary: "path/not_found/"},
+		EndpointError: &files.GetMetadataError{Tagged: dropbox.Tagged{Tag: "path"}, Path: &files.LookupError{Tagged: dropbox.Tagged{Tag: files.LookupErrorNotFound}}},
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// A remote path named on the command line, after glob expansion. `md` is
+// set if the path was looked up along the way.
+type remoteMatch struct {
+	path string
+	md   files.IsMetadata
+}
+
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// Reports whether err means a path doesn't exist.
+func isMissing(err error) bool {
+	summary, ok := apiErrorSummary(err)
+	if !ok {
+		return false
+	}
+	for _, tag := range strings.Split(summary, "/") {
+		if tag == "not_found" {
+			return true
+		}
+	}
+	return false
+}
+
+func metadataPath(md files.IsMetadata) string {
+	switch m := md.(type) {
+	case *files.FileMetadata:
+		return m.PathDisplay
+	case *files.FolderMetadata:
+		return m.PathDisplay
+	case *files.DeletedMetadata:
+		return m.PathDisplay
+	}
+	return ""
+}
+
+// Expands the glob patterns in the segments of `pattern`, matching names the
+// way Dropbox compares them, ignoring case. Folders which don't exist or
+// turn out to be files simply match nothing.
+func globRemote(ctx context.Context, dbx files.Client, pattern string) ([]remoteMatch, error) {
+	matches := []remoteMatch{{path: ""}}
+	segments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		var next []remoteMatch
+		for _, m := range matches {
+			if !hasGlob(segment) {
+				next = append(next, remoteMatch{path: m.path + "/" + segment})
+				continue
+			}
+
+			res, err := dbx.ListFolder(files.NewListFolderArg(m.path))
+			if e, ok := err.(files.ListFolderAPIError); ok && e.EndpointError != nil && e.EndpointError.Path != nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries, err := listFolderContinue(ctx, dbx, res)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				p := metadataPath(entry)
+				if p == "" {
+					continue
+				}
+				matched, err := path.Match(strings.ToLower(segment), strings.ToLower(path.Base(p)))
+				if err != nil {
+					return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+				}
+				if _, isFolder := entry.(*files.FolderMetadata); matched && (last || isFolder) {
+					next = append(next, remoteMatch{path: p, md: entry})
+				}
+			}
+		}
+		matches = next
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i].path) < strings.ToLower(matches[j].path)
+	})
+	return matches, nil
+}
+
+// Turns the remote argument `arg` into the paths it names, expanding globs,
+// and applies --missing-ok. This is the one place that policy lives:
+//
+// Without --missing-ok, a pattern that matches nothing is an error, and
+// explicit paths are returned unchecked so a missing one fails as usual.
+//
+// With it, a pattern that matches nothing or an explicit path that doesn't
+// exist is reported on stderr as skipped, and yields no matches.
+//
+// A pattern that matches nothing is also tried as a literal path, so names
+// which happen to contain "[" or "*" still work.
+func resolveArgs(cmd *cobra.Command, dbx files.Client, arg string) (matches []remoteMatch, err error) {
+	p, err := validatePath(arg)
+	if err != nil {
+		return
+	}
+	missingOK, _ := cmd.Flags().GetBool("missing-ok")
+
+	if hasGlob(p) && !isIDPath(p) {
+		if matches, err = globRemote(cmdCtx, dbx, p); err != nil || len(matches) > 0 {
+			return
+		}
+	} else if !missingOK {
+		return []remoteMatch{{path: p}}, nil
+	}
+
+	md, err := getFileMetadata(dbx, p)
+	switch {
+	case err == nil:
+		return []remoteMatch{{path: p, md: md}}, nil
+	case !isMissing(err):
+		return nil, err
+	case missingOK:
+		reportMissing(cmd, arg)
+		return nil, nil
+	case hasGlob(p):
+		return nil, fmt.Errorf("%s: no such file or folder, and nothing matches it", arg)
+	}
+	return nil, err
+}
+
+// A source of a command like mv or cp, which take several. `name` is where it
+// goes beneath the destination folder.
+type remoteSource struct {
+	path string
+	name string
+}
+
+// Expands the patterns among the remote sources `args`. Each match goes into
+// the destination under its own name; explicit paths are passed through
+// unchanged.
+func expandSources(cmd *cobra.Command, dbx files.Client, args []string) (sources []remoteSource, err error) {
+	for _, arg := range args {
+		if !hasGlob(arg) || isIDPath(arg) {
+			sources = append(sources, remoteSource{arg, arg})
+			continue
+		}
+		matches, err := resolveArgs(cmd, dbx, arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			sources = append(sources, remoteSource{m.path, path.Base(m.path)})
+		}
+	}
+	return
+}
+
+// Notes on stderr that a path given to `cmd` was skipped under --missing-ok.
+func reportMissing(cmd *cobra.Command, arg string) {
+	fmt.Fprintf(os.Stderr, "%s: skipped (missing): %s\n", cmd.Name(), arg)
+	logger.Info("skipped missing path", "command", cmd.Name(), "path", arg)
+}
@@ -0,0 +1,73 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+func metadata(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`metadata` requires a `path` argument")
+	}
+
+	path, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+
+	arg := files.NewGetMetadataArg(path)
+	arg.IncludeMediaInfo, _ = cmd.Flags().GetBool("include-media-info")
+	arg.IncludeDeleted, _ = cmd.Flags().GetBool("include-deleted")
+	arg.IncludeHasExplicitSharedMembers, _ = cmd.Flags().GetBool("include-shared-members")
+
+	// Decoding into the SDK's types would drop the fields it doesn't know
+	// about, so the response is printed as it came.
+	var raw json.RawMessage
+	if err = rpc(cmdCtx, "files", "get_metadata", arg, &raw); err != nil {
+		return
+	}
+	return printJSON(raw)
+}
+
+// metadataCmd represents the metadata command
+var metadataCmd = &cobra.Command{
+	Use:   "metadata [flags] <path>",
+	Short: "Print the full metadata of a file or folder as JSON",
+	Long: `Print everything Dropbox records about a file or folder as indented JSON:
+its id, revision, content hash, sharing info and so on, including fields
+dbxcli doesn't otherwise show. "dbxcli stat" shows the common ones in a
+readable form.
+
+<path> may also be an id such as "id:a4ayc_80_OEAAAAAAAAAXw". Media info
+(dimensions, location and time taken) for photos and videos is only included
+with --include-media-info.`,
+	Example: `  dbxcli metadata /reports/2016.pdf
+  dbxcli metadata --include-media-info /Photos/beach.jpg
+  dbxcli metadata --include-deleted /old/notes.txt | jq -r .rev`,
+	RunE: metadata,
+}
+
+func init() {
+	RootCmd.AddCommand(metadataCmd)
+
+	metadataCmd.Flags().Bool("include-media-info", false, "Include media info for photos and videos")
+	metadataCmd.Flags().Bool("include-deleted", false, "Print the metadata of a deleted file or folder instead of failing")
+	metadataCmd.Flags().Bool("include-shared-members", false, "Include whether files have explicitly shared members")
+}
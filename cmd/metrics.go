@@ -0,0 +1,219 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
+	"github.com/spf13/cobra"
+)
+
+// One sample of a team metric. Names and help texts are part of the output
+// format that monitoring setups depend on, so they must not change.
+type metric struct {
+	Name   string            `json:"name"`
+	Help   string            `json:"help"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+const (
+	metricMembers        = "dbxcli_team_members"
+	metricPendingInvites = "dbxcli_team_pending_invites"
+	metricStorageUsed    = "dbxcli_team_storage_used_bytes"
+	metricLinksCreated   = "dbxcli_team_shared_links_created_24h"
+	metricCollectorUp    = "dbxcli_team_collector_success"
+)
+
+var metricHelp = map[string]string{
+	metricMembers:        "Number of team members, by status.",
+	metricPendingInvites: "Number of invited members who haven't joined yet.",
+	metricStorageUsed:    "Total storage used by the team, in bytes, as of the latest daily report.",
+	metricLinksCreated:   "Number of shared links created in the last 24 hours, from the event log.",
+	metricCollectorUp:    "Whether each collector succeeded (1) or failed (0).",
+}
+
+func newMetric(name string, value float64, labels map[string]string) metric {
+	return metric{Name: name, Help: metricHelp[name], Labels: labels, Value: value}
+}
+
+func collectMemberMetrics(dbx team.Client) (metrics []metric, err error) {
+	counts := map[string]int{"active": 0, "invited": 0, "suspended": 0}
+
+	res, err := dbx.MembersList(team.NewMembersListArg())
+	for err == nil {
+		for _, m := range res.Members {
+			counts[m.Profile.Status.Tag]++
+		}
+		if !res.HasMore {
+			break
+		}
+		if err = cmdCtx.Err(); err != nil {
+			break
+		}
+		res, err = dbx.MembersListContinue(team.NewMembersListContinueArg(res.Cursor))
+	}
+	if err != nil {
+		return
+	}
+
+	var statuses []string
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		metrics = append(metrics, newMetric(metricMembers, float64(counts[status]), map[string]string{"status": status}))
+	}
+	metrics = append(metrics, newMetric(metricPendingInvites, float64(counts["invited"]), nil))
+	return
+}
+
+func collectStorageMetrics(dbx team.Client) (metrics []metric, err error) {
+	res, err := dbx.ReportsGetStorage(team.NewDateRange())
+	if err != nil {
+		return
+	}
+	if len(res.TotalUsage) == 0 {
+		return nil, fmt.Errorf("the storage report is empty")
+	}
+	used := res.TotalUsage[len(res.TotalUsage)-1]
+	return []metric{newMetric(metricStorageUsed, float64(used), nil)}, nil
+}
+
+func collectLinkMetrics() (metrics []metric, err error) {
+	arg := getEventsArg{
+		Limit:    maxEventsPage,
+		Time:     &timeRange{StartTime: time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)},
+		Category: &dropbox.Tagged{Tag: "sharing"},
+	}
+
+	created := 0
+	err = listEvents(&arg, func(raw json.RawMessage) error {
+		var e teamEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		if e.EventType.Tag == "shared_link_create" {
+			created++
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	return []metric{newMetric(metricLinksCreated, float64(created), nil)}, nil
+}
+
+// Escapes a label value for the Prometheus text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Writes metrics in the Prometheus text exposition format, with one HELP and
+// TYPE line per metric name.
+func writePrometheus(w io.Writer, metrics []metric) {
+	seen := make(map[string]bool)
+	for _, m := range metrics {
+		if !seen[m.Name] {
+			seen[m.Name] = true
+			fmt.Fprintf(w, "# HELP %s %s\n", m.Name, m.Help)
+			fmt.Fprintf(w, "# TYPE %s gauge\n", m.Name)
+		}
+
+		var labels []string
+		for k, v := range m.Labels {
+			labels = append(labels, fmt.Sprintf(`%s="%s"`, k, escapeLabel(v)))
+		}
+		sort.Strings(labels)
+		if len(labels) > 0 {
+			fmt.Fprintf(w, "%s{%s} %v\n", m.Name, strings.Join(labels, ","), m.Value)
+		} else {
+			fmt.Fprintf(w, "%s %v\n", m.Name, m.Value)
+		}
+	}
+}
+
+func teamMetrics(cmd *cobra.Command, args []string) (err error) {
+	dbx := newTeamClient(cmdCtx)
+	collectors := []struct {
+		name    string
+		collect func() ([]metric, error)
+	}{
+		{"members", func() ([]metric, error) { return collectMemberMetrics(dbx) }},
+		{"storage", func() ([]metric, error) { return collectStorageMetrics(dbx) }},
+		{"shared_links", collectLinkMetrics},
+	}
+
+	// A failing collector doesn't stop the others; its metrics are left out
+	// and its success metric says why they're missing.
+	var metrics, status []metric
+	failed := 0
+	for _, c := range collectors {
+		ms, err := c.collect()
+		up := 1.0
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", c.name, err)
+			up = 0
+			failed++
+		}
+		metrics = append(metrics, ms...)
+		status = append(status, newMetric(metricCollectorUp, up, map[string]string{"collector": c.name}))
+	}
+	metrics = append(metrics, status...)
+
+	if prom, _ := cmd.Flags().GetBool("prometheus"); prom {
+		writePrometheus(os.Stdout, metrics)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(metrics); err != nil {
+			return
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d collectors failed", failed, len(collectors))
+	}
+	return
+}
+
+// teamMetricsCmd represents the metrics command
+var teamMetricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Print team metrics for monitoring systems",
+	Long: `Print team metrics: members by status, pending invites, storage used and
+shared links created in the last day.
+
+The output is JSON, or the Prometheus text format with --prometheus, which
+suits the node exporter's textfile collector. Metrics which couldn't be
+gathered are left out, the rest are still printed, and the command exits with
+an error. Use --timeout to bound how long a run can take.`,
+	Example: `  dbxcli team metrics --prometheus --timeout 2m > /var/lib/node_exporter/dropbox.prom`,
+	RunE:    teamMetrics,
+}
+
+func init() {
+	teamCmd.AddCommand(teamMetricsCmd)
+	teamMetricsCmd.Flags().Bool("prometheus", false, "Print metrics in the Prometheus text format")
+}
@@ -0,0 +1,71 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path"
+	"time"
+)
+
+// One remote folder of a recursive download and where it goes locally.
+type mirroredFolder struct {
+	local string
+	// The newest server_modified time of anything beneath it; zero if it's
+	// empty.
+	newest time.Time
+}
+
+// The folders of a remote tree being mirrored locally, by lower-case remote
+// path.
+type folderMirror map[string]*mirroredFolder
+
+// Creates the local directory `local` for the remote folder `lower`, whether
+// or not anything is downloaded into it.
+func (m folderMirror) add(lower string, local string) error {
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return err
+	}
+	m[lower] = &mirroredFolder{local: local}
+	return nil
+}
+
+// Notes that the file `lower`, last modified on the server at `modified`,
+// was downloaded, which makes every folder above it at least that new.
+func (m folderMirror) fileDownloaded(lower string, modified time.Time) {
+	for dir := path.Dir(lower); ; dir = path.Dir(dir) {
+		if f, ok := m[dir]; ok && modified.After(f.newest) {
+			f.newest = modified
+		}
+		if dir == "/" || dir == "." {
+			return
+		}
+	}
+}
+
+// Sets the modification time of each directory with files beneath it to
+// that of the newest one. Writing into a directory updates its time, so this
+// comes after everything's downloaded.
+func (m folderMirror) setTimes() error {
+	for _, f := range m {
+		if f.newest.IsZero() {
+			continue
+		}
+		if err := os.Chtimes(f.local, f.newest, f.newest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -33,11 +33,15 @@ func mkdir(cmd *cobra.Command, args []string) (err error) {
 
 	arg := files.NewCreateFolderArg(dst)
 
-	dbx := files.New(config)
-	if _, err = dbx.CreateFolder(arg); err != nil {
+	dbx := newFilesClient(cmdCtx)
+	md, err := dbx.CreateFolder(arg)
+	if err != nil {
 		return
 	}
 
+	if jsonMode(cmd) {
+		return printJSON(newJSONEntry(md, fileExtras{}))
+	}
 	return
 }
 
@@ -45,7 +49,10 @@ func mkdir(cmd *cobra.Command, args []string) (err error) {
 var mkdirCmd = &cobra.Command{
 	Use:   "mkdir [flags] <directory>",
 	Short: "Create a new directory",
-	RunE:  mkdir,
+	Long: `Create a new directory.
+
+With --json, the new folder is printed as "ls --json" prints one.`,
+	RunE: mkdir,
 }
 
 func init() {
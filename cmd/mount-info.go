@@ -0,0 +1,174 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"text/tabwriter"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/spf13/cobra"
+)
+
+// The vendored SDK's SharedFolderMetadata lacks is_inside_team_folder.
+type sharedFolderInfo struct {
+	Name               string         `json:"name"`
+	SharedFolderId     string         `json:"shared_folder_id"`
+	PathLower          string         `json:"path_lower,omitempty"`
+	AccessType         dropbox.Tagged `json:"access_type"`
+	IsTeamFolder       bool           `json:"is_team_folder"`
+	IsInsideTeamFolder bool           `json:"is_inside_team_folder"`
+}
+
+type getFolderMetadataArg struct {
+	SharedFolderId string `json:"shared_folder_id"`
+}
+
+// What `mount-info` reports about a path.
+type mountInfo struct {
+	Path         string               `json:"path"`
+	SharedFolder *sharedFolderSummary `json:"shared_folder"`
+}
+
+type sharedFolderSummary struct {
+	Name               string `json:"name"`
+	Id                 string `json:"id"`
+	Path               string `json:"path,omitempty"`
+	AccessLevel        string `json:"access_level"`
+	Members            int    `json:"members"`
+	IsTeamFolder       bool   `json:"is_team_folder"`
+	IsInsideTeamFolder bool   `json:"is_inside_team_folder"`
+}
+
+// Returns the id of the shared folder containing `p`, or "" if there's none.
+// Paths which don't exist are looked up through their nearest existing
+// ancestor.
+func containingSharedFolder(dbx files.Client, p string) (string, error) {
+	for p != "" && p != "/" {
+		md, err := getFileMetadata(dbx, p)
+		if isMissing(err) {
+			p = gopath.Dir(p)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		switch m := md.(type) {
+		case *files.FileMetadata:
+			if m.SharingInfo != nil {
+				return m.SharingInfo.ParentSharedFolderId, nil
+			}
+		case *files.FolderMetadata:
+			if m.SharingInfo != nil {
+				if m.SharingInfo.SharedFolderId != "" {
+					return m.SharingInfo.SharedFolderId, nil
+				}
+				return m.SharingInfo.ParentSharedFolderId, nil
+			}
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+func countFolderMembers(dbx sharing.Client, id string) (n int, err error) {
+	res, err := dbx.ListFolderMembers(sharing.NewListFolderMembersArgs(id))
+	for err == nil {
+		n += len(res.Users) + len(res.Groups) + len(res.Invitees)
+		if res.Cursor == "" {
+			break
+		}
+		if err = cmdCtx.Err(); err != nil {
+			break
+		}
+		res, err = dbx.ListFolderMembersContinue(sharing.NewListFolderMembersContinueArg(res.Cursor))
+	}
+	return
+}
+
+func mountInfoCmdRun(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`mount-info` requires a `path` argument")
+	}
+
+	path, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+
+	id, err := containingSharedFolder(newFilesClient(cmdCtx), path)
+	if err != nil {
+		return
+	}
+
+	info := mountInfo{Path: args[0]}
+	if id != "" {
+		var folder sharedFolderInfo
+		if err = rpc(cmdCtx, "sharing", "get_folder_metadata", getFolderMetadataArg{id}, &folder); err != nil {
+			return
+		}
+		var members int
+		if members, err = countFolderMembers(newSharingClient(cmdCtx), id); err != nil {
+			return
+		}
+
+		info.SharedFolder = &sharedFolderSummary{folder.Name, folder.SharedFolderId, folder.PathLower, folder.AccessType.Tag, members, folder.IsTeamFolder, folder.IsInsideTeamFolder}
+	}
+
+	if jsonMode(cmd) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	if info.SharedFolder == nil {
+		fmt.Printf("%s is not inside a shared folder\n", args[0])
+		return
+	}
+
+	sf := info.SharedFolder
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Shared folder:\t%s\n", sf.Name)
+	fmt.Fprintf(w, "Id:\t%s\n", sf.Id)
+	if sf.Path != "" {
+		fmt.Fprintf(w, "Path:\t%s\n", sf.Path)
+	}
+	fmt.Fprintf(w, "Access level:\t%s\n", sf.AccessLevel)
+	fmt.Fprintf(w, "Members:\t%d\n", sf.Members)
+	fmt.Fprintf(w, "Team folder:\t%t\n", sf.IsTeamFolder)
+	fmt.Fprintf(w, "Inside team space:\t%t\n", sf.IsInsideTeamFolder)
+	return w.Flush()
+}
+
+// mountInfoCmd represents the mount-info command
+var mountInfoCmd = &cobra.Command{
+	Use:   "mount-info [flags] <path>",
+	Short: "Show which shared folder a path belongs to",
+	Long: `Show which shared folder a path belongs to: the folder's name and id, your
+access level, how many members it has and whether it's part of the team
+space. Paths outside any shared folder say so.`,
+	RunE: mountInfoCmdRun,
+}
+
+func init() {
+	RootCmd.AddCommand(mountInfoCmd)
+}
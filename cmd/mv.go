@@ -15,13 +15,102 @@
 package cmd
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/spf13/cobra"
 )
 
+// Dropbox is case-insensitive, so it treats a move that only changes the case
+// of a name as a move onto itself. Such a rename is done in two hops, through
+// a temporary name next to the source. If the second hop fails the first one
+// is undone, so the file is left where it was.
+func caseOnlyRename(dbx files.Client, from string, to string) error {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	tmp := path.Join(path.Dir(from), fmt.Sprintf(".%s.dbxcli-rename-%s", path.Base(from), hex.EncodeToString(b)))
+
+	if _, err := dbx.Move(files.NewRelocationArg(from, tmp)); err != nil {
+		return fmt.Errorf("rename %s to %s: %v", from, to, err)
+	}
+	if _, err := dbx.Move(files.NewRelocationArg(tmp, to)); err != nil {
+		if _, rollbackErr := dbx.Move(files.NewRelocationArg(tmp, from)); rollbackErr != nil {
+			return fmt.Errorf("rename %s to %s: %v; moving it back also failed, so it's now at %s: %v", from, to, err, tmp, rollbackErr)
+		}
+		return fmt.Errorf("rename %s to %s: %v", from, to, err)
+	}
+	return nil
+}
+
+// Handles a move of `source` onto `destination` when both name the same
+// path, ignoring case. Returns false if they don't. With --dry-run the rename
+// is only printed.
+func moveOntoItself(cmd *cobra.Command, dbx files.Client, s *settings, source string, destination string) (handled bool, err error) {
+	if isIDPath(source) || isIDPath(destination) {
+		return false, nil
+	}
+	from, err := validatePath(source)
+	if err != nil {
+		return false, nil
+	}
+	to, err := validatePath(destination)
+	if err != nil || !strings.EqualFold(from, to) {
+		return false, nil
+	}
+
+	if from == to {
+		fmt.Fprintf(os.Stderr, "%s is already at %s; nothing to do\n", source, to)
+		return true, nil
+	}
+	if err = checkProtected(s, from); err != nil {
+		return true, err
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		printDryRun(cmd, "rename", from, to)
+		return true, nil
+	}
+	if err = caseOnlyRename(dbx, from, to); err != nil {
+		return true, err
+	}
+	if jsonMode(cmd) {
+		printMoved(cmd, from, to, "moved", nil)
+	}
+	return true, nil
+}
+
+// Moves whatever is at `to` into the archive folder, so that a move can take
+// its place, and prints where it went. Does nothing if `to` is free. With
+// --dry-run the move is only printed.
+func archiveReplaced(cmd *cobra.Command, dbx files.Client, to string, archive string) error {
+	_, err := getFileMetadata(dbx, to)
+	if isMissing(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if isWithin(to, archive) {
+		return fmt.Errorf("%s is in the archive folder", to)
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		printDryRun(cmd, "archive", to, archive)
+		return nil
+	}
+	archived, err := archiveMove(dbx, to, archive)
+	if err != nil {
+		return err
+	}
+	printMoved(cmd, to, archived, "archived", nil)
+	return nil
+}
+
 func mv(cmd *cobra.Command, args []string) error {
 	var destination string
 	var argsToMove []string
@@ -36,25 +125,88 @@ func mv(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("mv command requires a source and a destination")
 	}
 
+	s, err := readSettings()
+	if err != nil {
+		return err
+	}
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	dbx := newFilesClient(cmdCtx)
+	if len(argsToMove) == 1 {
+		if handled, err := moveOntoItself(cmd, dbx, s, argsToMove[0], destination); handled {
+			return err
+		}
+	}
+
+	sources, err := expandSources(cmd, dbx, argsToMove)
+	if err != nil {
+		return err
+	}
+
 	mvErrors := []error{}
 	relocationArgs := []*files.RelocationArg{}
 
-	for _, argument := range argsToMove {
-		arg, err := makeRelocationArg(argument, destination+"/"+argument)
+	for _, source := range sources {
+		argument := source.path
+		var from string
+		var arg *files.RelocationArg
+		from, err = validatePath(argument)
+		if err == nil {
+			from, err = resolvePath(dbx, from)
+		}
+		if err == nil {
+			to := destination + "/" + source.name
+			if isIDPath(argument) {
+				// Sources given by id are moved under their current name.
+				to = destination + "/" + path.Base(from)
+			}
+			arg, err = makeRelocationArg(argument, to)
+		}
+		if err == nil {
+			if err = checkProtected(s, from); err == nil {
+				err = checkProtected(s, arg.ToPath)
+			}
+		}
 		if err != nil {
 			relocationError := fmt.Errorf("Error validating move for %s to %s: %v", argument, destination, err)
 			mvErrors = append(mvErrors, relocationError)
+			if jsonMode(cmd) {
+				printMoved(cmd, argument, destination, "", err)
+			}
 		} else {
 			relocationArgs = append(relocationArgs, arg)
 		}
 	}
 
-	dbx := files.New(config)
+	var archive string
+	if archiveTo, _ := cmd.Flags().GetString("archive-to"); archiveTo != "" {
+		if archive, err = validatePath(archiveTo); err != nil {
+			return err
+		}
+	}
+
 	for _, arg := range relocationArgs {
-		if _, err := dbx.Move(arg); err != nil {
+		if archive != "" {
+			if err := archiveReplaced(cmd, dbx, arg.ToPath, archive); err != nil {
+				mvErrors = append(mvErrors, fmt.Errorf("Error archiving %s: %v", arg.ToPath, err))
+				if jsonMode(cmd) {
+					printMoved(cmd, arg.FromPath, arg.ToPath, "", err)
+				}
+				continue
+			}
+		}
+		if dryRun {
+			printDryRun(cmd, "move", arg.FromPath, arg.ToPath)
+			continue
+		}
+		_, err := dbx.Move(arg)
+		if err != nil {
 			moveError := fmt.Errorf("Move error: %v", arg)
 			mvErrors = append(mvErrors, moveError)
 		}
+		if jsonMode(cmd) {
+			printMoved(cmd, arg.FromPath, arg.ToPath, "moved", err)
+		}
 	}
 
 	for _, mvError := range mvErrors {
@@ -68,9 +220,32 @@ func mv(cmd *cobra.Command, args []string) error {
 var mvCmd = &cobra.Command{
 	Use:   "mv [flags] <source> <target>",
 	Short: "Move files",
-	RunE:  mv,
+	Long: `Move files into the folder <target>.
+
+If <target> is the same path as a single <source> in different case, the
+source is renamed instead, which takes two moves since Dropbox ignores case;
+if the second fails the first is undone. If they're identical, nothing
+happens.
+
+A <source> may be a pattern such as '/reports/2024-*.csv', quoted so the
+shell leaves it alone; what it matches is moved into <target> by name.
+
+With --archive-to, anything already at the path a source is moved to is first
+moved into the archive folder, as "rm --archive-to" would, instead of the
+move failing. Set a default with ` + "`config set mv.archive-to /Trash`" + `.
+
+With --dry-run, mv only lists the moves it would make.
+
+With --json, each move, or each one --dry-run would make, is printed as a
+line of JSON.`,
+	Example: `  dbxcli mv /docs/Readme.md /docs/README.md
+  dbxcli mv '/Camera Uploads/*.mov' /Videos
+  dbxcli mv --archive-to /Trash /drafts/report.pdf /final`,
+	RunE: mv,
 }
 
 func init() {
 	RootCmd.AddCommand(mvCmd)
+	mvCmd.Flags().Bool("dry-run", false, "Only list what would be moved")
+	mvCmd.Flags().String("archive-to", "", "Move anything in the way into this archive folder first")
 }
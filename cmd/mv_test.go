@@ -0,0 +1,139 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// Returns the from and to paths of each Move call.
+func moves(fake *testutil.FakeFiles) (hops [][2]string) {
+	for _, c := range fake.Calls() {
+		if arg, ok := c.Arg.(*files.RelocationArg); ok && c.Method == "Move" {
+			hops = append(hops, [2]string{arg.FromPath, arg.ToPath})
+		}
+	}
+	return
+}
+
+func TestMvOntoItself(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"same path", []string{"/docs/README.md", "/docs/README.md"}},
+		{"same path once cleaned", []string{"/docs/./README.md", "/docs//README.md/"}},
+	}
+	for _, tt := range tests {
+		fake := useFakeFiles(t)
+		_, stderr, err := testutil.Capture(func() error { return mv(mvCmd, tt.args) })
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if calls := fake.Methods(); len(calls) != 0 {
+			t.Errorf("%s: made calls %v", tt.name, calls)
+		}
+		if !strings.Contains(stderr, "nothing to do") {
+			t.Errorf("%s: printed %q", tt.name, stderr)
+		}
+	}
+}
+
+func TestMvCaseOnlyRename(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("Move", fileMetadata("/docs/.Readme.md.dbxcli-rename-0", 1), nil)
+	fake.Respond("Move", fileMetadata("/docs/README.md", 1), nil)
+	if _, _, err := testutil.Capture(func() error { return mv(mvCmd, []string{"/docs/Readme.md", "/docs/README.md"}) }); err != nil {
+		t.Fatal(err)
+	}
+
+	hops := moves(fake)
+	if len(hops) != 2 {
+		t.Fatalf("moves = %v, want two hops", hops)
+	}
+	tmp := hops[0][1]
+	if hops[0][0] != "/docs/Readme.md" || !strings.HasPrefix(tmp, "/docs/.Readme.md.dbxcli-rename-") {
+		t.Errorf("first hop %v, want a temporary name next to the source", hops[0])
+	}
+	if hops[1] != [2]string{tmp, "/docs/README.md"} {
+		t.Errorf("second hop %v", hops[1])
+	}
+}
+
+func TestMvCaseOnlyRenameDryRun(t *testing.T) {
+	fake := useFakeFiles(t)
+	setFlags(t, mvCmd, map[string]string{"dry-run": "true"})
+	stdout, _, err := testutil.Capture(func() error { return mv(mvCmd, []string{"/docs/Readme.md", "/docs/README.md"}) })
+	if err != nil || stdout != "rename\t/docs/Readme.md -> /docs/README.md\n" {
+		t.Errorf("printed %q, %v", stdout, err)
+	}
+	if calls := fake.Methods(); len(calls) != 0 {
+		t.Errorf("made calls %v", calls)
+	}
+}
+
+func TestMvJSON(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("Move", fileMetadata("/dst/a.txt", 1), nil)
+	setFlags(t, mvCmd, map[string]string{"json": "true"})
+	stdout, _, err := testutil.Capture(func() error { return mv(mvCmd, []string{"/a.txt", "/dst"}) })
+	if want := `{"src":"/a.txt","dst":"/dst/a.txt","outcome":"moved"}` + "\n"; err != nil || stdout != want {
+		t.Errorf("printed %q, %v; want %q", stdout, err, want)
+	}
+
+	setFlags(t, mvCmd, map[string]string{"dry-run": "true"})
+	stdout, _, err = testutil.Capture(func() error { return mv(mvCmd, []string{"/a.txt", "/dst"}) })
+	if want := `{"src":"/a.txt","dst":"/dst/a.txt","action":"move"}` + "\n"; err != nil || stdout != want {
+		t.Errorf("dry run printed %q, %v; want %q", stdout, err, want)
+	}
+}
+
+func TestMvCaseOnlyRenameRollback(t *testing.T) {
+	secondHop := errors.New("too_many_write_operations")
+	tests := []struct {
+		name     string
+		rollback error
+		err      string
+	}{
+		{"rolled back", nil, "rename /docs/Readme.md to /docs/README.md: too_many_write_operations"},
+		{"stuck", errors.New("insufficient_space"), "moving it back also failed, so it's now at /docs/.Readme.md.dbxcli-rename-"},
+	}
+	for _, tt := range tests {
+		fake := useFakeFiles(t)
+		fake.Respond("Move", fileMetadata("/docs/.Readme.md.dbxcli-rename-0", 1), nil)
+		fake.Respond("Move", nil, secondHop)
+		fake.Respond("Move", fileMetadata("/docs/Readme.md", 1), tt.rollback)
+
+		_, _, err := testutil.Capture(func() error { return mv(mvCmd, []string{"/docs/Readme.md", "/docs/README.md"}) })
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: mv returned %v, want %q", tt.name, err, tt.err)
+		}
+
+		hops := moves(fake)
+		if len(hops) != 3 {
+			t.Fatalf("%s: moves = %v, want two hops and a rollback", tt.name, hops)
+		}
+		tmp := hops[0][1]
+		if want := [2]string{tmp, "/docs/Readme.md"}; !reflect.DeepEqual(hops[2], want) {
+			t.Errorf("%s: rolled back with %v, want %v", tt.name, hops[2], want)
+		}
+	}
+}
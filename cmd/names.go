@@ -0,0 +1,153 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Characters Windows doesn't allow in file names. Dropbox allows all of them.
+const windowsReservedChars = `\:*?"<>|`
+
+// Whether local names differing only by case are the same file, as they are
+// by default on Windows and macOS.
+var localNamesFoldCase = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// Returns the characters in `name` that can't be used in a local file name on
+// this system.
+func invalidLocalChars(name string) string {
+	if runtime.GOOS != "windows" {
+		return ""
+	}
+	var bad []rune
+	for _, r := range name {
+		if strings.ContainsRune(windowsReservedChars, r) {
+			bad = append(bad, r)
+		}
+	}
+	return string(bad)
+}
+
+// nameMapper decides the local names remote files are saved under. Unless
+// `sanitize` is set it only validates them; otherwise characters reserved on
+// any common filesystem are replaced, names that differ only by case are
+// disambiguated with a " (2)"-style suffix, and every rename is recorded.
+type nameMapper struct {
+	sanitize    bool
+	replacement string
+	used        map[string]bool
+	renames     [][2]string
+}
+
+func newNameMapper(sanitize bool, replacement string) *nameMapper {
+	return &nameMapper{
+		sanitize:    sanitize,
+		replacement: replacement,
+		used:        make(map[string]bool),
+	}
+}
+
+// Checks, before anything is downloaded, that the remote paths can be saved
+// locally as they are, and lists all the ones that can't: names with
+// characters this system doesn't allow, and names which only differ by case
+// where that makes them the same file. With `sanitize` set it only checks that
+// the replacement itself is allowed everywhere.
+func (m *nameMapper) check(remotes []string) error {
+	if m.sanitize {
+		if strings.ContainsAny(m.replacement, windowsReservedChars+"/") {
+			return fmt.Errorf("`--replacement` %q contains characters that are themselves reserved", m.replacement)
+		}
+		return nil
+	}
+	var bad []string
+	for _, remote := range remotes {
+		if chars := invalidLocalChars(path.Base(remote)); chars != "" {
+			bad = append(bad, fmt.Sprintf("  %s (%q)", remote, chars))
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("these names can't be used on this system, retry with `--sanitize-names`:\n%s",
+			strings.Join(bad, "\n"))
+	}
+
+	if !localNamesFoldCase {
+		return nil
+	}
+	first := make(map[string]string)
+	for _, remote := range remotes {
+		lower := strings.ToLower(remote)
+		if other, ok := first[lower]; ok && other != remote {
+			bad = append(bad, fmt.Sprintf("  %s and %s", other, remote))
+			continue
+		}
+		first[lower] = remote
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("these names only differ by case and would overwrite each other here, retry with `--sanitize-names`:\n%s",
+			strings.Join(bad, "\n"))
+	}
+	return nil
+}
+
+// Returns the local path inside `dir` to save the remote file `remote` as.
+func (m *nameMapper) localPath(dir string, remote string) string {
+	name := path.Base(remote)
+	if !m.sanitize {
+		return filepath.Join(dir, name)
+	}
+
+	local := name
+	for _, r := range windowsReservedChars {
+		local = strings.Replace(local, string(r), m.replacement, -1)
+	}
+
+	ext := path.Ext(local)
+	stem := strings.TrimSuffix(local, ext)
+	for n := 2; m.used[strings.ToLower(filepath.Join(dir, local))]; n++ {
+		local = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	m.used[strings.ToLower(filepath.Join(dir, local))] = true
+
+	if local != name {
+		m.renames = append(m.renames, [2]string{remote, filepath.Join(dir, local)})
+	}
+	return filepath.Join(dir, local)
+}
+
+// Appends the renames made so far to `file`, one tab-separated
+// "remote<TAB>local" pair per line.
+func (m *nameMapper) writeRenames(file string) (err error) {
+	if len(m.renames) == 0 {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	for _, r := range m.renames {
+		if _, err = fmt.Fprintf(f, "%s\t%s\n", r[0], r[1]); err != nil {
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Renamed %d file(s) to valid local names; see %s\n", len(m.renames), file)
+	return
+}
@@ -0,0 +1,93 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNameMapperCheck(t *testing.T) {
+	saved := localNamesFoldCase
+	defer func() { localNamesFoldCase = saved }()
+
+	tests := []struct {
+		name        string
+		sanitize    bool
+		replacement string
+		foldCase    bool
+		remotes     []string
+		// A substring of the error, if one is expected.
+		err string
+	}{
+		{
+			name:     "distinct names",
+			foldCase: true,
+			remotes:  []string{"/docs/a.txt", "/docs/b.txt"},
+		},
+		{
+			name:     "names differing by case",
+			foldCase: true,
+			remotes:  []string{"/docs/Readme.md", "/docs/a.txt", "/docs/README.md"},
+			err:      "/docs/Readme.md and /docs/README.md",
+		},
+		{
+			name:    "names differing by case where case matters",
+			remotes: []string{"/docs/Readme.md", "/docs/README.md"},
+		},
+		{
+			name:        "sanitized names differing by case",
+			sanitize:    true,
+			replacement: "_",
+			foldCase:    true,
+			remotes:     []string{"/docs/Readme.md", "/docs/README.md"},
+		},
+		{
+			name:        "reserved replacement",
+			sanitize:    true,
+			replacement: ":",
+			err:         "`--replacement` \":\" contains characters that are themselves reserved",
+		},
+		{
+			name:        "replacement with a slash",
+			sanitize:    true,
+			replacement: "a/b",
+			err:         "themselves reserved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localNamesFoldCase = tt.foldCase
+			err := newNameMapper(tt.sanitize, tt.replacement).check(tt.remotes)
+			if tt.err == "" && err != nil {
+				t.Errorf("check: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("check = %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// Sanitized names which only differ by case are numbered apart.
+func TestNameMapperCaseCollision(t *testing.T) {
+	m := newNameMapper(true, "_")
+	first := m.localPath("dir", "/docs/Readme.md")
+	second := m.localPath("dir", "/docs/README.md")
+	if first != filepath.Join("dir", "Readme.md") || second != filepath.Join("dir", "README (2).md") {
+		t.Errorf("localPath = %q, %q", first, second)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+const webHomeURL = "https://www.dropbox.com/home"
+
+// Escapes each component of a remote path for use in a URL.
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Returns the web URL for a remote path: folders open the folder view and
+// files open a preview inside their parent folder.
+func webURL(pathDisplay string, isFolder bool) string {
+	if isFolder {
+		return webHomeURL + escapePath(pathDisplay)
+	}
+	dir, name := path.Split(pathDisplay)
+	return webHomeURL + escapePath(strings.TrimSuffix(dir, "/")) + "?preview=" + url.QueryEscape(name)
+}
+
+// Launches the platform's handler for `target`, a URL or a local path.
+func openExternal(target string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		c = exec.Command("open", target)
+	case "windows":
+		// Hands `target` to the shell directly: going through `cmd /c start`
+		// would let characters like & in a name run as further commands.
+		c = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		c = exec.Command("xdg-open", target)
+	}
+	return c.Run()
+}
+
+// The official client records where it syncs to in info.json; see
+// https://help.dropbox.com/installs/locate-dropbox-folder.
+type dropboxInfo struct {
+	Personal *struct {
+		Path string `json:"path"`
+	} `json:"personal"`
+	Business *struct {
+		Path string `json:"path"`
+	} `json:"business"`
+}
+
+func dropboxInfoPaths() ([]string, error) {
+	if runtime.GOOS == "windows" {
+		var paths []string
+		for _, env := range []string{"APPDATA", "LOCALAPPDATA"} {
+			if dir := os.Getenv(env); dir != "" {
+				paths = append(paths, filepath.Join(dir, "Dropbox", "info.json"))
+			}
+		}
+		return paths, nil
+	}
+	dir, err := homedir.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return []string{filepath.Join(dir, ".dropbox", "info.json")}, nil
+}
+
+// Finds the root of the locally synced Dropbox folder. Team members get their
+// business folder, everyone else their personal one.
+func localDropboxRoot(business bool) (string, error) {
+	paths, err := dropboxInfoPaths()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		var info dropboxInfo
+		if err = json.Unmarshal(b, &info); err != nil {
+			return "", fmt.Errorf("%s: %v", p, err)
+		}
+		if business && info.Business != nil {
+			return info.Business.Path, nil
+		}
+		if info.Personal != nil {
+			return info.Personal.Path, nil
+		}
+		if info.Business != nil {
+			return info.Business.Path, nil
+		}
+	}
+	return "", errors.New("can't find the local Dropbox folder; is the Dropbox desktop app installed?")
+}
+
+func open(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`open` requires a `path` argument")
+	}
+
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+
+	pathDisplay, isFolder := p, true
+	if p != "" {
+		dbx := newFilesClient(cmdCtx)
+		var md files.IsMetadata
+		if md, err = getFileMetadata(dbx, p); err != nil {
+			return
+		}
+		switch m := md.(type) {
+		case *files.FileMetadata:
+			pathDisplay, isFolder = m.PathDisplay, false
+		case *files.FolderMetadata:
+			pathDisplay = m.PathDisplay
+		default:
+			return fmt.Errorf("%s has been deleted", p)
+		}
+	}
+
+	local, _ := cmd.Flags().GetBool("local")
+	if !local {
+		u := webURL(pathDisplay, isFolder)
+		fmt.Println(u)
+		return openExternal(u)
+	}
+
+	asMember, _ := cmd.Flags().GetString("as-member")
+	root, err := localDropboxRoot(asMember != "")
+	if err != nil {
+		return
+	}
+	target := filepath.Join(root, filepath.FromSlash(pathDisplay))
+	if _, err = os.Stat(target); err != nil {
+		return fmt.Errorf("%s hasn't been synced to this computer: %v", p, err)
+	}
+	fmt.Println(target)
+
+	// File managers open folders; for a file, show the folder it's in.
+	if !isFolder {
+		if runtime.GOOS == "darwin" {
+			return exec.Command("open", "-R", target).Run()
+		}
+		target = filepath.Dir(target)
+	}
+	return openExternal(target)
+}
+
+// openCmd represents the open command
+var openCmd = &cobra.Command{
+	Use:   "open [flags] <path>",
+	Short: "Open a file or folder in the Dropbox website",
+	Long: `Open a file or folder in the Dropbox website and print its URL. Folders
+open in the folder view and files in a preview.
+
+With --local, open the copy synced by the Dropbox desktop app in the file
+manager instead.`,
+	RunE: open,
+}
+
+func init() {
+	RootCmd.AddCommand(openCmd)
+
+	openCmd.Flags().Bool("local", false, "Open the locally synced copy in the file manager")
+}
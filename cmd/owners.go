@@ -0,0 +1,104 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/users"
+)
+
+// The most ids get_account_batch accepts in a single request.
+const maxAccountBatch = 300
+
+// Resolves account ids to display names, asking Dropbox about each id only
+// once however many files it modified.
+type accountNames struct {
+	dbx   users.Client
+	names map[string]string
+}
+
+func newAccountNames(dbx users.Client) *accountNames {
+	return &accountNames{dbx: dbx, names: make(map[string]string)}
+}
+
+// Looks up the ids which haven't been seen yet, up to maxAccountBatch at a
+// time. An id whose account has been deleted fails the whole batch, so it's
+// noted and the batch is sent again without it.
+func (a *accountNames) resolve(ids []string) error {
+	var missing []string
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if _, ok := a.names[id]; ok || id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		missing = append(missing, id)
+	}
+
+	for len(missing) > 0 {
+		n := len(missing)
+		if n > maxAccountBatch {
+			n = maxAccountBatch
+		}
+		batch := missing[:n]
+
+		res, err := a.dbx.GetAccountBatch(users.NewGetAccountBatchArg(batch))
+		if e, ok := err.(users.GetAccountBatchAPIError); ok && e.EndpointError != nil && e.EndpointError.NoAccount != "" {
+			a.names[e.EndpointError.NoAccount] = "(deleted account)"
+			missing = without(missing, e.EndpointError.NoAccount)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, account := range res {
+			if account.Name != nil {
+				a.names[account.AccountId] = account.Name.DisplayName
+			}
+		}
+		missing = missing[n:]
+	}
+	return nil
+}
+
+// Returns the display name for `id`, or a dash if there's no id.
+func (a *accountNames) name(id string) string {
+	if id == "" {
+		return "-"
+	}
+	if name, ok := a.names[id]; ok && name != "" {
+		return name
+	}
+	return id
+}
+
+func without(ids []string, id string) []string {
+	var rest []string
+	for _, other := range ids {
+		if other != id {
+			rest = append(rest, other)
+		}
+	}
+	return rest
+}
+
+// The account id of whoever last modified a file in a shared folder, or an
+// empty string for anything else.
+func modifiedBy(md files.IsMetadata) string {
+	if f, ok := md.(*files.FileMetadata); ok && f.SharingInfo != nil {
+		return f.SharingInfo.ModifiedBy
+	}
+	return ""
+}
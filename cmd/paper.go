@@ -0,0 +1,290 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Formats Paper docs can be exported as, and the extension each is saved
+// with.
+var paperExportFormats = map[string]string{"markdown": ".md", "html": ".html"}
+
+// The vendored SDK has no paper namespace.
+type listPaperDocsArg struct {
+	FilterBy string `json:"filter_by"`
+	Limit    int    `json:"limit"`
+}
+
+type listPaperDocsContinueArg struct {
+	Cursor string `json:"cursor"`
+}
+
+type listPaperDocsResult struct {
+	DocIDs []string `json:"doc_ids"`
+	Cursor struct {
+		Value string `json:"value"`
+	} `json:"cursor"`
+	HasMore bool `json:"has_more"`
+}
+
+type paperDocArg struct {
+	DocID string `json:"doc_id"`
+}
+
+type paperDoc struct {
+	DocID       string `json:"doc_id"`
+	Owner       string `json:"owner"`
+	Title       string `json:"title"`
+	CreatedDate string `json:"created_date"`
+	Status      struct {
+		Tag string `json:".tag"`
+	} `json:"status"`
+	Revision        int64  `json:"revision"`
+	LastUpdatedDate string `json:"last_updated_date"`
+	LastEditor      string `json:"last_editor"`
+}
+
+type paperDocExportArg struct {
+	DocID        string `json:"doc_id"`
+	ExportFormat string `json:"export_format"`
+}
+
+type paperDocExportResult struct {
+	Owner    string `json:"owner"`
+	Title    string `json:"title"`
+	Revision int64  `json:"revision"`
+	MimeType string `json:"mime_type"`
+}
+
+type paperDocCreateArg struct {
+	ImportFormat   string `json:"import_format"`
+	ParentFolderID string `json:"parent_folder_id,omitempty"`
+}
+
+type paperDocCreateResult struct {
+	DocID    string `json:"doc_id"`
+	Revision int64  `json:"revision"`
+	Title    string `json:"title"`
+}
+
+// Guesses the import format of `name` from its extension.
+func paperImportFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return "markdown"
+	case ".html", ".htm":
+		return "html"
+	}
+	return "plain_text"
+}
+
+func paperList(cmd *cobra.Command, args []string) (err error) {
+	arg := listPaperDocsArg{FilterBy: "docs_accessed", Limit: 1000}
+	if created, _ := cmd.Flags().GetBool("created"); created {
+		arg.FilterBy = "docs_created"
+	}
+	var res listPaperDocsResult
+	if err = rpc(cmdCtx, "paper", "docs/list", &arg, &res); err != nil {
+		return
+	}
+	ids := res.DocIDs
+	for res.HasMore {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		cursor := res.Cursor.Value
+		res = listPaperDocsResult{}
+		if err = rpc(cmdCtx, "paper", "docs/list/continue", &listPaperDocsContinueArg{cursor}, &res); err != nil {
+			return
+		}
+		ids = append(ids, res.DocIDs...)
+	}
+
+	docs := []paperDoc{}
+	for _, id := range ids {
+		var doc paperDoc
+		if err = rpc(cmdCtx, "paper", "docs/get_metadata", &paperDocArg{id}, &doc); err != nil {
+			return
+		}
+		docs = append(docs, doc)
+	}
+
+	if jsonMode(cmd) {
+		return printJSON(docs)
+	}
+	if len(docs) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmtStr := "%s\t%s\t%s\t%s\t%s\n"
+	fmt.Fprintf(w, fmtStr, "Id", "Title", "Owner", "Status", "Last updated")
+	for _, doc := range docs {
+		updated := doc.LastUpdatedDate
+		if t, err := time.Parse(time.RFC3339, updated); err == nil {
+			updated = t.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, fmtStr, doc.DocID, doc.Title, doc.Owner, doc.Status.Tag, updated)
+	}
+	return w.Flush()
+}
+
+func paperDownload(cmd *cobra.Command, args []string) (err error) {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("`paper download` requires an `id` and an optional `target` argument")
+	}
+	format, _ := cmd.Flags().GetString("format")
+	ext, ok := paperExportFormats[format]
+	if !ok {
+		return fmt.Errorf("invalid `--format` %q; use markdown or html", format)
+	}
+
+	resp, err := contentDownload(cmdCtx, "paper", "docs/download", &paperDocExportArg{args[0], format}, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var res paperDocExportResult
+	if err = json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &res); err != nil {
+		return
+	}
+
+	dst := ""
+	if len(args) == 2 {
+		dst = args[1]
+	} else {
+		// Titles can hold any character, so they're sanitized for use as a
+		// file name.
+		name := res.Title
+		if name == "" {
+			name = args[0]
+		}
+		dst = newNameMapper(true, "_").localPath(".", strings.Replace(name, "/", "_", -1)+ext)
+	}
+	if err = writeDownload(cmdCtx, dst, resp.Body, 0, decryptOptions{}); err != nil {
+		return
+	}
+	return printDownloaded(cmd, args[0], dst, 0)
+}
+
+func paperCreate(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 0 {
+		return errors.New("`paper create` takes no arguments; give the file with `--from`")
+	}
+	from, _ := cmd.Flags().GetString("from")
+	if from == "" {
+		return errors.New("`paper create` requires `--from`")
+	}
+
+	var content []byte
+	if from == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(from)
+	}
+	if err != nil {
+		return
+	}
+
+	arg := paperDocCreateArg{ImportFormat: paperImportFormat(from)}
+	if format, _ := cmd.Flags().GetString("format"); format != "" {
+		arg.ImportFormat = format
+	}
+	arg.ParentFolderID, _ = cmd.Flags().GetString("folder-id")
+
+	var res paperDocCreateResult
+	if err = contentUpload(cmdCtx, "paper", "docs/create", &arg, content, &res); err != nil {
+		return
+	}
+	if jsonMode(cmd) {
+		return printJSON(res)
+	}
+	fmt.Printf("Created %s (%s)\n", res.Title, res.DocID)
+	return
+}
+
+// paperCmd represents the paper command
+var paperCmd = &cobra.Command{
+	Use:   "paper",
+	Short: "List, export and create Paper docs",
+	Long: `List, export and create Dropbox Paper docs, for instance to back them up
+as Markdown.
+
+These commands use the Paper API, which only works for accounts whose Paper
+docs haven't moved into their Dropbox. Paper docs that live in Dropbox are
+.paper files: export those with "dbxcli get --format md".`,
+}
+
+// paperListCmd represents the paper ls command
+var paperListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List Paper docs",
+	Long: `List the Paper docs you've accessed, or only the ones you created with
+--created.`,
+	Example: `  dbxcli paper ls
+  dbxcli paper ls --created --json`,
+	RunE: paperList,
+}
+
+// paperDownloadCmd represents the paper download command
+var paperDownloadCmd = &cobra.Command{
+	Use:   "download <id> [<target>]",
+	Short: "Export a Paper doc",
+	Long: `Export the Paper doc <id> as Markdown or HTML. Without a <target> it's
+saved in the current directory, named after its title. A <target> of "-"
+writes it to standard output.`,
+	Example: `  dbxcli paper download XjbFqi2MDsrs3nRGrJnJ5
+  dbxcli paper download --format html XjbFqi2MDsrs3nRGrJnJ5 notes.html
+  dbxcli paper download XjbFqi2MDsrs3nRGrJnJ5 - | less`,
+	RunE: paperDownload,
+}
+
+// paperCreateCmd represents the paper create command
+var paperCreateCmd = &cobra.Command{
+	Use:   "create --from <file>",
+	Short: "Create a Paper doc from a file",
+	Long: `Create a Paper doc from a Markdown, HTML or plain text file, and print
+its id. The format is taken from the file's extension unless --format is
+given. A --from of "-" reads standard input.`,
+	Example: `  dbxcli paper create --from notes.md
+  cat notes.txt | dbxcli paper create --from - --format plain_text`,
+	RunE: paperCreate,
+}
+
+func init() {
+	RootCmd.AddCommand(paperCmd)
+	paperCmd.AddCommand(paperListCmd)
+	paperCmd.AddCommand(paperDownloadCmd)
+	paperCmd.AddCommand(paperCreateCmd)
+
+	paperListCmd.Flags().Bool("created", false, "Only list docs you created")
+	paperDownloadCmd.Flags().String("format", "markdown", "Export format: markdown or html")
+	paperCreateCmd.Flags().String("from", "", "File to create the doc from, or - for standard input")
+	paperCreateCmd.Flags().String("format", "", "Import format: markdown, html or plain_text (default: from the file's extension)")
+	paperCreateCmd.Flags().String("folder-id", "", "Id of the Paper folder to create the doc in")
+}
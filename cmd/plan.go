@@ -0,0 +1,167 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// Size classes used by the transfer planner.
+const (
+	smallFileSize int64 = 1 << 20
+	largeFileSize       = defaultChunkSize
+)
+
+// Uploads of at least this many small files are committed in batches.
+const minBatchFiles = 20
+
+// Upload sessions take chunks of at most 150 MiB, and keeping them multiples
+// of 4 MiB is what the API recommends.
+const maxChunkSize int64 = 128 << 20
+
+// A summary of the sizes of the files in a transfer.
+type sizeHistogram struct {
+	Small, Medium, Large int
+	TotalBytes           int64
+}
+
+func (h sizeHistogram) files() int {
+	return h.Small + h.Medium + h.Large
+}
+
+func (h *sizeHistogram) add(size int64) {
+	switch {
+	case size < smallFileSize:
+		h.Small++
+	case size < largeFileSize:
+		h.Medium++
+	default:
+		h.Large++
+	}
+	h.TotalBytes += size
+}
+
+// Builds the histogram for a set of local files. Files which can't be read
+// are left out; the upload itself reports them.
+func histogramOf(jobs []uploadJob) (h sizeHistogram) {
+	for _, job := range jobs {
+		if info, err := os.Stat(job.src); err == nil {
+			h.add(info.Size())
+		}
+	}
+	return
+}
+
+// What was measured about the connection before the transfer started.
+type connectionProbe struct {
+	// Round trip time of a small API call.
+	RTT time.Duration
+}
+
+// How a transfer should be carried out.
+type transferPlan struct {
+	// Files transferred at the same time; 0 means all of them.
+	Parallelism int
+	ChunkSize   int64
+	// Whether to commit small uploads in batches; see batchCommitter.
+	// Downloads have no such fast path.
+	Batch bool
+	// Why the plan looks the way it does, for `--verbose`.
+	Reason string
+}
+
+// Picks parallelism and chunk size for a transfer. It's a pure function of its
+// inputs so the heuristics stay predictable:
+//
+//   - Small files are dominated by per-request latency, so many of them run at
+//     once, more so on a slow round trip. Enough of them are also committed
+//     in batches.
+//   - Large files are dominated by bandwidth, so only a few run at once, and
+//     larger chunks cut the number of round trips when latency is high.
+func planTransfer(h sizeHistogram, probe connectionProbe) transferPlan {
+	slow := probe.RTT >= 200*time.Millisecond
+
+	plan := transferPlan{Parallelism: 4, ChunkSize: defaultChunkSize}
+	switch {
+	case h.files() == 0:
+		plan.Reason = "nothing to transfer"
+		return plan
+	case h.Large*2 >= h.files():
+		plan.Parallelism = 2
+		plan.Reason = "mostly large files"
+		if slow {
+			plan.ChunkSize = 4 * defaultChunkSize
+			plan.Reason += " over a high-latency connection"
+		}
+	case h.Small*2 >= h.files():
+		plan.Parallelism = 8
+		plan.Reason = "mostly small files"
+		if slow {
+			plan.Parallelism = 16
+			plan.Reason += " over a high-latency connection"
+		}
+		plan.Batch = h.Small >= minBatchFiles
+	default:
+		plan.Reason = "a mix of file sizes"
+	}
+
+	if plan.Parallelism > h.files() {
+		plan.Parallelism = h.files()
+	}
+	if plan.ChunkSize > maxChunkSize {
+		plan.ChunkSize = maxChunkSize
+	}
+	return plan
+}
+
+func (p transferPlan) String() string {
+	s := fmt.Sprintf("%d at a time in %s chunks", p.Parallelism, humanize.IBytes(uint64(p.ChunkSize)))
+	if p.Batch {
+		s += ", small files committed in batches"
+	}
+	return s + " (" + p.Reason + ")"
+}
+
+// Picks the number of downloads to run at once for files of `sizes` when
+// `--auto-tune` is given, and otherwise returns `parallelism` unchanged.
+func tuneDownloads(cmd *cobra.Command, sizes []uint64, parallelism int) int {
+	if autoTune, _ := cmd.Flags().GetBool("auto-tune"); !autoTune {
+		return parallelism
+	}
+	var h sizeHistogram
+	for _, size := range sizes {
+		h.add(int64(size))
+	}
+	plan := planTransfer(h, probeConnection())
+	if config.Verbose {
+		fmt.Fprintf(os.Stderr, "Transfer plan: %d at a time (%s)\n", plan.Parallelism, plan.Reason)
+	}
+	return plan.Parallelism
+}
+
+// Measures the round trip of a cheap API call. A failed probe just leaves the
+// planner assuming a fast connection; the transfer will report real errors.
+func probeConnection() (probe connectionProbe) {
+	start := time.Now()
+	if _, err := newUsersClient(cmdCtx).GetCurrentAccount(); err == nil {
+		probe.RTT = time.Since(start)
+	}
+	return
+}
@@ -0,0 +1,113 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanTransfer(t *testing.T) {
+	const mib = 1 << 20
+	fast := connectionProbe{RTT: 20 * time.Millisecond}
+	slow := connectionProbe{RTT: 300 * time.Millisecond}
+
+	tests := []struct {
+		name  string
+		sizes []int64
+		probe connectionProbe
+		want  transferPlan
+	}{
+		{
+			name: "nothing",
+			want: transferPlan{Parallelism: 4, ChunkSize: defaultChunkSize, Reason: "nothing to transfer"},
+		},
+		{
+			name:  "a few small files",
+			sizes: []int64{10, 20, 30},
+			probe: fast,
+			want:  transferPlan{Parallelism: 3, ChunkSize: defaultChunkSize, Reason: "mostly small files"},
+		},
+		{
+			name:  "many small files are batched",
+			sizes: repeatSize(100, 40),
+			probe: fast,
+			want:  transferPlan{Parallelism: 8, ChunkSize: defaultChunkSize, Batch: true, Reason: "mostly small files"},
+		},
+		{
+			name:  "small files over a slow connection",
+			sizes: repeatSize(100, minBatchFiles),
+			probe: slow,
+			want:  transferPlan{Parallelism: 16, ChunkSize: defaultChunkSize, Batch: true, Reason: "mostly small files over a high-latency connection"},
+		},
+		{
+			name:  "one short of a batch",
+			sizes: append(repeatSize(100, minBatchFiles-1), repeatSize(8*mib, minBatchFiles-2)...),
+			probe: fast,
+			want:  transferPlan{Parallelism: 8, ChunkSize: defaultChunkSize, Reason: "mostly small files"},
+		},
+		{
+			name:  "large files",
+			sizes: []int64{1 << 30, 2 << 30, 100},
+			probe: fast,
+			want:  transferPlan{Parallelism: 2, ChunkSize: defaultChunkSize, Reason: "mostly large files"},
+		},
+		{
+			name:  "large files over a slow connection",
+			sizes: []int64{1 << 30, 2 << 30},
+			probe: slow,
+			want:  transferPlan{Parallelism: 2, ChunkSize: 4 * defaultChunkSize, Reason: "mostly large files over a high-latency connection"},
+		},
+		{
+			name:  "a failed probe counts as fast",
+			sizes: []int64{1 << 30, 2 << 30},
+			want:  transferPlan{Parallelism: 2, ChunkSize: defaultChunkSize, Reason: "mostly large files"},
+		},
+		{
+			name:  "a mix",
+			sizes: []int64{100, 2 * mib, 3 * mib, 4 * mib, 1 << 30},
+			probe: slow,
+			want:  transferPlan{Parallelism: 4, ChunkSize: defaultChunkSize, Reason: "a mix of file sizes"},
+		},
+	}
+	for _, tt := range tests {
+		var h sizeHistogram
+		for _, size := range tt.sizes {
+			h.add(size)
+		}
+		if got := planTransfer(h, tt.probe); got != tt.want {
+			t.Errorf("%s: planTransfer = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSizeHistogram(t *testing.T) {
+	var h sizeHistogram
+	for _, size := range []int64{0, smallFileSize - 1, smallFileSize, largeFileSize - 1, largeFileSize, 1 << 40} {
+		h.add(size)
+	}
+	want := sizeHistogram{Small: 2, Medium: 2, Large: 2, TotalBytes: 2*smallFileSize + 2*largeFileSize - 2 + 1<<40}
+	if h != want {
+		t.Errorf("histogram = %+v, want %+v", h, want)
+	}
+}
+
+func repeatSize(size int64, n int) []int64 {
+	sizes := make([]int64, n)
+	for i := range sizes {
+		sizes[i] = size
+	}
+	return sizes
+}
@@ -0,0 +1,325 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+// The profile whose tokens live in auth.json, used unless --profile or
+// "profile use" chooses another.
+const defaultProfile = "default"
+
+// Other profiles keep their tokens in profiles/<name>.json, in the same
+// format as auth.json.
+const profilesDirName = "profiles"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func profilesDir() (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, ".config", "dbxcli", profilesDirName), nil
+}
+
+// Returns the file holding the tokens of `profile`; an empty name is the
+// default profile.
+func authFilePath(profile string) (string, error) {
+	if profile == "" || profile == defaultProfile {
+		dir, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		return path.Join(dir, ".config", "dbxcli", configFileName), nil
+	}
+	if !profileNamePattern.MatchString(profile) {
+		return "", fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", profile)
+	}
+	dir, err := profilesDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, profile+".json"), nil
+}
+
+// Named profiles have to be added before they're used, so that a mistyped
+// `--profile` doesn't start authorizing a new account. The default profile
+// is authorized on first use, as it always has been.
+func checkProfileExists(profile string, filePath string) error {
+	if profile == "" || profile == defaultProfile {
+		return nil
+	}
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return fmt.Errorf("there's no profile %q; add it with `dbxcli profile add %s`", profile, profile)
+	}
+	return nil
+}
+
+// Returns the names of the profiles with saved tokens, default first.
+func profileNames() ([]string, error) {
+	var names []string
+	if p, err := authFilePath(defaultProfile); err != nil {
+		return nil, err
+	} else if _, err := os.Stat(p); err == nil {
+		names = append(names, defaultProfile)
+	}
+
+	dir, err := profilesDir()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var named []string
+	for _, info := range infos {
+		name := strings.TrimSuffix(info.Name(), ".json")
+		if !info.IsDir() && name != info.Name() && profileNamePattern.MatchString(name) {
+			named = append(named, name)
+		}
+	}
+	sort.Strings(named)
+	return append(names, named...), nil
+}
+
+func currentProfile(cmd *cobra.Command) string {
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		return profile
+	}
+	return defaultProfile
+}
+
+// A profile as printed by `profile list --json`.
+type jsonProfile struct {
+	Name    string   `json:"name"`
+	Current bool     `json:"current"`
+	Tokens  []string `json:"tokens"`
+}
+
+func profileList(cmd *cobra.Command, args []string) (err error) {
+	names, err := profileNames()
+	if err != nil {
+		return
+	}
+	current := currentProfile(cmd)
+
+	var profiles []jsonProfile
+	for _, name := range names {
+		filePath, err := authFilePath(name)
+		if err != nil {
+			return err
+		}
+		tokMap, _ := readTokens(filePath)
+		kinds := make(map[string]bool)
+		for _, tokens := range tokMap {
+			for kind, token := range tokens {
+				if token != nil && token.AccessToken != "" {
+					kinds[kind] = true
+				}
+			}
+		}
+		p := jsonProfile{Name: name, Current: name == current, Tokens: []string{}}
+		for kind := range kinds {
+			p.Tokens = append(p.Tokens, kind)
+		}
+		sort.Strings(p.Tokens)
+		profiles = append(profiles, p)
+	}
+
+	if jsonMode(cmd) {
+		if profiles == nil {
+			profiles = []jsonProfile{}
+		}
+		return printJSON(profiles)
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintln(os.Stderr, "No profiles yet; add one with `dbxcli profile add`")
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	for _, p := range profiles {
+		marker := " "
+		if p.Current {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", marker, p.Name, strings.Join(p.Tokens, ", "))
+	}
+	return w.Flush()
+}
+
+func profileAdd(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`profile add` requires a `name` argument")
+	}
+	name := args[0]
+	filePath, err := authFilePath(name)
+	if err != nil {
+		return
+	}
+	if _, err = os.Stat(filePath); err == nil {
+		return fmt.Errorf("profile %q already exists; remove it first to authorize a different account", name)
+	}
+
+	domain, _ := cmd.Flags().GetString("domain")
+	token, err := authorize(oauthConfig(tokenPersonal, domain))
+	if err != nil {
+		return
+	}
+	writeTokens(filePath, TokenMap{domain: {tokenPersonal: token}})
+	fmt.Printf("Added profile %s; use it with --profile %s or \"dbxcli profile use %s\"\n", name, name, name)
+	return
+}
+
+func profileRemove(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`profile remove` requires a `name` argument")
+	}
+	name := args[0]
+	filePath, err := authFilePath(name)
+	if err != nil {
+		return
+	}
+	tokMap, err := readTokens(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("there's no profile %q", name)
+	}
+	if err != nil {
+		return
+	}
+	revokeTokens(tokMap)
+	forgetKeyringTokens(filePath, tokMap)
+	if err = os.Remove(filePath); err != nil {
+		return
+	}
+
+	s, err := readSettings()
+	if err != nil {
+		return
+	}
+	if s.Flags["profile"] == name {
+		delete(s.Flags, "profile")
+		if err = writeSettings(s); err != nil {
+			return
+		}
+	}
+	fmt.Printf("Removed profile %s\n", name)
+	return
+}
+
+func profileUse(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`profile use` requires a `name` argument")
+	}
+	name := args[0]
+	filePath, err := authFilePath(name)
+	if err != nil {
+		return
+	}
+	if err = checkProfileExists(name, filePath); err != nil {
+		return
+	}
+
+	s, err := readSettings()
+	if err != nil {
+		return
+	}
+	if name == defaultProfile {
+		delete(s.Flags, "profile")
+	} else {
+		if s.Flags == nil {
+			s.Flags = make(map[string]string)
+		}
+		s.Flags["profile"] = name
+	}
+	if err = writeSettings(s); err != nil {
+		return
+	}
+	fmt.Printf("Using profile %s\n", name)
+	return
+}
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage account profiles",
+	Long: `Manage account profiles, for using dbxcli with several Dropbox accounts.
+
+Each profile has its own tokens. The "default" profile is the one dbxcli has
+always used, kept in ~/.config/dbxcli/auth.json; others are kept in
+~/.config/dbxcli/profiles. Where the system has a keyring (the macOS
+Keychain, the Secret Service through secret-tool, or the Windows Credential
+Manager) the tokens themselves are kept in it, and the files only note which
+there are. Commands use the profile chosen by --profile, or else the one
+chosen by "dbxcli profile use".`,
+	Example: `  dbxcli profile add work
+  dbxcli --profile work ls /
+  dbxcli profile use work
+  dbxcli profile list`,
+	// Managing profiles doesn't need a Dropbox account, but the current
+	// profile may come from the config file.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return applyFlagDefaults(cmd) },
+}
+
+// profileListCmd represents the profile list command
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles, marking the current one",
+	RunE:  profileList,
+}
+
+// profileAddCmd represents the profile add command
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a profile and authorize an account for it",
+	RunE:  profileAdd,
+}
+
+// profileRemoveCmd represents the profile remove command
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Revoke a profile's tokens and remove it",
+	RunE:  profileRemove,
+}
+
+// profileUseCmd represents the profile use command
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Choose the profile commands use by default",
+	RunE:  profileUse,
+}
+
+func init() {
+	RootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileAddCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+	profileCmd.AddCommand(profileUseCmd)
+}
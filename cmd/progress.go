@@ -0,0 +1,216 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mitchellh/ioprogress"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Ways of reporting transfer progress, chosen with `--progress`.
+const (
+	progressNone  = "none"
+	progressPlain = "plain"
+	progressAuto  = "auto"
+	progressFancy = "fancy"
+)
+
+var (
+	progressMode     = progressAuto
+	progressInterval = 10 * time.Second
+)
+
+// Reads the progress flags. "auto" is resolved here: the live display is only
+// used when stderr is a terminal, since in CI logs every redraw becomes a line
+// of its own. With --json, stderr that isn't a terminal is left to lines of
+// JSON, so there's no progress at all.
+func initProgress(cmd *cobra.Command) (err error) {
+	mode, _ := cmd.Flags().GetString("progress")
+	if progressInterval, err = cmd.Flags().GetDuration("progress-interval"); err != nil {
+		return
+	}
+
+	switch mode {
+	case progressNone, progressPlain, progressFancy:
+		progressMode = mode
+	case progressAuto:
+		switch {
+		case terminal.IsTerminal(int(os.Stderr.Fd())):
+			progressMode = progressFancy
+		case jsonMode(cmd):
+			progressMode = progressNone
+		default:
+			progressMode = progressPlain
+		}
+	default:
+		return fmt.Errorf("invalid `--progress` %q: use none, plain, auto or fancy", mode)
+	}
+	return
+}
+
+// Draws a line at most every `progressInterval`, like
+// "Uploading foo.iso: 1.4 GiB / 3.0 GiB (46%) in 2m10s".
+func drawPlain(w io.Writer, label string, name string) ioprogress.DrawFunc {
+	start := time.Now()
+	return func(progress, total int64) error {
+		// ioprogress asks for a line break when it's done, and draws once
+		// before anything has been read; neither is worth a line here.
+		if progress < 0 || (progress == 0 && total > 0) {
+			return nil
+		}
+		if total <= 0 {
+			// The size of a stream isn't known in advance.
+			_, err := fmt.Fprintf(w, "%s %s: %s in %s\n", label, name,
+				humanize.IBytes(uint64(progress)), time.Since(start).Round(time.Second))
+			return err
+		}
+		percent := progress * 100 / total
+		_, err := fmt.Fprintf(w, "%s %s: %s / %s (%d%%) in %s\n", label, name,
+			humanize.IBytes(uint64(progress)), humanize.IBytes(uint64(total)),
+			percent, time.Since(start).Round(time.Second))
+		return err
+	}
+}
+
+// Wraps `r` so reading from it reports progress on stderr in the selected
+// style, and fails once `ctx` is done. `label` describes the transfer, e.g.
+// "Uploading", and `name` what's being transferred; `size` is 0 if unknown.
+func newProgressReader(ctx context.Context, r io.Reader, size int64, label string, name string) io.Reader {
+	r = contextReader{ctx, r}
+	if t, ok := ctx.Value(tallyKey{}).(*transferTally); ok {
+		return tallyReader{r, t}
+	}
+
+	switch progressMode {
+	case progressNone:
+		return r
+	case progressPlain:
+		return &ioprogress.Reader{
+			Reader:       r,
+			DrawFunc:     drawPlain(os.Stderr, label, name),
+			DrawInterval: progressInterval,
+			Size:         size,
+		}
+	}
+	return &ioprogress.Reader{
+		Reader: r,
+		DrawFunc: ioprogress.DrawTerminalf(os.Stderr, func(progress, total int64) string {
+			if total <= 0 {
+				return fmt.Sprintf("%s %s", label, humanize.IBytes(uint64(progress)))
+			}
+			return fmt.Sprintf("%s %s/%s", label,
+				humanize.IBytes(uint64(progress)), humanize.IBytes(uint64(total)))
+		}),
+		Size: size,
+	}
+}
+
+// Totals up several transfers that run at once, so their progress is drawn
+// as one line, like "Downloading 3/10 files: 1.4 GiB / 3.0 GiB (46%)",
+// rather than a line each fighting over the terminal.
+type transferTally struct {
+	label string
+	files int64
+	size  int64
+
+	// Updated atomically by the transfers.
+	done     int64
+	finished int64
+}
+
+type tallyKey struct{}
+
+// Returns a context under which newProgressReader counts towards `t` instead
+// of drawing progress of its own.
+func withTally(ctx context.Context, t *transferTally) context.Context {
+	return context.WithValue(ctx, tallyKey{}, t)
+}
+
+// Counts a finished transfer, failed or not.
+func (t *transferTally) fileDone() {
+	atomic.AddInt64(&t.finished, 1)
+}
+
+func (t *transferTally) String() string {
+	progress := fmt.Sprintf("%s %d/%d files: %s", t.label,
+		atomic.LoadInt64(&t.finished), t.files, humanize.IBytes(uint64(atomic.LoadInt64(&t.done))))
+	if t.size > 0 {
+		progress += fmt.Sprintf(" / %s (%d%%)", humanize.IBytes(uint64(t.size)), atomic.LoadInt64(&t.done)*100/t.size)
+	}
+	return progress
+}
+
+// Draws `t` on stderr in the selected style until the returned function is
+// called.
+func (t *transferTally) draw() (stop func()) {
+	if progressMode == progressNone || t.files == 0 {
+		return func() {}
+	}
+	interval, draw := time.Second, ioprogress.DrawTerminalf(os.Stderr, func(int64, int64) string {
+		return t.String()
+	})
+	if progressMode == progressPlain {
+		start := time.Now()
+		interval = progressInterval
+		draw = func(int64, int64) error {
+			_, err := fmt.Fprintf(os.Stderr, "%s in %s\n", t, time.Since(start).Round(time.Second))
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				draw(0, 0)
+			case <-done:
+				if progressMode == progressFancy {
+					draw(0, 0)
+					draw(-1, -1)
+				}
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+type tallyReader struct {
+	r io.Reader
+	t *transferTally
+}
+
+func (r tallyReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	atomic.AddInt64(&r.t.done, int64(n))
+	return
+}
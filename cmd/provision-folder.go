@@ -0,0 +1,419 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
+	"github.com/spf13/cobra"
+)
+
+// Access levels a group can be given on a shared folder. Groups can't own
+// folders.
+var groupAccessLevels = []string{"editor", "viewer", "viewer_no_comment"}
+
+// A group and the access it should have, from `--group "Name:level"`.
+type groupGrant struct {
+	name   string
+	access string
+	id     string
+}
+
+func parseGroupGrants(specs []string) ([]groupGrant, error) {
+	var grants []groupGrant
+	for _, spec := range specs {
+		// Group names may contain colons; the level never does.
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid `--group` %q; use \"Name:level\" with a level of %s", spec, strings.Join(groupAccessLevels, ", "))
+		}
+		g := groupGrant{name: spec[:i], access: strings.ToLower(spec[i+1:])}
+		valid := false
+		for _, level := range groupAccessLevels {
+			valid = valid || g.access == level
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid access level %q for group %s; use one of %s", g.access, g.name, strings.Join(groupAccessLevels, ", "))
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// Fills in the ids of the groups in `grants` by name, ignoring case. Every
+// name must exist before anything is changed.
+func resolveGroupIDs(dbx team.Client, grants []groupGrant) error {
+	res, err := allGroups(dbx)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]string)
+	for _, g := range res.Groups {
+		ids[strings.ToLower(g.GroupName)] = g.GroupId
+	}
+
+	var unknown []string
+	for i := range grants {
+		if grants[i].id = ids[strings.ToLower(grants[i].name)]; grants[i].id == "" {
+			unknown = append(unknown, grants[i].name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("no such group: %s; see `dbxcli team groups list`", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+type authenticatedAdmin struct {
+	AdminProfile struct {
+		TeamMemberID string `json:"team_member_id"`
+	} `json:"admin_profile"`
+}
+
+// Sends requests to user routes, such as the sharing ones, as the admin who
+// authorized the team token.
+type adminSession struct {
+	ctx    context.Context
+	header http.Header
+}
+
+func newAdminSession(ctx context.Context) (*adminSession, error) {
+	var admin authenticatedAdmin
+	if err := rpc(ctx, "team", "token/get_authenticated_admin", nil, &admin); err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	header.Set("Dropbox-API-Select-Admin", admin.AdminProfile.TeamMemberID)
+	return &adminSession{ctx, header}, nil
+}
+
+func (a *adminSession) rpc(namespace string, route string, arg interface{}, res interface{}) error {
+	return rpcWithHeader(a.ctx, namespace, route, arg, res, a.header)
+}
+
+type teamFolder struct {
+	TeamFolderID string `json:"team_folder_id"`
+	Name         string `json:"name"`
+	// active, archived or archive_in_progress.
+	Status dropbox.Tagged `json:"status"`
+}
+
+type teamFolderList struct {
+	TeamFolders []teamFolder `json:"team_folders"`
+	Cursor      string       `json:"cursor"`
+	HasMore     bool         `json:"has_more"`
+}
+
+// Creates the team folder `name`, or finds it if it already exists. Team
+// folders are shared from the start, so its id is also its shared folder id.
+func ensureTeamFolder(ctx context.Context, name string) (id string, created bool, err error) {
+	var tf teamFolder
+	err = rpc(ctx, "team", "team_folder/create", struct {
+		Name string `json:"name"`
+	}{name}, &tf)
+	if err == nil {
+		return tf.TeamFolderID, true, nil
+	}
+	if e, ok := err.(rpcError); !ok || !strings.HasPrefix(e.ErrorSummary, "folder_name_already_used") {
+		return "", false, err
+	}
+
+	folders, err := listTeamFolders(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	for _, f := range folders {
+		if strings.EqualFold(f.Name, name) {
+			return f.TeamFolderID, false, nil
+		}
+	}
+	return "", false, fmt.Errorf("the name %s is taken, but not by a team folder", name)
+}
+
+// Lists all of the team's folders, archived ones included.
+func listTeamFolders(ctx context.Context) (folders []teamFolder, err error) {
+	var list teamFolderList
+	err = rpc(ctx, "team", "team_folder/list", struct{}{}, &list)
+	for err == nil {
+		folders = append(folders, list.TeamFolders...)
+		if !list.HasMore {
+			return
+		}
+		cursor := list.Cursor
+		list = teamFolderList{}
+		err = rpc(ctx, "team", "team_folder/list/continue", struct {
+			Cursor string `json:"cursor"`
+		}{cursor}, &list)
+	}
+	return nil, err
+}
+
+type shareFolderStatus struct {
+	dropbox.Tagged
+	AsyncJobID     string `json:"async_job_id,omitempty"`
+	SharedFolderID string `json:"shared_folder_id,omitempty"`
+}
+
+// Creates the folder `p` unless it exists and shares it unless it's shared,
+// waiting for the share to finish. Returns the shared folder id and what was
+// done.
+func ensureSharedFolder(a *adminSession, p string) (id string, steps []string, err error) {
+	err = a.rpc("files", "create_folder_v2", struct {
+		Path string `json:"path"`
+	}{p}, nil)
+	switch e, ok := err.(rpcError); {
+	case err == nil:
+		steps = append(steps, "created folder "+p)
+	case ok && strings.HasPrefix(e.ErrorSummary, "path/conflict/folder"):
+		err = nil
+	default:
+		return
+	}
+
+	var md struct {
+		SharedFolderID string `json:"shared_folder_id"`
+	}
+	if err = a.rpc("files", "get_metadata", struct {
+		Path string `json:"path"`
+	}{p}, &md); err != nil {
+		return
+	}
+	if md.SharedFolderID != "" {
+		return md.SharedFolderID, steps, nil
+	}
+
+	var status shareFolderStatus
+	if err = a.rpc("sharing", "share_folder", struct {
+		Path string `json:"path"`
+	}{p}, &status); err != nil {
+		return
+	}
+	if status.Tag == "async_job_id" {
+		jobID := asyncJobIDArg{status.AsyncJobID}
+		err = retry.PollUntil(a.ctx, batchPollPolicy, func() (bool, error) {
+			status = shareFolderStatus{}
+			if err := a.rpc("sharing", "check_share_job_status", jobID, &status); err != nil {
+				return false, err
+			}
+			return status.Tag != "in_progress", nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	if status.Tag != "complete" || status.SharedFolderID == "" {
+		return "", steps, fmt.Errorf("sharing %s ended with status %q", p, status.Tag)
+	}
+	return status.SharedFolderID, append(steps, "shared "+p), nil
+}
+
+type folderGroupMember struct {
+	AccessType dropbox.Tagged `json:"access_type"`
+	Group      struct {
+		GroupID   string `json:"group_id"`
+		GroupName string `json:"group_name"`
+	} `json:"group"`
+}
+
+type folderMembers struct {
+	Groups []folderGroupMember `json:"groups"`
+	Cursor string              `json:"cursor"`
+}
+
+// Returns the access level of each group on the shared folder, by group id.
+func folderGroupAccess(a *adminSession, sharedFolderID string) (map[string]string, error) {
+	access := make(map[string]string)
+	var res folderMembers
+	err := a.rpc("sharing", "list_folder_members", struct {
+		SharedFolderID string `json:"shared_folder_id"`
+	}{sharedFolderID}, &res)
+	for err == nil {
+		for _, g := range res.Groups {
+			access[g.Group.GroupID] = g.AccessType.Tag
+		}
+		if res.Cursor == "" {
+			break
+		}
+		cursor := res.Cursor
+		res = folderMembers{}
+		err = a.rpc("sharing", "list_folder_members/continue", struct {
+			Cursor string `json:"cursor"`
+		}{cursor}, &res)
+	}
+	return access, err
+}
+
+type memberSelector struct {
+	dropbox.Tagged
+	DropboxID string `json:"dropbox_id"`
+}
+
+func groupSelector(id string) memberSelector {
+	return memberSelector{dropbox.Tagged{Tag: "dropbox_id"}, id}
+}
+
+type folderMemberAdd struct {
+	Member      memberSelector `json:"member"`
+	AccessLevel dropbox.Tagged `json:"access_level"`
+}
+
+type addFolderMemberArg struct {
+	SharedFolderID string            `json:"shared_folder_id"`
+	Members        []folderMemberAdd `json:"members"`
+	Quiet          bool              `json:"quiet"`
+}
+
+type updateFolderMemberArg struct {
+	SharedFolderID string         `json:"shared_folder_id"`
+	Member         memberSelector `json:"member"`
+	AccessLevel    dropbox.Tagged `json:"access_level"`
+}
+
+// Brings the access of one group in line with `g`. Returns what was done.
+func grantGroup(a *adminSession, sharedFolderID string, current map[string]string, g groupGrant, enforce bool) (string, error) {
+	have, isMember := current[g.id]
+	switch {
+	case !isMember:
+		err := a.rpc("sharing", "add_folder_member", addFolderMemberArg{
+			SharedFolderID: sharedFolderID,
+			Members:        []folderMemberAdd{{groupSelector(g.id), dropbox.Tagged{Tag: g.access}}},
+			Quiet:          true,
+		}, nil)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("added group %s as %s", g.name, g.access), nil
+	case have == g.access:
+		return fmt.Sprintf("group %s already has %s access", g.name, g.access), nil
+	case !enforce:
+		return fmt.Sprintf("group %s has %s access instead of %s; use `--enforce` to change it", g.name, have, g.access), nil
+	}
+	err := a.rpc("sharing", "update_folder_member", updateFolderMemberArg{
+		SharedFolderID: sharedFolderID,
+		Member:         groupSelector(g.id),
+		AccessLevel:    dropbox.Tagged{Tag: g.access},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("changed group %s from %s to %s", g.name, have, g.access), nil
+}
+
+func provisionFolder(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`provision-folder` requires a `path` argument")
+	}
+	if jsonMode(cmd) {
+		return errors.New("`provision-folder` only describes what it did in text; leave out `--json`")
+	}
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	specs, _ := cmd.Flags().GetStringSlice("group")
+	grants, err := parseGroupGrants(specs)
+	if err != nil {
+		return
+	}
+	useTeamFolder, _ := cmd.Flags().GetBool("team-folder")
+	if useTeamFolder && pathDepth(p) != 1 {
+		return fmt.Errorf("team folders live at the top level; %s isn't", p)
+	}
+	enforce, _ := cmd.Flags().GetBool("enforce")
+
+	// Look everything up before changing anything, so a typo in a group name
+	// leaves no half-provisioned folder behind.
+	if err = resolveGroupIDs(newTeamClient(cmdCtx), grants); err != nil {
+		return
+	}
+	admin, err := newAdminSession(cmdCtx)
+	if err != nil {
+		return
+	}
+
+	var sharedFolderID string
+	if useTeamFolder {
+		var created bool
+		if sharedFolderID, created, err = ensureTeamFolder(cmdCtx, path.Base(p)); err != nil {
+			return
+		}
+		if created {
+			fmt.Printf("created team folder %s\n", path.Base(p))
+		}
+	} else {
+		var steps []string
+		sharedFolderID, steps, err = ensureSharedFolder(admin, p)
+		for _, step := range steps {
+			fmt.Println(step)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	current, err := folderGroupAccess(admin, sharedFolderID)
+	if err != nil {
+		return
+	}
+	failed := 0
+	for _, g := range grants {
+		step, err := grantGroup(admin, sharedFolderID, current, g, enforce)
+		if err != nil {
+			fmt.Printf("group %s: failed: %s\n", g.name, explainError(err))
+			failed++
+			continue
+		}
+		fmt.Println(step)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d groups could not be set up", failed, len(grants))
+	}
+	return
+}
+
+// provisionFolderCmd represents the provision-folder command
+var provisionFolderCmd = &cobra.Command{
+	Use:   "provision-folder [flags] <path>",
+	Short: "Create a shared folder and give groups access to it",
+	Long: `Create a folder, share it and give each --group access to it, as the admin
+who authorized dbxcli for the team. With --team-folder, a team folder named
+after <path> is created instead.
+
+Each --group is a group name and an access level separated by a colon: one
+of editor, viewer or viewer_no_comment. All groups are looked up before
+anything is changed.
+
+Running the command again converges on the same setup: an existing folder or
+share is reused and groups that already have access are left alone. A group
+with different access is reported, and changed with --enforce.`,
+	Example: `  dbxcli team provision-folder /Projects/Apollo --group Engineering:editor --group Contractors:viewer
+  dbxcli team provision-folder /Apollo --team-folder --group Leads:editor --enforce`,
+	RunE: provisionFolder,
+}
+
+func init() {
+	teamCmd.AddCommand(provisionFolderCmd)
+	provisionFolderCmd.Flags().StringSlice("group", nil, "Group and access level, e.g. \"Engineering:editor\" (repeatable or comma-separated)")
+	provisionFolderCmd.Flags().Bool("team-folder", false, "Create a team folder instead of a shared folder")
+	provisionFolderCmd.Flags().Bool("enforce", false, "Change the access of groups that have a different level")
+}
@@ -0,0 +1,149 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// Appends whose upload loses a race with another writer are started over
+// after a short, randomized wait so that competing appenders spread out.
+func appendRetryPolicy(attempts int) retry.Policy {
+	return retry.Exponential{
+		Initial:     250 * time.Millisecond,
+		Max:         5 * time.Second,
+		Jitter:      0.5,
+		MaxAttempts: attempts,
+	}
+}
+
+// Reports whether an upload failed because the file changed since it was
+// read, or appeared since it was found missing.
+func isWriteConflict(err error) bool {
+	summary, ok := apiErrorSummary(err)
+	if !ok {
+		return false
+	}
+	for _, tag := range strings.Split(summary, "/") {
+		if tag == "conflict" {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads the current contents of `dst` and its revision. A missing file reads
+// as empty, with no revision.
+func readForAppend(dbx files.Client, dst string) (contents []byte, rev string, err error) {
+	md, body, err := dbx.Download(files.NewDownloadArg(dst))
+	if isMissing(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	if contents, err = ioutil.ReadAll(body); err != nil {
+		return
+	}
+	return contents, md.Rev, nil
+}
+
+// Appends `data` to the remote file `dst` by reading it, adding `data` at the
+// end and writing it back on the condition that it's still at the revision
+// that was read. If another writer got there first, the whole round trip is
+// done again, up to `attempts` times in total, so concurrent appends are
+// never silently lost.
+func appendRemote(ctx context.Context, dbx files.Client, data []byte, dst string, attempts int, chunkSize int64) (md *files.FileMetadata, err error) {
+	err = retry.Do(ctx, appendRetryPolicy(attempts), func() error {
+		contents, rev, err := readForAppend(dbx, dst)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+
+		commitInfo := files.NewCommitInfo(dst)
+		if rev != "" {
+			commitInfo.Mode.Tag = files.WriteModeUpdate
+			commitInfo.Mode.Update = rev
+		} else {
+			commitInfo.Mode.Tag = files.WriteModeAdd
+		}
+		commitInfo.ClientModified = time.Now().UTC().Round(time.Second)
+
+		contents = append(contents, data...)
+		size := int64(len(contents))
+		if size > chunkSize {
+			md, err = uploadChunked(ctx, dbx, bytes.NewReader(contents), commitInfo, size, chunkSize)
+		} else {
+			md, err = dbx.Upload(commitInfo, bytes.NewReader(contents))
+		}
+		if err == nil {
+			// Dropbox renames instead of failing on some conflicts; the data
+			// must land in `dst` itself.
+			if md != nil && md.PathDisplay != "" && !strings.EqualFold(md.PathDisplay, dst) {
+				return retry.Permanent(fmt.Errorf("%s changed while appending to it, and the result was saved as %s", dst, md.PathDisplay))
+			}
+			return nil
+		}
+		if isWriteConflict(err) {
+			logger.Info("append conflict, retrying", "dst", dst, "rev", rev)
+			return err
+		}
+		return retry.Permanent(err)
+	})
+	if isWriteConflict(err) {
+		err = fmt.Errorf("%s kept changing while appending to it; gave up after %d attempts", dst, attempts)
+	}
+	return
+}
+
+// Implements `put --append <source> <target>`, where <source> may be "-" to
+// read standard input.
+func putAppend(src string, dst string, attempts int, chunkSize int64) (err error) {
+	if attempts < 1 {
+		return errors.New("`--append-attempts` must be at least 1")
+	}
+
+	var data []byte
+	if src == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(src)
+	}
+	if err != nil {
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	if _, err = appendRemote(cmdCtx, newFilesClient(cmdCtx), data, dst, attempts, chunkSize); err != nil {
+		logger.Error("append failed", "src", src, "dst", dst, "error", err)
+		return
+	}
+	logger.Info("append", "src", src, "dst", dst, "bytes", len(data))
+	fmt.Printf("%s >> %s\n", src, dst)
+	return
+}
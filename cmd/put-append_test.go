@@ -0,0 +1,210 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func fileAtRev(p string, rev string) *files.FileMetadata {
+	md := fileMetadata(p, 0)
+	md.Rev = rev
+	return md
+}
+
+func downloadNotFound() error {
+	return files.DownloadAPIError{
+		APIError:      dropbox.APIError{ErrorSummary: "path/not_found/"},
+		EndpointError: &files.DownloadError{Tagged: dropbox.Tagged{Tag: "path"}, Path: &files.LookupError{Tagged: dropbox.Tagged{Tag: files.LookupErrorNotFound}}},
+	}
+}
+
+func uploadConflict() error {
+	return files.UploadAPIError{APIError: dropbox.APIError{ErrorSummary: "path/conflict/file/.."}}
+}
+
+// The uploads of an append, as the mode each was made with and its contents.
+type appendUpload struct {
+	mode, rev, content string
+}
+
+func appendUploads(fake *testutil.FakeFiles) (uploads []appendUpload) {
+	for _, c := range fake.Calls() {
+		if c.Method == "Upload" {
+			arg := c.Arg.(*files.CommitInfo)
+			uploads = append(uploads, appendUpload{arg.Mode.Tag, arg.Mode.Update, string(c.Content)})
+		}
+	}
+	return
+}
+
+func TestAppendRemote(t *testing.T) {
+	const dst = "/logs/app.log"
+	type download struct {
+		content string
+		rev     string
+		err     error
+	}
+	type upload struct {
+		rev string
+		err error
+	}
+	tests := []struct {
+		name      string
+		attempts  int
+		downloads []download
+		uploads   []upload
+		want      []appendUpload
+		err       string
+	}{
+		{
+			name:      "new file",
+			attempts:  3,
+			downloads: []download{{err: downloadNotFound()}},
+			uploads:   []upload{{rev: "r1"}},
+			want:      []appendUpload{{"add", "", "new\n"}},
+		},
+		{
+			name:      "existing file",
+			attempts:  3,
+			downloads: []download{{content: "old\n", rev: "r1"}},
+			uploads:   []upload{{rev: "r2"}},
+			want:      []appendUpload{{"update", "r1", "old\nnew\n"}},
+		},
+		{
+			name:      "lost a race",
+			attempts:  3,
+			downloads: []download{{content: "old\n", rev: "r1"}, {content: "old\nother\n", rev: "r2"}},
+			uploads:   []upload{{err: uploadConflict()}, {rev: "r3"}},
+			want:      []appendUpload{{"update", "r1", "old\nnew\n"}, {"update", "r2", "old\nother\nnew\n"}},
+		},
+		{
+			name:      "created meanwhile",
+			attempts:  3,
+			downloads: []download{{err: downloadNotFound()}, {content: "other\n", rev: "r1"}},
+			uploads:   []upload{{err: uploadConflict()}, {rev: "r2"}},
+			want:      []appendUpload{{"add", "", "new\n"}, {"update", "r1", "other\nnew\n"}},
+		},
+		{
+			name:      "kept losing",
+			attempts:  2,
+			downloads: []download{{content: "a", rev: "r1"}, {content: "ab", rev: "r2"}},
+			uploads:   []upload{{err: uploadConflict()}, {err: uploadConflict()}},
+			want:      []appendUpload{{"update", "r1", "anew\n"}, {"update", "r2", "abnew\n"}},
+			err:       "/logs/app.log kept changing while appending to it; gave up after 2 attempts",
+		},
+		{
+			name:      "other upload error",
+			attempts:  3,
+			downloads: []download{{content: "old\n", rev: "r1"}},
+			uploads:   []upload{{err: errors.New("insufficient_space")}},
+			want:      []appendUpload{{"update", "r1", "old\nnew\n"}},
+			err:       "insufficient_space",
+		},
+		{
+			name:      "download error",
+			attempts:  3,
+			downloads: []download{{err: errors.New("too_many_requests")}},
+			err:       "too_many_requests",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeFiles(t)
+			for _, d := range tt.downloads {
+				var md *files.FileMetadata
+				if d.rev != "" {
+					md = fileAtRev(dst, d.rev)
+				}
+				fake.RespondContent("Download", md, []byte(d.content), d.err)
+			}
+			for _, u := range tt.uploads {
+				fake.Respond("Upload", fileAtRev(dst, u.rev), u.err)
+			}
+
+			_, err := appendRemote(cmdCtx, fake, []byte("new\n"), dst, tt.attempts, 1<<20)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("appendRemote returned %v, want %q", err, tt.err)
+			}
+			got := appendUploads(fake)
+			if len(got) != len(tt.want) {
+				t.Fatalf("uploads = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("upload %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// If Dropbox saves the file under another name rather than failing, the
+// append has gone astray and isn't retried.
+func TestAppendRemoteRenamed(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.RespondContent("Download", fileAtRev("/app.log", "r1"), []byte("old\n"), nil)
+	fake.Respond("Upload", fileAtRev("/app (1).log", "r1"), nil)
+	_, err := appendRemote(cmdCtx, fake, []byte("new\n"), "/app.log", 3, 1<<20)
+	if err == nil || !strings.Contains(err.Error(), "saved as /app (1).log") {
+		t.Errorf("appendRemote returned %v", err)
+	}
+	if n := len(appendUploads(fake)); n != 1 {
+		t.Errorf("uploaded %d times", n)
+	}
+}
+
+func TestPutAppendStdin(t *testing.T) {
+	fake := useFakeFiles(t)
+	useStdin(t, "from stdin\n")
+	fake.RespondContent("Download", fileAtRev("/app.log", "r1"), []byte("old\n"), nil)
+	fake.Respond("Upload", fileAtRev("/app.log", "r2"), nil)
+	stdout, _, err := testutil.Capture(func() error { return putAppend("-", "/app.log", 3, 1<<20) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "- >> /app.log\n" {
+		t.Errorf("printed %q", stdout)
+	}
+	if got := appendUploads(fake); len(got) != 1 || got[0].content != "old\nfrom stdin\n" {
+		t.Errorf("uploads = %+v", got)
+	}
+
+	if err := putAppend("-", "/app.log", 0, 1<<20); err == nil {
+		t.Error("0 attempts were accepted")
+	}
+}
+
+func TestPutAppendDryRun(t *testing.T) {
+	fake := useFakeFiles(t)
+	setFlags(t, putCmd, map[string]string{"append": "true", "dry-run": "true"})
+	src := writeTempFile(t, "app.log", 10)
+	stdout, _, err := testutil.Capture(func() error { return put(putCmd, []string{src, "/app.log"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "append\t" + src + " >> /app.log\n"; stdout != want {
+		t.Errorf("printed %q, want %q", stdout, want)
+	}
+	if calls := fake.Methods(); len(calls) != 0 {
+		t.Errorf("calls = %v, want none", calls)
+	}
+}
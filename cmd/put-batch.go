@@ -0,0 +1,186 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// How long the committer waits for another upload before committing the
+// ones it has.
+const commitBatchDelay = 250 * time.Millisecond
+
+// Commits small uploads together with upload_session/finish_batch, the API's
+// fast path for many small files: each file is sent to an upload session of
+// its own, and the sessions are committed a batch at a time instead of taking
+// the lock on the destination folder once per file.
+type batchCommitter struct {
+	pending chan pendingCommit
+	done    chan struct{}
+}
+
+type pendingCommit struct {
+	arg    *files.UploadSessionFinishArg
+	result chan<- commitResult
+}
+
+type commitResult struct {
+	md  *files.FileMetadata
+	err error
+}
+
+// The launch result and job status of upload_session/finish_batch. The
+// vendored SDK's launch result drops the job id, so these are sent with rpc.
+type uploadBatchStatus struct {
+	dropbox.Tagged
+	AsyncJobID string            `json:"async_job_id,omitempty"`
+	Entries    []json.RawMessage `json:"entries,omitempty"`
+}
+
+func newBatchCommitter(ctx context.Context) *batchCommitter {
+	b := &batchCommitter{pending: make(chan pendingCommit), done: make(chan struct{})}
+	go b.run(ctx)
+	return b
+}
+
+// Commits whatever is still pending and stops the committer. No uploads may
+// be handed to it afterwards.
+func (b *batchCommitter) close() {
+	close(b.pending)
+	<-b.done
+}
+
+// Collects uploads until none has arrived for commitBatchDelay, which is
+// when all the workers are waiting on the committer, or until a batch is
+// full.
+func (b *batchCommitter) run(ctx context.Context) {
+	defer close(b.done)
+	var batch []pendingCommit
+	var flush <-chan time.Time
+	for {
+		select {
+		case c, ok := <-b.pending:
+			if !ok {
+				b.commit(ctx, batch)
+				return
+			}
+			batch = append(batch, c)
+			flush = time.After(commitBatchDelay)
+			if len(batch) < maxBatchEntries {
+				continue
+			}
+		case <-flush:
+		}
+		b.commit(ctx, batch)
+		batch, flush = nil, nil
+	}
+}
+
+// Uploads `body` to a session of its own and hands the session to the
+// committer. Returns once the batch it went into is committed.
+func (b *batchCommitter) upload(ctx context.Context, dbx files.Client, body io.Reader, commitInfo *files.CommitInfo) (md *files.FileMetadata, err error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return
+	}
+
+	arg := files.NewUploadSessionStartArg()
+	arg.Close = true
+	var res *files.UploadSessionStartResult
+	err = retry.Do(ctx, chunkRetryPolicy, func() (err error) {
+		res, err = dbx.UploadSessionStart(arg, bytes.NewReader(data))
+		return retryableUploadError(err)
+	})
+	if err != nil {
+		return
+	}
+
+	cursor := files.NewUploadSessionCursor(res.SessionId, uint64(len(data)))
+	result := make(chan commitResult, 1)
+	b.pending <- pendingCommit{files.NewUploadSessionFinishArg(cursor, commitInfo), result}
+	r := <-result
+	return r.md, r.err
+}
+
+// Commits `batch` and sends each upload its result.
+func (b *batchCommitter) commit(ctx context.Context, batch []pendingCommit) {
+	if len(batch) == 0 {
+		return
+	}
+	var args []*files.UploadSessionFinishArg
+	for _, c := range batch {
+		args = append(args, c.arg)
+	}
+	entries, err := finishUploadBatch(ctx, args)
+	for i, c := range batch {
+		if err != nil {
+			c.result <- commitResult{err: err}
+			continue
+		}
+		var entry files.UploadSessionFinishBatchResultEntry
+		if err := json.Unmarshal(entries[i], &entry); err != nil {
+			c.result <- commitResult{err: err}
+			continue
+		}
+		if entry.Tag != files.UploadSessionFinishBatchResultEntrySuccess {
+			var failure batchResultEntry
+			json.Unmarshal(entries[i], &failure)
+			c.result <- commitResult{err: fmt.Errorf("upload failed: %s", describeTagged(failure.Failure))}
+			continue
+		}
+		c.result <- commitResult{md: entry.Success}
+	}
+}
+
+// Runs upload_session/finish_batch and waits for it to finish, like
+// runBatch. Returns the raw entries of the result, one for each of `args`.
+func finishUploadBatch(ctx context.Context, args []*files.UploadSessionFinishArg) ([]json.RawMessage, error) {
+	status := new(uploadBatchStatus)
+	if err := rpc(ctx, "files", "upload_session/finish_batch", files.NewUploadSessionFinishBatchArg(args), status); err != nil {
+		return nil, err
+	}
+
+	if status.Tag == "async_job_id" {
+		jobID := asyncJobIDArg{status.AsyncJobID}
+		err := retry.PollUntil(ctx, batchPollPolicy, func() (bool, error) {
+			status = new(uploadBatchStatus)
+			if err := rpc(ctx, "files", "upload_session/finish_batch/check", jobID, status); err != nil {
+				return false, err
+			}
+			return status.Tag != "in_progress", nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if status.Tag != "complete" {
+		return nil, fmt.Errorf("upload_session/finish_batch ended with unexpected status %q", status.Tag)
+	}
+	if len(status.Entries) != len(args) {
+		return nil, fmt.Errorf("upload_session/finish_batch returned %d results for %d entries", len(status.Entries), len(args))
+	}
+	return status.Entries, nil
+}
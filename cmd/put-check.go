@@ -0,0 +1,222 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// What put would do with one file, as decided by planUploads.
+const (
+	planUpload    = "upload"
+	planOverwrite = "overwrite"
+	planRename    = "rename"
+	planSkip      = "skip"
+	planUnchanged = "unchanged"
+	planFail      = "fail"
+)
+
+// Everything planUploads needs to know about one upload. `Remote` is nil if
+// the destination is free. The hashes are Dropbox content hashes; an empty
+// one wasn't computed, and never matches.
+type planInput struct {
+	Src        string
+	Dst        string
+	Size       int64
+	ModTime    time.Time
+	Hash       string
+	Remote     files.IsMetadata
+	RemoteHash string
+}
+
+// One change a transfer would make, as listed by --check. Src is empty for
+// changes to Dst alone, such as deleting it.
+type plannedChange struct {
+	Src    string `json:"src,omitempty"`
+	Dst    string `json:"dst"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type uploadPlan []plannedChange
+
+// Returns the entries of the plan which would transfer something or fail.
+func (p uploadPlan) differences() (diffs uploadPlan) {
+	for _, u := range p {
+		if u.Action != planUnchanged && u.Action != planSkip {
+			diffs = append(diffs, u)
+		}
+	}
+	return
+}
+
+// Decides what put would do with each file under the conflict `strategy`,
+// without touching anything. A file whose destination already has the same
+// contents is unchanged whatever the strategy.
+func planUploads(inputs []planInput, strategy string) uploadPlan {
+	plan := make(uploadPlan, 0, len(inputs))
+	for _, in := range inputs {
+		u := plannedChange{Src: in.Src, Dst: in.Dst}
+		if f, ok := in.Remote.(*files.FileMetadata); ok && f.Size == uint64(in.Size) && in.Hash != "" && in.Hash == in.RemoteHash {
+			u.Action = planUnchanged
+			plan = append(plan, u)
+			continue
+		}
+
+		d := decideConflict(strategy, in.Remote, in.ModTime)
+		switch {
+		case d.err != nil:
+			u.Action, u.Reason = planFail, d.err.Error()
+		case !d.upload:
+			u.Action, u.Reason = planSkip, "differs, but --on-conflict is "+strategy
+		case d.autorename:
+			u.Action = planRename
+		case d.mode == "overwrite" && in.Remote != nil:
+			// Overwrite mode is also used for free destinations.
+			u.Action = planOverwrite
+		default:
+			u.Action = planUpload
+		}
+		plan = append(plan, u)
+	}
+	return plan
+}
+
+// Looks up what planUploads needs for `jobs`. Local files are only hashed
+// when the remote file has the same size, since they differ otherwise.
+func gatherPlanInputs(ctx context.Context, jobs []uploadJob) ([]planInput, error) {
+	inputs := make([]planInput, 0, len(jobs))
+	for _, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(job.src)
+		if err != nil {
+			return nil, err
+		}
+		in := planInput{Src: job.src, Dst: job.dst, Size: info.Size(), ModTime: info.ModTime()}
+
+		md, x, _, err := getMetadataExtras(ctx, job.dst)
+		switch {
+		case isMissing(err):
+		case err != nil:
+			return nil, err
+		default:
+			in.Remote, in.RemoteHash = md, x.ContentHash
+		}
+		if f, ok := in.Remote.(*files.FileMetadata); ok && f.Size == uint64(in.Size) && in.RemoteHash != "" {
+			if in.Hash, err = contentHash(job.src); err != nil {
+				return nil, err
+			}
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// Reports whether the local file `src` of `size` bytes has the same contents
+// as `remote`, whose content hash is `remoteHash`. The file is only hashed
+// when the sizes match.
+func sameContent(src string, size int64, remote files.IsMetadata, remoteHash string) (bool, error) {
+	f, ok := remote.(*files.FileMetadata)
+	if !ok || f.Size != uint64(size) || remoteHash == "" {
+		return false, nil
+	}
+	hash, err := contentHash(src)
+	if err != nil {
+		return false, err
+	}
+	return hash == remoteHash, nil
+}
+
+// Implements `put --check`: prints what an upload would change and exits
+// with status 1 if anything, 0 if nothing.
+func checkUploads(cmd *cobra.Command, jobs []uploadJob, strategy string) error {
+	if encrypt, _ := cmd.Flags().GetBool("encrypt"); encrypt {
+		return errors.New("`--check` can't compare encrypted uploads, which differ every time")
+	}
+	inputs, err := gatherPlanInputs(cmdCtx, jobs)
+	if err != nil {
+		return err
+	}
+	return reportCheck(cmd, planUploads(inputs, strategy).differences())
+}
+
+// Prints `changes` as a table, one per line.
+func printChanges(w io.Writer, changes []plannedChange) {
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 4, 8, 1, ' ', 0)
+	for _, c := range changes {
+		if c.Src != "" {
+			fmt.Fprintf(tw, "%s\t%s -> %s", c.Action, c.Src, c.Dst)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s", c.Action, c.Dst)
+		}
+		if c.Reason != "" {
+			fmt.Fprintf(tw, "\t(%s)", c.Reason)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// Prints one change a dry run would make as it's found, like a line of
+// printChanges, or in JSON mode as a line of JSON. `src` is empty for changes
+// to `dst` alone.
+func printDryRun(cmd *cobra.Command, action string, src string, dst string) {
+	switch {
+	case jsonMode(cmd):
+		printJSONLine(plannedChange{Src: src, Dst: dst, Action: action})
+	case src != "":
+		fmt.Printf("%s\t%s -> %s\n", action, src, dst)
+	default:
+		fmt.Printf("%s\t%s\n", action, dst)
+	}
+}
+
+// Prints `changes` to stdout, as a table or in JSON mode as a list.
+func listChanges(cmd *cobra.Command, changes []plannedChange) error {
+	if !jsonMode(cmd) {
+		printChanges(os.Stdout, changes)
+		return nil
+	}
+	if changes == nil {
+		changes = []plannedChange{}
+	}
+	return printJSON(changes)
+}
+
+// Ends a `--check`: lists the changes that were found, not at all with
+// --quiet, and exits with status 1 if there are any.
+func reportCheck(cmd *cobra.Command, changes []plannedChange) error {
+	if quiet, _ := cmd.Flags().GetBool("quiet"); !quiet {
+		if err := listChanges(cmd, changes); err != nil {
+			return err
+		}
+	}
+	if len(changes) > 0 {
+		return exitStatus(exitDifferences)
+	}
+	return nil
+}
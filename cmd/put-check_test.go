@@ -0,0 +1,169 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestPlanUploads(t *testing.T) {
+	older := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	remote := fileMetadata("/a.txt", 5)
+	remote.ServerModified = older.Add(time.Minute)
+
+	same := planInput{Src: "a.txt", Dst: "/a.txt", Size: 5, ModTime: newer, Hash: "h1", Remote: remote, RemoteHash: "h1"}
+	changed := same
+	changed.Hash = "h2"
+	stale := changed
+	stale.ModTime = older
+	// Hashes are only computed when the sizes match.
+	resized := planInput{Src: "a.txt", Dst: "/a.txt", Size: 6, ModTime: newer, Remote: remote, RemoteHash: "h1"}
+	free := planInput{Src: "b.txt", Dst: "/b.txt", Size: 1, ModTime: newer}
+	folder := planInput{Src: "c", Dst: "/c", Size: 1, ModTime: newer, Remote: folderMetadata("/c")}
+
+	tests := []struct {
+		in       planInput
+		strategy string
+		action   string
+		reason   string
+	}{
+		{same, conflictOverwrite, planUnchanged, ""},
+		{same, conflictFail, planUnchanged, ""},
+		{same, conflictRename, planUnchanged, ""},
+		{changed, conflictOverwrite, planOverwrite, ""},
+		{resized, conflictOverwrite, planOverwrite, ""},
+		{changed, conflictSkip, planSkip, "differs, but --on-conflict is skip"},
+		{changed, conflictRename, planRename, ""},
+		{changed, conflictFail, planFail, "/a.txt already exists"},
+		{changed, conflictNewer, planOverwrite, ""},
+		{stale, conflictNewer, planSkip, "differs, but --on-conflict is newer"},
+		{free, conflictOverwrite, planUpload, ""},
+		{free, conflictFail, planUpload, ""},
+		{folder, conflictOverwrite, planFail, "a folder already exists at /c"},
+		{folder, conflictRename, planRename, ""},
+	}
+	for i, tt := range tests {
+		plan := planUploads([]planInput{tt.in}, tt.strategy)
+		want := plannedChange{Src: tt.in.Src, Dst: tt.in.Dst, Action: tt.action, Reason: tt.reason}
+		if len(plan) != 1 || plan[0] != want {
+			t.Errorf("%d: planUploads(%s) = %+v, want %+v", i, tt.strategy, plan, want)
+		}
+	}
+}
+
+func TestUploadPlanDifferences(t *testing.T) {
+	plan := uploadPlan{
+		{Src: "a", Action: planUnchanged},
+		{Src: "b", Action: planUpload},
+		{Src: "c", Action: planSkip},
+		{Src: "d", Action: planFail},
+		{Src: "e", Action: planOverwrite},
+		{Src: "f", Action: planRename},
+	}
+	var got []string
+	for _, u := range plan.differences() {
+		got = append(got, u.Src)
+	}
+	if want := []string{"b", "d", "e", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("differences = %v, want %v", got, want)
+	}
+	if diffs := (uploadPlan{{Action: planUnchanged}, {Action: planSkip}}).differences(); len(diffs) != 0 {
+		t.Errorf("differences = %v, want none", diffs)
+	}
+}
+
+func TestCheckUploads(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"same.txt": "hello", "changed.txt": "hello", "new.txt": "x"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := contentHash(filepath.Join(dir, "same.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []uploadJob{
+		{filepath.Join(dir, "same.txt"), "/same.txt"},
+		{filepath.Join(dir, "changed.txt"), "/changed.txt"},
+		{filepath.Join(dir, "new.txt"), "/new.txt"},
+	}
+	metadata := func(name, hash string) string {
+		return fmt.Sprintf(`{".tag": "file", "name": "%s", "id": "id:%s", "path_lower": "/%s", "path_display": "/%s",
+			"rev": "015f0000000000000001", "size": 5, "client_modified": "2016-06-01T12:00:00Z",
+			"server_modified": "2016-06-01T12:00:00Z", "content_hash": "%s"}`, name, name, name, name, hash)
+	}
+	script := func(api *testutil.FakeAPI) {
+		api.Respond("files/get_metadata", 200, metadata("same.txt", hash))
+		api.Respond("files/get_metadata", 200, metadata("changed.txt", "0000"))
+		api.Respond("files/get_metadata", 409, `{"error_summary": "path/not_found/..", "error": {".tag": "path", "path": {".tag": "not_found"}}}`)
+	}
+
+	api := useFakeAPI(t)
+	script(api)
+	stdout, _, err := testutil.Capture(func() error { return checkUploads(putCmd, jobs, conflictOverwrite) })
+	if err != exitStatus(1) {
+		t.Errorf("checkUploads returned %v, want exit status 1", err)
+	}
+	want := fmt.Sprintf("overwrite %s -> /changed.txt\nupload    %s -> /new.txt\n", jobs[1].src, jobs[2].src)
+	if stdout != want {
+		t.Errorf("printed\n%s\nwant\n%s", stdout, want)
+	}
+
+	// Nothing differs once the changed and new files are left out.
+	api = useFakeAPI(t)
+	api.Respond("files/get_metadata", 200, metadata("same.txt", hash))
+	stdout, _, err = testutil.Capture(func() error { return checkUploads(putCmd, jobs[:1], conflictOverwrite) })
+	if err != nil || stdout != "" {
+		t.Errorf("checking an unchanged file printed %q, %v", stdout, err)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		setFlags(t, putCmd, map[string]string{"json": "true"})
+		script(useFakeAPI(t))
+		stdout, _, err := testutil.Capture(func() error { return checkUploads(putCmd, jobs, conflictOverwrite) })
+		if err != exitStatus(1) {
+			t.Errorf("checkUploads returned %v, want exit status 1", err)
+		}
+		var got []plannedChange
+		if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+			t.Fatalf("printed %q: %v", stdout, err)
+		}
+		want := []plannedChange{
+			{Src: jobs[1].src, Dst: "/changed.txt", Action: planOverwrite},
+			{Src: jobs[2].src, Dst: "/new.txt", Action: planUpload},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("printed %+v, want %+v", got, want)
+		}
+	})
+
+	setFlags(t, putCmd, map[string]string{"quiet": "true"})
+	api = useFakeAPI(t)
+	script(api)
+	stdout, _, err = testutil.Capture(func() error { return checkUploads(putCmd, jobs, conflictOverwrite) })
+	if err != exitStatus(1) || stdout != "" {
+		t.Errorf("with --quiet: printed %q, %v", stdout, err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// In a concurrent upload session every chunk but the last must be a multiple
+// of this size.
+const concurrentChunkAlign int64 = 4 << 20
+
+type sessionType struct {
+	dropbox.Tagged
+}
+
+// upload_session/start with a session type, which the vendored SDK predates.
+type concurrentSessionStartArg struct {
+	Close       bool        `json:"close"`
+	SessionType sessionType `json:"session_type"`
+}
+
+type concurrentChunk struct {
+	offset uint64
+	data   []byte
+	last   bool
+}
+
+// Uploads `r` through a concurrent upload session, with up to `parallelism`
+// chunks in flight at once. The chunks are read from `r` in order, so `r`
+// needn't be seekable, and at most `parallelism` of them are held in memory.
+func uploadConcurrent(ctx context.Context, dbx files.Client, r io.Reader, commitInfo *files.CommitInfo, sizeTotal int64, chunkSize int64, parallelism int) (md *files.FileMetadata, err error) {
+	if chunkSize < concurrentChunkAlign {
+		chunkSize = concurrentChunkAlign
+	}
+	chunkSize -= chunkSize % concurrentChunkAlign
+
+	var start files.UploadSessionStartResult
+	arg := &concurrentSessionStartArg{SessionType: sessionType{dropbox.Tagged{Tag: "concurrent"}}}
+	err = retry.Do(ctx, chunkRetryPolicy, func() error {
+		return retryableUploadError(contentUpload(ctx, "files", "upload_session/start", arg, nil, &start))
+	})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan concurrentChunk)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				appendArg := files.NewUploadSessionAppendArg(files.NewUploadSessionCursor(start.SessionId, c.offset))
+				appendArg.Close = c.last
+				err := retry.Do(ctx, chunkRetryPolicy, func() error {
+					return retryableUploadError(dbx.UploadSessionAppendV2(appendArg, bytes.NewReader(c.data)))
+				})
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	var offset uint64
+	for ctx.Err() == nil {
+		data, readErr := readChunk(r, make([]byte, chunkSize))
+		if readErr != nil {
+			fail(readErr)
+			break
+		}
+		last := int64(offset)+int64(len(data)) >= sizeTotal || len(data) == 0
+		select {
+		case chunks <- concurrentChunk{offset, data, last}:
+		case <-ctx.Done():
+		}
+		offset += uint64(len(data))
+		if last {
+			break
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	cursor := files.NewUploadSessionCursor(start.SessionId, offset)
+	return dbx.UploadSessionFinish(files.NewUploadSessionFinishArg(cursor, commitInfo), bytes.NewReader(nil))
+}
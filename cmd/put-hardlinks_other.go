@@ -0,0 +1,25 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix && !windows
+// +build !unix,!windows
+
+package cmd
+
+import "os"
+
+// Files can't be told apart here, so none are treated as hard links.
+func fileIDOf(path string, info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,38 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// Identifies the file behind a path by its volume serial number and file
+// index. Stat doesn't fill these in on Windows, so the file is opened to ask.
+func fileIDOf(path string, info os.FileInfo) (fileID, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	defer f.Close()
+	var d syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &d); err != nil {
+		return fileID{}, false
+	}
+	return fileID{uint64(d.VolumeSerialNumber), uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow)}, true
+}
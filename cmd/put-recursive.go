@@ -0,0 +1,138 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// Replaces each directory among the sources with jobs for the files beneath
+// it, at the same relative paths under the directory's destination. Returns
+// the destinations of directories with no files anywhere beneath them too,
+// since uploads alone wouldn't create them. Symlinks to files are followed,
+// but not symlinks to directories, which could loop. Files and directories
+// that `filter` excludes, along with each directory's .dbxignore, are left
+// out.
+func expandDirectories(jobs []uploadJob, recursive bool, filter *pathFilter) (expanded []uploadJob, emptyDirs []uploadJob, err error) {
+	for _, job := range jobs {
+		if job.src == stdinSource {
+			expanded = append(expanded, job)
+			continue
+		}
+		info, statErr := os.Stat(job.src)
+		if statErr != nil || !info.IsDir() {
+			expanded = append(expanded, job)
+			continue
+		}
+		if !recursive {
+			return nil, nil, fmt.Errorf("%s is a directory; use `--recursive` to upload it", job.src)
+		}
+
+		// Counts the files beneath each directory, so empty ones stand out.
+		// Excluded files count too, so their directories aren't created.
+		root := filepath.Clean(job.src)
+		var dirFilter *pathFilter
+		if dirFilter, err = filter.withIgnoreFile(root); err != nil {
+			return nil, nil, err
+		}
+		fileCount := make(map[string]int)
+		var dirs []string
+		err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			excluded := rel != "." && dirFilter.excluded(filepath.ToSlash(rel), info.IsDir())
+			if info.IsDir() {
+				if excluded {
+					return filepath.SkipDir
+				}
+				dirs = append(dirs, p)
+				return nil
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				if target, err := os.Stat(p); err == nil && target.IsDir() {
+					fmt.Fprintf(os.Stderr, "Skipping %s: symlink to a directory\n", p)
+					return nil
+				}
+			}
+
+			if !excluded {
+				expanded = append(expanded, uploadJob{p, job.dst + "/" + filepath.ToSlash(rel)})
+			}
+			for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+				fileCount[dir]++
+				if dir == root || dir == filepath.Dir(dir) {
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, dir := range dirs {
+			if fileCount[dir] > 0 {
+				continue
+			}
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return nil, nil, err
+			}
+			dst := job.dst
+			if rel != "." {
+				dst += "/" + filepath.ToSlash(rel)
+			}
+			emptyDirs = append(emptyDirs, uploadJob{dir, dst})
+		}
+	}
+	return
+}
+
+// Creates the remote folders for the empty directories `dirs`; ones which
+// already exist are fine. With `asJSON` each folder gets a line of JSON
+// like an upload does.
+func createFolders(dbx files.Client, dirs []uploadJob, asJSON bool) (failed int) {
+	for _, dir := range dirs {
+		_, err := dbx.CreateFolder(files.NewCreateFolderArg(dir.dst))
+		if err != nil && !isWriteConflict(err) {
+			failed++
+		} else {
+			err = nil
+		}
+		switch {
+		case asJSON:
+			rec := uploadRecord{Src: dir.src, Dst: dir.dst, Path: dir.dst, Outcome: "created"}
+			if err != nil {
+				f := errorFields(err)
+				rec.Path, rec.Outcome, rec.Error = "", outcomeFailed, &f
+			}
+			printJSONLine(rec)
+		case err != nil:
+			fmt.Printf("%s: creating folder failed: %v\n", dir.src, err)
+		default:
+			fmt.Printf("%s -> %s\n", dir.src, dir.dst)
+		}
+	}
+	return
+}
@@ -0,0 +1,198 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Dropbox keeps an unfinished upload session for a week; older saved
+// sessions are useless.
+const uploadSessionLifetime = 7 * 24 * time.Hour
+
+// The saved state of a chunked upload: which session it's in and how much of
+// the file the server has. The size and modification time of the file are
+// kept so that a session isn't continued with a file that changed since.
+type uploadSessionState struct {
+	SessionID   string    `json:"session_id"`
+	Offset      uint64    `json:"offset"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Started     time.Time `json:"started"`
+}
+
+func uploadSessionsDir() (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, ".config", "dbxcli", "sessions"), nil
+}
+
+// Each upload of a local file to a remote path has its own state file, named
+// after a hash of the two.
+func uploadSessionPath(src string, dst string) (string, error) {
+	dir, err := uploadSessionsDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs + "\x00" + dst))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// Reads the saved session for uploading `src` to `dst`. Returns nil if there
+// is none, or if it's expired or was for a different version of the file.
+func loadUploadSession(src string, dst string, info os.FileInfo) (*uploadSessionState, error) {
+	filePath, err := uploadSessionPath(src, dst)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st uploadSessionState
+	if err = json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	if st.Size != info.Size() || !st.ModTime.Equal(info.ModTime()) ||
+		time.Since(st.Started) > uploadSessionLifetime {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func saveUploadSession(st *uploadSessionState) error {
+	filePath, err := uploadSessionPath(st.Source, st.Destination)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, append(b, '\n'))
+}
+
+func removeUploadSession(src string, dst string) {
+	if filePath, err := uploadSessionPath(src, dst); err == nil {
+		os.Remove(filePath)
+	}
+}
+
+// Asks the server how much of the session it has by appending nothing at the
+// saved offset: if the last chunk arrived but the state wasn't saved, the
+// server reports the offset it expects instead. Returns false if the session
+// is gone, in which case the upload has to start over.
+func sessionOffset(dbx files.Client, st *uploadSessionState) (offset uint64, ok bool, err error) {
+	arg := files.NewUploadSessionAppendArg(files.NewUploadSessionCursor(st.SessionID, st.Offset))
+	err = dbx.UploadSessionAppendV2(arg, bytes.NewReader(nil))
+	if err == nil {
+		return st.Offset, true, nil
+	}
+	apiErr, isAPIErr := err.(files.UploadSessionAppendV2APIError)
+	if !isAPIErr || apiErr.EndpointError == nil {
+		return 0, false, err
+	}
+	switch apiErr.EndpointError.Tag {
+	case files.UploadSessionLookupErrorIncorrectOffset:
+		if apiErr.EndpointError.IncorrectOffset == nil {
+			return 0, false, err
+		}
+		return apiErr.EndpointError.IncorrectOffset.CorrectOffset, true, nil
+	case files.UploadSessionLookupErrorNotFound, files.UploadSessionLookupErrorClosed:
+		return 0, false, nil
+	}
+	return 0, false, err
+}
+
+// Uploads `contents` in chunks, saving the session after every chunk so the
+// upload can be continued if it's interrupted. With `opts.resume`, a saved
+// session for the same file and destination is continued instead of starting
+// from the beginning. The saved session is removed once the upload succeeds.
+func uploadResumable(ctx context.Context, dbx files.Client, contents *os.File, info os.FileInfo, job uploadJob, commitInfo *files.CommitInfo, opts uploadOptions) (md *files.FileMetadata, err error) {
+	var cursor *files.UploadSessionCursor
+	st := &uploadSessionState{
+		Source:      job.src,
+		Destination: job.dst,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Started:     time.Now(),
+	}
+	if opts.resume {
+		saved, err := loadUploadSession(job.src, job.dst, info)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			offset, ok, err := sessionOffset(dbx, saved)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if _, err = contents.Seek(int64(offset), io.SeekStart); err != nil {
+					return nil, err
+				}
+				st, cursor = saved, files.NewUploadSessionCursor(saved.SessionID, offset)
+				logger.Info("resuming upload", "src", job.src, "dst", job.dst, "offset", offset)
+			}
+		}
+	}
+
+	label := "Uploading"
+	remaining := info.Size()
+	if cursor != nil {
+		label, remaining = "Resuming", info.Size()-int64(cursor.Offset)
+	}
+	body := newProgressReader(ctx, contents, remaining, label, job.src)
+	checkpoint := func(c *files.UploadSessionCursor) {
+		st.SessionID, st.Offset = c.SessionId, c.Offset
+		if err := saveUploadSession(st); err != nil {
+			logger.Warn("saving upload session failed", "src", job.src, "error", err)
+		}
+	}
+
+	md, err = uploadChunkedFrom(ctx, dbx, body, commitInfo, info.Size(), opts.chunkSize, cursor, checkpoint)
+	if err == nil {
+		removeUploadSession(job.src, job.dst)
+	}
+	return
+}
@@ -0,0 +1,118 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// The <source> that stands for standard input.
+const stdinSource = "-"
+
+// Uploads `r`, whose size isn't known in advance, reading it a chunk at a
+// time until EOF. A stream that fits in one chunk is uploaded in a single
+// request; anything longer goes through an upload session. Only the session's
+// start and appends are retried after a network error: a commit whose
+// response was lost may well have been applied.
+func uploadStream(ctx context.Context, dbx files.Client, r io.Reader, commitInfo *files.CommitInfo, chunkSize int64) (md *files.FileMetadata, err error) {
+	buf := make([]byte, chunkSize)
+	chunk, err := readChunk(r, buf)
+	if err != nil {
+		return
+	}
+	if int64(len(chunk)) < chunkSize {
+		return dbx.Upload(commitInfo, bytes.NewReader(chunk))
+	}
+
+	var res *files.UploadSessionStartResult
+	err = retry.Do(ctx, chunkRetryPolicy, func() (err error) {
+		res, err = dbx.UploadSessionStart(files.NewUploadSessionStartArg(), bytes.NewReader(chunk))
+		return retryableUploadError(err)
+	})
+	if err != nil {
+		return
+	}
+	cursor := files.NewUploadSessionCursor(res.SessionId, uint64(len(chunk)))
+
+	// A short chunk is the last one; a stream that ends on a chunk boundary
+	// finishes with an empty one.
+	for {
+		if chunk, err = readChunk(r, buf); err != nil {
+			return
+		}
+		if int64(len(chunk)) < chunkSize {
+			break
+		}
+		args := files.NewUploadSessionCursor(cursor.SessionId, cursor.Offset)
+		err = retry.Do(ctx, chunkRetryPolicy, func() error {
+			return retryableUploadError(dbx.UploadSessionAppend(args, bytes.NewReader(chunk)))
+		})
+		if err != nil {
+			return
+		}
+		cursor.Offset += uint64(len(chunk))
+	}
+
+	return dbx.UploadSessionFinish(files.NewUploadSessionFinishArg(cursor, commitInfo), bytes.NewReader(chunk))
+}
+
+// Uploads standard input to job.dst, for `put - <target>`. There's no local
+// file to compare, so the destination is treated as being older than the
+// upload by --on-conflict newer.
+func uploadStdin(ctx context.Context, dbx files.Client, job uploadJob, opts uploadOptions) (outcome string, dst string, err error) {
+	dst = job.dst
+	remote, err := getFileMetadata(dbx, job.dst)
+	if isMissing(err) {
+		remote, err = nil, nil
+	}
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC().Round(time.Second)
+	decision := decideConflict(opts.strategy, remote, now)
+	outcome, err = decision.outcome, decision.err
+	if !decision.upload {
+		return
+	}
+
+	body := newProgressReader(ctx, os.Stdin, 0, "Uploading", "standard input")
+	if opts.recipients != nil {
+		encrypted := encryptingReader(body, opts.recipients)
+		defer encrypted.Close()
+		body = encrypted
+	}
+
+	commitInfo := files.NewCommitInfo(job.dst)
+	commitInfo.Mode.Tag = decision.mode
+	commitInfo.Autorename = decision.autorename
+	commitInfo.ClientModified = now
+
+	md, err := uploadStream(ctx, dbx, body, commitInfo, opts.chunkSize)
+	if err != nil {
+		return
+	}
+	if md != nil && md.PathDisplay != "" && !strings.EqualFold(md.PathDisplay, job.dst) {
+		outcome, dst = outcomeRenamed, md.PathDisplay
+	}
+	return
+}
@@ -0,0 +1,102 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func resetByPeer(route string) error {
+	return &url.Error{Op: "Post", URL: "https://content.dropboxapi.com/2/files/" + route, Err: errors.New("connection reset by peer")}
+}
+
+func TestUploadStreamSendsCommitsOnce(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("Upload", nil, resetByPeer("upload"))
+	if _, err := uploadStream(cmdCtx, fake, strings.NewReader("hello"), files.NewCommitInfo("/in.txt"), 1<<20); err == nil {
+		t.Error("the failed upload was ignored")
+	}
+	if got := fake.Methods(); !reflect.DeepEqual(got, []string{"Upload"}) {
+		t.Errorf("calls = %v, want the upload sent once", got)
+	}
+}
+
+func TestUploadStreamRetriesSessionChunks(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("UploadSessionStart", nil, resetByPeer("upload_session/start"))
+	fake.Respond("UploadSessionStart", &files.UploadSessionStartResult{SessionId: "s1"}, nil)
+	fake.Respond("UploadSessionAppend", nil, resetByPeer("upload_session/append"))
+	fake.Respond("UploadSessionAppend", nil, nil)
+	fake.Respond("UploadSessionFinish", nil, resetByPeer("upload_session/finish"))
+
+	_, err := uploadStream(cmdCtx, fake, strings.NewReader("hello world"), files.NewCommitInfo("/in.txt"), 4)
+	if err == nil {
+		t.Error("the failed finish was ignored")
+	}
+	want := []string{"UploadSessionStart", "UploadSessionStart", "UploadSessionAppend", "UploadSessionAppend", "UploadSessionFinish"}
+	if got := fake.Methods(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	var chunks []string
+	for _, c := range fake.Calls() {
+		chunks = append(chunks, string(c.Content))
+	}
+	if want := []string{"hell", "hell", "o wo", "o wo", "rld"}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q, want %q", chunks, want)
+	}
+}
+
+// A file or directory that happens to be called "-" doesn't stop "-" from
+// meaning standard input.
+func TestStdinSourceIsNotStatted(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, stdinSource), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	jobs := []uploadJob{{src: stdinSource, dst: "/in.txt"}}
+
+	expanded, emptyDirs, err := expandDirectories(jobs, false, nil)
+	if err != nil || !reflect.DeepEqual(expanded, jobs) || len(emptyDirs) != 0 {
+		t.Errorf("expandDirectories = %v, %v, %v", expanded, emptyDirs, err)
+	}
+	kept, skipped, err := skipSpecialFiles(jobs, true)
+	if err != nil || !reflect.DeepEqual(kept, jobs) || skipped != 0 {
+		t.Errorf("skipSpecialFiles = %v, %d, %v", kept, skipped, err)
+	}
+	if kept, skipped = skipHardlinks(append(jobs, jobs...)); len(kept) != 2 || skipped != 0 {
+		t.Errorf("skipHardlinks kept %v and skipped %d", kept, skipped)
+	}
+	// Nothing is counted, so the quota isn't even fetched.
+	useFakeAPI(t)
+	_, stderr, err := testutil.Capture(func() error {
+		return checkQuota(jobs, nil)
+	})
+	if err != nil {
+		t.Errorf("checkQuota: %v", err)
+	}
+	if want := "Skipping the quota check for standard input: its size isn't known\n"; stderr != want {
+		t.Errorf("checkQuota printed %q, want %q", stderr, want)
+	}
+}
@@ -15,122 +15,981 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"os"
-	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/dropbox/dbxcli/crypt"
+	"github.com/dropbox/dbxcli/retry"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/dustin/go-humanize"
-	"github.com/mitchellh/ioprogress"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-const chunkSize int64 = 1 << 24
+const defaultChunkSize int64 = 1 << 24
 
-func uploadChunked(dbx files.Client, r io.Reader, commitInfo *files.CommitInfo, sizeTotal int64) (err error) {
-	res, err := dbx.UploadSessionStart(files.NewUploadSessionStartArg(),
-		&io.LimitedReader{R: r, N: chunkSize})
+// Dropbox accepts at most 150 MiB of file contents per request.
+const maxRequestSize int64 = 150 << 20
+
+// Parses a size such as "8M", "512K" or "16777216". The suffixes are binary
+// multiples and may be followed by "iB" or "B"; a bare number may be
+// followed by "B".
+func parseByteSize(s string) (int64, error) {
+	units := strings.ToUpper(strings.TrimSpace(s))
+	if n := len(units); n > 2 && strings.HasSuffix(units, "IB") && strings.ContainsRune("KMG", rune(units[n-3])) {
+		units = units[:n-2]
+	} else {
+		units = strings.TrimSuffix(units, "B")
+	}
+	shift := uint(0)
+	if n := len(units); n > 0 {
+		switch units[n-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		}
+		if shift > 0 {
+			units = units[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(units, 10, 64)
+	if err != nil || size <= 0 || size > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return size << shift, nil
+}
+
+// Parses a chunk size like parseByteSize, up to the most Dropbox accepts per
+// request.
+func parseChunkSize(s string) (int64, error) {
+	size, err := parseByteSize(s)
 	if err != nil {
+		return 0, fmt.Errorf("invalid chunk size %q", s)
+	}
+	if size > maxRequestSize {
+		return 0, fmt.Errorf("chunk size %s is larger than the %s Dropbox accepts per request",
+			s, humanize.IBytes(uint64(maxRequestSize)))
+	}
+	return size, nil
+}
+
+// Chunk uploads are retried after network errors, which sendWithRetry leaves
+// to them; each chunk is buffered so it can be resent from the start. Upload
+// sessions address chunks by offset, so a chunk that did arrive is rejected
+// rather than appended twice.
+var chunkRetryPolicy retry.Policy = retry.Exponential{
+	Initial:     time.Second,
+	Max:         30 * time.Second,
+	Jitter:      0.2,
+	MaxAttempts: 5,
+}
+
+// Decides whether a failed session start or append is worth another try.
+// Only network errors are: rate limits and server errors have already been
+// retried by sendWithRetry, and any other response describes a problem with
+// the request itself. Commits such as uploads and session finishes mustn't
+// go through this at all, since a network error can hide one that was
+// applied (see isIdempotentRoute).
+func retryableUploadError(err error) error {
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) || err == io.ErrUnexpectedEOF {
+		return err
+	}
+	return retry.Permanent(err)
+}
+
+func readChunk(r io.Reader, buf []byte) ([]byte, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+func uploadChunked(ctx context.Context, dbx files.Client, r io.Reader, commitInfo *files.CommitInfo, sizeTotal int64, chunkSize int64) (md *files.FileMetadata, err error) {
+	return uploadChunkedFrom(ctx, dbx, r, commitInfo, sizeTotal, chunkSize, nil, nil)
+}
+
+// Uploads `r` through an upload session. With a nil `cursor` a new session is
+// started; otherwise the upload continues the given session and `r` must be
+// positioned at the cursor's offset. If `checkpoint` isn't nil it's called
+// with the session's cursor whenever a chunk has been accepted, so an
+// interrupted upload can be continued later.
+func uploadChunkedFrom(ctx context.Context, dbx files.Client, r io.Reader, commitInfo *files.CommitInfo, sizeTotal int64, chunkSize int64, cursor *files.UploadSessionCursor, checkpoint func(*files.UploadSessionCursor)) (md *files.FileMetadata, err error) {
+	buf := make([]byte, chunkSize)
+
+	var chunk []byte
+	if cursor == nil {
+		if chunk, err = readChunk(r, buf); err != nil {
+			return
+		}
+		var res *files.UploadSessionStartResult
+		err = retry.Do(ctx, chunkRetryPolicy, func() (err error) {
+			res, err = dbx.UploadSessionStart(files.NewUploadSessionStartArg(), bytes.NewReader(chunk))
+			return retryableUploadError(err)
+		})
+		if err != nil {
+			return
+		}
+		cursor = files.NewUploadSessionCursor(res.SessionId, uint64(len(chunk)))
+		if checkpoint != nil {
+			checkpoint(cursor)
+		}
+	}
+
+	for (sizeTotal - int64(cursor.Offset)) > chunkSize {
+		if chunk, err = readChunk(r, buf); err != nil {
+			return
+		}
+
+		args := files.NewUploadSessionCursor(cursor.SessionId, cursor.Offset)
+		err = retry.Do(ctx, chunkRetryPolicy, func() error {
+			return retryableUploadError(dbx.UploadSessionAppend(args, bytes.NewReader(chunk)))
+		})
+		if err != nil {
+			return
+		}
+		cursor = files.NewUploadSessionCursor(cursor.SessionId, cursor.Offset+uint64(len(chunk)))
+		if checkpoint != nil {
+			checkpoint(cursor)
+		}
+	}
+
+	if chunk, err = readChunk(r, buf); err != nil {
 		return
 	}
 
-	written := chunkSize
+	return dbx.UploadSessionFinish(files.NewUploadSessionFinishArg(cursor, commitInfo), bytes.NewReader(chunk))
+}
 
-	for (sizeTotal - written) > chunkSize {
-		args := files.NewUploadSessionCursor(res.SessionId, uint64(written))
+// An uploadJob is a single local file and the remote path it's uploaded to.
+type uploadJob struct {
+	src string
+	dst string
+}
 
-		err = dbx.UploadSessionAppend(args, &io.LimitedReader{R: r, N: chunkSize})
+type uploadResult struct {
+	index int
+	job   uploadJob
+	// The path the file ended up at, which differs from job.dst if it was
+	// renamed to avoid a conflict.
+	path    string
+	outcome string
+	err     error
+}
+
+// Settings shared by all uploads of one `put`.
+type uploadOptions struct {
+	// How to handle an existing destination; see decideConflict.
+	strategy  string
+	chunkSize int64
+	// When set, files are encrypted to these recipients on the way up.
+	recipients []crypt.Recipient
+	// Continue interrupted chunked uploads from their saved sessions.
+	resume bool
+	// How many chunks of one large file to upload at once.
+	chunkParallelism int
+	// Leave destinations which already have the same contents alone, and
+	// with updateOnly also those that don't exist.
+	skipUnchanged bool
+	updateOnly    bool
+	// Record the time of the upload as the files' modification time rather
+	// than their local one.
+	discardMtime bool
+	// Compare the content hash of each upload with that of the local file.
+	verify bool
+	// When set, small files are committed in batches by it.
+	batch *batchCommitter
+	// When set, each destination is only replaced at the revision it has
+	// here, and one without a revision only if it's still free; see
+	// decideUpdate. The strategy isn't used then.
+	revs map[string]string
+}
+
+// Returns a reader of `r` encrypted to `rcpts`. Encryption runs in its own
+// goroutine, one chunk at a time; closing the returned reader stops it.
+func encryptingReader(r io.Reader, rcpts []crypt.Recipient) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := crypt.NewWriter(pw, rcpts...)
+		if err == nil {
+			_, err = io.Copy(w, r)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+func uploadFile(ctx context.Context, dbx files.Client, job uploadJob, opts uploadOptions) (res uploadResult) {
+	res.job = job
+	res.outcome, res.path, res.err = uploadWithOptions(ctx, dbx, job, opts)
+	return
+}
+
+func uploadWithOptions(ctx context.Context, dbx files.Client, job uploadJob, opts uploadOptions) (outcome string, dst string, err error) {
+	dst = job.dst
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if job.src == stdinSource {
+		return uploadStdin(ctx, dbx, job, opts)
+	}
+
+	contents, err := os.Open(job.src)
+	if err != nil {
+		return
+	}
+	defer contents.Close()
+
+	contentsInfo, err := contents.Stat()
+	if err != nil {
+		return
+	}
+
+	var decision conflictDecision
+	if opts.revs != nil {
+		decision = decideUpdate(opts.revs[job.dst])
+	} else {
+		var remote files.IsMetadata
+		var remoteHash string
+		if opts.skipUnchanged {
+			// Only the API's own metadata has the content hash.
+			var x fileExtras
+			remote, x, _, err = getMetadataExtras(ctx, job.dst)
+			remoteHash = x.ContentHash
+		} else {
+			remote, err = getFileMetadata(dbx, job.dst)
+		}
+		if isMissing(err) {
+			remote, err = nil, nil
+		}
 		if err != nil {
 			return
 		}
-		written += chunkSize
+		if _, isFile := remote.(*files.FileMetadata); opts.updateOnly && !isFile {
+			outcome = outcomeAbsent
+			return
+		}
+		if opts.skipUnchanged {
+			var same bool
+			if same, err = sameContent(job.src, contentsInfo.Size(), remote, remoteHash); err != nil {
+				return
+			}
+			if same {
+				outcome = outcomeUnchanged
+				return
+			}
+		}
+		decision = decideConflict(opts.strategy, remote, contentsInfo.ModTime())
+	}
+	outcome, err = decision.outcome, decision.err
+	if !decision.upload {
+		return
 	}
 
-	cursor := files.NewUploadSessionCursor(res.SessionId, uint64(written))
-	args := files.NewUploadSessionFinishArg(cursor, commitInfo)
+	var body io.Reader = newProgressReader(ctx, contents, contentsInfo.Size(), "Uploading", job.src)
+	size := contentsInfo.Size()
+	if opts.recipients != nil {
+		encrypted := encryptingReader(body, opts.recipients)
+		defer encrypted.Close()
+		body = encrypted
+		size = crypt.EncryptedSize(size, crypt.DefaultChunkSize, opts.recipients...)
+	}
+
+	commitInfo := files.NewCommitInfo(job.dst)
+	commitInfo.Mode.Tag = decision.mode
+	commitInfo.Mode.Update = decision.rev
+	commitInfo.Autorename = decision.autorename
 
-	if _, err = dbx.UploadSessionFinish(args, r); err != nil {
+	// The Dropbox API only accepts timestamps in UTC with second precision.
+	commitInfo.ClientModified = contentsInfo.ModTime().UTC().Round(time.Second)
+	if opts.discardMtime {
+		commitInfo.ClientModified = time.Now().UTC().Round(time.Second)
+	}
+
+	var md *files.FileMetadata
+	switch {
+	case size > opts.chunkSize && opts.chunkParallelism > 1:
+		md, err = uploadConcurrent(ctx, dbx, body, commitInfo, size, opts.chunkSize, opts.chunkParallelism)
+	case size > opts.chunkSize && opts.recipients == nil:
+		// Ciphertext differs on every run, so only plain uploads can be
+		// continued.
+		md, err = uploadResumable(ctx, dbx, contents, contentsInfo, job, commitInfo, opts)
+	case size > opts.chunkSize:
+		md, err = uploadChunked(ctx, dbx, body, commitInfo, size, opts.chunkSize)
+	case opts.batch != nil && size < smallFileSize:
+		md, err = opts.batch.upload(ctx, dbx, body, commitInfo)
+	default:
+		md, err = dbx.Upload(commitInfo, body)
+	}
+	if err != nil {
 		return
 	}
 
+	if md != nil && md.PathDisplay != "" && !strings.EqualFold(md.PathDisplay, job.dst) {
+		outcome, dst = outcomeRenamed, md.PathDisplay
+	}
+	if opts.verify && md != nil {
+		remote := md.Id
+		if remote == "" {
+			remote = md.PathLower
+		}
+		err = verifyTransfer(ctx, job.src, remote, md.Rev)
+	}
+	return
+}
+
+// Drops sources that aren't regular files or directories, such as named pipes,
+// sockets and device nodes: opening a FIFO blocks forever and devices have no
+// meaningful size. Symlinks are followed. With `strict` their presence is an
+// error instead.
+func skipSpecialFiles(jobs []uploadJob, strict bool) (kept []uploadJob, skipped int, err error) {
+	for _, job := range jobs {
+		if job.src == stdinSource {
+			kept = append(kept, job)
+			continue
+		}
+		info, err := os.Stat(job.src)
+		if err != nil {
+			// Let the upload itself report missing or unreadable files.
+			kept = append(kept, job)
+			continue
+		}
+		if mode := info.Mode(); !mode.IsRegular() && !mode.IsDir() {
+			if strict {
+				return nil, 0, fmt.Errorf("%s is not a regular file (%s)", job.src, mode)
+			}
+			fmt.Fprintf(os.Stderr, "Skipping %s: not a regular file (%s)\n", job.src, mode)
+			skipped++
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return
+}
+
+// Where a file lives on its filesystem: its device and inode on Unix, or its
+// volume and file index on Windows. Hard links to a file share its fileID.
+type fileID struct {
+	dev, ino uint64
+}
+
+// Drops sources that are hard links to (or repeats of) an earlier source, so
+// each underlying file is uploaded once, and counts them.
+func skipHardlinks(jobs []uploadJob) (kept []uploadJob, skipped int) {
+	seen := make(map[fileID]string)
+	for _, job := range jobs {
+		if job.src == stdinSource {
+			kept = append(kept, job)
+			continue
+		}
+		info, err := os.Stat(job.src)
+		if err != nil {
+			kept = append(kept, job)
+			continue
+		}
+		id, ok := fileIDOf(job.src, info)
+		if !ok {
+			kept = append(kept, job)
+			continue
+		}
+		if first, dup := seen[id]; dup {
+			fmt.Fprintf(os.Stderr, "Skipping %s: same file as %s\n", job.src, first)
+			skipped++
+			continue
+		}
+		seen[id] = job.src
+		kept = append(kept, job)
+	}
 	return
 }
 
+// Uploads `jobs` with `parallelism` workers, or all at once if it's 0, and
+// returns their results as they finish. Progress is drawn live on stderr
+// while the results are funneled through the channel, which is closed once
+// all are done, so they can be printed in a stable order.
+func uploadAll(ctx context.Context, dbx files.Client, jobs []uploadJob, opts uploadOptions, parallelism int) <-chan uploadResult {
+	if parallelism <= 0 || parallelism > len(jobs) {
+		parallelism = len(jobs)
+	}
+	queue := make(chan int)
+	results := make(chan uploadResult)
+	var wg sync.WaitGroup
+	for n := 0; n < parallelism; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				res := uploadFile(ctx, dbx, jobs[i], opts)
+				res.index = i
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		for i := range jobs {
+			queue <- i
+		}
+		close(queue)
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// How the uploads of one put turned out, for the summary printed after them.
+// In JSON mode the summary is this, as a line of JSON on stderr.
+type uploadCounts struct {
+	Uploaded int `json:"uploaded"`
+	Failed   int `json:"failed"`
+	// Files left alone because their destination exists (by the conflict
+	// strategy) or already has their contents (--skip-existing and
+	// --update-only).
+	Skipped int `json:"skipped"`
+	// Sources which weren't even tried: special files, and with
+	// --detect-hardlinks, hard links to a file uploaded already.
+	SkippedSpecial   int `json:"skipped_special"`
+	SkippedHardlinks int `json:"skipped_hardlinks"`
+	// How many uploads ended in each conflict outcome.
+	Conflicts map[string]int `json:"conflicts,omitempty"`
+}
+
+// Writes a record for each result in job order rather than completion order,
+// holding back results that finish before their predecessors (the same idea as
+// GNU parallel's --keep-order). Returns the jobs that failed and how the
+// others turned out. With `asJSON` each record is a line of JSON instead.
+func printOrderedResults(w io.Writer, results <-chan uploadResult, asJSON bool) (failures []uploadResult, counts uploadCounts) {
+	pending := make(map[int]uploadResult)
+	counts.Conflicts = make(map[string]int)
+	next := 0
+
+	for r := range results {
+		pending[r.index] = r
+
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if r.outcome != "" && r.outcome != outcomeUnchanged && r.outcome != outcomeAbsent {
+				counts.Conflicts[r.outcome]++
+			}
+			switch {
+			case r.err != nil:
+				counts.Failed++
+			case r.outcome == outcomeSkipped || r.outcome == outcomeUnchanged || r.outcome == outcomeAbsent:
+				counts.Skipped++
+			default:
+				counts.Uploaded++
+			}
+			if r.err != nil {
+				logger.Error("upload failed", "src", r.job.src, "dst", r.job.dst, "error", r.err)
+			} else {
+				logger.Info("upload", "src", r.job.src, "dst", r.path, "outcome", r.outcome)
+			}
+			if r.err != nil {
+				failures = append(failures, r)
+			}
+			if asJSON {
+				json.NewEncoder(w).Encode(newUploadRecord(r))
+				continue
+			}
+			switch {
+			case r.err != nil:
+				fmt.Fprintf(w, "%s: upload failed: %v\n", r.job.src, r.err)
+			case r.outcome == outcomeSkipped:
+				fmt.Fprintf(w, "%s: skipped, %s already exists\n", r.job.src, r.job.dst)
+			case r.outcome == outcomeUnchanged:
+				fmt.Fprintf(w, "%s: skipped, %s is the same\n", r.job.src, r.job.dst)
+			case r.outcome == outcomeAbsent:
+				fmt.Fprintf(w, "%s: skipped, there's no file at %s\n", r.job.src, r.job.dst)
+			default:
+				fmt.Fprintf(w, "%s -> %s\n", r.job.src, r.path)
+			}
+		}
+	}
+
+	return
+}
+
+// One line of JSON output for an upload. Path is where the file ended up,
+// which differs from Dst when it was renamed, and Outcome is "uploaded" or one
+// of the conflict outcomes.
+type uploadRecord struct {
+	Src     string           `json:"src"`
+	Dst     string           `json:"dst"`
+	Path    string           `json:"path,omitempty"`
+	Outcome string           `json:"outcome"`
+	Error   *jsonErrorFields `json:"error,omitempty"`
+}
+
+func newUploadRecord(r uploadResult) uploadRecord {
+	rec := uploadRecord{Src: r.job.src, Dst: r.job.dst, Path: r.path, Outcome: r.outcome}
+	switch {
+	case r.err != nil:
+		f := errorFields(r.err)
+		rec.Outcome, rec.Error = outcomeFailed, &f
+	case rec.Outcome == "":
+		rec.Outcome = "uploaded"
+	}
+	return rec
+}
+
+// Lists what put would do for --dry-run, in the form sync uses.
+func dryRunPlan(jobs []uploadJob, emptyDirs []uploadJob) (changes []plannedChange) {
+	for _, job := range jobs {
+		changes = append(changes, plannedChange{Src: job.src, Dst: job.dst, Action: "upload"})
+	}
+	for _, dir := range emptyDirs {
+		changes = append(changes, plannedChange{Dst: dir.dst, Action: "mkdir"})
+	}
+	return
+}
+
+// Prints the summary of a put on stderr, as a line of JSON in JSON mode. The
+// text one is left out when everything was simply uploaded.
+func printUploadCounts(cmd *cobra.Command, counts uploadCounts, failures []uploadResult) {
+	if jsonMode(cmd) {
+		printJSONSummary(counts)
+		return
+	}
+	if counts.Failed > 0 || counts.Skipped > 0 || counts.SkippedSpecial > 0 || counts.SkippedHardlinks > 0 {
+		fmt.Fprintf(os.Stderr, "Uploaded %d, failed %d, skipped %d existing files, %d special files and %d hard links\n",
+			counts.Uploaded, counts.Failed, counts.Skipped, counts.SkippedSpecial, counts.SkippedHardlinks)
+	}
+	if len(counts.Conflicts) > 0 {
+		fmt.Fprintf(os.Stderr, "Conflicts: %s\n", formatConflictCounts(counts.Conflicts))
+	}
+	if len(failures) > 0 {
+		printFailedUploads(os.Stderr, failures)
+	}
+}
+
+// Lists the uploads that failed once more after all the records, so they
+// aren't lost among the successful ones when many files are uploaded.
+func printFailedUploads(w io.Writer, failures []uploadResult) {
+	fmt.Fprintln(w, "Failed uploads:")
+	for _, r := range failures {
+		fmt.Fprintf(w, "  %s: %s\n", r.job.src, explainError(r.err))
+	}
+}
+
+// Fails if the uploads don't fit in the space left in the account, so that a
+// large upload doesn't run out of space part of the way through. Files that
+// will be overwritten are counted in full, so the check errs on the side of
+// caution. Standard input, whose size isn't known, isn't counted, which is
+// noted on stderr.
+func checkQuota(jobs []uploadJob, rcpts []crypt.Recipient) error {
+	var total uint64
+	for _, job := range jobs {
+		if job.src == stdinSource {
+			fmt.Fprintln(os.Stderr, "Skipping the quota check for standard input: its size isn't known")
+			continue
+		}
+		if info, err := os.Stat(job.src); err == nil && info.Mode().IsRegular() {
+			size := info.Size()
+			if rcpts != nil {
+				size = crypt.EncryptedSize(size, crypt.DefaultChunkSize, rcpts...)
+			}
+			total += uint64(size)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	usage, err := fetchSpaceUsage()
+	if err != nil {
+		return err
+	}
+	q := quotaOf(usage)
+	if q.Allocated == 0 {
+		// Nothing to check against.
+		return nil
+	}
+	if available := q.available(); total > available {
+		return fmt.Errorf("needs %s, only %s available; use `--ignore-quota` to upload anyway",
+			humanize.IBytes(total), humanize.IBytes(available))
+	}
+	return nil
+}
+
 func put(cmd *cobra.Command, args []string) (err error) {
-	if len(args) == 0 || len(args) > 2 {
+	if len(args) == 0 {
 		return errors.New("`put` requires `src` and/or `dst` arguments")
 	}
 
-	src := args[0]
+	if cmd.Flags().Changed("destination") {
+		destination, _ := cmd.Flags().GetString("destination")
+		args = append(args, destination)
+	}
 
-	// Default `dst` to the base segment of the source path; use the second argument if provided.
-	dst := "/" + path.Base(src)
-	if len(args) == 2 {
-		dst, err = validatePath(args[1])
-		if err != nil {
+	chunkSizeFlag, _ := cmd.Flags().GetString("chunk-size")
+	chunkSize, err := parseChunkSize(chunkSizeFlag)
+	if err != nil {
+		return
+	}
+
+	if appendMode, _ := cmd.Flags().GetBool("append"); appendMode {
+		if len(args) != 2 {
+			return errors.New("`put --append` requires a `src` and a `dst` argument")
+		}
+		if encrypt, _ := cmd.Flags().GetBool("encrypt"); encrypt {
+			return errors.New("`--append` can't be combined with `--encrypt`")
+		}
+		var dst string
+		if dst, err = validatePath(args[1]); err != nil {
 			return
 		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if jsonMode(cmd) {
+				return listChanges(cmd, []plannedChange{{Src: args[0], Dst: dst, Action: "append"}})
+			}
+			fmt.Printf("append\t%s >> %s\n", args[0], dst)
+			return
+		}
+		attempts, _ := cmd.Flags().GetInt("append-attempts")
+		return putAppend(args[0], dst, attempts, chunkSize)
 	}
 
-	contents, err := os.Open(src)
-	defer contents.Close()
+	for i, src := range args[:len(args)-1] {
+		if src == stdinSource && (i > 0 || len(args) != 2) {
+			return errors.New("`put -` uploads standard input on its own and needs a <target>")
+		}
+	}
+	if args[0] == stdinSource {
+		if len(args) == 1 {
+			return errors.New("`put -` needs a <target> to upload standard input to")
+		}
+		for _, flag := range []string{"skip-existing", "update-only", "resume", "check", "verify"} {
+			if set, _ := cmd.Flags().GetBool(flag); set {
+				return fmt.Errorf("`--%s` can't be used when uploading standard input", flag)
+			}
+		}
+	}
+
+	var jobs []uploadJob
+	switch len(args) {
+	case 1:
+		// Default `dst` to the base segment of the source path.
+		jobs = append(jobs, uploadJob{args[0], "/" + filepath.Base(args[0])})
+	case 2:
+		jobs = append(jobs, uploadJob{args[0], args[1]})
+	default:
+		// Several sources; the last argument is the destination folder.
+		dir := args[len(args)-1]
+		for _, src := range args[:len(args)-1] {
+			jobs = append(jobs, uploadJob{src, dir + "/" + filepath.Base(src)})
+		}
+	}
+
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	filter, err := newPathFilter(cmd)
 	if err != nil {
 		return
 	}
+	jobs, emptyDirs, err := expandDirectories(jobs, recursive, filter)
+	if err != nil {
+		return
+	}
+	for i := range emptyDirs {
+		if emptyDirs[i].dst, err = validatePath(emptyDirs[i].dst); err != nil {
+			return
+		}
+	}
 
-	contentsInfo, err := contents.Stat()
+	encrypt, _ := cmd.Flags().GetBool("encrypt")
+	suffix, _ := cmd.Flags().GetString("encrypt-suffix")
+	for i := range jobs {
+		if encrypt {
+			jobs[i].dst += suffix
+		}
+		if jobs[i].dst, err = validatePath(jobs[i].dst); err != nil {
+			return
+		}
+	}
+
+	strict, _ := cmd.Flags().GetBool("strict")
+	jobs, skipped, err := skipSpecialFiles(jobs, strict)
 	if err != nil {
 		return
 	}
+	hardlinks := 0
+	if detect, _ := cmd.Flags().GetBool("detect-hardlinks"); detect {
+		jobs, hardlinks = skipHardlinks(jobs)
+	}
 
-	progressbar := &ioprogress.Reader{
-		Reader: contents,
-		DrawFunc: ioprogress.DrawTerminalf(os.Stderr, func(progress, total int64) string {
-			return fmt.Sprintf("Uploading %s/%s",
-				humanize.IBytes(uint64(progress)), humanize.IBytes(uint64(total)))
-		}),
-		Size: contentsInfo.Size(),
+	opts := uploadOptions{chunkSize: chunkSize}
+	opts.resume, _ = cmd.Flags().GetBool("resume")
+	if opts.resume && encrypt {
+		return errors.New("`--resume` can't be combined with `--encrypt`")
+	}
+	preserveMtime, _ := cmd.Flags().GetBool("preserve-mtime")
+	opts.discardMtime = !preserveMtime
+	opts.skipUnchanged, _ = cmd.Flags().GetBool("skip-existing")
+	opts.updateOnly, _ = cmd.Flags().GetBool("update-only")
+	if opts.updateOnly {
+		opts.skipUnchanged = true
+	}
+	if opts.skipUnchanged && encrypt {
+		return errors.New("`--skip-existing` and `--update-only` can't compare encrypted uploads, which differ every time")
+	}
+	opts.verify, _ = cmd.Flags().GetBool("verify")
+	if opts.verify && encrypt {
+		return errors.New("`--verify` can't check encrypted uploads against the local files")
+	}
+	opts.chunkParallelism, _ = cmd.Flags().GetInt("chunk-parallelism")
+	if opts.chunkParallelism < 1 {
+		return fmt.Errorf("`--chunk-parallelism` must be at least 1, not %d", opts.chunkParallelism)
+	}
+	if opts.resume && opts.chunkParallelism > 1 {
+		return errors.New("`--resume` can't be combined with `--chunk-parallelism`")
+	}
+	if opts.strategy, err = conflictStrategy(cmd); err != nil {
+		return
+	}
+	if encrypt {
+		if opts.recipients, err = encryptionRecipients(cmd); err != nil {
+			return
+		}
 	}
 
-	commitInfo := files.NewCommitInfo(dst)
-	commitInfo.Mode.Tag = "overwrite"
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		return listChanges(cmd, dryRunPlan(jobs, emptyDirs))
+	}
 
-	// The Dropbox API only accepts timestamps in UTC with second precision.
-	commitInfo.ClientModified = time.Now().UTC().Round(time.Second)
+	if check, _ := cmd.Flags().GetBool("check"); check {
+		return checkUploads(cmd, jobs, opts.strategy)
+	}
 
-	dbx := files.New(config)
-	if contentsInfo.Size() > chunkSize {
-		return uploadChunked(dbx, progressbar, commitInfo, contentsInfo.Size())
+	if ignore, _ := cmd.Flags().GetBool("ignore-quota"); !ignore {
+		if err = checkQuota(jobs, opts.recipients); err != nil {
+			return
+		}
 	}
 
-	if _, err = dbx.Upload(commitInfo, progressbar); err != nil {
-		return
+	dbx := newFilesClient(cmdCtx)
+	parallelism, _ := cmd.Flags().GetInt("transfers")
+	if parallelism < 0 {
+		return fmt.Errorf("`--transfers` must be 0 or more, not %d", parallelism)
+	}
+	if autoTune, _ := cmd.Flags().GetBool("auto-tune"); autoTune {
+		plan := planTransfer(histogramOf(jobs), probeConnection())
+		parallelism = plan.Parallelism
+		if !cmd.Flags().Changed("chunk-size") {
+			opts.chunkSize = plan.ChunkSize
+		}
+		if plan.Batch {
+			opts.batch = newBatchCommitter(cmdCtx)
+			defer opts.batch.close()
+		}
+		if config.Verbose {
+			fmt.Fprintf(os.Stderr, "Transfer plan: %v\n", plan)
+		}
+	}
+
+	results := uploadAll(cmdCtx, dbx, jobs, opts, parallelism)
+	failures, counts := printOrderedResults(os.Stdout, results, jsonMode(cmd))
+	counts.SkippedSpecial, counts.SkippedHardlinks = skipped, hardlinks
+	printUploadCounts(cmd, counts, failures)
+	failed := len(failures)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d uploads failed", failed, len(jobs))
+	}
+	if failed = createFolders(dbx, emptyDirs, jsonMode(cmd)); failed > 0 {
+		return fmt.Errorf("%d of %d empty folders could not be created", failed, len(emptyDirs))
 	}
 
 	return
 }
 
+// Number of files put uploads at once unless told otherwise. Enough to keep
+// a connection busy without running into Dropbox's rate limits.
+const defaultTransfers = 4
+
+// Returns a flag name normalizer under which each key of `aliases` is
+// another name for the flag named by its value, on the command line, in
+// settings keys and in presets alike.
+func flagAliases(aliases map[string]string) func(*pflag.FlagSet, string) pflag.NormalizedName {
+	return func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if to, ok := aliases[name]; ok {
+			name = to
+		}
+		return pflag.NormalizedName(name)
+	}
+}
+
 // putCmd represents the put command
 var putCmd = &cobra.Command{
-	Use:   "put [flags] <source> [<target>]",
+	Use:   "put [flags] <source>... [<target>]",
 	Short: "Upload files",
-	RunE:  put,
-}
+	Long: `Upload files to your Dropbox.
 
-func init() {
-	RootCmd.AddCommand(putCmd)
+When more than one <source> is given, <target> is the folder to upload them
+into. With --recursive, a directory <source> is uploaded with everything in
+it, keeping its structure, and empty directories become empty folders.
+
+--exclude and --include choose what a recursive upload leaves out. A
+pattern without a slash, such as "*.o" or "node_modules", matches a name at
+any depth; one with a slash, such as "build/*.log", matches the path from the
+directory being uploaded. A trailing slash only matches directories, and
+nothing beneath an excluded directory is uploaded. With --include, only files
+that match one of its patterns are uploaded. Patterns in a .dbxignore file
+at the top of the directory, one per line, are excluded too. Both flags can
+be repeated or given comma-separated lists.
+
+Files are uploaded in parallel, up to --transfers at a time, but the record
+printed for each file always follows the order of the arguments. There are 4
+transfers by default; --transfers 0 starts them all at once, as put used to
+do. --parallel is another name for --transfers. With --auto-tune, the
+number of transfers and the chunk size for large files are chosen from the
+sizes of the files and the latency of the connection, and many small files
+are committed to Dropbox in batches, which is much faster than one at a
+time; use --verbose to see the choice.
+
+--on-conflict decides what happens when a destination already exists:
+
+  overwrite  replace it (the default)
+  skip       leave it alone
+  rename     upload under a new name, like "report (1).pdf"
+  fail       report an error
+  newer      replace it only if the local file was modified more recently
+
+Before anything is uploaded, the total size of the files is checked against
+the space left in your Dropbox (or your team's), and put stops if they won't
+fit. Files that will be overwritten are counted in full; pass --ignore-quota
+to skip the check. Standard input's size isn't known in advance, so it's
+left out of the check with a notice.
 
-	// Here you will define your flags and configuration settings.
+Each file keeps its local modification time on Dropbox, where it's shown
+as the time the file was last modified; --preserve-mtime=false records the
+time of the upload instead. Standard input always gets the upload time.
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// putCmd.PersistentFlags().String("foo", "", "A help for foo")
+With --skip-existing, files whose destination already has the same contents
+are skipped before --on-conflict is consulted, so running the same put again
+only uploads what changed. The contents are compared by Dropbox content hash,
+computed locally. --update-only also skips files whose destination doesn't
+exist, so only files already on Dropbox are brought up to date.
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// putCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+--mode names the same choices by the API's write modes: add fails on an
+existing file, overwrite replaces it and autorename uploads a copy named
+like "report (1).pdf". It can't contradict --on-conflict.
 
+--force, --autorename and --update are deprecated spellings of overwrite,
+rename and newer. If they're combined with --on-conflict or --mode, those win
+and the old flags are ignored with a warning.
+
+With --encrypt, files are encrypted before they leave this machine and
+--encrypt-suffix is appended to their names; Dropbox only ever sees the
+ciphertext. They're encrypted to every key in the key file given with
+--identity or $DBXCLI_IDENTITY (see "dbxcli keygen"), or else to the
+passphrase in $DBXCLI_PASSPHRASE. Use "get --decrypt" to download them again.
+
+With --dry-run, nothing is uploaded and Dropbox isn't contacted: put only
+lists the files it would upload and the empty folders it would create, as a
+JSON list with --json.
+
+With --check, nothing is uploaded. Instead put compares the files with
+their destinations and lists those it would upload, or would fail on, given
+--on-conflict; files whose destination has the same contents don't count.
+It exits with status 0 if there are none, 1 if there are some and more than
+1 on errors, so it can gate scripts. --quiet leaves out the list, and --json
+prints it as JSON.
+
+A <source> of "-" uploads standard input to <target>, reading it a chunk at
+a time since its size isn't known in advance, so data can be piped straight
+into Dropbox. It can't be combined with other sources, --skip-existing,
+--update-only, --resume or --check.
+
+With --append, <source> (or standard input, if it's "-") is added to the end
+of the file <target>, which is created if it doesn't exist. The file is
+downloaded, extended and uploaded again only if nobody changed it in the
+meantime; otherwise the append starts over, up to --append-attempts times,
+so appends from several machines at once are never lost.
+
+Files larger than --chunk-size (16M by default, at most 150M) are uploaded in
+chunks; smaller chunks lose less on an unstable connection, larger ones are
+faster on a good one. After each chunk the upload session is saved under
+~/.config/dbxcli/sessions. If such an upload is interrupted, running the same
+put again with --resume continues it from the last chunk the server
+received, provided the file hasn't changed and the session is less than a
+week old; otherwise it starts over. Encrypted uploads can't be resumed.
+
+With --chunk-parallelism N greater than 1, up to N chunks of each large file
+are uploaded at once through a concurrent upload session, which can be much
+faster on a fast connection. This holds up to N chunks per file in memory
+(times --transfers files) and such uploads can't be resumed.
+
+With --verify, each file's Dropbox content hash is fetched once it's
+uploaded and compared with that of the local file, and the upload fails if
+they differ. It can't be used with --encrypt or standard input.`,
+	Example: `  dbxcli put report.pdf
+  dbxcli put report.pdf /reports/2016.pdf
+  dbxcli put *.log /logs
+  dbxcli put --recursive ./project /backup/project
+  dbxcli put --resume backup.tar /backups/backup.tar
+  dbxcli put --check --quiet build/*.tar.gz /releases
+  dbxcli put --encrypt --identity ~/.config/dbxcli/key.txt taxes.pdf
+  tar czf - project | dbxcli put - -d /backups/project.tgz
+  echo "$(date) backup done" | dbxcli put --append - /logs/backup.log`,
+	RunE: put,
+}
+
+func init() {
+	RootCmd.AddCommand(putCmd)
+	putCmd.Flags().String("preset", "", "Use the flag values of this preset from the config file")
+	putCmd.Flags().StringP("destination", "d", "", "Upload to this path, as if it were given as <target>")
+	putCmd.Flags().BoolP("recursive", "r", false, "Upload directories and everything in them")
+	addFilterFlags(putCmd)
+	putCmd.Flags().Bool("strict", false, "Fail instead of skipping sources that aren't regular files")
+	putCmd.Flags().Bool("detect-hardlinks", false, "Upload files that are hard links to each other only once")
+	putCmd.Flags().Int("transfers", defaultTransfers, "Number of files to upload at once (0 means all of them); also --parallel")
+	putCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"parallel": "transfers"}))
+	putCmd.Flags().Bool("auto-tune", false, "Pick --transfers and the chunk size from the files and the connection")
+	putCmd.Flags().String("on-conflict", conflictOverwrite, "What to do when a destination exists: overwrite, skip, rename, fail or newer")
+	putCmd.Flags().String("mode", "overwrite", "Write mode for existing destinations: add (fail), overwrite or autorename")
+	putCmd.Flags().Bool("skip-existing", false, "Don't upload files whose destination already has the same contents")
+	putCmd.Flags().Bool("update-only", false, "Only upload files whose destination exists and has different contents")
+	putCmd.Flags().Bool("dry-run", false, "Only list what would be uploaded, without contacting Dropbox")
+	putCmd.Flags().Bool("check", false, "Only list what would be uploaded, and exit with status 1 if anything would")
+	putCmd.Flags().BoolP("quiet", "q", false, "Don't list the differences found by --check")
+	putCmd.Flags().Bool("append", false, "Add <source> to the end of the file <target>")
+	putCmd.Flags().Int("append-attempts", 5, "Times to try an --append when others change the file at the same time")
+	putCmd.Flags().String("chunk-size", "16M", "Size of the chunks large files are uploaded in, up to 150M")
+	putCmd.Flags().Int("chunk-parallelism", 1, "Number of chunks of each large file to upload at once")
+	putCmd.Flags().Bool("resume", false, "Continue interrupted uploads of large files where they stopped")
+	putCmd.Flags().Bool("preserve-mtime", true, "Keep the files' local modification times (use --preserve-mtime=false for the upload time)")
+	putCmd.Flags().Bool("verify", false, "Check each upload's content hash against the local file")
+	putCmd.Flags().Bool("ignore-quota", false, "Upload even if the files don't fit in the space left")
+	putCmd.Flags().Bool("encrypt", false, "Encrypt files before uploading them")
+	putCmd.Flags().String("encrypt-suffix", defaultEncryptSuffix, "Suffix appended to the names of encrypted files")
+	putCmd.Flags().String("identity", "", "Key file to encrypt to (default $"+identityEnv+")")
+
+	for _, alias := range conflictAliases {
+		putCmd.Flags().Bool(alias.flag, false, "")
+		putCmd.Flags().MarkDeprecated(alias.flag, "use --on-conflict "+alias.strategy)
+	}
 }
@@ -0,0 +1,449 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"16777216", 16777216},
+		{"512B", 512},
+		{"512K", 512 << 10},
+		{"8m", 8 << 20},
+		{"8MB", 8 << 20},
+		{"8MiB", 8 << 20},
+		{"1gib", 1 << 30},
+		{" 64M ", 64 << 20},
+		{"", 0},
+		{"0", 0},
+		{"-1M", 0},
+		{"16I", 0},
+		{"16iB", 0},
+		{"1KIBIB", 0},
+		{"1KBB", 0},
+		{"1BK", 0},
+		{"8T", 0},
+		{"9999999999G", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.in)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("parseByteSize(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func writeTempFile(t *testing.T, name string, size int) string {
+	p := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(p, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		chunkSize string
+		flags     map[string]string
+		script    func(*testutil.FakeFiles)
+		calls     []string
+		err       string
+	}{
+		{
+			name:      "small files are uploaded in one request",
+			size:      10,
+			chunkSize: "1K",
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", nil, notFoundError())
+				f.Respond("Upload", fileMetadata("/report.txt", 10), nil)
+			},
+			calls: []string{"GetMetadata", "Upload"},
+		},
+		{
+			name:      "large files are uploaded in chunks",
+			size:      2500,
+			chunkSize: "1K",
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", nil, notFoundError())
+				f.Respond("UploadSessionStart", &files.UploadSessionStartResult{SessionId: "s1"}, nil)
+				f.Respond("UploadSessionAppend", nil, nil)
+				f.Respond("UploadSessionFinish", fileMetadata("/report.txt", 2500), nil)
+			},
+			calls: []string{"GetMetadata", "UploadSessionStart", "UploadSessionAppend", "UploadSessionFinish"},
+		},
+		{
+			name:      "upload errors fail the command",
+			size:      10,
+			chunkSize: "1K",
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", nil, notFoundError())
+				f.Respond("Upload", nil, errors.New("insufficient_space"))
+			},
+			calls: []string{"GetMetadata", "Upload"},
+			err:   "1 of 1 uploads failed",
+		},
+		{
+			name:      "existing destinations are skipped without uploading",
+			size:      10,
+			chunkSize: "1K",
+			flags:     map[string]string{"on-conflict": conflictSkip},
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", fileMetadata("/report.txt", 5), nil)
+			},
+			calls: []string{"GetMetadata"},
+		},
+		{
+			name:      "free destinations are uploaded in add mode",
+			size:      10,
+			chunkSize: "1K",
+			flags:     map[string]string{"on-conflict": conflictFail},
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", nil, notFoundError())
+				f.Respond("Upload", fileMetadata("/report.txt", 10), nil)
+			},
+			calls: []string{"GetMetadata", "Upload"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeFiles(t)
+			tt.script(fake)
+			setFlags(t, putCmd, map[string]string{"chunk-size": tt.chunkSize, "ignore-quota": "true"})
+			setFlags(t, putCmd, tt.flags)
+			src := writeTempFile(t, "report.txt", tt.size)
+
+			_, stderr, err := testutil.Capture(func() error {
+				return put(putCmd, []string{src, "/report.txt"})
+			})
+			if tt.err == "" && err != nil {
+				t.Fatalf("put failed: %v\n%s", err, stderr)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("put = %v, want %q", err, tt.err)
+			}
+			if got := fake.Methods(); !reflect.DeepEqual(got, tt.calls) {
+				t.Errorf("calls = %v, want %v", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestPutConflictCounts(t *testing.T) {
+	fake := useFakeFiles(t)
+	// new.txt is free; old.txt and older.txt already exist.
+	fake.Respond("GetMetadata", nil, notFoundError())
+	fake.Respond("GetMetadata", fileMetadata("/dst/old.txt", 5), nil)
+	fake.Respond("GetMetadata", fileMetadata("/dst/older.txt", 5), nil)
+	for _, name := range []string{"new.txt", "old.txt", "older.txt"} {
+		fake.Respond("Upload", fileMetadata("/dst/"+name, 10), nil)
+	}
+	setFlags(t, putCmd, map[string]string{"transfers": "1", "ignore-quota": "true"})
+	dir := t.TempDir()
+	var srcs []string
+	for _, name := range []string{"new.txt", "old.txt", "older.txt"} {
+		srcs = append(srcs, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(srcs[len(srcs)-1], []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, stderr, err := testutil.Capture(func() error {
+		return put(putCmd, append(srcs, "/dst"))
+	})
+	if err != nil {
+		t.Fatalf("put failed: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stderr, "Conflicts: 2 overwritten\n") {
+		t.Errorf("stderr = %q, want the two existing files counted as overwritten", stderr)
+	}
+	for _, c := range fake.Calls() {
+		if commit, ok := c.Arg.(*files.CommitInfo); ok && commit.Mode.Tag != "overwrite" {
+			t.Errorf("%s uploaded in %s mode", commit.Path, commit.Mode.Tag)
+		}
+	}
+}
+
+// Only files that were actually uploaded are counted as such; the others are
+// counted by why they were left out.
+func TestPutSummaryCounts(t *testing.T) {
+	fake := useFakeFiles(t)
+	// new.txt is free and old.txt already exists.
+	fake.Respond("GetMetadata", nil, notFoundError())
+	fake.Respond("GetMetadata", fileMetadata("/dst/old.txt", 5), nil)
+	fake.Respond("Upload", fileMetadata("/dst/new.txt", 10), nil)
+	setFlags(t, putCmd, map[string]string{
+		"transfers": "1", "ignore-quota": "true", "on-conflict": "skip", "detect-hardlinks": "true",
+	})
+	dir := t.TempDir()
+	var srcs []string
+	for _, name := range []string{"new.txt", "old.txt"} {
+		srcs = append(srcs, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(srcs[len(srcs)-1], []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Link(srcs[0], link); err != nil {
+		t.Skipf("can't make a hard link here: %v", err)
+	}
+
+	_, stderr, err := testutil.Capture(func() error {
+		return put(putCmd, append(srcs, link, "/dst"))
+	})
+	if err != nil {
+		t.Fatalf("put failed: %v\n%s", err, stderr)
+	}
+	if want := "Uploaded 1, failed 0, skipped 1 existing files, 0 special files and 1 hard links\n"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+	if !strings.Contains(stderr, "Conflicts: 1 skipped\n") {
+		t.Errorf("stderr = %q, want the existing file counted as skipped", stderr)
+	}
+}
+
+// With --json, everything put prints on stderr when some uploads fail is a
+// line of JSON: the summary, then the error.
+func TestPutJSONStderr(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("GetMetadata", nil, notFoundError())
+	fake.Respond("GetMetadata", nil, notFoundError())
+	fake.Respond("Upload", fileMetadata("/dst/a.txt", 10), nil)
+	fake.Respond("Upload", nil, errors.New("disk on fire"))
+	setFlags(t, putCmd, map[string]string{"transfers": "1", "ignore-quota": "true", "json": "true"})
+	if err := initProgress(putCmd); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { progressMode = progressAuto }()
+	dir := t.TempDir()
+	var srcs []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		srcs = append(srcs, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(srcs[len(srcs)-1], []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, stderr, err := testutil.Capture(func() error {
+		err := put(putCmd, append(srcs, "/dst"))
+		if err != nil {
+			writeJSONError(os.Stderr, err, "")
+		}
+		return err
+	})
+	if err == nil {
+		t.Fatal("put succeeded with a failed upload")
+	}
+	lines := strings.Split(strings.TrimSuffix(stderr, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("stderr = %q, want a summary and an error", stderr)
+	}
+	var counts uploadCounts
+	if err := json.Unmarshal([]byte(lines[0]), &counts); err != nil {
+		t.Fatalf("summary %q isn't JSON: %v", lines[0], err)
+	}
+	if counts.Uploaded != 1 || counts.Failed != 1 {
+		t.Errorf("summary = %+v, want 1 uploaded and 1 failed", counts)
+	}
+	var e jsonError
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil || e.Error.Message == "" {
+		t.Errorf("error %q isn't a JSON error: %v", lines[1], err)
+	}
+}
+
+func TestPutDryRunJSON(t *testing.T) {
+	fake := useFakeFiles(t)
+	setFlags(t, putCmd, map[string]string{"dry-run": "true", "json": "true"})
+	src := writeTempFile(t, "a.txt", 10)
+
+	stdout, _, err := testutil.Capture(func() error { return put(putCmd, []string{src, "/dst/a.txt"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []plannedChange
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("%q isn't a JSON list: %v", stdout, err)
+	}
+	if want := []plannedChange{{Src: src, Dst: "/dst/a.txt", Action: "upload"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plan = %+v, want %+v", got, want)
+	}
+	if calls := fake.Methods(); len(calls) != 0 {
+		t.Errorf("calls = %v, want none", calls)
+	}
+}
+
+func TestPutChunkContents(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("GetMetadata", nil, notFoundError())
+	fake.Respond("UploadSessionStart", &files.UploadSessionStartResult{SessionId: "s1"}, nil)
+	fake.Respond("UploadSessionAppend", nil, nil)
+	fake.Respond("UploadSessionFinish", fileMetadata("/big.bin", 2500), nil)
+	setFlags(t, putCmd, map[string]string{"chunk-size": "1K", "ignore-quota": "true"})
+	src := writeTempFile(t, "big.bin", 2500)
+
+	if _, stderr, err := testutil.Capture(func() error {
+		return put(putCmd, []string{src, "/big.bin"})
+	}); err != nil {
+		t.Fatalf("put failed: %v\n%s", err, stderr)
+	}
+
+	var sizes []int
+	for _, c := range fake.Calls() {
+		if c.Content != nil {
+			sizes = append(sizes, len(c.Content))
+		}
+	}
+	if want := []int{1024, 1024, 452}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("chunk sizes = %v, want %v", sizes, want)
+	}
+	finish := fake.Calls()[3].Arg.(*files.UploadSessionFinishArg)
+	if finish.Cursor.SessionId != "s1" || finish.Cursor.Offset != 2048 || finish.Commit.Path != "/big.bin" {
+		t.Errorf("finished with cursor %+v and commit %+v", finish.Cursor, finish.Commit)
+	}
+}
+
+func TestUploadChunkedSendsFinishOnce(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("UploadSessionStart", &files.UploadSessionStartResult{SessionId: "s1"}, nil)
+	fake.Respond("UploadSessionAppend", nil, resetByPeer("upload_session/append"))
+	fake.Respond("UploadSessionAppend", nil, nil)
+	fake.Respond("UploadSessionFinish", nil, resetByPeer("upload_session/finish"))
+
+	_, err := uploadChunked(cmdCtx, fake, strings.NewReader("0123456789"), files.NewCommitInfo("/big.bin"), 10, 4)
+	if err == nil {
+		t.Error("the failed finish was ignored")
+	}
+	want := []string{"UploadSessionStart", "UploadSessionAppend", "UploadSessionAppend", "UploadSessionFinish"}
+	if got := fake.Methods(); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestPutBatchCommit(t *testing.T) {
+	fake := useFakeFiles(t)
+	api := useFakeAPI(t)
+	var jobs []uploadJob
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("f%d.txt", i)
+		jobs = append(jobs, uploadJob{src: writeTempFile(t, name, 10+i), dst: "/batch/" + name})
+		fake.Respond("GetMetadata", nil, notFoundError())
+		fake.Respond("UploadSessionStart", &files.UploadSessionStartResult{SessionId: fmt.Sprintf("s%d", i)}, nil)
+	}
+	api.Respond("files/upload_session/finish_batch", 200, `{".tag": "async_job_id", "async_job_id": "j1"}`)
+	api.Respond("files/upload_session/finish_batch/check", 200, `{".tag": "in_progress"}`)
+	api.Respond("files/upload_session/finish_batch/check", 200, `{".tag": "complete", "entries": [
+		{".tag": "success", "name": "f.txt", "id": "id:1", "size": 10},
+		{".tag": "failure", "failure": {".tag": "path", "path": {".tag": "insufficient_space"}}},
+		{".tag": "success", "name": "f.txt", "id": "id:2", "size": 10}
+	]}`)
+
+	opts := uploadOptions{strategy: conflictOverwrite, chunkSize: defaultChunkSize, batch: newBatchCommitter(cmdCtx)}
+	var errs []string
+	testutil.Capture(func() error {
+		for r := range uploadAll(cmdCtx, fake, jobs, opts, len(jobs)) {
+			if r.err != nil {
+				errs = append(errs, r.err.Error())
+			}
+		}
+		opts.batch.close()
+		return nil
+	})
+
+	if want := []string{"upload failed: path/insufficient_space"}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors = %q, want %q", errs, want)
+	}
+	if want := []string{"files/upload_session/finish_batch", "files/upload_session/finish_batch/check", "files/upload_session/finish_batch/check"}; !reflect.DeepEqual(api.Routes(), want) {
+		t.Errorf("routes = %v, want %v", api.Routes(), want)
+	}
+
+	// Each session holds the whole file and is committed to its destination.
+	sessions := make(map[string]int)
+	for _, c := range fake.Calls() {
+		start, ok := c.Arg.(*files.UploadSessionStartArg)
+		if !ok {
+			continue
+		}
+		id := fmt.Sprintf("s%d", len(sessions))
+		if !start.Close {
+			t.Errorf("session %s wasn't closed", id)
+		}
+		sessions[id] = len(c.Content)
+	}
+	var arg files.UploadSessionFinishBatchArg
+	if err := json.Unmarshal([]byte(api.Calls()[0].Arg), &arg); err != nil {
+		t.Fatal(err)
+	}
+	if len(arg.Entries) != len(jobs) {
+		t.Fatalf("committed %d entries, want %d", len(arg.Entries), len(jobs))
+	}
+	for _, e := range arg.Entries {
+		size := sessions[e.Cursor.SessionId]
+		if want := fmt.Sprintf("/batch/f%d.txt", size-10); e.Commit.Path != want || e.Cursor.Offset != uint64(size) {
+			t.Errorf("session %s of %d bytes committed to %s at offset %d", e.Cursor.SessionId, size, e.Commit.Path, e.Cursor.Offset)
+		}
+	}
+}
+
+func TestSkipHardlinks(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for _, p := range []string{a, c} {
+		if err := ioutil.WriteFile(p, []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(a, b); err != nil {
+		t.Skipf("can't make a hard link here: %v", err)
+	}
+	jobs := []uploadJob{{src: a, dst: "/a"}, {src: b, dst: "/b"}, {src: c, dst: "/c"}, {src: a, dst: "/a2"}}
+
+	var kept []uploadJob
+	var skipped int
+	_, stderr, _ := testutil.Capture(func() error {
+		kept, skipped = skipHardlinks(jobs)
+		return nil
+	})
+	if want := []uploadJob{jobs[0], jobs[2]}; !reflect.DeepEqual(kept, want) || skipped != 2 {
+		t.Errorf("skipHardlinks kept %v and skipped %d, want %v and 2", kept, skipped, want)
+	}
+	// Skipped files are reported even without --verbose.
+	want := fmt.Sprintf("Skipping %s: same file as %s\nSkipping %s: same file as %s\n", b, a, a, a)
+	if stderr != want {
+		t.Errorf("skipHardlinks printed %q, want %q", stderr, want)
+	}
+}
@@ -18,27 +18,54 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
 	"github.com/spf13/cobra"
 )
 
+// The vendored SDK drops the id of the job members/remove starts.
+type membersRemoveArg struct {
+	User        *team.UserSelectorArg `json:"user"`
+	WipeData    bool                  `json:"wipe_data"`
+	KeepAccount bool                  `json:"keep_account"`
+}
+
+type asyncLaunch struct {
+	dropbox.Tagged
+	AsyncJobID string `json:"async_job_id"`
+}
+
 func removeMember(cmd *cobra.Command, args []string) (err error) {
 	if len(args) != 1 {
 		return errors.New("`remove-member` requires an `email` argument")
 	}
 
-	dbx := team.New(config)
-	email := args[0]
-	selector := &team.UserSelectorArg{Email: email}
-	selector.Tag = "email"
-	arg := team.NewMembersRemoveArg(selector)
-	res, err := dbx.MembersRemove(arg)
-	if err != nil {
+	// Dropbox won't wipe the data of an account the member keeps.
+	keepAccount, _ := cmd.Flags().GetBool("keep-account")
+	arg := membersRemoveArg{User: teamMemberSelector(args[0]), WipeData: !keepAccount, KeepAccount: keepAccount}
+	var res asyncLaunch
+	if err = rpc(cmdCtx, "team", "members/remove", &arg, &res); err != nil {
 		return err
 	}
-	if res.Tag == "complete" {
-		fmt.Printf("User successfully removed from team.\n")
+
+	// Removing a member with a lot of data runs as a job.
+	if res.Tag == "async_job_id" {
+		var status dropbox.Tagged
+		err = retry.PollUntil(cmdCtx, batchPollPolicy, func() (bool, error) {
+			if err := rpc(cmdCtx, "team", "members/remove/job_status/get", &asyncJobIDArg{res.AsyncJobID}, &status); err != nil {
+				return false, err
+			}
+			return status.Tag != "in_progress", nil
+		})
+		if err != nil {
+			return err
+		}
+		if status.Tag != "complete" {
+			return fmt.Errorf("removing %s ended with status %q", args[0], status.Tag)
+		}
 	}
+	fmt.Printf("User successfully removed from team.\n")
 	return
 }
 
@@ -51,4 +78,6 @@ var removeMemberCmd = &cobra.Command{
 
 func init() {
 	teamCmd.AddCommand(removeMemberCmd)
+
+	removeMemberCmd.Flags().Bool("keep-account", false, "Turn the member's account into a personal Dropbox account instead of deleting it")
 }
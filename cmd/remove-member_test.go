@@ -0,0 +1,45 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestRemoveMember(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		api := useFakeAPI(t)
+		api.Respond("team/members/remove", 200, `{".tag": "complete"}`)
+		if keep {
+			setFlags(t, removeMemberCmd, map[string]string{"keep-account": "true"})
+		}
+		if _, _, err := testutil.Capture(func() error { return removeMember(removeMemberCmd, []string{"ann@example.com"}) }); err != nil {
+			t.Fatal(err)
+		}
+		var arg struct {
+			WipeData    bool `json:"wipe_data"`
+			KeepAccount bool `json:"keep_account"`
+		}
+		if err := json.Unmarshal([]byte(api.Calls()[0].Arg), &arg); err != nil {
+			t.Fatal(err)
+		}
+		if arg.KeepAccount != keep || arg.WipeData == keep {
+			t.Errorf("--keep-account=%t sent keep_account %t, wipe_data %t", keep, arg.KeepAccount, arg.WipeData)
+		}
+	}
+}
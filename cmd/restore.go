@@ -16,14 +16,32 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/spf13/cobra"
 )
 
+// Picks the newest of `revs`, which are sorted newest first, that was saved
+// before `t`.
+func latestRevisionBefore(revs []*revision, t time.Time) (*revision, error) {
+	for _, r := range revs {
+		if r.ServerModified.Before(t) {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("no revision was saved before %s", t.Local().Format(time.RFC3339))
+}
+
 func restore(cmd *cobra.Command, args []string) (err error) {
-	if len(args) != 2 {
+	before, _ := cmd.Flags().GetString("latest-before")
+	switch {
+	case before == "" && len(args) != 2:
 		return errors.New("`restore` requires `file` and `revision` arguments")
+	case before != "" && len(args) != 1:
+		return errors.New("`restore --latest-before` requires just a `file` argument")
 	}
 
 	path, err := validatePath(args[0])
@@ -31,25 +49,61 @@ func restore(cmd *cobra.Command, args []string) (err error) {
 		return
 	}
 
-	rev := args[1]
+	var rev string
+	if before != "" {
+		t, err := parseRelativeTime(before, ago)
+		if err != nil {
+			return fmt.Errorf("`--latest-before`: %v", err)
+		}
+		res, err := listRevisions(cmdCtx, path)
+		if err != nil {
+			return err
+		}
+		r, err := latestRevisionBefore(res.Entries, t)
+		if err != nil {
+			return err
+		}
+		rev = r.Rev
+		if !jsonMode(cmd) {
+			fmt.Fprintf(os.Stderr, "Restoring revision %s, saved %s\n", rev, r.ServerModified.Local().Format(time.RFC3339))
+		}
+	} else {
+		rev = args[1]
+	}
 
 	arg := files.NewRestoreArg(path, rev)
 
-	dbx := files.New(config)
-	if _, err = dbx.Restore(arg); err != nil {
+	dbx := newFilesClient(cmdCtx)
+	restored, err := dbx.Restore(arg)
+	if err != nil {
 		return
 	}
 
+	if jsonMode(cmd) {
+		return printJSON(newJSONEntry(restored, fileExtras{}))
+	}
 	return
 }
 
 // restoreCmd represents the restore command
 var restoreCmd = &cobra.Command{
-	Use:   "restore [flags] <file> <revision>",
+	Use:   "restore [flags] <file> [<revision>]",
 	Short: "Restore files",
-	RunE:  restore,
+	Long: `Restore a file to one of its earlier revisions, as listed by "dbxcli revs".
+
+Instead of a <revision>, --latest-before picks the newest revision saved
+before a date such as 2024-01-01, an RFC 3339 time, or an age such as 2h,
+which is handy after a file has been overwritten by mistake. Only the
+latest 100 revisions are considered.
+
+With --json the restored file is printed as a JSON object.`,
+	Example: `  dbxcli restore /notes.txt a1c10ce0dd78
+  dbxcli restore --latest-before 2h /notes.txt
+  dbxcli restore --latest-before 2024-01-01T09:00:00Z /notes.txt`,
+	RunE: restore,
 }
 
 func init() {
 	RootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().String("latest-before", "", "Restore the newest revision saved before this date, time or age")
 }
@@ -0,0 +1,161 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/spf13/cobra"
+)
+
+// Longest wait between retries of a request, unless Dropbox asks for longer.
+const maxRetryDelay = time.Minute
+
+// How API requests which fail with a transient error are retried, set from
+// `--retries` and `--retry-delay`. Nil means they aren't.
+var requestRetryPolicy retry.Policy
+
+func initRetries(cmd *cobra.Command) error {
+	retries, _ := cmd.Flags().GetInt("retries")
+	delay, _ := cmd.Flags().GetDuration("retry-delay")
+	if retries < 0 {
+		return fmt.Errorf("`--retries` must be 0 or more, not %d", retries)
+	}
+	if delay <= 0 {
+		return errors.New("`--retry-delay` must be positive")
+	}
+
+	requestRetryPolicy = nil
+	if retries > 0 {
+		requestRetryPolicy = retry.HonorRetryAfter{Policy: retry.Exponential{
+			Initial:     delay,
+			Max:         maxRetryDelay,
+			Jitter:      0.2,
+			MaxAttempts: retries + 1,
+		}}
+	}
+	return nil
+}
+
+// A response worth retrying: Dropbox is rate limiting us or has a temporary
+// problem.
+type transientResponse struct {
+	status int
+	after  time.Duration
+}
+
+func (e transientResponse) Error() string {
+	return fmt.Sprintf("transient API error: %s", http.StatusText(e.status))
+}
+
+// RetryAfter implements retry.RetryAfterer, so a Retry-After header is
+// honored.
+func (e transientResponse) RetryAfter() time.Duration {
+	return e.after
+}
+
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Read-only routes are named after what they do, e.g. "files/list_folder"
+// or "users/get_space_usage".
+var readRoutePrefixes = []string{"get", "list", "search", "download", "export", "count"}
+
+// Reports whether sending a request to `route` twice does no more than
+// sending it once, which makes it safe to send again after a network error
+// left it unknown whether the first one arrived. Moves, copies, deletes and
+// uploads aren't: if only the response was lost, they'd be applied twice or
+// fail on their own result.
+func isIdempotentRoute(route string) bool {
+	parts := strings.Split(route, "/")
+	if parts[len(parts)-1] == "check" {
+		// Polls an async job, such as files/delete_batch/check.
+		return true
+	}
+	for _, part := range parts[1:] {
+		for _, prefix := range readRoutePrefixes {
+			if strings.HasPrefix(part, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sends `req` with `send`, retrying transient responses under
+// requestRetryPolicy, and network errors too if the route is idempotent.
+// Requests whose body can't be sent again, such as uploads streamed from a
+// file, are only sent once. When the retries run out the last response is
+// returned as it is, so the usual API error comes out of it.
+func sendWithRetry(ctx context.Context, req *http.Request, send func(*http.Request) (*http.Response, error)) (resp *http.Response, err error) {
+	if requestRetryPolicy == nil || (req.Body != nil && req.GetBody == nil) {
+		return send(req)
+	}
+	idempotent := isIdempotentRoute(strings.TrimPrefix(req.URL.Path, "/2/"))
+
+	first := true
+	err = retry.Do(ctx, requestRetryPolicy, func() error {
+		attempt := req
+		if !first && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return retry.Permanent(err)
+			}
+			attempt = new(http.Request)
+			*attempt = *req
+			attempt.Body = body
+		}
+		first = false
+
+		var err error
+		if resp, err = send(attempt); err != nil {
+			if ctx.Err() != nil || !idempotent {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+		if !isTransientStatus(resp.StatusCode) {
+			return nil
+		}
+
+		// Keep the body of the response, which may be the last one.
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+		after, _ := retry.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return transientResponse{resp.StatusCode, after}
+	})
+	if _, ok := err.(transientResponse); ok {
+		return resp, nil
+	}
+	if err != nil {
+		resp = nil
+	}
+	return
+}
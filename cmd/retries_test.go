@@ -0,0 +1,100 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+)
+
+func TestIsIdempotentRoute(t *testing.T) {
+	tests := []struct {
+		route string
+		want  bool
+	}{
+		{"files/get_metadata", true},
+		{"files/list_folder/continue", true},
+		{"files/search_v2", true},
+		{"files/download", true},
+		{"files/delete_batch/check", true},
+		{"users/get_space_usage", true},
+		{"team/members/list_v2", true},
+		{"files/move_v2", false},
+		{"files/copy_v2", false},
+		{"files/delete_v2", false},
+		{"files/upload", false},
+		{"files/upload_session/append_v2", false},
+		{"sharing/create_shared_link_with_settings", false},
+		{"team/members/remove", false},
+	}
+	for _, tt := range tests {
+		if got := isIdempotentRoute(tt.route); got != tt.want {
+			t.Errorf("isIdempotentRoute(%q) = %v, want %v", tt.route, got, tt.want)
+		}
+	}
+}
+
+// Makes API requests retry as they would with `--retries 2` until the test
+// ends.
+func useRequestRetries(t *testing.T) {
+	saved := requestRetryPolicy
+	requestRetryPolicy = retry.Exponential{Initial: time.Second, MaxAttempts: 3}
+	t.Cleanup(func() { requestRetryPolicy = saved })
+}
+
+func TestSendWithRetry(t *testing.T) {
+	useRequestRetries(t)
+	ctx := retry.WithClock(context.Background(), instantClock{})
+
+	lost := errors.New("connection reset by peer")
+	tests := []struct {
+		name      string
+		route     string
+		responses []*http.Response
+		errs      []error
+		sent      int
+		wantErr   bool
+	}{
+		{"read after a network error", "files/list_folder", []*http.Response{nil, jsonResponse(200, "{}")}, []error{lost, nil}, 2, false},
+		{"move after a network error", "files/move_v2", []*http.Response{nil}, []error{lost}, 1, true},
+		{"move after a 503", "files/move_v2", []*http.Response{jsonResponse(503, ""), jsonResponse(200, "{}")}, []error{nil, nil}, 2, false},
+		{"move after a 409", "files/move_v2", []*http.Response{jsonResponse(409, "{}")}, []error{nil}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "https://api.dropboxapi.com/2/"+tt.route, bytes.NewReader([]byte(`{"path":"/a"}`)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := 0
+			resp, err := sendWithRetry(ctx, req, func(*http.Request) (*http.Response, error) {
+				sent++
+				return tt.responses[sent-1], tt.errs[sent-1]
+			})
+			if sent != tt.sent {
+				t.Errorf("sent %d times, want %d", sent, tt.sent)
+			}
+			if tt.wantErr != (err != nil) || (err == nil && resp.StatusCode != tt.responses[sent-1].StatusCode) {
+				t.Errorf("sendWithRetry = %v, %v", resp, err)
+			}
+		})
+	}
+}
@@ -15,14 +15,93 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
 
-	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
 	"github.com/spf13/cobra"
 )
 
+// Limit on the number of revisions list_revisions returns; 100 is the most
+// the API allows.
+const maxRevisions = 100
+
+// The vendored SDK's FileMetadata lacks content_hash and is_downloadable, so
+// revisions are listed with rpc.
+type listRevisionsArg struct {
+	Path  string `json:"path"`
+	Mode  string `json:"mode"`
+	Limit uint64 `json:"limit"`
+}
+
+type revision struct {
+	Rev            string    `json:"rev"`
+	Id             string    `json:"id"`
+	Name           string    `json:"name"`
+	Size           uint64    `json:"size"`
+	ContentHash    string    `json:"content_hash,omitempty"`
+	ClientModified time.Time `json:"client_modified"`
+	ServerModified time.Time `json:"server_modified"`
+	IsDownloadable *bool     `json:"is_downloadable,omitempty"`
+}
+
+type listRevisionsResult struct {
+	IsDeleted bool        `json:"is_deleted"`
+	Entries   []*revision `json:"entries"`
+}
+
+// Shape of each object printed by `revs --json`.
+type revisionJSON struct {
+	Rev            string    `json:"rev"`
+	Id             string    `json:"id"`
+	Size           uint64    `json:"size"`
+	ContentHash    string    `json:"content_hash"`
+	ClientModified time.Time `json:"client_modified"`
+	ServerModified time.Time `json:"server_modified"`
+	IsDownloadable bool      `json:"is_downloadable"`
+}
+
+func listRevisions(ctx context.Context, path string) (res *listRevisionsResult, err error) {
+	res = new(listRevisionsResult)
+	err = rpc(ctx, "files", "list_revisions", listRevisionsArg{path, "path", maxRevisions}, res)
+	if isMissing(err) {
+		return nil, fmt.Errorf("%s has never existed", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first, regardless of the order the server used.
+	sort.SliceStable(res.Entries, func(i, j int) bool {
+		return res.Entries[i].ServerModified.After(res.Entries[j].ServerModified)
+	})
+	return
+}
+
+func printRevisionsJSON(w io.Writer, revs []*revision) error {
+	out := make([]revisionJSON, len(revs))
+	for i, r := range revs {
+		out[i] = revisionJSON{
+			Rev:            r.Rev,
+			Id:             r.Id,
+			Size:           r.Size,
+			ContentHash:    r.ContentHash,
+			ClientModified: r.ClientModified,
+			ServerModified: r.ServerModified,
+			IsDownloadable: r.IsDownloadable == nil || *r.IsDownloadable,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
 func revs(cmd *cobra.Command, args []string) (err error) {
 	if len(args) != 1 {
 		return errors.New("`revs` requires a `file` argument")
@@ -33,36 +112,53 @@ func revs(cmd *cobra.Command, args []string) (err error) {
 		return
 	}
 
-	arg := files.NewListRevisionsArg(path)
-
-	dbx := files.New(config)
-	res, err := dbx.ListRevisions(arg)
+	res, err := listRevisions(cmdCtx, path)
 	if err != nil {
 		return
 	}
 
-	long, _ := cmd.Flags().GetBool("long")
+	if jsonMode(cmd) {
+		return printRevisionsJSON(os.Stdout, res.Entries)
+	}
 
-	if long {
-		fmt.Printf("Revision\tSize\tLast modified\tPath\n")
+	if res.IsDeleted {
+		fmt.Fprintf(os.Stderr, "%s is deleted; `restore` can bring back one of these revisions\n", path)
 	}
 
-	for _, e := range res.Entries {
-		if long {
-			printFileMetadata(os.Stdout, e, long)
-		} else {
+	long, _ := cmd.Flags().GetBool("long")
+	if !long {
+		for _, e := range res.Entries {
 			fmt.Printf("%s\n", e.Rev)
 		}
+		return
 	}
 
-	return
+	// Columns are fixed, space-padded and never contain blanks themselves,
+	// with the revision first, so the output is easy to take apart with awk.
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Revision\tSize\tServer modified\tContent hash\n")
+	for _, e := range res.Entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.Rev, e.Size, e.ServerModified.UTC().Format(time.RFC3339), e.ContentHash)
+	}
+	return w.Flush()
 }
 
 // revsCmd represents the revs command
 var revsCmd = &cobra.Command{
 	Use:   "revs [flags] <file>",
 	Short: "List file revisions",
-	RunE:  revs,
+	Long: `List file revisions, newest first.
+
+By default only revision identifiers are printed, one per line. A path which
+has never existed is an error, while a file with a single revision prints one
+line. With --long, each revision's size, the time it was saved to Dropbox and
+its content hash are shown too, and with --json all of that as a JSON array.
+Up to the latest 100 revisions are listed.`,
+	Example: `  dbxcli revs /notes.txt
+  dbxcli revs -l /notes.txt
+  dbxcli revs --json /notes.txt | jq -r '.[0].rev'`,
+	RunE: revs,
 }
 
 func init() {
@@ -15,44 +15,323 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dustin/go-humanize"
 
 	"github.com/spf13/cobra"
 )
 
+// Returns the number of components in a normalized remote path, so "/" is 0
+// and "/Photos" is 1. For "id:" and "ns:" forms only the components after the
+// prefix are counted.
+func pathDepth(p string) int {
+	for _, idPrefix := range pathIDPrefixes {
+		if strings.HasPrefix(p, idPrefix) {
+			i := strings.Index(p, "/")
+			if i < 0 {
+				return 0
+			}
+			p = p[i:]
+		}
+	}
+	return strings.Count(p, "/")
+}
+
+// Reads the answers to prompts. There's one reader for the whole run, since a
+// reader of its own per prompt could buffer the answers to later ones when
+// they're piped in.
+var stdinLines = bufio.NewReader(os.Stdin)
+
+// Asks the user to type `expected` back, and fails unless they do.
+func confirmByTyping(prompt string, expected string) error {
+	fmt.Fprintf(os.Stderr, "%s\nType %q to confirm: ", prompt, expected)
+	line, err := stdinLines.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if strings.TrimRight(line, "\r\n") != expected {
+		return errors.New("confirmation didn't match; nothing was deleted")
+	}
+	return nil
+}
+
+// Asks a yes/no question on stderr; anything but "y" or "yes" is a no.
+func confirmYesNo(prompt string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	line, err := stdinLines.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// Guards against deleting the root folder or a top-level folder by accident.
+// `p` must already be normalized by validatePath so "//" or "/./" can't slip
+// through, and resolved by resolvePath so an id can't either. `md` tells a
+// top-level file, which needs no guarding, from a folder; see
+// removableMetadata. A dry run doesn't ask for confirmation, since nothing will
+// be removed.
+func checkRemovable(p string, md files.IsMetadata, force bool, dryRun bool) error {
+	if p == "" || (pathDepth(p) == 0 && !strings.HasPrefix(p, "id:")) {
+		return errors.New("rm: refusing to remove the root folder")
+	}
+
+	s, err := readSettings()
+	if err != nil {
+		return err
+	}
+	if err = checkProtected(s, p); err != nil {
+		return fmt.Errorf("rm: %v", err)
+	}
+
+	if _, isFile := md.(*files.FileMetadata); pathDepth(p) == 1 && !isFile {
+		if !force {
+			return fmt.Errorf("rm: %s is a top-level folder; use `--force` to remove it", p)
+		}
+		if dryRun {
+			return nil
+		}
+		return confirmByTyping(fmt.Sprintf("You are about to remove the top-level folder %s.", p), p)
+	}
+	return nil
+}
+
+// Returns what checkRemovable needs to know about `p`: its metadata if it's
+// at the top level, fetched unless `md` already holds it.
+func removableMetadata(dbx files.Client, p string, md files.IsMetadata) (files.IsMetadata, error) {
+	if md != nil || pathDepth(p) != 1 {
+		return md, nil
+	}
+	return getFileMetadata(dbx, p)
+}
+
 func rm(cmd *cobra.Command, args []string) error {
-	if len(args) != 1 {
+	if len(args) == 0 {
 		return errors.New("`rm` requires a `file` argument")
 	}
 
-	path, err := validatePath(args[0])
+	force, err := cmd.Flags().GetBool("force")
 	if err != nil {
 		return err
 	}
 
-	dbx := files.New(config)
-	pathMetaData, err := getFileMetadata(dbx, path)
+	dbx := newFilesClient(cmdCtx)
+	var matches []remoteMatch
+	for _, arg := range args {
+		m, err := resolveArgs(cmd, dbx, arg)
+		if err != nil {
+			return err
+		}
+		matches = append(matches, m...)
+	}
+
+	archiveTo, _ := cmd.Flags().GetString("archive-to")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if permanent, _ := cmd.Flags().GetBool("permanent"); permanent {
+		if archiveTo != "" {
+			return errors.New("`--permanent` can't be combined with `--archive-to`")
+		}
+		return removePermanently(cmd, dbx, matches, force, dryRun)
+	}
+	if len(matches) == 1 || archiveTo != "" || dryRun {
+		for _, m := range matches {
+			if err = removePath(cmd, dbx, m.path, m.md, force); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Check everything first, then delete it all in batches.
+	var paths []string
+	for _, m := range matches {
+		resolved, err := resolvePath(dbx, m.path)
+		if err != nil {
+			return err
+		}
+		md, err := removableMetadata(dbx, resolved, m.md)
+		if err != nil {
+			return err
+		}
+		if err = checkRemovable(resolved, md, force, dryRun); err != nil {
+			return err
+		}
+		if err = checkEmptyFolder(cmd, dbx, m.path, md, force); err != nil {
+			return err
+		}
+		paths = append(paths, m.path)
+	}
+	failed, err := deleteAll(cmd, paths)
 	if err != nil {
 		return err
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d deletions failed", len(failed), len(paths))
+	}
+	return nil
+}
 
-	force, err := cmd.Flags().GetBool("force")
+// Implements `rm --permanent`: deletes `matches` for good, bypassing the
+// trash, once the same checks as for deleting them have passed and the user
+// has confirmed twice.
+func removePermanently(cmd *cobra.Command, dbx files.Client, matches []remoteMatch, force bool, dryRun bool) error {
+	var paths []string
+	for _, m := range matches {
+		resolved, err := resolvePath(dbx, m.path)
+		if err != nil {
+			return err
+		}
+		md, err := removableMetadata(dbx, resolved, m.md)
+		if err != nil {
+			return err
+		}
+		if err = checkRemovable(resolved, md, force, dryRun); err != nil {
+			return err
+		}
+		if err = checkEmptyFolder(cmd, dbx, m.path, md, force); err != nil {
+			return err
+		}
+		paths = append(paths, m.path)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		for _, p := range paths {
+			printDryRun(cmd, "permanently delete", "", p)
+		}
+		return nil
+	}
+
+	ok, err := confirmYesNo(fmt.Sprintf("Permanently delete %d item(s)? They can't be restored, not even from the trash:\n  %s\n",
+		len(paths), strings.Join(paths, "\n  ")))
 	if err != nil {
 		return err
 	}
+	if !ok {
+		return errors.New("rm: cancelled; nothing was deleted")
+	}
+	if err = confirmByTyping("This can't be undone.", "permanently delete"); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, p := range paths {
+		if err = rpc(cmdCtx, "files", "permanently_delete", files.NewDeleteArg(p), nil); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p, explainError(err))
+			failed++
+		}
+		if jsonMode(cmd) {
+			printDeleted(cmd, p, err)
+		} else if err == nil {
+			fmt.Printf("Permanently deleted %s\n", p)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d permanent deletions failed", failed, len(paths))
+	}
+	return nil
+}
 
-	if _, ok := pathMetaData.(*files.FileMetadata); !ok {
-		folderArg := files.NewListFolderArg(path)
-		res, err := dbx.ListFolder(folderArg)
+// Refuses to delete a folder with anything in it unless `force` is set, or
+// with --recursive the user agrees once they've been told how much is in it.
+// `md` is the metadata of `path`, or nil to look it up.
+func checkEmptyFolder(cmd *cobra.Command, dbx files.Client, path string, md files.IsMetadata, force bool) (err error) {
+	if md == nil {
+		if md, err = getFileMetadata(dbx, path); err != nil {
+			return
+		}
+	}
+	if _, ok := md.(*files.FileMetadata); ok || force {
+		return nil
+	}
+	folderArg := files.NewListFolderArg(path)
+	res, err := dbx.ListFolder(folderArg)
+	if err != nil {
+		return
+	}
+	if len(res.Entries) == 0 {
+		return nil
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	if !recursive {
+		return fmt.Errorf("rm: cannot remove ‘%s’: Directory not empty, use `-r` to be asked first, or `--force` or `-f` to proceed", path)
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		return nil
+	}
+
+	var count, size uint64
+	err = walkFolder(cmdCtx, dbx, path, "", 0, nil, func(entries []files.IsMetadata) {
+		for _, entry := range entries {
+			if f, ok := entry.(*files.FileMetadata); ok {
+				count++
+				size += f.Size
+			}
+		}
+	})
+	if err != nil {
+		return
+	}
+	ok, err := confirmYesNo(fmt.Sprintf("Delete %s files (%s) under %s?", humanize.Comma(int64(count)), humanize.IBytes(size), path))
+	if err != nil {
+		return
+	}
+	if !ok {
+		return errors.New("rm: cancelled; nothing was deleted")
+	}
+	return nil
+}
+
+// Removes a single `path`, whose metadata `md` may be nil if it isn't known
+// yet.
+func removePath(cmd *cobra.Command, dbx files.Client, path string, md files.IsMetadata, force bool) error {
+	resolved, err := resolvePath(dbx, path)
+	if err != nil {
+		return err
+	}
+	if md, err = removableMetadata(dbx, resolved, md); err != nil {
+		return err
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if err = checkRemovable(resolved, md, force, dryRun); err != nil {
+		return err
+	}
+
+	if archiveTo, _ := cmd.Flags().GetString("archive-to"); archiveTo != "" {
+		archive, err := validatePath(archiveTo)
 		if err != nil {
 			return err
 		}
-		if len(res.Entries) != 0 && !force {
-			return fmt.Errorf("rm: cannot remove ‘%s’: Directory not empty, use `--force` or `-f` to proceed", path)
+		if dryRun {
+			printDryRun(cmd, "archive", resolved, archive)
+			return nil
 		}
+		return archiveRemove(cmd, dbx, resolved, archive)
+	}
+
+	if err = checkEmptyFolder(cmd, dbx, path, md, force); err != nil {
+		return err
+	}
+
+	if dryRun {
+		printDryRun(cmd, "delete", "", path)
+		return nil
 	}
 
 	arg := files.NewDeleteArg(path)
@@ -60,18 +339,60 @@ func rm(cmd *cobra.Command, args []string) error {
 	if _, err = dbx.Delete(arg); err != nil {
 		return err
 	}
-
-	return err
+	// Without --json, a single deletion is silent, like rm(1).
+	if jsonMode(cmd) {
+		printDeleted(cmd, path, nil)
+	}
+	return nil
 }
 
 // rmCmd represents the rm command
 var rmCmd = &cobra.Command{
-	Use:   "rm [flags] <file>",
+	Use:   "rm [flags] <file>...",
 	Short: "Remove files",
-	RunE:  rm,
+	Long: `Remove files.
+
+With --archive-to, the file or folder is moved into the archive folder instead
+of being deleted, under a folder for the current day and at the same relative
+path, e.g. /Trash/2016-04-01/Photos/cat.jpg. A timestamp is added to the name
+if that path is taken, and a counter too if that's taken as well. Set a default with ` + "`config set rm.archive-to /Trash`" + `,
+and use ` + "`trash empty`" + ` to delete old archived items for good.
+
+<file> may be a glob pattern such as "/logs/*.tmp", which removes everything
+that matches. Patterns are matched ignoring case, like Dropbox names. If
+nothing matches, or <file> doesn't exist, rm fails unless --missing-ok is
+given, in which case it only notes the path as skipped.
+
+When there's more than one thing to remove, every path is checked first and
+then they're deleted together with batch requests of up to 1000 paths, each
+reported as it's deleted or fails. This is much faster than one request per
+path, and less likely to be slowed down by Dropbox.
+
+A folder with anything in it is only removed with --force, or with
+--recursive (-r) once you've answered yes to a question showing how many
+files are beneath it and their total size.
+
+--permanent deletes for good, bypassing the trash, so nothing removed this
+way can be restored. It's only available to members of Dropbox Business
+teams whose admins allow it. You're asked twice before anything is
+deleted: once to agree to the list of paths and once to type "permanently
+delete".
+
+With --dry-run, rm only lists what it would delete or archive. The same
+checks are made, so a folder that isn't empty still needs --force or
+--recursive, but nothing is asked.
+
+With --json, each deletion or archived item, or each change --dry-run would
+make, is printed as a line of JSON.`,
+	RunE: rm,
 }
 
 func init() {
 	RootCmd.AddCommand(rmCmd)
 	rmCmd.Flags().BoolP("force", "f", false, "Force removal")
+	rmCmd.Flags().BoolP("recursive", "r", false, "Remove folders and everything in them after asking")
+	rmCmd.Flags().Bool("permanent", false, "Delete for good instead of moving to the trash (Dropbox Business only)")
+	rmCmd.Flags().String("archive-to", "", "Move into this archive folder instead of deleting")
+	rmCmd.Flags().Bool("dry-run", false, "Only list what would be removed")
+	rmCmd.Flags().Bool("missing-ok", false, "Don't fail if <file> doesn't exist or matches nothing")
 }
@@ -0,0 +1,188 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestRmBatches(t *testing.T) {
+	files := useFakeFiles(t)
+	files.Respond("GetMetadata", fileMetadata("/docs/a.txt", 1), nil)
+	files.Respond("GetMetadata", fileMetadata("/docs/b.txt", 1), nil)
+	api := useFakeAPI(t)
+	api.Respond("files/delete_batch", 200, `{".tag": "async_job_id", "async_job_id": "j1"}`)
+	api.Respond("files/delete_batch/check", 200, `{".tag": "in_progress"}`)
+	api.Respond("files/delete_batch/check", 200, `{".tag": "complete", "entries": [
+		{".tag": "success", "metadata": {".tag": "file", "path_display": "/docs/a.txt"}},
+		{".tag": "failure", "failure": {".tag": "path_lookup", "path_lookup": {".tag": "not_found"}}}]}`)
+
+	stdout, stderr, err := testutil.Capture(func() error {
+		return rm(rmCmd, []string{"/docs/a.txt", "/docs/b.txt"})
+	})
+	if err == nil || err.Error() != "1 of 2 deletions failed" {
+		t.Fatalf("rm = %v, want 1 of 2 deletions failed\n%s", err, stderr)
+	}
+	want := []string{"files/delete_batch", "files/delete_batch/check", "files/delete_batch/check"}
+	if got := api.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+	var arg deleteBatchArg
+	if err := json.Unmarshal([]byte(api.Calls()[0].Arg), &arg); err != nil {
+		t.Fatal(err)
+	}
+	if want := []deleteBatchEntry{{"/docs/a.txt"}, {"/docs/b.txt"}}; !reflect.DeepEqual(arg.Entries, want) {
+		t.Errorf("batch = %+v, want %+v", arg.Entries, want)
+	}
+	if stdout != "Deleted /docs/a.txt\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if !strings.Contains(stderr, "/docs/b.txt: delete failed: path_lookup/not_found") {
+		t.Errorf("stderr = %q", stderr)
+	}
+}
+
+// With --json, each deletion in a batch is a line saying how it went.
+func TestRmBatchesJSON(t *testing.T) {
+	files := useFakeFiles(t)
+	files.Respond("GetMetadata", fileMetadata("/docs/a.txt", 1), nil)
+	files.Respond("GetMetadata", fileMetadata("/docs/b.txt", 1), nil)
+	api := useFakeAPI(t)
+	api.Respond("files/delete_batch", 200, `{".tag": "complete", "entries": [
+		{".tag": "success", "metadata": {".tag": "file", "path_display": "/docs/a.txt"}},
+		{".tag": "failure", "failure": {".tag": "path_lookup", "path_lookup": {".tag": "not_found"}}}]}`)
+	setFlags(t, rmCmd, map[string]string{"json": "true"})
+
+	stdout, _, _ := testutil.Capture(func() error {
+		return rm(rmCmd, []string{"/docs/a.txt", "/docs/b.txt"})
+	})
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("printed %q", stdout)
+	}
+	var deleted, failed deleteRecord
+	json.Unmarshal([]byte(lines[0]), &deleted)
+	json.Unmarshal([]byte(lines[1]), &failed)
+	if deleted != (deleteRecord{Path: "/docs/a.txt", Outcome: "deleted"}) {
+		t.Errorf("first line = %s", lines[0])
+	}
+	if failed.Path != "/docs/b.txt" || failed.Outcome != outcomeFailed || failed.Error == nil {
+		t.Errorf("second line = %s", lines[1])
+	}
+}
+
+// A file at the top level is removed like any other, without --force.
+func TestRmTopLevelFile(t *testing.T) {
+	fake := useFakeFiles(t)
+	fake.Respond("GetMetadata", fileMetadata("/notes.txt", 1), nil)
+	fake.Respond("Delete", fileMetadata("/notes.txt", 1), nil)
+
+	_, stderr, err := testutil.Capture(func() error {
+		return rm(rmCmd, []string{"/notes.txt"})
+	})
+	if err != nil {
+		t.Fatalf("rm = %v\n%s", err, stderr)
+	}
+	if want := []string{"GetMetadata", "Delete"}; !reflect.DeepEqual(fake.Methods(), want) {
+		t.Errorf("calls = %v, want %v", fake.Methods(), want)
+	}
+}
+
+func TestCheckRemovable(t *testing.T) {
+	if err := writeSettings(&settings{ProtectedPaths: []string{"/Work/Contracts"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer writeSettings(&settings{})
+
+	tests := []struct {
+		path   string
+		file   bool
+		force  bool
+		dryRun bool
+		stdin  string
+		// A substring of the error, if one is expected.
+		err string
+	}{
+		{path: "/", err: "refusing to remove the root folder"},
+		{path: "", err: "refusing to remove the root folder"},
+		{path: "//", err: "refusing to remove the root folder"},
+		{path: "/./", err: "refusing to remove the root folder"},
+		{path: `\`, err: "refusing to remove the root folder"},
+		{path: "/Photos/..", err: "refusing to remove the root folder"},
+		{path: "/Photos/../", force: true, err: "refusing to remove the root folder"},
+		{path: "ns:123", err: "refusing to remove the root folder"},
+		{path: "/Photos", err: "is a top-level folder; use `--force`"},
+		{path: "//Photos/", err: "is a top-level folder; use `--force`"},
+		{path: "/Photos/./2016/..", err: "is a top-level folder; use `--force`"},
+		{path: "/Photos", force: true, stdin: "/Photos\n"},
+		{path: "/Photos", force: true, stdin: "/photos\n", err: "confirmation didn't match"},
+		{path: "/Photos", force: true, stdin: "", err: "confirmation didn't match"},
+		{path: "/Photos", force: true, dryRun: true},
+		{path: "/notes.txt", file: true},
+		{path: "/notes.txt", err: "is a top-level folder; use `--force`"},
+		{path: "/Photos/2016", force: false},
+		{path: "/work/contracts", err: "protected by the `protected_paths` setting"},
+		{path: "/Work//Contracts/./acme.pdf", err: "protected by the `protected_paths` setting"},
+		{path: "/Work", force: true, stdin: "/Work\n", err: "protected by the `protected_paths` setting"},
+		{path: "/Work/Invoices"},
+	}
+	for _, tt := range tests {
+		useStdin(t, tt.stdin)
+		p, err := validatePath(tt.path)
+		var md files.IsMetadata = folderMetadata(p)
+		if tt.file {
+			md = fileMetadata(p, 1)
+		}
+		if err == nil {
+			_, _, err = testutil.Capture(func() error {
+				return checkRemovable(p, md, tt.force, tt.dryRun)
+			})
+		}
+		if tt.err == "" && err != nil {
+			t.Errorf("checkRemovable(%q, %t) = %v", tt.path, tt.force, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("checkRemovable(%q, %t) = %v, want error containing %q", tt.path, tt.force, err, tt.err)
+		}
+	}
+}
+
+// Answers piped in for several prompts each reach their own prompt.
+func TestConfirmPipedAnswers(t *testing.T) {
+	useStdin(t, "y\n/Photos\nn\n")
+	var answers []interface{}
+	_, _, err := testutil.Capture(func() error {
+		yes, err := confirmYesNo("Remove /a?")
+		if err != nil {
+			return err
+		}
+		answers = append(answers, yes, confirmByTyping("Really?", "/Photos"))
+		yes, err = confirmYesNo("Remove /b?")
+		answers = append(answers, yes)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{true, nil, false}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("answers = %v, want %v", answers, want)
+	}
+}
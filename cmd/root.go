@@ -15,19 +15,28 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
-	"github.com/mitchellh/go-homedir"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/users"
 	"github.com/spf13/cobra"
 )
 
@@ -43,48 +52,226 @@ const (
 )
 
 var (
-	personalAppKey      = "mvhz183vwqibe7q"
-	personalAppSecret   = "q0kquhzgetjwcz1"
-	teamAccessAppKey    = "zud1va492pnehkc"
-	teamAccessAppSecret = "p3ginm1gy0kmj54"
-	teamManageAppKey    = "xxe04eai4wmlitv"
-	teamManageAppSecret = "t8ms714yun7nu5s"
+	personalAppKey   = "mvhz183vwqibe7q"
+	teamAccessAppKey = "zud1va492pnehkc"
+	teamManageAppKey = "xxe04eai4wmlitv"
 )
 
-// Map of map of strings
+// Map of map of tokens
 // For each domain, we want to save different tokens depending on the
 // command type: personal, team access and team manage
-type TokenMap map[string]map[string]string
+type TokenMap map[string]map[string]*savedToken
 
 var config dropbox.Config
 
+// Commands run under cmdCtx. It's canceled on Ctrl-C or once `--timeout`
+// expires, which aborts any API request in flight.
+var cmdCtx = context.Background()
+
+func initContext(cmd *cobra.Command) {
+	var cancel context.CancelFunc
+	if timeout, _ := cmd.Flags().GetDuration("timeout"); timeout > 0 {
+		cmdCtx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		cmdCtx, cancel = context.WithCancel(context.Background())
+	}
+	cmdCtx = withRetryLogging(cmdCtx)
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		fmt.Fprintln(os.Stderr, "Interrupted, stopping. Press Ctrl-C again to exit immediately.")
+		logger.Warn("interrupted")
+		cancel()
+		<-interrupts
+		finishLogging(errors.New("interrupted twice, exiting immediately"))
+		os.Exit(130)
+	}()
+}
+
+// The SDK's clients don't take a context, so the transport binds each of their
+// requests to one instead.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	start := time.Now()
+	req = req.WithContext(t.ctx)
+	resp, err = sendWithRetry(t.ctx, req, throttled(t.ctx, func(req *http.Request) (*http.Response, error) {
+		if activeTracer != nil {
+			return activeTracer.roundTrip(t.base, req)
+		}
+		return t.base.RoundTrip(req)
+	}))
+	if err == nil {
+		tagRequestID(resp)
+	}
+
+	switch {
+	case err != nil:
+		logger.Warn("API request failed", "url", req.URL.String(), "error", err)
+	case resp.StatusCode >= 400:
+		logger.Warn("API error", "url", req.URL.String(), "status", resp.StatusCode,
+			"request_id", resp.Header.Get("X-Dropbox-Request-Id"))
+	default:
+		logger.Debug("API request", "url", req.URL.String(), "status", resp.StatusCode,
+			"duration", time.Since(start).Round(time.Millisecond))
+	}
+	return
+}
+
+func withContext(ctx context.Context, c *http.Client) *http.Client {
+	base := c.Transport
+	if activeTokens != nil {
+		// The SDK's transport would send the token the command started
+		// with, even once it has been refreshed.
+		base = tokenTransport{activeTokens, http.DefaultTransport}
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{Transport: contextTransport{ctx, base}}
+}
+
+// contextReader fails reads once its context is done, so that long copies
+// stop between reads.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Commands create their SDK clients through these variables rather than the
+// SDK constructors so that tests can substitute fakes (see package testutil).
+var (
+	newFilesClient = func(ctx context.Context) files.Client {
+		dbx := files.New(config)
+		dbx.Client = withContext(ctx, dbx.Client)
+		return dbx
+	}
+	newSharingClient = func(ctx context.Context) sharing.Client {
+		dbx := sharing.New(config)
+		dbx.Client = withContext(ctx, dbx.Client)
+		return dbx
+	}
+	newUsersClient = func(ctx context.Context) users.Client {
+		dbx := users.New(config)
+		dbx.Client = withContext(ctx, dbx.Client)
+		return dbx
+	}
+	newTeamClient = func(ctx context.Context) team.Client {
+		dbx := team.New(config)
+		dbx.Client = withContext(ctx, dbx.Client)
+		return dbx
+	}
+)
+
+// Apps authorize with PKCE, so they have no secret to ship.
 func oauthConfig(tokenType string, domain string) *oauth2.Config {
-	var appKey, appSecret string
+	var appKey string
 	switch tokenType {
 	case "personal":
-		appKey, appSecret = personalAppKey, personalAppSecret
+		appKey = personalAppKey
 	case "teamAccess":
-		appKey, appSecret = teamAccessAppKey, teamAccessAppSecret
+		appKey = teamAccessAppKey
 	case "teamManage":
-		appKey, appSecret = teamManageAppKey, teamManageAppSecret
+		appKey = teamManageAppKey
 	}
 	return &oauth2.Config{
-		ClientID:     appKey,
-		ClientSecret: appSecret,
-		Endpoint:     dropbox.OAuthEndpoint(domain),
+		ClientID: appKey,
+		Endpoint: oauthEndpoint(domain),
 	}
 }
 
-func validatePath(p string) (path string, err error) {
-	path = p
+// Prefixes of remote path forms which address content by something other
+// than its location, e.g. "id:a4ayc_80_OEAAAAAAAAAXw". Everything up to the
+// first slash is passed to the API untouched.
+var pathIDPrefixes = []string{"id:", "rev:", "ns:"}
+
+// Normalizes a remote path given on the command line: backslashes become
+// slashes, duplicate slashes and "." / ".." segments are collapsed, and a
+// leading slash is added. The root folder is returned as "", which is how the
+// API addresses it. Characters and names Dropbox doesn't allow are rejected
+// here with an error pointing at the culprit, instead of a generic
+// "malformed_path" from the API.
+func validatePath(p string) (string, error) {
+	for i, r := range p {
+		if r < 0x20 || r == 0x7f {
+			return "", fmt.Errorf("invalid path %q: control character %q at position %d", p, r, i+1)
+		}
+	}
 
-	if !strings.HasPrefix(path, "/") {
-		path = fmt.Sprintf("/%s", path)
+	var prefix string
+	rest := p
+	for _, idPrefix := range pathIDPrefixes {
+		if strings.HasPrefix(rest, idPrefix) {
+			prefix, rest = rest, ""
+			if i := strings.Index(prefix, "/"); i >= 0 {
+				prefix, rest = prefix[:i], prefix[i:]
+			}
+			break
+		}
 	}
 
-	path = strings.TrimSuffix(path, "/")
+	rest = strings.Replace(rest, "\\", "/", -1)
+	rest = path.Clean("/" + rest)
 
-	return
+	for _, name := range strings.Split(rest, "/") {
+		if strings.HasSuffix(name, " ") || strings.HasSuffix(name, ".") {
+			return "", fmt.Errorf("invalid path %q: name %q must not end with a space or a period", p, name)
+		}
+	}
+
+	if rest == "/" {
+		rest = ""
+	}
+
+	return prefix + rest, nil
+}
+
+// Reports whether `p` addresses content by id, revision or namespace rather
+// than by its location.
+func isIDPath(p string) bool {
+	for _, idPrefix := range pathIDPrefixes {
+		if strings.HasPrefix(p, idPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the display path for a normalized remote path. Paths given by
+// location are returned unchanged; the others are looked up, so that callers
+// can name local files after them or check them against protected paths.
+func resolvePath(dbx files.Client, p string) (string, error) {
+	if !isIDPath(p) {
+		return p, nil
+	}
+	md, err := getFileMetadata(dbx, p)
+	if err != nil {
+		return "", err
+	}
+	var pathDisplay string
+	switch m := md.(type) {
+	case *files.FileMetadata:
+		pathDisplay = m.PathDisplay
+	case *files.FolderMetadata:
+		pathDisplay = m.PathDisplay
+	}
+	if pathDisplay == "" {
+		// Content outside the user's own Dropbox has no path.
+		return p, nil
+	}
+	return pathDisplay, nil
 }
 
 func makeRelocationArg(s string, d string) (arg *files.RelocationArg, err error) {
@@ -109,9 +296,10 @@ func readTokens(filePath string) (TokenMap, error) {
 	}
 
 	var tokens TokenMap
-	if json.Unmarshal(b, &tokens) != nil {
+	if err = json.Unmarshal(b, &tokens); err != nil {
 		return nil, err
 	}
+	loadKeyringTokens(filePath, tokens)
 
 	return tokens, nil
 }
@@ -127,18 +315,21 @@ func writeTokens(filePath string, tokens TokenMap) {
 	}
 
 	// At this point, file must exist. Lets (over)write it.
-	b, err := json.Marshal(tokens)
+	b, err := json.Marshal(storeKeyringTokens(filePath, tokens))
 	if err != nil {
 		return
 	}
-	if err = ioutil.WriteFile(filePath, b, 0600); err != nil {
+	if err = writeFileAtomic(filePath, b); err != nil {
 		return
 	}
 }
 
 func tokenType(cmd *cobra.Command) string {
-	if cmd.Parent().Name() == "team" {
-		return tokenTeamManage
+	// Team commands, however deeply nested, need a team token.
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		if c.Name() == "team" && !c.Parent().HasParent() {
+			return tokenTeamManage
+		}
 	}
 	if asMember, _ := cmd.Flags().GetString("as-member"); asMember != "" {
 		return tokenTeamAccess
@@ -147,47 +338,48 @@ func tokenType(cmd *cobra.Command) string {
 }
 
 func initDbx(cmd *cobra.Command, args []string) (err error) {
+	if err = applyFlagDefaults(cmd); err != nil {
+		return
+	}
+	if err = initLogging(cmd, args); err != nil {
+		return
+	}
+
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	asMember, _ := cmd.Flags().GetString("as-member")
 	domain, _ := cmd.Flags().GetString("domain")
 
-	dir, err := homedir.Dir()
+	if activeTokens, err = initTokens(cmd, domain); err != nil {
+		return
+	}
+	token, err := activeTokens.accessToken()
 	if err != nil {
 		return
 	}
-	filePath := path.Join(dir, ".config", "dbxcli", configFileName)
-	tokType := tokenType(cmd)
-	conf := oauthConfig(tokType, domain)
 
-	tokenMap, err := readTokens(filePath)
-	if tokenMap == nil {
-		tokenMap = make(TokenMap)
+	config = dropbox.Config{
+		Token:   token,
+		Verbose: verbose,
+		Domain:  domain,
 	}
-	if tokenMap[domain] == nil {
-		tokenMap[domain] = make(map[string]string)
+	initContext(cmd)
+	if err = initRetries(cmd); err != nil {
+		return
 	}
-	tokens := tokenMap[domain]
-
-	if err != nil || tokens[tokType] == "" {
-		fmt.Printf("1. Go to %v\n", conf.AuthCodeURL("state"))
-		fmt.Printf("2. Click \"Allow\" (you might have to log in first).\n")
-		fmt.Printf("3. Copy the authorization code.\n")
-		fmt.Printf("Enter the authorization code here: ")
-
-		var code string
-		if _, err = fmt.Scan(&code); err != nil {
-			return
-		}
-		var token *oauth2.Token
-		token, err = conf.Exchange(oauth2.NoContext, code)
-		if err != nil {
+	if err = initBandwidthLimit(cmd); err != nil {
+		return
+	}
+	if err = initProgress(cmd); err != nil {
+		return
+	}
+	if trace, _ := cmd.Flags().GetString("record-trace"); trace != "" {
+		if err = startTrace(trace, config.Token); err != nil {
 			return
 		}
-		tokens[tokType] = token.AccessToken
-		writeTokens(filePath, tokenMap)
 	}
-
-	config = dropbox.Config{tokens[tokType], verbose, asMember, domain}
+	if asMember != "" {
+		config.AsMemberID, err = resolveMemberID(asMember)
+	}
 
 	return
 }
@@ -199,20 +391,67 @@ var RootCmd = &cobra.Command{
 	Long: `Use dbxcli to quickly interact with your Dropbox, upload/download files,
 manage your team and more. It is easy, scriptable and works on all platforms!`,
 	SilenceUsage:      true,
+	SilenceErrors:     true,
 	PersistentPreRunE: initDbx,
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	defer func() {
+		// Make sure the log says how the command died.
+		if r := recover(); r != nil {
+			logger.Error("panic", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			finishLogging(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
+	cmd, err := RootCmd.ExecuteC()
+	finishLogging(err)
+	if status, ok := err.(exitStatus); ok {
+		os.Exit(int(status))
+	}
+	if err != nil {
+		var hint string
+		timedOut := cmdCtx.Err() == context.DeadlineExceeded
+		if timedOut {
+			hint = "Timed out; see `--timeout`"
+		}
+		// Commands printing JSON report errors as JSON too, so that
+		// programs reading their output can parse both streams, and exit
+		// with a status that says what kind of error it was.
+		if cmd != nil && jsonMode(cmd) {
+			writeJSONError(os.Stderr, err, hint)
+			os.Exit(jsonExitStatus(err, timedOut))
+		}
+		fmt.Fprintf(os.Stderr, "Error: %s\n", explainError(err))
+		if hint != "" {
+			fmt.Fprintln(os.Stderr, hint)
+		}
 		os.Exit(-1)
 	}
 }
 
 func init() {
 	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose logging")
-	RootCmd.PersistentFlags().String("as-member", "", "Member ID to perform action as")
+	RootCmd.PersistentFlags().String("as-member", "", "Act as this team member, given by member id or email address (needs a team token)")
+	RootCmd.PersistentFlags().Duration("timeout", 0, "Give up after this long, e.g. 30s or 5m (0 means no limit)")
+	RootCmd.PersistentFlags().Int("retries", 3, "Times to retry API requests that fail with a 429 or 5xx, or read-only ones that fail with a network error")
+	RootCmd.PersistentFlags().Duration("retry-delay", time.Second, "Wait before the first retry, doubled for each one after it")
+	RootCmd.PersistentFlags().String("bwlimit", "", "Limit uploads and downloads to this many bytes per second in total, e.g. 2M")
+	RootCmd.PersistentFlags().String("progress", progressAuto, "How to show transfer progress: none, plain, auto or fancy")
+	RootCmd.PersistentFlags().Duration("progress-interval", 10*time.Second, "Time between lines of plain progress output")
+	RootCmd.PersistentFlags().Bool("json", false, "Print results as JSON, for scripts")
+	RootCmd.PersistentFlags().String("token", "", "Access token to use instead of a saved one (or set "+tokenEnvVar+", which --profile overrides)")
+	RootCmd.PersistentFlags().String("profile", "", "Account profile to use, as added by \"dbxcli profile add\"")
+	RootCmd.PersistentFlags().Bool("no-config", false, "Ignore default flag values from the config file")
+	RootCmd.PersistentFlags().String("log-file", "", "Log what commands do to `file`, in addition to their usual output")
+	RootCmd.PersistentFlags().String("log-level", "info", "Least severe messages written to --log-file: debug, info, warn or error")
+	RootCmd.PersistentFlags().String("log-format", "text", "Format of --log-file: text (key=value) or json")
+	RootCmd.PersistentFlags().Int64("log-max-size", 10, "Rotate --log-file once it reaches this many MiB")
+	RootCmd.PersistentFlags().Int("log-keep", 5, "Number of rotated log files to keep")
+	RootCmd.PersistentFlags().String("record-trace", "", "Record every API request and response to `file`, without credentials or file contents")
 	// This flag should only be used for testing. Marked hidden so it doesn't clutter usage etc.
 	RootCmd.PersistentFlags().String("domain", "", "Override default Dropbox domain, useful for testing")
 	RootCmd.PersistentFlags().MarkHidden("domain")
@@ -0,0 +1,110 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		// A substring of the error, if one is expected.
+		err string
+	}{
+		{in: "", want: ""},
+		{in: "/", want: ""},
+		{in: "//", want: ""},
+		{in: "/./", want: ""},
+		{in: "foo", want: "/foo"},
+		{in: "/foo/", want: "/foo"},
+		{in: "//foo//bar", want: "/foo/bar"},
+		{in: "/foo/./bar/../baz", want: "/foo/baz"},
+		{in: "/../../foo", want: "/foo"},
+		{in: `\foo\bar`, want: "/foo/bar"},
+		{in: `foo\\bar\`, want: "/foo/bar"},
+		{in: "/Ünïcödé/ファイル", want: "/Ünïcödé/ファイル"},
+		{in: "/foo\tbar", err: `control character '\t' at position 5`},
+		{in: "/foo\x7f", err: `control character '\x7f' at position 5`},
+		{in: "/foo /bar", err: `name "foo " must not end with a space`},
+		{in: "/foo/bar.", err: `name "bar." must not end with a space or a period`},
+		{in: "/foo/.bar", want: "/foo/.bar"},
+		{in: "id:a4ayc_80_OEAAAAAAAAAXw", want: "id:a4ayc_80_OEAAAAAAAAAXw"},
+		{in: "id:a4ayc_80_OEAAAAAAAAAXw/", want: "id:a4ayc_80_OEAAAAAAAAAXw"},
+		{in: "id:a4ayc_80_OEAAAAAAAAAXw//2016/./report.pdf", want: "id:a4ayc_80_OEAAAAAAAAAXw/2016/report.pdf"},
+		{in: `id:a4ayc_80_OEAAAAAAAAAXw\2016`, want: `id:a4ayc_80_OEAAAAAAAAAXw\2016`},
+		{in: "id:a4ayc_80_OEAAAAAAAAAXw/../..", want: "id:a4ayc_80_OEAAAAAAAAAXw"},
+		{in: "rev:a1c10ce0dd78", want: "rev:a1c10ce0dd78"},
+		{in: "ns:123456/Photos/", want: "ns:123456/Photos"},
+		{in: "/id:abc", want: "/id:abc"},
+		{in: "Id:abc", want: "/Id:abc"},
+	}
+	for _, tt := range tests {
+		got, err := validatePath(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("validatePath(%q) = %q, %v, want error containing %q", tt.in, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("validatePath(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		in     string
+		script func(*testutil.FakeFiles)
+		want   string
+	}{
+		{in: "/Reports/2016.pdf", want: "/Reports/2016.pdf"},
+		{
+			in: "id:a4ayc_80_OEAAAAAAAAAXw",
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", fileMetadata("/Reports/2016.pdf", 10), nil)
+			},
+			want: "/Reports/2016.pdf",
+		},
+		{
+			in: "ns:123456",
+			script: func(f *testutil.FakeFiles) {
+				f.Respond("GetMetadata", folderMetadata(""), nil)
+			},
+			want: "ns:123456",
+		},
+	}
+	for _, tt := range tests {
+		fake := testutil.NewFakeFiles()
+		if tt.script != nil {
+			tt.script(fake)
+		}
+		got, err := resolvePath(fake, tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("resolvePath(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+		if calls := fake.Calls(); len(calls) > 0 {
+			if arg := calls[0].Arg.(*files.GetMetadataArg); arg.Path != tt.in {
+				t.Errorf("resolvePath(%q) looked up %q", tt.in, arg.Path)
+			}
+		}
+	}
+}
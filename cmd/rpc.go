@@ -0,0 +1,262 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+)
+
+// rpcError is returned by `rpc` for endpoint-specific (HTTP 409) errors.
+type rpcError struct {
+	dropbox.APIError
+	EndpointError dropbox.Tagged `json:"error"`
+}
+
+// rateLimitError is returned by `rpc` when Dropbox is rate limiting us. It
+// implements retry.RetryAfterer with the delay Dropbox asks for.
+type rateLimitError struct {
+	dropbox.APIError
+	Details struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"error"`
+}
+
+func (e rateLimitError) RetryAfter() time.Duration {
+	return time.Duration(e.Details.RetryAfter) * time.Second
+}
+
+// Sends an RPC-style request for a route or argument the vendored SDK doesn't
+// know about yet, and decodes the response into `res` if it's non-nil. The
+// request is built the same way the SDK builds its own so that `--verbose`,
+// `--as-member` and `--domain` behave identically.
+func rpc(ctx context.Context, namespace string, route string, arg interface{}, res interface{}) (err error) {
+	return rpcWithHeader(ctx, namespace, route, arg, res, nil)
+}
+
+// Like rpc, but adds `header` to the request, for instance
+// Dropbox-API-Select-Admin to act as the admin behind a team token.
+func rpcWithHeader(ctx context.Context, namespace string, route string, arg interface{}, res interface{}, header http.Header) (err error) {
+	dbx := dropbox.NewContext(config)
+
+	if config.Verbose {
+		log.Printf("arg: %v", arg)
+	}
+	b, err := json.Marshal(arg)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("POST", dbx.GenerateURL("api", namespace, route), bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if config.AsMemberID != "" {
+		req.Header.Set("Dropbox-API-Select-User", config.AsMemberID)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if config.Verbose {
+		log.Printf("req: %v", req)
+	}
+	resp, err := withContext(ctx, dbx.Client).Do(req)
+	if config.Verbose {
+		log.Printf("resp: %v", resp)
+	}
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if config.Verbose {
+		log.Printf("body: %s", body)
+	}
+	return decodeResponse(resp.StatusCode, body, res)
+}
+
+// Decodes the body of a response to an RPC or upload request into `res`, or
+// into the error it describes.
+func decodeResponse(status int, body []byte, res interface{}) (err error) {
+	switch status {
+	case http.StatusOK:
+		if res != nil {
+			err = json.Unmarshal(body, res)
+		}
+		return
+	case http.StatusConflict:
+		var apiError rpcError
+		if err = json.Unmarshal(body, &apiError); err != nil {
+			return
+		}
+		err = apiError
+		return
+	case http.StatusBadRequest:
+		err = dropbox.APIError{ErrorSummary: string(body)}
+		return
+	case http.StatusTooManyRequests:
+		var apiError rateLimitError
+		if err = json.Unmarshal(body, &apiError); err != nil {
+			return
+		}
+		err = apiError
+		return
+	}
+	var apiError dropbox.APIError
+	if err = json.Unmarshal(body, &apiError); err != nil {
+		return
+	}
+	err = apiError
+	return
+}
+
+// Sends a content-upload request with `content` as the body, for arguments
+// the vendored SDK can't express, and decodes the response into `res` if
+// it's non-nil.
+func contentUpload(ctx context.Context, namespace string, route string, arg interface{}, content []byte, res interface{}) (err error) {
+	dbx := dropbox.NewContext(config)
+
+	a, err := headerArg(arg)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", dbx.GenerateURL("content", namespace, route), bytes.NewReader(content))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Dropbox-API-Arg", a)
+	if config.AsMemberID != "" {
+		req.Header.Set("Dropbox-API-Select-User", config.AsMemberID)
+	}
+	if config.Verbose {
+		log.Printf("req: %v", req)
+	}
+	resp, err := withContext(ctx, dbx.Client).Do(req)
+	if config.Verbose {
+		log.Printf("resp: %v", resp)
+	}
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if config.Verbose {
+		log.Printf("body: %s", body)
+	}
+	return decodeResponse(resp.StatusCode, body, res)
+}
+
+// Header values must be ASCII, so non-ASCII characters in a Dropbox-API-Arg
+// are sent as JSON \u escapes.
+func headerArg(arg interface{}) (string, error) {
+	b, err := json.Marshal(arg)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, r := range string(b) {
+		if r < 0x80 {
+			buf.WriteRune(r)
+			continue
+		}
+		for _, c := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&buf, `\u%04x`, c)
+		}
+	}
+	return buf.String(), nil
+}
+
+// Sends a content-download request such as files/download, passing along
+// `header` (for instance a Range). Unlike the SDK it returns the raw response,
+// which the caller must close, so partial content can be streamed as is.
+func contentDownload(ctx context.Context, namespace string, route string, arg interface{}, header http.Header) (resp *http.Response, err error) {
+	dbx := dropbox.NewContext(config)
+
+	a, err := headerArg(arg)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", dbx.GenerateURL("content", namespace, route), nil)
+	if err != nil {
+		return
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Dropbox-API-Arg", a)
+	if config.AsMemberID != "" {
+		req.Header.Set("Dropbox-API-Select-User", config.AsMemberID)
+	}
+	if config.Verbose {
+		log.Printf("req: %v", req)
+	}
+	resp, err = withContext(ctx, dbx.Client).Do(req)
+	if config.Verbose {
+		log.Printf("resp: %v", resp)
+	}
+	if err != nil {
+		return
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if config.Verbose {
+		log.Printf("body: %s", body)
+	}
+	switch resp.StatusCode {
+	case http.StatusConflict:
+		var apiError rpcError
+		if err = json.Unmarshal(body, &apiError); err == nil {
+			err = apiError
+		}
+	case http.StatusBadRequest:
+		err = dropbox.APIError{ErrorSummary: string(body)}
+	default:
+		var apiError dropbox.APIError
+		if err = json.Unmarshal(body, &apiError); err == nil {
+			err = apiError
+		}
+	}
+	return nil, err
+}
@@ -15,51 +15,223 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
+// The most matches search_v2 returns in one page.
+const maxSearchPage = 1000
+
+type searchV2Options struct {
+	Path           string   `json:"path,omitempty"`
+	MaxResults     uint64   `json:"max_results,omitempty"`
+	FileExtensions []string `json:"file_extensions,omitempty"`
+}
+
+type searchV2MatchFieldOptions struct {
+	IncludeHighlights bool `json:"include_highlights"`
+}
+
+type searchV2Arg struct {
+	Query             string                     `json:"query"`
+	Options           *searchV2Options           `json:"options,omitempty"`
+	MatchFieldOptions *searchV2MatchFieldOptions `json:"match_field_options,omitempty"`
+}
+
+type searchV2ContinueArg struct {
+	Cursor string `json:"cursor"`
+}
+
+type highlightSpan struct {
+	HighlightStr  string `json:"highlight_str"`
+	IsHighlighted bool   `json:"is_highlighted"`
+}
+
+type searchV2Match struct {
+	Metadata struct {
+		Metadata json.RawMessage `json:"metadata"`
+	} `json:"metadata"`
+	MatchType      *dropbox.Tagged `json:"match_type,omitempty"`
+	HighlightSpans []highlightSpan `json:"highlight_spans,omitempty"`
+}
+
+type searchV2Result struct {
+	Matches []searchV2Match `json:"matches"`
+	HasMore bool            `json:"has_more"`
+	Cursor  string          `json:"cursor"`
+}
+
+// One line of `search --json` output. Metadata is passed through exactly as
+// the API returned it.
+type searchJSONMatch struct {
+	Metadata       json.RawMessage `json:"metadata"`
+	MatchType      string          `json:"match_type,omitempty"`
+	HighlightSpans []highlightSpan `json:"highlight_spans,omitempty"`
+}
+
+// Runs the query with search_v2, which the vendored SDK doesn't support yet,
+// calling `visit` for each match as pages arrive until `max` matches have
+// been seen, or all of them when `max` is 0.
+func searchV2(ctx context.Context, arg *searchV2Arg, max int, visit func(searchV2Match) error) (err error) {
+	if arg.Options == nil {
+		arg.Options = &searchV2Options{}
+	}
+	arg.Options.MaxResults = maxSearchPage
+	if max > 0 && max < maxSearchPage {
+		arg.Options.MaxResults = uint64(max)
+	}
+
+	var res searchV2Result
+	if err = rpc(ctx, "files", "search_v2", arg, &res); err != nil {
+		return
+	}
+	for seen := 0; ; {
+		for _, m := range res.Matches {
+			if max > 0 && seen == max {
+				return
+			}
+			if err = visit(m); err != nil {
+				return
+			}
+			seen++
+		}
+
+		if !res.HasMore || (max > 0 && seen == max) {
+			return
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		cursor := res.Cursor
+		res = searchV2Result{}
+		if err = rpc(ctx, "files", "search/continue_v2", &searchV2ContinueArg{cursor}, &res); err != nil {
+			return
+		}
+	}
+}
+
+// Writes each match as a JSON object on its own line.
+func searchJSON(ctx context.Context, arg *searchV2Arg, max int) (err error) {
+	enc := json.NewEncoder(os.Stdout)
+	return searchV2(ctx, arg, max, func(m searchV2Match) error {
+		out := searchJSONMatch{
+			Metadata:       m.Metadata.Metadata,
+			HighlightSpans: m.HighlightSpans,
+		}
+		if m.MatchType != nil {
+			out.MatchType = m.MatchType.Tag
+		}
+		return enc.Encode(out)
+	})
+}
+
+// Joins highlight spans into one line of context, with the matching parts in
+// bold when `bold` is set.
+func searchSnippet(spans []highlightSpan, bold bool) string {
+	var b strings.Builder
+	for _, s := range spans {
+		if s.IsHighlighted && bold {
+			b.WriteString("\x1b[1m" + s.HighlightStr + "\x1b[0m")
+			continue
+		}
+		b.WriteString(s.HighlightStr)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
 func search(cmd *cobra.Command, args []string) (err error) {
-	if len(args) == 0 {
-		return errors.New("`search` requires a `query` argument")
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("`search` requires a `query` argument and an optional `path-scope` argument")
 	}
 
 	// Parse path scope, if provided.
-	var scope string
+	scope, _ := cmd.Flags().GetString("path")
 	if len(args) == 2 {
+		if scope != "" {
+			return errors.New("give the path scope either as an argument or with `--path`, not both")
+		}
 		scope = args[1]
-		if !strings.HasPrefix(scope, "/") {
-			return errors.New("`search` `path-scope` must begin with \"/\"")
+	}
+	if scope != "" {
+		if scope, err = validatePath(scope); err != nil {
+			return
 		}
 	}
 
-	arg := files.NewSearchArg(scope, args[0])
+	max, _ := cmd.Flags().GetInt("max")
+	if max < 0 {
+		return fmt.Errorf("`--max` must be 0 or more, not %d", max)
+	}
+	exts, _ := cmd.Flags().GetStringSlice("ext")
+	for i, ext := range exts {
+		exts[i] = strings.TrimPrefix(ext, ".")
+	}
 
-	dbx := files.New(config)
-	res, err := dbx.Search(arg)
-	if err != nil {
-		return
+	arg := &searchV2Arg{Query: args[0], Options: &searchV2Options{Path: scope, FileExtensions: exts}}
+	if jsonMode(cmd) {
+		if highlights, _ := cmd.Flags().GetBool("highlights"); highlights {
+			arg.MatchFieldOptions = &searchV2MatchFieldOptions{IncludeHighlights: true}
+		}
+		return searchJSON(cmdCtx, arg, max)
 	}
+	arg.MatchFieldOptions = &searchV2MatchFieldOptions{IncludeHighlights: true}
 
 	long, _ := cmd.Flags().GetBool("long")
+	bold := terminal.IsTerminal(int(os.Stdout.Fd()))
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
 	if long {
-		fmt.Printf("Revision\tSize\tLast modified\tPath\n")
+		fmt.Fprintf(w, "Revision\tSize\tLast modified\tPath\n")
 	}
 
-	for _, m := range res.Matches {
-		switch f := m.Metadata.(type) {
+	err = searchV2(cmdCtx, arg, max, func(m searchV2Match) error {
+		md, _, err := decodeMetadata(m.Metadata.Metadata)
+		if err != nil {
+			return err
+		}
+		var name string
+		switch f := md.(type) {
 		case *files.FileMetadata:
-			printFileMetadata(os.Stdout, f, long)
+			name = f.Name
+			if long {
+				fmt.Fprintf(w, "%s\t%s\t%s\t", f.Rev, humanize.IBytes(f.Size), humanize.Time(f.ServerModified))
+			}
+			fmt.Fprintf(w, "%s\n", f.PathDisplay)
 		case *files.FolderMetadata:
-			printFolderMetadata(os.Stdout, f, long)
+			name = f.Name
+			if long {
+				fmt.Fprintf(w, "-\t-\t-\t")
+			}
+			fmt.Fprintf(w, "%s\n", f.PathDisplay)
+		default:
+			return nil
 		}
-	}
 
+		// Matches on the name alone just repeat it, so only show context
+		// from the file's contents. With -l the snippet goes in the Path
+		// column, so it doesn't break up the columns of the rows around it.
+		if snippet := searchSnippet(m.HighlightSpans, bold); snippet != "" && snippet != name && m.MatchType != nil && m.MatchType.Tag != "filename" {
+			if long {
+				fmt.Fprintf(w, "\t\t\t")
+			}
+			fmt.Fprintf(w, "    %s\n", snippet)
+		}
+		return nil
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
 	return
 }
 
@@ -67,10 +239,24 @@ func search(cmd *cobra.Command, args []string) (err error) {
 var searchCmd = &cobra.Command{
 	Use:   "search [flags] <query> [path-scope]",
 	Short: "Search",
-	RunE:  search,
+	Long: `Search file and folder names, and the contents of files, for <query>, and
+print the paths that match. When a file matched on its contents, a line of
+context follows its path with the matching words in bold.
+
+Results come in order of relevance. --max limits how many are printed
+(0 for all of them), --path (or a <path-scope> argument) limits the search
+to one folder, and --ext to files with the given extensions.`,
+	Example: `  dbxcli search "quarterly report"
+  dbxcli search "quarterly report" --path /Finance --ext pdf --max 100
+  dbxcli search invoice --ext pdf,docx --json --highlights`,
+	RunE: search,
 }
 
 func init() {
 	RootCmd.AddCommand(searchCmd)
 	searchCmd.Flags().BoolP("long", "l", false, "Long listing")
+	searchCmd.Flags().Bool("highlights", false, "Include highlight spans in --json output")
+	searchCmd.Flags().String("path", "", "Only search within this folder")
+	searchCmd.Flags().StringSlice("ext", nil, "Only match files with these extensions, e.g. pdf (repeatable)")
+	searchCmd.Flags().Int("max", 100, "Print at most this many matches (0 for no limit)")
 }
@@ -0,0 +1,58 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestSearchLongSnippets(t *testing.T) {
+	api := useFakeAPI(t)
+	api.Respond("files/search_v2", http.StatusOK, `{"matches": [
+		{"metadata": {".tag": "metadata", "metadata": {".tag": "file", "name": "notes.txt", "id": "id:1", "path_lower": "/docs/notes.txt", "path_display": "/docs/notes.txt", "rev": "015a", "size": 2048, "client_modified": "2020-01-01T00:00:00Z", "server_modified": "2020-01-01T00:00:00Z"}},
+		 "match_type": {".tag": "filename_and_content"},
+		 "highlight_spans": [{"highlight_str": "the quick", "is_highlighted": false}, {"highlight_str": " fox", "is_highlighted": true}]},
+		{"metadata": {".tag": "metadata", "metadata": {".tag": "folder", "name": "fox", "id": "id:2", "path_lower": "/fox", "path_display": "/fox"}},
+		 "match_type": {".tag": "filename"},
+		 "highlight_spans": [{"highlight_str": "fox", "is_highlighted": true}]}
+	], "has_more": false, "cursor": ""}`)
+	setFlags(t, searchCmd, map[string]string{"long": "true"})
+
+	stdout, _, err := testutil.Capture(func() error {
+		return search(searchCmd, []string{"fox"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), stdout)
+	}
+	col := strings.Index(lines[0], "Path")
+	for i, want := range []string{"/docs/notes.txt", "    the quick fox", "/fox"} {
+		line := lines[i+1]
+		if len(line) < col || line[col:] != want {
+			t.Errorf("line %d = %q, want %q in the Path column at %d", i+1, line, want, col)
+		}
+	}
+	if strings.TrimSpace(lines[2][:col]) != "" {
+		t.Errorf("snippet line %q has text before the Path column", lines[2])
+	}
+}
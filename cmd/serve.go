@@ -0,0 +1,391 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// A cachedEntry is the metadata of a path and, for folders, their contents.
+type cachedEntry struct {
+	meta    files.IsMetadata
+	entries []files.IsMetadata
+	expires time.Time
+}
+
+// listingCache keeps lookups for a short while so that a browser reloading a
+// page, or a player seeking through a video, doesn't cost an API call each
+// time.
+type listingCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	paths map[string]cachedEntry
+}
+
+func (c *listingCache) get(p string) (e cachedEntry, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok = c.paths[strings.ToLower(p)]
+	if ok && time.Now().After(e.expires) {
+		delete(c.paths, strings.ToLower(p))
+		ok = false
+	}
+	return
+}
+
+func (c *listingCache) put(p string, e cachedEntry) {
+	if c.ttl <= 0 {
+		return
+	}
+	e.expires = time.Now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths[strings.ToLower(p)] = e
+}
+
+// folderServer serves the folder `root` read-only over HTTP.
+type folderServer struct {
+	root  string
+	cache *listingCache
+	// Holds a token for each request being served.
+	slots chan struct{}
+	// Basic auth credentials; empty if none are required.
+	user, password string
+}
+
+func parseBasicAuth(s string) (user, password string, err error) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return "", "", errors.New("`--auth` must be of the form user:password")
+	}
+	return s[:i], s[i+1:], nil
+}
+
+func (s *folderServer) authorized(r *http.Request) bool {
+	if s.user == "" {
+		return true
+	}
+	user, password, ok := r.BasicAuth()
+	// Compare both halves so that timing doesn't reveal which was wrong.
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.user))
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.password))
+	return ok && userOK&passwordOK == 1
+}
+
+// Maps a request path to the Dropbox path beneath the served folder. Cleaning
+// the path first keeps requests from escaping it with "..".
+func (s *folderServer) remotePath(urlPath string) string {
+	p := path.Clean("/" + urlPath)
+	if p == "/" {
+		return s.root
+	}
+	return s.root + p
+}
+
+func (s *folderServer) lookup(ctx context.Context, remote string) (e cachedEntry, err error) {
+	if e, ok := s.cache.get(remote); ok {
+		return e, nil
+	}
+
+	dbx := newFilesClient(ctx)
+	if remote == "" {
+		// The root has no metadata of its own.
+		e.meta = &files.FolderMetadata{}
+	} else if e.meta, err = getFileMetadata(dbx, remote); err != nil {
+		return
+	}
+	if _, ok := e.meta.(*files.FolderMetadata); ok {
+		if e.entries, err = listFolder(ctx, dbx, remote); err != nil {
+			return
+		}
+	}
+	s.cache.put(remote, e)
+	return
+}
+
+func (s *folderServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger.Info("serve request", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path)
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="dbxcli"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	remote := s.remotePath(r.URL.Path)
+	e, err := s.lookup(r.Context(), remote)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	switch m := e.meta.(type) {
+	case *files.FolderMetadata:
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Build the target from the cleaned path so that a request
+			// for //example.com can't redirect off the server.
+			target := "/" + strings.TrimLeft(path.Clean(r.URL.Path), "/") + "/"
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		s.serveIndex(w, r, e.entries)
+	case *files.FileMetadata:
+		s.serveFile(w, r, m)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *folderServer) fail(w http.ResponseWriter, err error) {
+	if isMissing(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	logger.Error("serve failed", "error", err, "request_id", requestIDOf(err))
+	http.Error(w, "upstream error", http.StatusBadGateway)
+}
+
+type indexEntry struct {
+	Name     string
+	Href     string
+	Size     string
+	Modified string
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th align="left">Name</th><th align="right">Size</th><th align="left">Modified</th></tr>
+{{if .Parent}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td align="right">{{.Size}}</td><td>{{.Modified}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (s *folderServer) serveIndex(w http.ResponseWriter, r *http.Request, entries []files.IsMetadata) {
+	var folders, others []indexEntry
+	for _, entry := range entries {
+		switch m := entry.(type) {
+		case *files.FolderMetadata:
+			folders = append(folders, indexEntry{
+				Name: m.Name + "/",
+				Href: url.PathEscape(m.Name) + "/",
+			})
+		case *files.FileMetadata:
+			others = append(others, indexEntry{
+				Name:     m.Name,
+				Href:     url.PathEscape(m.Name),
+				Size:     humanize.IBytes(m.Size),
+				Modified: m.ServerModified.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	byName := func(l []indexEntry) {
+		sort.Slice(l, func(i, j int) bool { return strings.ToLower(l[i].Name) < strings.ToLower(l[j].Name) })
+	}
+	byName(folders)
+	byName(others)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == "HEAD" {
+		return
+	}
+	indexTemplate.Execute(w, struct {
+		Title   string
+		Parent  bool
+		Entries []indexEntry
+	}{path.Clean("/" + s.remotePath(r.URL.Path)), path.Clean(r.URL.Path) != "/", append(folders, others...)})
+}
+
+// Streams a file from the download endpoint. Range requests are passed on to
+// Dropbox, which answers them itself, so seeking in a video only fetches the
+// part that's played.
+func (s *folderServer) serveFile(w http.ResponseWriter, r *http.Request, m *files.FileMetadata) {
+	h := w.Header()
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("ETag", strconv.Quote(m.Rev))
+	h.Set("Last-Modified", m.ServerModified.UTC().Format(http.TimeFormat))
+	contentType := mime.TypeByExtension(path.Ext(m.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	// Files are shown as they are, but mustn't run scripts on this origin.
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "sandbox")
+
+	if r.Method == "HEAD" {
+		h.Set("Content-Length", strconv.FormatUint(m.Size, 10))
+		return
+	}
+
+	header := make(http.Header)
+	if rg := r.Header.Get("Range"); rg != "" {
+		header.Set("Range", rg)
+	}
+	resp, err := contentDownload(r.Context(), "files", "download", files.NewDownloadArg("rev:"+m.Rev), header)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, k := range []string{"Content-Length", "Content-Range"} {
+		if v := resp.Header.Get(k); v != "" {
+			h.Set(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// How long a client of `serve` gets to send the headers of a request.
+const serveHeaderTimeout = 10 * time.Second
+
+func serve(cmd *cobra.Command, args []string) (err error) {
+	if len(args) > 1 {
+		return errors.New("`serve` accepts at most one `path` argument")
+	}
+	root := ""
+	if len(args) == 1 {
+		if root, err = validatePath(args[0]); err != nil {
+			return
+		}
+	}
+
+	addr, _ := cmd.Flags().GetString("addr")
+	ttl, _ := cmd.Flags().GetDuration("cache-ttl")
+	maxConcurrent, _ := cmd.Flags().GetInt("max-concurrent")
+	if maxConcurrent < 1 {
+		return errors.New("`--max-concurrent` must be at least 1")
+	}
+
+	s := &folderServer{
+		root:  root,
+		cache: &listingCache{ttl: ttl, paths: make(map[string]cachedEntry)},
+		slots: make(chan struct{}, maxConcurrent),
+	}
+	if auth, _ := cmd.Flags().GetString("auth"); auth != "" {
+		if s.user, s.password, err = parseBasicAuth(auth); err != nil {
+			return
+		}
+	}
+
+	// Fail early if the folder doesn't exist, rather than on the first
+	// request.
+	if root != "" {
+		var e cachedEntry
+		if e, err = s.lookup(cmdCtx, root); err != nil {
+			return
+		}
+		if _, ok := e.meta.(*files.FolderMetadata); !ok {
+			return fmt.Errorf("%s is not a folder", root)
+		}
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+	// Clients that trickle in their headers would otherwise hold on to a
+	// connection for as long as they like.
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: serveHeaderTimeout}
+	fmt.Fprintf(os.Stderr, "Serving %s at http://%s/ (press Ctrl-C to stop)\n", path.Clean("/"+root), l.Addr())
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+
+	select {
+	case err = <-done:
+		return
+	case <-cmdCtx.Done():
+	}
+
+	// Give requests in flight a moment to finish.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = srv.Shutdown(ctx); err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Stopped")
+	if cmdCtx.Err() == context.DeadlineExceeded {
+		return cmdCtx.Err()
+	}
+	return nil
+}
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve [flags] [<path>]",
+	Short: "Serve a folder over HTTP, read-only",
+	Long: `Serve a Dropbox folder over HTTP so that a browser or another tool can
+browse and download it without Dropbox credentials.
+
+Folders are shown as an index page and files are streamed from Dropbox as
+they're requested. Range requests are supported, so media players can seek.
+Nothing can be changed through the server. Folder listings are cached for
+--cache-ttl, and at most --max-concurrent requests are handled at once; the
+rest wait their turn.
+
+The server listens on 127.0.0.1 by default. Before listening on other
+addresses, consider requiring a password with --auth; note that it's sent
+unencrypted. Press Ctrl-C to stop the server.`,
+	Example: `  dbxcli serve /Reports
+  dbxcli serve /Reports --addr 0.0.0.0:8080 --auth guest:s3cret`,
+	RunE: serve,
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().String("auth", "", "Require HTTP basic auth with these credentials (user:password)")
+	serveCmd.Flags().Int("max-concurrent", 8, "Maximum number of requests handled at once")
+	serveCmd.Flags().Duration("cache-ttl", 30*time.Second, "How long folder listings are cached")
+}
@@ -0,0 +1,56 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestServeFolderRedirect(t *testing.T) {
+	s := &folderServer{
+		root:  "/Public",
+		cache: &listingCache{ttl: time.Hour, paths: make(map[string]cachedEntry)},
+		slots: make(chan struct{}, 1),
+	}
+	for _, p := range []string{"/Public/photos", "/Public/evil.com"} {
+		s.cache.put(p, cachedEntry{meta: &files.FolderMetadata{}})
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/photos", "/photos/"},
+		{"//evil.com", "/evil.com/"},
+		{"/./evil.com", "/evil.com/"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+		r.URL.Path = tt.path
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("GET %s: status %d, want %d", tt.path, w.Code, http.StatusMovedPermanently)
+			continue
+		}
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("GET %s: redirected to %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
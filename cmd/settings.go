@@ -0,0 +1,246 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const settingsFileName = "config.json"
+
+// User settings, stored next to the auth tokens.
+type settings struct {
+	// Paths which rm and mv refuse to touch, along with everything beneath
+	// them.
+	ProtectedPaths []string `json:"protected_paths,omitempty"`
+	// Default flag values, keyed by "<command>.<flag>", e.g. "put.strict" or
+	// "team.list-members.long". Flags of the root command, like "timeout",
+	// are keyed by the flag name alone.
+	Flags map[string]string `json:"flags,omitempty"`
+	// Named sets of flag values for the transfer commands, chosen with
+	// --preset. Keys are flag names alone, e.g. "transfers" or "progress".
+	Presets map[string]map[string]string `json:"presets,omitempty"`
+}
+
+// Commands which take --preset, named as in settings keys.
+var presetCommands = []string{"put", "get", "sync.push", "sync.pull", "sync.both"}
+
+func presetNames(s *settings) []string {
+	names := make([]string, 0, len(s.Presets))
+	for name := range s.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the flag values of the preset called `name`.
+func lookupPreset(s *settings, name string) (map[string]string, error) {
+	preset, ok := s.Presets[name]
+	if ok {
+		return preset, nil
+	}
+	if len(s.Presets) == 0 {
+		return nil, fmt.Errorf("unknown preset %q; there are no presets in the config file", name)
+	}
+	return nil, fmt.Errorf("unknown preset %q; available presets: %s", name, strings.Join(presetNames(s), ", "))
+}
+
+// Reports whether a preset key names a flag of any command taking --preset.
+func presetKeyKnown(root *cobra.Command, key string) bool {
+	if lookupFlagKey(root, key) != nil {
+		return true
+	}
+	for _, name := range presetCommands {
+		if lookupFlagKey(root, name+"."+key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func settingsFilePath() (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, ".config", "dbxcli", settingsFileName), nil
+}
+
+// Reads the settings file. A missing file is the same as an empty one.
+func readSettings() (*settings, error) {
+	s := new(settings)
+
+	filePath, err := settingsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	return s, nil
+}
+
+func writeSettings(s *settings) error {
+	filePath, err := settingsFilePath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, append(b, '\n'), 0600)
+}
+
+// Writes `data` to a temporary file next to `name` and renames it into
+// place, so an interruption never leaves a truncated file behind. Like the
+// other files dbxcli keeps, it's only readable by the user.
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Finds the flag a settings key refers to, or returns nil if there's no such
+// command or flag (the key may be meant for a different version of dbxcli).
+func lookupFlagKey(root *cobra.Command, key string) *pflag.Flag {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return root.PersistentFlags().Lookup(key)
+	}
+	c, rest, err := root.Find(strings.Split(key[:i], "."))
+	if err != nil || c == root || len(rest) > 0 {
+		return nil
+	}
+	return c.Flags().Lookup(key[i+1:])
+}
+
+// Returns the settings key prefix for flags of `cmd`, e.g. "team.list-members.".
+func flagKeyPrefix(cmd *cobra.Command) string {
+	var names []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		names = append([]string{c.Name()}, names...)
+	}
+	return strings.Join(names, ".") + "."
+}
+
+// Applies default flag values from the settings file to `cmd`, starting with
+// those of the preset chosen by --preset. Flags given on the command line are
+// left alone, and keys which don't name a known flag only produce a warning.
+func applyFlagDefaults(cmd *cobra.Command) error {
+	presetName, _ := cmd.Flags().GetString("preset")
+	if noConfig, _ := cmd.Flags().GetBool("no-config"); noConfig {
+		if presetName != "" {
+			return errors.New("`--preset` can't be used with `--no-config`")
+		}
+		return nil
+	}
+
+	s, err := readSettings()
+	if err != nil {
+		return err
+	}
+
+	fromPreset := make(map[string]bool)
+	if presetName != "" {
+		preset, err := lookupPreset(s, presetName)
+		if err != nil {
+			return err
+		}
+		for name, value := range preset {
+			if !presetKeyKnown(cmd.Root(), name) {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring unknown setting %q in preset %q\n", name, presetName)
+				continue
+			}
+			flag := cmd.Flags().Lookup(name)
+			if flag == nil || flag.Changed {
+				continue
+			}
+			if err = flag.Value.Set(value); err != nil {
+				return fmt.Errorf("invalid value %q for %q in preset %q: %v", value, name, presetName, err)
+			}
+			fromPreset[name] = true
+		}
+	}
+
+	prefix := flagKeyPrefix(cmd)
+	for key, value := range s.Flags {
+		if lookupFlagKey(cmd.Root(), key) == nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring unknown setting %q\n", key)
+			continue
+		}
+
+		var name string
+		switch {
+		case !strings.Contains(key, "."):
+			name = key
+		case strings.HasPrefix(key, prefix) && !strings.Contains(key[len(prefix):], "."):
+			name = key[len(prefix):]
+		default:
+			continue
+		}
+
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed || fromPreset[name] {
+			continue
+		}
+		if err = flag.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid value %q for setting %q: %v", value, key, err)
+		}
+	}
+	return nil
+}
+
+// Returns an error if `p` is one of the protected paths, lies beneath one or
+// contains one. Dropbox paths are case-insensitive, and so is the check.
+func checkProtected(s *settings, p string) error {
+	lower := strings.ToLower(p)
+	for _, protected := range s.ProtectedPaths {
+		prot, err := validatePath(protected)
+		if err != nil {
+			continue
+		}
+		prot = strings.ToLower(prot)
+		if lower == prot || strings.HasPrefix(lower, prot+"/") || strings.HasPrefix(prot, lower+"/") {
+			return fmt.Errorf("%s is protected by the `protected_paths` setting (%s)", p, protected)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/spf13/cobra"
+)
+
+// Writes `s` as the settings file until the test ends.
+func useSettings(t *testing.T, s *settings) {
+	if err := writeSettings(s); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { writeSettings(&settings{}) })
+}
+
+// Resets those of the named flags that `cmd` has when the test ends, after
+// applyFlagDefaults has set them.
+func resetFlagsLater(t *testing.T, cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		if f := cmd.Flags().Lookup(name); f != nil {
+			t.Cleanup(func() { resetFlag(f) })
+		}
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	useSettings(t, &settings{
+		Flags: map[string]string{"sync.push.chunk-size": "8M", "sync.push.parallel": "2"},
+		Presets: map[string]map[string]string{
+			"lan":     {"parallel": "16", "chunk-size": "64M"},
+			"trickle": {"parallel": "1"},
+		},
+	})
+
+	tests := []struct {
+		name  string
+		cmd   *cobra.Command
+		flags map[string]string
+		want  map[string]string
+	}{
+		{"push", syncPushCmd, map[string]string{"preset": "lan"}, map[string]string{"parallel": "16", "chunk-size": "64M"}},
+		{"config default", syncPushCmd, map[string]string{"preset": "trickle"}, map[string]string{"parallel": "1", "chunk-size": "8M"}},
+		{"flag wins", syncPushCmd, map[string]string{"preset": "lan", "parallel": "3"}, map[string]string{"parallel": "3", "chunk-size": "64M"}},
+		{"both", syncBothCmd, map[string]string{"preset": "lan"}, map[string]string{"parallel": "16"}},
+		{"put", putCmd, map[string]string{"preset": "lan"}, map[string]string{"transfers": "16", "chunk-size": "64M"}},
+		{"put alias", putCmd, map[string]string{"parallel": "3"}, map[string]string{"transfers": "3"}},
+		{"push alias", syncPushCmd, map[string]string{"transfers": "5"}, map[string]string{"parallel": "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.cmd, tt.flags)
+			resetFlagsLater(t, tt.cmd, "parallel", "chunk-size", "transfers")
+			if err := applyFlagDefaults(tt.cmd); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := tt.cmd.Flags().Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownPreset(t *testing.T) {
+	useSettings(t, &settings{Presets: map[string]map[string]string{"lan": {}, "trickle": {}}})
+	api := useFakeAPI(t)
+	for _, cmd := range []*cobra.Command{putCmd, getCmd, syncPushCmd, syncPullCmd, syncBothCmd} {
+		setFlags(t, cmd, map[string]string{"preset": "wan"})
+		err := applyFlagDefaults(cmd)
+		if err == nil || !strings.Contains(err.Error(), "available presets: lan, trickle") {
+			t.Errorf("%s: %v", cmd.CommandPath(), err)
+		}
+	}
+	if calls := api.Calls(); len(calls) != 0 {
+		t.Errorf("made API calls %v", calls)
+	}
+}
+
+func TestConfigPresets(t *testing.T) {
+	useSettings(t, &settings{Presets: map[string]map[string]string{"trickle": {"parallel": "1", "bogus": "x"}}})
+	stdout, _, err := testutil.Capture(func() error { return configPresets(configPresetsCmd, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"trickle put --parallel 1 preset", "trickle sync push --parallel 1 preset", "trickle sync both --parallel 1 preset", "trickle - --bogus x (unknown)"} {
+		if !strings.Contains(strings.Join(strings.Fields(stdout), " "), want) {
+			t.Errorf("config presets printed\n%s\nwant %q", stdout, want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/spf13/cobra"
+)
+
+// The vendored SDK would send a zero expiry for every setting left unset, and
+// with fractional seconds, which the API rejects.
+type linkExpirySettings struct {
+	Expires string `json:"expires"`
+}
+
+type modifyLinkExpiryArg struct {
+	Url      string             `json:"url"`
+	Settings linkExpirySettings `json:"settings"`
+}
+
+func linkMetadata(l sharing.IsSharedLinkMetadata) *sharing.SharedLinkMetadata {
+	switch sl := l.(type) {
+	case *sharing.FileLinkMetadata:
+		return &sl.SharedLinkMetadata
+	case *sharing.FolderLinkMetadata:
+		return &sl.SharedLinkMetadata
+	}
+	return nil
+}
+
+// Lists all of the user's shared links, following cursors.
+func listAllSharedLinks(dbx sharing.Client) (links []*sharing.SharedLinkMetadata, err error) {
+	arg := sharing.NewListSharedLinksArg()
+	for {
+		var res *sharing.ListSharedLinksResult
+		if res, err = dbx.ListSharedLinks(arg); err != nil {
+			return
+		}
+		for _, l := range res.Links {
+			if m := linkMetadata(l); m != nil {
+				links = append(links, m)
+			}
+		}
+		if !res.HasMore {
+			return
+		}
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		arg = sharing.NewListSharedLinksArg()
+		arg.Cursor = res.Cursor
+	}
+}
+
+// Returns the links that expire before `deadline`, soonest first.
+func expiringLinks(links []*sharing.SharedLinkMetadata, deadline time.Time) (expiring []*sharing.SharedLinkMetadata) {
+	for _, l := range links {
+		if !l.Expires.IsZero() && l.Expires.Before(deadline) {
+			expiring = append(expiring, l)
+		}
+	}
+	sort.SliceStable(expiring, func(i, j int) bool { return expiring[i].Expires.Before(expiring[j].Expires) })
+	return
+}
+
+// The link's path, or its name for content outside the user's Dropbox.
+func linkPath(l *sharing.SharedLinkMetadata) string {
+	if l.PathLower != "" {
+		return l.PathLower
+	}
+	return l.Name
+}
+
+func daysLeft(expires time.Time, now time.Time) int {
+	return int(math.Ceil(expires.Sub(now).Hours() / 24))
+}
+
+// Links are extended from their current expiry, or from now if they've
+// already expired.
+func extendedExpiry(expires time.Time, by time.Duration, now time.Time) time.Time {
+	if expires.Before(now) {
+		expires = now
+	}
+	return expires.Add(by).UTC().Truncate(time.Second)
+}
+
+// Reports whether an error means the account isn't allowed to set expiry
+// dates at all, as opposed to a problem with one link.
+func expiryNotAllowed(err error) bool {
+	e, ok := err.(rpcError)
+	return ok && strings.HasPrefix(e.ErrorSummary, "settings_error/not_authorized")
+}
+
+// A link as printed by `share expiring --json`: the list of links, or with
+// --extend a line for each link once it's been extended, or has failed to be.
+type expiringLink struct {
+	Path       string           `json:"path"`
+	URL        string           `json:"url"`
+	Expires    time.Time        `json:"expires"`
+	DaysLeft   *int             `json:"days_left,omitempty"`
+	NewExpires *time.Time       `json:"new_expires,omitempty"`
+	Outcome    string           `json:"outcome,omitempty"`
+	Error      *jsonErrorFields `json:"error,omitempty"`
+}
+
+// Implements `share expiring` in JSON mode. Extending links can't be
+// confirmed without the table, so it needs --yes.
+func shareExpiringJSON(cmd *cobra.Command, expiring []*sharing.SharedLinkMetadata, by time.Duration, now time.Time) error {
+	if by == 0 {
+		out := []expiringLink{}
+		for _, l := range expiring {
+			days := daysLeft(l.Expires, now)
+			out = append(out, expiringLink{Path: linkPath(l), URL: l.Url, Expires: l.Expires.UTC(), DaysLeft: &days})
+		}
+		return printJSON(out)
+	}
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		return errors.New("`--extend` with `--json` needs `--yes`, as there's no table to confirm")
+	}
+
+	failed := 0
+	for i, l := range expiring {
+		newExpires := extendedExpiry(l.Expires, by, now).UTC()
+		arg := modifyLinkExpiryArg{l.Url, linkExpirySettings{newExpires.Format(time.RFC3339)}}
+		err := rpc(cmdCtx, "sharing", "modify_shared_link_settings", &arg, nil)
+		if expiryNotAllowed(err) {
+			return fmt.Errorf("your Dropbox plan doesn't allow setting link expiry dates; extended %d of %d links", i-failed, len(expiring))
+		}
+		rec := expiringLink{Path: linkPath(l), URL: l.Url, Expires: l.Expires.UTC(), NewExpires: &newExpires, Outcome: "extended"}
+		if err != nil {
+			f := errorFields(err)
+			rec.Outcome, rec.Error = outcomeFailed, &f
+			failed++
+		}
+		printJSONLine(rec)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links couldn't be extended", failed, len(expiring))
+	}
+	return nil
+}
+
+func shareExpiring(cmd *cobra.Command, args []string) (err error) {
+	within, _ := cmd.Flags().GetString("within")
+	window, err := parseAge(within)
+	if err != nil {
+		return
+	}
+	var by time.Duration
+	if extend, _ := cmd.Flags().GetString("extend"); extend != "" {
+		if by, err = parseAge(extend); err != nil {
+			return
+		}
+	}
+
+	links, err := listAllSharedLinks(newSharingClient(cmdCtx))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	expiring := expiringLinks(links, now.Add(window))
+	asJSON := jsonMode(cmd)
+	if len(expiring) == 0 {
+		if asJSON && by == 0 {
+			return printJSON([]expiringLink{})
+		}
+		if !asJSON {
+			fmt.Fprintf(os.Stderr, "No links expire within %s\n", within)
+		}
+		return
+	}
+	if asJSON {
+		return shareExpiringJSON(cmd, expiring, by, now)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	if by == 0 {
+		fmt.Fprintf(w, "Path\tURL\tExpires\tDays left\n")
+		for _, l := range expiring {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", linkPath(l), l.Url, l.Expires.Local().Format("2006-01-02"), daysLeft(l.Expires, now))
+		}
+		return w.Flush()
+	}
+
+	fmt.Fprintf(w, "Path\tURL\tExpires\tNew expiry\n")
+	for _, l := range expiring {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", linkPath(l), l.Url, l.Expires.Local().Format("2006-01-02"),
+			extendedExpiry(l.Expires, by, now).Local().Format("2006-01-02"))
+	}
+	if err = w.Flush(); err != nil {
+		return
+	}
+
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+		ok, err := confirmYesNo(fmt.Sprintf("Extend %d links?", len(expiring)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("nothing was changed")
+		}
+	}
+
+	var failed int
+	for i, l := range expiring {
+		arg := modifyLinkExpiryArg{l.Url, linkExpirySettings{extendedExpiry(l.Expires, by, now).Format(time.RFC3339)}}
+		err = rpc(cmdCtx, "sharing", "modify_shared_link_settings", &arg, nil)
+		if expiryNotAllowed(err) {
+			// Every other link would fail the same way.
+			return fmt.Errorf("your Dropbox plan doesn't allow setting link expiry dates; extended %d of %d links", i-failed, len(expiring))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", linkPath(l), err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links couldn't be extended", failed, len(expiring))
+	}
+	fmt.Fprintf(os.Stderr, "Extended %d links\n", len(expiring))
+	return nil
+}
+
+// shareExpiringCmd represents the share expiring command
+var shareExpiringCmd = &cobra.Command{
+	Use:   "expiring [flags]",
+	Short: "List shared links that expire soon, and extend them",
+	Long: `List your shared links whose expiry date falls within --within, soonest
+first, including links that have already expired.
+
+With --extend, each of them is extended by that long, counted from its
+current expiry (or from now, for links that have already expired). The
+changes are listed and confirmed first unless --yes is given. Setting expiry
+dates needs a Dropbox plan that allows it.
+
+With --json the links are printed as a JSON list, and with --extend, which
+then needs --yes, each extended link is printed as a line of JSON.`,
+	Example: `  dbxcli share expiring
+  dbxcli share expiring --within 30d --extend 90d`,
+	RunE: shareExpiring,
+}
+
+func init() {
+	shareCmd.AddCommand(shareExpiringCmd)
+	shareExpiringCmd.Flags().String("within", "14d", "List links expiring within this long, like 14d or 72h")
+	shareExpiringCmd.Flags().String("extend", "", "Extend each listed link's expiry by this long, like 90d")
+	shareExpiringCmd.Flags().BoolP("yes", "y", false, "Extend links without asking")
+}
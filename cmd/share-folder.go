@@ -0,0 +1,263 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/async"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/spf13/cobra"
+)
+
+// Access levels a member can be given on a shared folder. Ownership can only
+// be transferred, not granted.
+var folderAccessLevels = []string{"editor", "viewer", "viewer_no_comment"}
+
+// Looks up the id of the shared folder at `p`.
+func sharedFolderID(p string) (string, error) {
+	md, err := getFileMetadata(newFilesClient(cmdCtx), p)
+	if err != nil {
+		return "", err
+	}
+	folder, ok := md.(*files.FolderMetadata)
+	if !ok {
+		return "", fmt.Errorf("%s is not a folder", p)
+	}
+	if folder.SharedFolderId == "" {
+		return "", fmt.Errorf("%s is not a shared folder; share it first, for example with `provision-folder`", p)
+	}
+	return folder.SharedFolderId, nil
+}
+
+// Picks out a member by email address if `member` looks like one, and by
+// account, team member or group id otherwise.
+func newMemberSelector(member string) *sharing.MemberSelector {
+	if strings.Contains(member, "@") {
+		return &sharing.MemberSelector{Tagged: dropbox.Tagged{Tag: sharing.MemberSelectorEmail}, Email: member}
+	}
+	return &sharing.MemberSelector{Tagged: dropbox.Tagged{Tag: sharing.MemberSelectorDropboxId}, DropboxId: member}
+}
+
+func validFolderAccessLevel(level string) bool {
+	for _, l := range folderAccessLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+func shareFolderAddMember(cmd *cobra.Command, args []string) (err error) {
+	if len(args) < 2 {
+		return errors.New("`share folder add-member` requires a `folder` and at least one `member` argument")
+	}
+	role, _ := cmd.Flags().GetString("role")
+	if !validFolderAccessLevel(role) {
+		return fmt.Errorf("`--role` must be one of %s, not %q", strings.Join(folderAccessLevels, ", "), role)
+	}
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	id, err := sharedFolderID(p)
+	if err != nil {
+		return
+	}
+
+	var members []*sharing.AddMember
+	for _, member := range args[1:] {
+		m := sharing.NewAddMember(newMemberSelector(member))
+		m.AccessLevel = &sharing.AccessLevel{Tagged: dropbox.Tagged{Tag: role}}
+		members = append(members, m)
+	}
+	arg := sharing.NewAddFolderMemberArg(id, members)
+	arg.Quiet, _ = cmd.Flags().GetBool("quiet")
+	arg.CustomMessage, _ = cmd.Flags().GetString("message")
+
+	if err = newSharingClient(cmdCtx).AddFolderMember(arg); err != nil {
+		return
+	}
+	for _, member := range args[1:] {
+		fmt.Printf("Added %s to %s as %s\n", member, p, role)
+	}
+	return
+}
+
+func shareFolderRemoveMember(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("`share folder remove-member` requires a `folder` and a `member` argument")
+	}
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	id, err := sharedFolderID(p)
+	if err != nil {
+		return
+	}
+	leaveACopy, _ := cmd.Flags().GetBool("leave-a-copy")
+
+	dbx := newSharingClient(cmdCtx)
+	res, err := dbx.RemoveFolderMember(sharing.NewRemoveFolderMemberArg(id, newMemberSelector(args[1]), leaveACopy))
+	if err != nil {
+		return
+	}
+
+	// Removal always runs as a job; wait for it so a failure isn't lost.
+	var status *sharing.RemoveMemberJobStatus
+	err = retry.PollUntil(cmdCtx, batchPollPolicy, func() (bool, error) {
+		var err error
+		status, err = dbx.CheckRemoveMemberJobStatus(async.NewPollArg(res.AsyncJobId))
+		if err != nil {
+			return false, err
+		}
+		return status.Tag != "in_progress", nil
+	})
+	if err != nil {
+		return
+	}
+	if status.Tag != sharing.RemoveMemberJobStatusComplete {
+		reason := status.Tag
+		if status.Failed != nil {
+			reason = status.Failed.Tag
+		}
+		return fmt.Errorf("removing %s from %s failed: %s", args[1], p, reason)
+	}
+	fmt.Printf("Removed %s from %s\n", args[1], p)
+	return
+}
+
+func shareFolderListMembers(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`share folder list-members` requires a `folder` argument")
+	}
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	id, err := sharedFolderID(p)
+	if err != nil {
+		return
+	}
+
+	dbx := newSharingClient(cmdCtx)
+	res, err := dbx.ListFolderMembers(sharing.NewListFolderMembersArgs(id))
+	if err != nil {
+		return
+	}
+	all := *res
+	for res.Cursor != "" {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		res, err = dbx.ListFolderMembersContinue(sharing.NewListFolderMembersContinueArg(res.Cursor))
+		if err != nil {
+			return
+		}
+		all.Users = append(all.Users, res.Users...)
+		all.Groups = append(all.Groups, res.Groups...)
+		all.Invitees = append(all.Invitees, res.Invitees...)
+	}
+	all.Cursor = ""
+
+	if jsonMode(cmd) {
+		return printJSON(all)
+	}
+
+	var ids []string
+	for _, u := range all.Users {
+		ids = append(ids, u.User.AccountId)
+	}
+	names := newAccountNames(newUsersClient(cmdCtx))
+	if err = names.resolve(ids); err != nil {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmtStr := "%s\t%s\t%s\n"
+	fmt.Fprintf(w, fmtStr, "Type", "Name", "Access")
+	for _, u := range all.Users {
+		fmt.Fprintf(w, fmtStr, "user", names.name(u.User.AccountId), u.AccessType.Tag)
+	}
+	for _, g := range all.Groups {
+		fmt.Fprintf(w, fmtStr, "group", g.Group.GroupName, g.AccessType.Tag)
+	}
+	for _, i := range all.Invitees {
+		fmt.Fprintf(w, fmtStr, "invitee", i.Invitee.Email, i.AccessType.Tag)
+	}
+	return w.Flush()
+}
+
+// shareFolderCmd represents the share folder command
+var shareFolderCmd = &cobra.Command{
+	Use:   "folder",
+	Short: "Manage who can access shared folders",
+}
+
+// shareFolderAddMemberCmd represents the share folder add-member command
+var shareFolderAddMemberCmd = &cobra.Command{
+	Use:   "add-member <folder> <member>...",
+	Short: "Invite people or groups to a shared folder",
+	Long: `Invite people or groups to a shared folder. A member is an email
+address, or an account, team member or group id. Everyone added gets the
+access level given by --role, and is notified by email unless --quiet is set.`,
+	Example: `  dbxcli share folder add-member /Team/Project user@example.com --role editor
+  dbxcli share folder add-member /Team/Project a@example.com b@example.com --role viewer --message "Specs for review"`,
+	RunE: shareFolderAddMember,
+}
+
+// shareFolderRemoveMemberCmd represents the share folder remove-member command
+var shareFolderRemoveMemberCmd = &cobra.Command{
+	Use:   "remove-member <folder> <member>",
+	Short: "Remove someone from a shared folder",
+	Long: `Remove a person or group from a shared folder, given by email
+address or id. With --leave-a-copy a removed person keeps a copy of the
+folder's contents in their own Dropbox.`,
+	Example: `  dbxcli share folder remove-member /Team/Project user@example.com
+  dbxcli share folder remove-member --leave-a-copy /Team/Project user@example.com`,
+	RunE: shareFolderRemoveMember,
+}
+
+// shareFolderListMembersCmd represents the share folder list-members command
+var shareFolderListMembersCmd = &cobra.Command{
+	Use:   "list-members <folder>",
+	Short: "List who can access a shared folder",
+	Long: `List the people, groups and pending invitees of a shared folder,
+with the access each of them has.`,
+	Example: `  dbxcli share folder list-members /Team/Project
+  dbxcli share folder list-members --json /Team/Project`,
+	RunE: shareFolderListMembers,
+}
+
+func init() {
+	shareCmd.AddCommand(shareFolderCmd)
+	shareFolderCmd.AddCommand(shareFolderAddMemberCmd)
+	shareFolderCmd.AddCommand(shareFolderRemoveMemberCmd)
+	shareFolderCmd.AddCommand(shareFolderListMembersCmd)
+
+	shareFolderAddMemberCmd.Flags().String("role", "editor", "Access level: editor, viewer or viewer_no_comment")
+	shareFolderAddMemberCmd.Flags().String("message", "", "Message to include in the invitation")
+	shareFolderAddMemberCmd.Flags().Bool("quiet", false, "Don't notify the new members")
+	shareFolderRemoveMemberCmd.Flags().Bool("leave-a-copy", false, "Let the removed member keep a copy of the folder's contents")
+}
@@ -0,0 +1,320 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/qrcode"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Pixels per module in `--qr-file` images.
+const qrImageScale = 8
+
+// Returns the URL of a shared link, whichever kind it is.
+func sharedLinkURL(l sharing.IsSharedLinkMetadata) string {
+	switch sl := l.(type) {
+	case *sharing.FileLinkMetadata:
+		return sl.Url
+	case *sharing.FolderLinkMetadata:
+		return sl.Url
+	}
+	return ""
+}
+
+// The settings `share link` can give a link. Like linkExpirySettings, they're
+// sent with rpc, since the vendored SDK would send every unset one too.
+type linkSettings struct {
+	RequirePassword bool            `json:"require_password,omitempty"`
+	LinkPassword    string          `json:"link_password,omitempty"`
+	Expires         string          `json:"expires,omitempty"`
+	Access          *dropbox.Tagged `json:"access,omitempty"`
+}
+
+// String keeps the password out of `--verbose` logs.
+func (s *linkSettings) String() string {
+	redacted := *s
+	if redacted.LinkPassword != "" {
+		redacted.LinkPassword = "[REDACTED]"
+	}
+	type plain linkSettings
+	return fmt.Sprintf("%v", plain(redacted))
+}
+
+// Reads a password from standard input: typed at `prompt` without echo if
+// it's a terminal, or the first line of what's piped in otherwise.
+func readPassword(prompt string) (string, error) {
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	line, err := stdinLines.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+type createSharedLinkArg struct {
+	Path     string        `json:"path"`
+	Settings *linkSettings `json:"settings"`
+}
+
+type modifyLinkSettingsArg struct {
+	Url      string        `json:"url"`
+	Settings *linkSettings `json:"settings"`
+}
+
+// Reads the link settings flags; nil means none were given.
+func newLinkSettings(cmd *cobra.Command) (s *linkSettings, err error) {
+	password, _ := cmd.Flags().GetString("password")
+	if fromStdin, _ := cmd.Flags().GetBool("password-stdin"); fromStdin {
+		if password != "" {
+			return nil, errors.New("`--password` and `--password-stdin` can't be combined")
+		}
+		if password, err = readPassword("Link password: "); err != nil {
+			return
+		}
+		if password == "" {
+			return nil, errors.New("`--password-stdin`: no password was given")
+		}
+	}
+	expires, _ := cmd.Flags().GetString("expires")
+	access, _ := cmd.Flags().GetString("access")
+	if password == "" && expires == "" && access == "" {
+		return nil, nil
+	}
+
+	s = &linkSettings{RequirePassword: password != "", LinkPassword: password}
+	if expires != "" {
+		t, err := parseRelativeTime(expires, fromNow)
+		if err != nil {
+			return nil, fmt.Errorf("`--expires`: %v", err)
+		}
+		if !t.After(time.Now()) {
+			return nil, fmt.Errorf("`--expires` %s is in the past", expires)
+		}
+		s.Expires = t.UTC().Format(time.RFC3339)
+	}
+	switch access {
+	case "":
+	case "viewer", "editor", "max":
+		s.Access = &dropbox.Tagged{Tag: access}
+	default:
+		return nil, fmt.Errorf("invalid `--access` %q: use viewer, editor or max", access)
+	}
+	return
+}
+
+// Creates a shared link for `path` with `settings`, or changes the settings
+// of the link it already has.
+func createSharedLinkWithSettings(dbx sharing.Client, path string, settings *linkSettings) (url string, err error) {
+	var res struct {
+		Url string `json:"url"`
+	}
+	err = rpc(cmdCtx, "sharing", "create_shared_link_with_settings", &createSharedLinkArg{path, settings}, &res)
+	if e, ok := err.(rpcError); !ok || !strings.HasPrefix(e.ErrorSummary, "shared_link_already_exists") {
+		return res.Url, err
+	}
+
+	if url, err = existingSharedLink(dbx, path); err != nil {
+		return
+	}
+	err = rpc(cmdCtx, "sharing", "modify_shared_link_settings", &modifyLinkSettingsArg{url, settings}, &res)
+	return res.Url, err
+}
+
+// Creates a shared link for `path`, or returns the one it already has.
+func getOrCreateSharedLink(dbx sharing.Client, path string) (url string, err error) {
+	res, err := dbx.CreateSharedLinkWithSettings(sharing.NewCreateSharedLinkWithSettingsArg(path))
+	if err == nil {
+		return sharedLinkURL(res), nil
+	}
+	e, ok := err.(sharing.CreateSharedLinkWithSettingsAPIError)
+	if !ok || e.EndpointError == nil || e.EndpointError.Tag != sharing.CreateSharedLinkWithSettingsErrorSharedLinkAlreadyExists {
+		return
+	}
+	return existingSharedLink(dbx, path)
+}
+
+// Returns the URL of the shared link `path` already has.
+func existingSharedLink(dbx sharing.Client, path string) (url string, err error) {
+	arg := sharing.NewListSharedLinksArg()
+	arg.Path = path
+	arg.DirectOnly = true
+	links, err := dbx.ListSharedLinks(arg)
+	if err != nil {
+		return
+	}
+	if len(links.Links) == 0 {
+		return "", fmt.Errorf("%s has a shared link, but it couldn't be listed", path)
+	}
+	return sharedLinkURL(links.Links[0]), nil
+}
+
+// Reports whether the locale promises a UTF-8 terminal. The first of the
+// usual variables that's set decides, as it does for the C library.
+func localeIsUTF8() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
+
+func writeQRImage(file string, code *qrcode.Code) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return png.Encode(f, code.Image(qrImageScale))
+}
+
+// Shows `url` as a QR code: in a file with `--qr-file`, otherwise on stderr so
+// stdout carries just the URL.
+func showQR(cmd *cobra.Command, url string) (err error) {
+	code, err := qrcode.Encode([]byte(url))
+	if err != nil {
+		return
+	}
+
+	if file, _ := cmd.Flags().GetString("qr-file"); file != "" {
+		return writeQRImage(file, code)
+	}
+
+	style, _ := cmd.Flags().GetString("qr-style")
+	switch style {
+	case "auto":
+		if localeIsUTF8() {
+			style = "unicode"
+		} else {
+			style = "ascii"
+		}
+	case "unicode", "ascii":
+	default:
+		return fmt.Errorf("invalid `--qr-style` %q: use auto, unicode or ascii", style)
+	}
+
+	if style == "unicode" {
+		fmt.Fprint(os.Stderr, code.HalfBlocks())
+	} else {
+		fmt.Fprint(os.Stderr, code.ASCII())
+	}
+	return
+}
+
+// What `share link --json` prints.
+type createdLink struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+func shareLink(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`share link` requires a `path` argument")
+	}
+
+	path, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+
+	settings, err := newLinkSettings(cmd)
+	if err != nil {
+		return
+	}
+
+	dbx := newSharingClient(cmdCtx)
+	var url string
+	if settings != nil {
+		url, err = createSharedLinkWithSettings(dbx, path, settings)
+	} else {
+		url, err = getOrCreateSharedLink(dbx, path)
+	}
+	if err != nil {
+		return
+	}
+	if jsonMode(cmd) {
+		err = printJSON(createdLink{Path: path, URL: url})
+	} else {
+		fmt.Println(url)
+	}
+	if err != nil {
+		return
+	}
+
+	qr, _ := cmd.Flags().GetBool("qr")
+	if qr || cmd.Flags().Changed("qr-file") {
+		return showQR(cmd, url)
+	}
+	return
+}
+
+// shareLinkCmd represents the share link command
+var shareLinkCmd = &cobra.Command{
+	Use:   "link [flags] <path>",
+	Short: "Create or get a shared link",
+	Long: `Print the shared link for a file or folder, creating it if needed.
+
+--password, --expires and --access set up the link: who has it needs the
+password, it stops working at the given date, time or after the given
+duration (such as 7d), and it lets them view (viewer), edit (editor) or do
+as much as you can (max). A link that already exists is changed to match.
+Passwords and expiry dates need a paid Dropbox plan. --password-stdin asks
+for the password, or reads it from a pipe, so it stays out of your shell
+history.
+
+With --qr the link is also shown as a QR code on stderr, drawn with Unicode
+half blocks when the locale is UTF-8 and with ASCII otherwise; --qr-style
+overrides the choice. --qr-file writes the code to a PNG image instead.
+
+With --json the path and the link are printed as a JSON object.`,
+	Example: `  dbxcli share link /slides.pdf
+  dbxcli share link --password s3cret --expires 2025-01-01 --access viewer /slides.pdf
+  pass show dropbox/slides | dbxcli share link --password-stdin /slides.pdf
+  dbxcli share link --qr /slides.pdf
+  dbxcli share link --qr-file slides.png /slides.pdf`,
+	RunE: shareLink,
+}
+
+func init() {
+	shareCmd.AddCommand(shareLinkCmd)
+	shareLinkCmd.Flags().String("password", "", "Require this password to open the link")
+	shareLinkCmd.Flags().Bool("password-stdin", false, "Like --password, but read the password from standard input")
+	shareLinkCmd.Flags().String("expires", "", "Make the link stop working at this date or time, or after this long (like 7d)")
+	shareLinkCmd.Flags().String("access", "", "What the link allows: viewer, editor or max")
+	shareLinkCmd.Flags().Bool("qr", false, "Show the link as a QR code on stderr")
+	shareLinkCmd.Flags().String("qr-file", "", "Write the QR code to a PNG `file` instead")
+	shareLinkCmd.Flags().String("qr-style", "auto", "QR code characters: auto, unicode or ascii")
+}
@@ -0,0 +1,46 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLinkPasswordStdin(t *testing.T) {
+	useStdin(t, "s3cret\n")
+	setFlags(t, shareLinkCmd, map[string]string{"password-stdin": "true"})
+	s, err := newLinkSettings(shareLinkCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.RequirePassword || s.LinkPassword != "s3cret" {
+		t.Errorf("settings = %+v", *s)
+	}
+	if logged := fmt.Sprintf("%v", &createSharedLinkArg{"/slides.pdf", s}); strings.Contains(logged, "s3cret") {
+		t.Errorf("logged %s", logged)
+	}
+
+	useStdin(t, "")
+	if _, err = newLinkSettings(shareLinkCmd); err == nil || !strings.Contains(err.Error(), "no password was given") {
+		t.Errorf("empty password: %v", err)
+	}
+
+	setFlags(t, shareLinkCmd, map[string]string{"password": "s3cret"})
+	if _, err = newLinkSettings(shareLinkCmd); err == nil || !strings.Contains(err.Error(), "can't be combined") {
+		t.Errorf("both flags: %v", err)
+	}
+}
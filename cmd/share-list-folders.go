@@ -24,15 +24,26 @@ import (
 func shareListFolders(cmd *cobra.Command, args []string) (err error) {
 	arg := sharing.NewListFoldersArgs()
 
-	dbx := sharing.New(config)
+	dbx := newSharingClient(cmdCtx)
 	res, err := dbx.ListFolders(arg)
 	if err != nil {
 		return
 	}
 
-	printFolders(res.Entries)
+	// In JSON mode the folders are printed as one list once they're all in.
+	var all []*sharing.SharedFolderMetadata
+	asJSON := jsonMode(cmd)
+	if asJSON {
+		all = append(all, res.Entries...)
+	} else {
+		printFolders(res.Entries)
+	}
 
 	for len(res.Cursor) > 0 {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+
 		continueArg := sharing.NewListFoldersContinueArg(res.Cursor)
 
 		res, err = dbx.ListFoldersContinue(continueArg)
@@ -40,9 +51,16 @@ func shareListFolders(cmd *cobra.Command, args []string) (err error) {
 			return
 		}
 
-		printFolders(res.Entries)
+		if asJSON {
+			all = append(all, res.Entries...)
+		} else {
+			printFolders(res.Entries)
+		}
 	}
 
+	if asJSON {
+		return printJSON(jsonSharedFolders(all))
+	}
 	return
 }
 
@@ -52,10 +70,30 @@ func printFolders(entries []*sharing.SharedFolderMetadata) {
 	}
 }
 
+// A shared folder as printed by `share list folder --json`. Path is empty for
+// folders which aren't in your Dropbox, such as ones you haven't added.
+type jsonSharedFolder struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+}
+
+func jsonSharedFolders(entries []*sharing.SharedFolderMetadata) []jsonSharedFolder {
+	out := []jsonSharedFolder{}
+	for _, f := range entries {
+		out = append(out, jsonSharedFolder{Name: f.Name, Path: f.PathLower, ID: f.SharedFolderId, URL: f.PreviewUrl})
+	}
+	return out
+}
+
 var shareListFoldersCmd = &cobra.Command{
 	Use:   "folder",
 	Short: "List shared folders",
-	RunE:  shareListFolders,
+	Long: `List the folders shared with you or by you, with the link to preview each.
+
+With --json they're printed as a JSON list.`,
+	RunE: shareListFolders,
 }
 
 func init() {
@@ -15,38 +15,92 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
 	"github.com/spf13/cobra"
 )
 
 func shareListLinks(cmd *cobra.Command, args []string) (err error) {
+	if len(args) > 1 {
+		return errors.New("`share list link` takes at most one `path` argument")
+	}
+	var path string
+	if len(args) == 1 {
+		if path, err = validatePath(args[0]); err != nil {
+			return
+		}
+	}
+	directOnly, _ := cmd.Flags().GetBool("direct-only")
+
 	arg := sharing.NewListSharedLinksArg()
+	arg.Path, arg.DirectOnly = path, directOnly
 
-	dbx := sharing.New(config)
+	dbx := newSharingClient(cmdCtx)
 	res, err := dbx.ListSharedLinks(arg)
 	if err != nil {
 		return
 	}
 
-	printLinks(res.Links)
-
+	links := res.Links
 	for res.HasMore {
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+
 		arg = sharing.NewListSharedLinksArg()
+		arg.Path, arg.DirectOnly = path, directOnly
 		arg.Cursor = res.Cursor
 
 		res, err = dbx.ListSharedLinks(arg)
 		if err != nil {
 			return
 		}
-
-		printLinks(res.Links)
+		links = append(links, res.Links...)
 	}
 
+	if jsonMode(cmd) {
+		return printJSON(jsonLinks(links))
+	}
+	printLinks(links)
 	return
 }
 
+// A shared link as printed with --json. `path` is only known for links to
+// your own files, and `expires` only for links that expire.
+type jsonSharedLink struct {
+	Type    string     `json:"type"`
+	Name    string     `json:"name"`
+	URL     string     `json:"url"`
+	Path    string     `json:"path,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func jsonLinks(links []sharing.IsSharedLinkMetadata) []jsonSharedLink {
+	out := []jsonSharedLink{}
+	for _, l := range links {
+		var sl sharing.SharedLinkMetadata
+		j := jsonSharedLink{}
+		switch m := l.(type) {
+		case *sharing.FileLinkMetadata:
+			sl, j.Type = m.SharedLinkMetadata, "file"
+		case *sharing.FolderLinkMetadata:
+			sl, j.Type = m.SharedLinkMetadata, "folder"
+		default:
+			continue
+		}
+		j.Name, j.URL, j.Path = sl.Name, sl.Url, sl.PathLower
+		if !sl.Expires.IsZero() {
+			expires := sl.Expires.UTC()
+			j.Expires = &expires
+		}
+		out = append(out, j)
+	}
+	return out
+}
+
 func printLinks(links []sharing.IsSharedLinkMetadata) {
 	for _, l := range links {
 		switch sl := l.(type) {
@@ -65,11 +119,23 @@ func printLink(sl sharing.SharedLinkMetadata) {
 }
 
 var shareListLinksCmd = &cobra.Command{
-	Use:   "link",
+	Use:   "link [flags] [<path>]",
 	Short: "List shared links",
-	RunE:  shareListLinks,
+	Long: `List your shared links, or those that give access to <path>.
+
+For a <path>, links to the folders it's in are listed too, since they also
+give access to it, unless --direct-only is given. Remove a link with "dbxcli
+share revoke".
+
+With --json, the links are printed as a list of objects with the fields
+"type" ("file" or "folder"), "name", "url", and "path" and "expires" where
+they're known.`,
+	Example: `  dbxcli share list link
+  dbxcli share list link --direct-only /slides.pdf`,
+	RunE: shareListLinks,
 }
 
 func init() {
 	shareListCmd.AddCommand(shareListLinksCmd)
+	shareListLinksCmd.Flags().Bool("direct-only", false, "With a <path>, only list links to <path> itself")
 }
@@ -0,0 +1,44 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+)
+
+func TestJSONLinks(t *testing.T) {
+	file := sharing.NewFileLinkMetadata("https://db.tt/a", "a.pdf", nil, time.Time{}, time.Time{}, "015", 1)
+	file.PathLower = "/docs/a.pdf"
+	file.Expires = time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+	folder := sharing.NewFolderLinkMetadata("https://db.tt/b", "Docs", nil)
+
+	b, err := json.Marshal(jsonLinks([]sharing.IsSharedLinkMetadata{file, folder}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"type":"file","name":"a.pdf","url":"https://db.tt/a","path":"/docs/a.pdf","expires":"2016-09-01T12:00:00Z"},` +
+		`{"type":"folder","name":"Docs","url":"https://db.tt/b"}]`
+	if string(b) != want {
+		t.Errorf("jsonLinks = %s, want %s", b, want)
+	}
+
+	if b, _ = json.Marshal(jsonLinks(nil)); string(b) != "[]" {
+		t.Errorf("no links = %s, want []", b)
+	}
+}
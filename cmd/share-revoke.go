@@ -0,0 +1,80 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/sharing"
+	"github.com/spf13/cobra"
+)
+
+// One line of `share revoke --json` output. Outcome is "revoked" or "failed".
+type revokeRecord struct {
+	URL     string           `json:"url"`
+	Outcome string           `json:"outcome"`
+	Error   *jsonErrorFields `json:"error,omitempty"`
+}
+
+func shareRevoke(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`share revoke` requires a `url` argument")
+	}
+
+	dbx := newSharingClient(cmdCtx)
+	failed := 0
+	for _, url := range args {
+		if err = dbx.RevokeSharedLink(sharing.NewRevokeSharedLinkArg(url)); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			failed++
+			if jsonMode(cmd) {
+				f := errorFields(err)
+				printJSONLine(revokeRecord{URL: url, Outcome: outcomeFailed, Error: &f})
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", url, explainError(err))
+			continue
+		}
+		if jsonMode(cmd) {
+			printJSONLine(revokeRecord{URL: url, Outcome: "revoked"})
+			continue
+		}
+		fmt.Printf("Revoked %s\n", url)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links couldn't be revoked", failed, len(args))
+	}
+	return nil
+}
+
+// shareRevokeCmd represents the share revoke command
+var shareRevokeCmd = &cobra.Command{
+	Use:   "revoke <url>...",
+	Short: "Revoke shared links",
+	Long: `Revoke shared links, so they stop working. "dbxcli share list link"
+lists the links you have. With --json, the outcome for each link is
+printed as a line of JSON.`,
+	Example: `  dbxcli share revoke 'https://www.dropbox.com/s/abc123/report.pdf?dl=0'
+  dbxcli share list link --direct-only /report.pdf | cut -f2 | xargs dbxcli share revoke`,
+	RunE: shareRevoke,
+}
+
+func init() {
+	shareCmd.AddCommand(shareRevokeCmd)
+}
@@ -0,0 +1,52 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestShareRevokeJSON(t *testing.T) {
+	api := useFakeAPI(t)
+	api.Respond("sharing/revoke_shared_link", 200, `null`)
+	api.Respond("sharing/revoke_shared_link", 409, `{"error_summary": "shared_link_not_found/..", "error": {".tag": "shared_link_not_found"}}`)
+	setFlags(t, shareRevokeCmd, map[string]string{"json": "true"})
+
+	stdout, stderr, err := testutil.Capture(func() error {
+		return shareRevoke(shareRevokeCmd, []string{"https://db.tt/a", "https://db.tt/b"})
+	})
+	if err == nil {
+		t.Error("a failed revocation was ignored")
+	}
+	if stderr != "" {
+		t.Errorf("stderr = %q, want nothing", stderr)
+	}
+	var got []revokeRecord
+	for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+		var rec revokeRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("%q isn't JSON: %v", line, err)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != 2 || got[0].Outcome != "revoked" || got[1].Outcome != outcomeFailed ||
+		got[1].URL != "https://db.tt/b" || got[1].Error == nil || got[1].Error.Tag != "shared_link_not_found" {
+		t.Errorf("records = %+v", got)
+	}
+}
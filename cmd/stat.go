@@ -0,0 +1,115 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func stat(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`stat` requires a `path` argument")
+	}
+
+	path, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+
+	md, x, raw, err := getMetadataExtras(cmdCtx, path)
+	if err != nil {
+		return
+	}
+
+	if jsonMode(cmd) {
+		// Print the metadata exactly as the API returned it.
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(raw)
+	}
+
+	names := newAccountNames(newUsersClient(cmdCtx))
+	if err = names.resolve([]string{modifiedBy(md)}); err != nil {
+		return
+	}
+	return printMetadata(md, x, names.name(modifiedBy(md)))
+}
+
+// Prints the metadata of a file or folder the way stat does. `editor` is who
+// last modified a file, and is left out if empty.
+func printMetadata(md files.IsMetadata, x fileExtras, editor string) error {
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	switch m := md.(type) {
+	case *files.FileMetadata:
+		fmt.Fprintf(w, "Type:\tfile\n")
+		fmt.Fprintf(w, "Id:\t%s\n", m.Id)
+		fmt.Fprintf(w, "Path:\t%s\n", m.PathDisplay)
+		fmt.Fprintf(w, "Size:\t%s (%d bytes)\n", humanize.IBytes(m.Size), m.Size)
+		fmt.Fprintf(w, "Revision:\t%s\n", m.Rev)
+		fmt.Fprintf(w, "Client modified:\t%s\n", m.ClientModified.Format(time.RFC3339))
+		fmt.Fprintf(w, "Server modified:\t%s\n", m.ServerModified.Format(time.RFC3339))
+		if editor != "" {
+			fmt.Fprintf(w, "Modified by:\t%s\n", editor)
+		}
+		fmt.Fprintf(w, "Downloadable:\t%t\n", x.downloadable())
+		if e := x.ExportInfo; e != nil {
+			fmt.Fprintf(w, "Exports as:\t%s\n", e.ExportAs)
+			if len(e.ExportOptions) > 0 {
+				fmt.Fprintf(w, "Export options:\t%s\n", strings.Join(e.ExportOptions, ", "))
+			}
+		}
+	case *files.FolderMetadata:
+		fmt.Fprintf(w, "Type:\tfolder\n")
+		fmt.Fprintf(w, "Id:\t%s\n", m.Id)
+		fmt.Fprintf(w, "Path:\t%s\n", m.PathDisplay)
+	case *files.DeletedMetadata:
+		fmt.Fprintf(w, "Type:\tdeleted\n")
+		fmt.Fprintf(w, "Path:\t%s\n", m.PathDisplay)
+	}
+	return w.Flush()
+}
+
+// statCmd represents the stat command
+var statCmd = &cobra.Command{
+	Use:   "stat [flags] <path>",
+	Short: "Show metadata for a file or folder",
+	Long: `Show metadata for a file or folder, including both its id and its path.
+
+<path> may be a path or an id such as "id:a4ayc_80_OEAAAAAAAAAXw", so stat
+also converts between the two.
+
+Cloud documents, such as Paper docs and Google files, can't be downloaded but
+can be exported with "get --format"; stat lists the formats they export to.
+For files in shared folders it also shows who last modified them.
+--json prints the metadata exactly as Dropbox returned it.`,
+	Example: `  dbxcli stat /reports/2016.pdf
+  dbxcli stat id:a4ayc_80_OEAAAAAAAAAXw`,
+	RunE: stat,
+}
+
+func init() {
+	RootCmd.AddCommand(statCmd)
+}
@@ -0,0 +1,57 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestStatByID(t *testing.T) {
+	api := useFakeAPI(t)
+	api.Respond("files/get_metadata", 200, `{
+		".tag": "file",
+		"name": "2016.pdf",
+		"id": "id:a4ayc_80_OEAAAAAAAAAXw",
+		"path_lower": "/reports/2016.pdf",
+		"path_display": "/Reports/2016.pdf",
+		"rev": "a1c10ce0dd78",
+		"size": 2048,
+		"client_modified": "2016-06-01T12:00:00Z",
+		"server_modified": "2016-06-01T12:00:00Z"
+	}`)
+
+	stdout, _, err := testutil.Capture(func() error {
+		return stat(statCmd, []string{"id:a4ayc_80_OEAAAAAAAAAXw/"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Id:              id:a4ayc_80_OEAAAAAAAAAXw\n", "Path:            /Reports/2016.pdf\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stat printed\n%s\nwant a line %q", stdout, want)
+		}
+	}
+
+	var arg struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(api.Calls()[0].Arg), &arg); err != nil || arg.Path != "id:a4ayc_80_OEAAAAAAAAAXw" {
+		t.Errorf("looked up %q, %v", arg.Path, err)
+	}
+}
@@ -0,0 +1,675 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+// Bumped whenever the layout of syncState changes, so old state files are
+// rejected instead of misread.
+const syncStateVersion = 1
+
+// What both sides of a two-way sync looked like after the last run, keyed
+// like the sync trees. A change is anything that differs from this.
+type syncState struct {
+	Version int                    `json:"version"`
+	Local   string                 `json:"local"`
+	Remote  string                 `json:"remote"`
+	Entries map[string]syncedEntry `json:"entries"`
+	// Set when Entries are exactly what the remote folder held at this
+	// list_folder cursor, so an unchanged folder needn't be listed again.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// One file or folder as last synced. Size and ModTime describe the local
+// file, so it only needs hashing again if either changed; Rev is the remote
+// file's revision, where known.
+type syncedEntry struct {
+	Rel     string    `json:"rel"`
+	Dir     bool      `json:"dir,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+	Rev     string    `json:"rev,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+}
+
+// Each pair of local directory and remote folder has its own state file,
+// named after a hash of the two.
+func defaultSyncStatePath(localRoot string, remoteRoot string) (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(localRoot)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs + "\x00" + strings.ToLower(remoteRoot)))
+	return path.Join(dir, ".config", "dbxcli", "sync", hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// Reads the state of earlier syncs of the same pair. A missing file means
+// they've never been synced.
+func readSyncState(stateFile string, localRoot string, remoteRoot string) (*syncState, error) {
+	st := &syncState{Version: syncStateVersion, Local: localRoot, Remote: remoteRoot, Entries: map[string]syncedEntry{}}
+	b, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("%s: %v", stateFile, err)
+	}
+	if st.Version != syncStateVersion {
+		return nil, fmt.Errorf("%s was written by a different version of dbxcli (state version %d, expected %d); delete it to start over",
+			stateFile, st.Version, syncStateVersion)
+	}
+	if !strings.EqualFold(st.Remote, remoteRoot) {
+		return nil, fmt.Errorf("%s belongs to a sync with %q, not %q", stateFile, st.Remote, remoteRoot)
+	}
+	if st.Entries == nil {
+		st.Entries = map[string]syncedEntry{}
+	}
+	return st, nil
+}
+
+func writeSyncState(stateFile string, st *syncState) error {
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(stateFile, append(b, '\n'))
+}
+
+func syncedFile(rel string, info os.FileInfo, hash string, rev string) syncedEntry {
+	return syncedEntry{Rel: rel, Hash: hash, Rev: rev, Size: info.Size(), ModTime: info.ModTime()}
+}
+
+// Rebuilds the remote tree from a state saved with a cursor, which records
+// what the remote folder held then.
+func remoteTreeFromState(st *syncState, remoteRoot string) map[string]remoteEntry {
+	tree := make(map[string]remoteEntry, len(st.Entries))
+	for key, e := range st.Entries {
+		p := remoteRoot + "/" + e.Rel
+		if e.Dir {
+			md := files.NewFolderMetadata(path.Base(e.Rel), "")
+			md.PathDisplay, md.PathLower = p, strings.ToLower(p)
+			tree[key] = remoteEntry{rel: e.Rel, md: md}
+			continue
+		}
+		md := files.NewFileMetadata(path.Base(e.Rel), "", e.ModTime, e.ModTime, e.Rev, uint64(e.Size))
+		md.PathDisplay, md.PathLower = p, strings.ToLower(p)
+		tree[key] = remoteEntry{rel: e.Rel, md: md, hash: e.Hash}
+	}
+	return tree
+}
+
+// Lists the remote side of a two-way sync. If nothing changed remotely since
+// the cursor the state was saved with, the state describes the folder and it
+// isn't listed again. Also returns a cursor taken before listing, which the
+// next run can check for changes from.
+func listSyncRemote(ctx context.Context, dbx files.Client, st *syncState, remoteRoot string) (map[string]remoteEntry, string, error) {
+	if st.Cursor != "" {
+		res, err := dbx.ListFolderContinue(files.NewListFolderContinueArg(st.Cursor))
+		if err == nil && len(res.Entries) == 0 && !res.HasMore {
+			return remoteTreeFromState(st, remoteRoot), res.Cursor, nil
+		}
+	}
+
+	arg := files.NewListFolderArg(remoteRoot)
+	arg.Recursive = true
+	var cursor string
+	// A folder that doesn't exist yet has no cursor; it's listed every time
+	// until it does.
+	if res, err := dbx.ListFolderGetLatestCursor(arg); err == nil {
+		cursor = res.Cursor
+	}
+	remote, err := listRemoteTree(ctx, remoteRoot)
+	return remote, cursor, err
+}
+
+// Hashes a local file, unless it has the size and modification time it had
+// when it was last synced.
+func localSyncHash(l localEntry, base syncedEntry, synced bool) (string, error) {
+	if synced && !base.Dir && base.Hash != "" && base.Size == l.info.Size() && base.ModTime.Equal(l.info.ModTime()) {
+		return base.Hash, nil
+	}
+	return contentHash(l.path)
+}
+
+// Returns the name a conflicting edit of `rel` is kept under, e.g.
+// "notes (conflicted copy).txt", numbered if `taken` says the name is in use.
+func conflictedCopyName(rel string, taken func(key string) bool) string {
+	ext := path.Ext(rel)
+	if strings.Contains(ext, "/") {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(rel, ext)
+	name := stem + " (conflicted copy)" + ext
+	for n := 2; taken(syncKey(name)); n++ {
+		name = fmt.Sprintf("%s (conflicted copy %d)%s", stem, n, ext)
+	}
+	return name
+}
+
+// A file changed on both sides. The remote version keeps the name; the local
+// one is renamed and uploaded under its new name as well.
+type syncConflict struct {
+	key      string
+	local    string
+	copyKey  string
+	copyRel  string
+	copyPath string
+}
+
+// What `sync both` does to reconcile the two sides. `next` is the state
+// once every change has been made; entries whose change fails are put back
+// the way they were, so the change is tried again next time.
+type bothPlan struct {
+	uploads       []uploadJob
+	uploadKeys    []string
+	downloads     []pullJob
+	downloadKeys  []string
+	conflicts     []syncConflict
+	localMkdirs   []string
+	remoteMkdirs  []uploadJob
+	localDeletes  []string
+	remoteDeletes []string
+	blocked       []error
+	next          map[string]syncedEntry
+	// The revision each upload may replace, keyed by its destination; see
+	// decideUpdate.
+	revs map[string]string
+}
+
+// Compares both trees with the state of the last sync. Whatever changed on
+// one side only is copied to the other, deletions included. A file changed
+// differently on both sides is a conflict, unless one side deleted it, in
+// which case the edit wins. Folders are only deleted if nothing beneath them
+// is left on the other side.
+func planBoth(local map[string]localEntry, remote map[string]remoteEntry, st *syncState, localRoot string, remoteRoot string) (*bothPlan, error) {
+	plan := &bothPlan{next: make(map[string]syncedEntry), revs: make(map[string]string)}
+	keys := make(map[string]bool)
+	for k := range local {
+		keys[k] = true
+	}
+	for k := range remote {
+		keys[k] = true
+	}
+	sorted := syncKeys(keys)
+
+	// What exists on each side once the plan is carried out, to decide
+	// whether a folder is empty enough to delete.
+	keepLocal := make(map[string]bool)
+	keepRemote := make(map[string]bool)
+	localDeletes := make(map[string]bool)
+	remoteDeletes := make(map[string]bool)
+	taken := func(key string) bool {
+		_, l := local[key]
+		_, r := remote[key]
+		return l || r || keys[key]
+	}
+	dsts := make(map[string]string)
+
+	for _, key := range sorted {
+		l, lok := local[key]
+		r, rok := remote[key]
+		base, synced := st.Entries[key]
+		var dst string
+		if rok {
+			dst = localPathFor(dsts, local, localRoot, key, r.rel)
+		}
+		if lok && rok && l.info.IsDir() != r.isDir() {
+			plan.blocked = append(plan.blocked, fmt.Errorf("%s: a file on one side is a folder on the other", l.path))
+			if synced {
+				plan.next[key] = base
+			}
+			keepLocal[key], keepRemote[key] = true, true
+			continue
+		}
+		if (lok && l.info.IsDir()) || (rok && r.isDir()) {
+			continue
+		}
+
+		var hash string
+		if lok {
+			var err error
+			if hash, err = localSyncHash(l, base, synced); err != nil {
+				return nil, err
+			}
+		}
+		localChanged := lok != synced || (lok && hash != base.Hash)
+		remoteChanged := rok != synced || (rok && r.hash != base.Hash)
+		upload := func(rel string) {
+			dst := remoteRoot + "/" + rel
+			plan.uploads = append(plan.uploads, uploadJob{l.path, dst})
+			plan.uploadKeys = append(plan.uploadKeys, key)
+			plan.revs[dst] = r.rev()
+			plan.next[key] = syncedFile(rel, l.info, hash, "")
+			keepLocal[key], keepRemote[key] = true, true
+		}
+		download := func() {
+			plan.downloads = append(plan.downloads, pullJob{src: metadataPath(r.md), dst: dst, update: lok})
+			plan.downloadKeys = append(plan.downloadKeys, key)
+			keepLocal[key], keepRemote[key] = true, true
+		}
+
+		switch {
+		case !lok && !rok:
+			// Deleted on both sides.
+		case !localChanged && !remoteChanged, lok && rok && hash == r.hash:
+			if lok {
+				plan.next[key] = syncedFile(l.rel, l.info, hash, r.rev())
+			}
+			keepLocal[key], keepRemote[key] = lok, rok
+		case !remoteChanged && lok:
+			upload(l.rel)
+		case !remoteChanged:
+			remoteDeletes[key] = true
+		case !localChanged && rok:
+			download()
+		case !localChanged:
+			localDeletes[key] = true
+		case !rok:
+			// An edit wins over a deletion.
+			upload(l.rel)
+		case !lok:
+			download()
+		default:
+			copyRel := conflictedCopyName(l.rel, taken)
+			c := syncConflict{
+				key:      key,
+				local:    l.path,
+				copyKey:  syncKey(copyRel),
+				copyRel:  copyRel,
+				copyPath: filepath.Join(filepath.Dir(l.path), path.Base(copyRel)),
+			}
+			keys[c.copyKey] = true
+			plan.conflicts = append(plan.conflicts, c)
+			plan.uploads = append(plan.uploads, uploadJob{c.copyPath, remoteRoot + "/" + copyRel})
+			plan.uploadKeys = append(plan.uploadKeys, c.copyKey)
+			plan.revs[remoteRoot+"/"+copyRel] = ""
+			plan.next[c.copyKey] = syncedFile(copyRel, l.info, hash, "")
+			download()
+		}
+	}
+
+	// Folders, children first, so a folder knows whether anything beneath
+	// it survives.
+	survives := func(keep map[string]bool, key string) bool {
+		for k, ok := range keep {
+			if ok && strings.HasPrefix(k, key+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		key := sorted[i]
+		l, lok := local[key]
+		r, rok := remote[key]
+		if !(lok && l.info.IsDir()) && !(rok && r.isDir()) || (lok && rok && l.info.IsDir() != r.isDir()) {
+			continue
+		}
+		_, synced := st.Entries[key]
+		switch {
+		case lok && rok:
+			plan.next[key] = syncedEntry{Rel: l.rel, Dir: true}
+			keepLocal[key], keepRemote[key] = true, true
+		case lok && synced && !survives(keepLocal, key):
+			localDeletes[key] = true
+		case lok:
+			plan.remoteMkdirs = append(plan.remoteMkdirs, uploadJob{l.path, remoteRoot + "/" + l.rel})
+			plan.next[key] = syncedEntry{Rel: l.rel, Dir: true}
+			keepLocal[key], keepRemote[key] = true, true
+		case synced && !survives(keepRemote, key):
+			remoteDeletes[key] = true
+		default:
+			plan.localMkdirs = append(plan.localMkdirs, localPathFor(dsts, local, localRoot, key, r.rel))
+			plan.next[key] = syncedEntry{Rel: r.rel, Dir: true}
+			keepLocal[key], keepRemote[key] = true, true
+		}
+	}
+
+	// Deleting a folder deletes everything in it.
+	for _, key := range syncKeys(localDeletes) {
+		if !parentIn(localDeletes, key) {
+			plan.localDeletes = append(plan.localDeletes, local[key].path)
+		}
+	}
+	for _, key := range syncKeys(remoteDeletes) {
+		if !parentIn(remoteDeletes, key) {
+			plan.remoteDeletes = append(plan.remoteDeletes, remoteRoot+"/"+remote[key].rel)
+		}
+	}
+	// Folders were visited children first.
+	for i, j := 0, len(plan.localMkdirs)-1; i < j; i, j = i+1, j-1 {
+		plan.localMkdirs[i], plan.localMkdirs[j] = plan.localMkdirs[j], plan.localMkdirs[i]
+	}
+	return plan, nil
+}
+
+// Lists what the plan changes, for --dry-run and --check.
+func (plan *bothPlan) changes() (changes []plannedChange) {
+	for _, dir := range plan.localMkdirs {
+		changes = append(changes, plannedChange{Dst: dir, Action: "mkdir"})
+	}
+	for _, dir := range plan.remoteMkdirs {
+		changes = append(changes, plannedChange{Dst: dir.dst, Action: "mkdir"})
+	}
+	for _, c := range plan.conflicts {
+		changes = append(changes, plannedChange{Src: c.local, Dst: c.copyPath, Action: "conflict"})
+	}
+	for _, job := range plan.downloads {
+		changes = append(changes, plannedChange{Src: job.src, Dst: job.dst, Action: "download"})
+	}
+	for _, job := range plan.uploads {
+		changes = append(changes, plannedChange{Src: job.src, Dst: job.dst, Action: "upload"})
+	}
+	for _, p := range plan.localDeletes {
+		changes = append(changes, plannedChange{Dst: p, Action: "delete"})
+	}
+	for _, p := range plan.remoteDeletes {
+		changes = append(changes, plannedChange{Dst: p, Action: "delete"})
+	}
+	return
+}
+
+func syncBoth(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("`sync both` requires `local` and `remote` arguments")
+	}
+	localRoot := filepath.Clean(args[0])
+	remoteRoot, err := validatePath(args[1])
+	if err != nil {
+		return
+	}
+	stateFile, _ := cmd.Flags().GetString("state")
+	if stateFile == "" {
+		if stateFile, err = defaultSyncStatePath(localRoot, remoteRoot); err != nil {
+			return
+		}
+	}
+	st, err := readSyncState(stateFile, localRoot, remoteRoot)
+	if err != nil {
+		return
+	}
+
+	local, err := listLocalTree(localRoot)
+	if err != nil {
+		return
+	}
+	dbx := newFilesClient(cmdCtx)
+	remote, cursor, err := listSyncRemote(cmdCtx, dbx, st, remoteRoot)
+	if err != nil {
+		return
+	}
+	filter, err := newPathFilter(cmd)
+	if err == nil {
+		filter, err = filter.withIgnoreFile(localRoot)
+	}
+	if err != nil {
+		return
+	}
+	listed := len(remote)
+	filterSyncTrees(filter, local, remote)
+	// The new state only describes the whole remote folder if nothing in it
+	// was excluded.
+	partial := len(remote) < listed
+	plan, err := planBoth(local, remote, st, localRoot, remoteRoot)
+	if err != nil {
+		return
+	}
+	// Remember excluded files, in case they're included again later.
+	for key, e := range st.Entries {
+		if filter.excluded(e.Rel, e.Dir) {
+			plan.next[key] = e
+			partial = true
+		}
+	}
+	for _, err := range plan.blocked {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if check, _ := cmd.Flags().GetBool("check"); check {
+		return checkSync(cmd, plan.changes(), plan.blocked)
+	}
+
+	if len(plan.remoteDeletes) > 0 {
+		s, err := readSettings()
+		if err != nil {
+			return err
+		}
+		for _, p := range plan.remoteDeletes {
+			if err = checkProtected(s, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		return listChanges(cmd, plan.changes())
+	}
+
+	failed := len(plan.blocked)
+	// Puts an entry back the way it was synced last, so its change is tried
+	// again next time.
+	revert := func(key string) {
+		if base, ok := st.Entries[key]; ok {
+			plan.next[key] = base
+		} else {
+			delete(plan.next, key)
+		}
+	}
+
+	for _, dir := range plan.localMkdirs {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+	}
+	for _, c := range plan.conflicts {
+		if err = os.Rename(c.local, c.copyPath); err != nil {
+			return
+		}
+		fmt.Printf("Conflict: kept your version of %s as %s\n", c.local, c.copyPath)
+	}
+
+	for i, job := range plan.downloads {
+		key := plan.downloadKeys[i]
+		if _, err = pullFile(dbx, job); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: download failed: %s\n", job.src, explainError(err))
+			revert(key)
+			failed++
+			continue
+		}
+		fmt.Printf("%s -> %s\n", job.src, job.dst)
+		info, statErr := os.Stat(job.dst)
+		if statErr != nil {
+			return statErr
+		}
+		plan.next[key] = syncedFile(remote[key].rel, info, remote[key].hash, remote[key].rev())
+	}
+
+	var renamed []uploadResult
+	if len(plan.uploads) > 0 {
+		opts := uploadOptions{chunkSize: defaultChunkSize, chunkParallelism: 1, revs: plan.revs}
+		parallelism, _ := cmd.Flags().GetInt("parallel")
+		results := make(chan uploadResult)
+		go func() {
+			for r := range uploadAll(cmdCtx, dbx, plan.uploads, opts, parallelism) {
+				if r.err == nil && r.outcome == outcomeRenamed {
+					renamed = append(renamed, r)
+				}
+				results <- r
+			}
+			close(results)
+		}()
+		failures, _ := printOrderedResults(os.Stdout, results, jsonMode(cmd))
+		for _, r := range failures {
+			revert(plan.uploadKeys[r.index])
+			failed++
+		}
+	}
+	// Files which changed on Dropbox after they were listed were kept there
+	// as conflicted copies instead of being replaced. The local file takes
+	// the copy's name and the remote version is downloaded in its place.
+	for _, r := range renamed {
+		key := plan.uploadKeys[r.index]
+		entry := plan.next[key]
+		revert(key)
+		if len(r.path) <= len(remoteRoot) || !strings.EqualFold(r.path[:len(remoteRoot)+1], remoteRoot+"/") {
+			fmt.Fprintf(os.Stderr, "%s: uploaded to %s, outside %s\n", r.job.src, r.path, remoteRoot)
+			failed++
+			continue
+		}
+		entry.Rel = r.path[len(remoteRoot)+1:]
+		copyPath := filepath.Join(filepath.Dir(r.job.src), path.Base(entry.Rel))
+		if err = os.Rename(r.job.src, copyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed++
+			continue
+		}
+		plan.next[syncKey(entry.Rel)] = entry
+		fmt.Printf("Conflict: %s changed on Dropbox meanwhile; kept your version as %s\n", r.job.dst, copyPath)
+		if _, err = pullFile(dbx, pullJob{src: r.job.dst, dst: r.job.src}); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: download failed: %s\n", r.job.dst, explainError(err))
+			failed++
+			continue
+		}
+		fmt.Printf("%s -> %s\n", r.job.dst, r.job.src)
+	}
+	failed += createFolders(dbx, plan.remoteMkdirs, jsonMode(cmd))
+
+	// Forgets about everything at or beneath `key` whose deletion failed, so
+	// it's compared afresh next time.
+	revertTree := func(key string) {
+		for k := range st.Entries {
+			if k == key || strings.HasPrefix(k, key+"/") {
+				revert(k)
+			}
+		}
+	}
+	for _, p := range plan.localDeletes {
+		if err = os.RemoveAll(p); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			if rel, relErr := filepath.Rel(localRoot, p); relErr == nil {
+				revertTree(syncKey(filepath.ToSlash(rel)))
+			}
+			failed++
+			continue
+		}
+		fmt.Printf("Deleted %s\n", p)
+	}
+	if len(plan.remoteDeletes) > 0 {
+		var notDeleted []string
+		if notDeleted, err = deleteAll(cmd, plan.remoteDeletes); err != nil {
+			return
+		}
+		for _, p := range notDeleted {
+			revertTree(syncKey(strings.TrimPrefix(p, remoteRoot+"/")))
+		}
+		failed += len(notDeleted)
+	}
+
+	st.Entries = plan.next
+	// The next run can skip listing an unchanged remote folder only if the
+	// state matches it exactly, which it doesn't after a change there or a
+	// failure.
+	st.Cursor = ""
+	if !partial && failed == 0 && len(plan.uploads)+len(plan.remoteMkdirs)+len(plan.remoteDeletes) == 0 {
+		st.Cursor = cursor
+	}
+	if err = writeSyncState(stateFile, st); err != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Downloaded %d, uploaded %d, deleted %d, %d conflicts, %d failed\n",
+		len(plan.downloads), len(plan.uploads), len(plan.localDeletes)+len(plan.remoteDeletes), len(plan.conflicts)+len(renamed), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d changes failed", failed)
+	}
+	return
+}
+
+// syncBothCmd represents the sync both command
+var syncBothCmd = &cobra.Command{
+	Use:   "both [flags] <local> <remote>",
+	Short: "Keep a local directory and a Dropbox folder in sync both ways",
+	Long: `Reconcile the local directory <local> and the Dropbox folder <remote>, so
+changes made on either side since the last run are made on the other.
+
+What both sides looked like after each run is kept in a state file under
+~/.config/dbxcli/sync (or --state). Against it, new and changed files are
+copied across and deletions are repeated on the other side. Files are
+compared by Dropbox content hash; local files are only hashed again if their
+size or modification time changed, so the first run is the slowest. If
+nothing changed on Dropbox since a run, the next one doesn't list the
+folder again.
+
+If a file was changed differently on both sides, neither edit is lost: the
+local version is renamed to "name (conflicted copy).ext" and uploaded under
+that name, and the remote version is downloaded in its place. The same
+happens if a file changes on Dropbox while it's being synced, as uploads
+only replace the revision that was listed. A file that was edited on one
+side and deleted on the other is kept. Folders are only deleted if nothing
+beneath them is left.
+
+Without a state file, as on the first run, nothing is deleted and files that
+differ are treated as conflicts.
+
+Use --dry-run to only list the changes. --check lists them too, and exits
+with status 0 if there are none, 1 if there are some and more than 1 on
+errors; see "put --help".`,
+	Example: `  dbxcli sync both ~/Notes /Notes
+  dbxcli sync both --dry-run ~/Notes /Notes`,
+	RunE: syncBoth,
+}
+
+func init() {
+	syncCmd.AddCommand(syncBothCmd)
+	addFilterFlags(syncBothCmd)
+	syncBothCmd.Flags().Bool("dry-run", false, "Only list what would change")
+	addCheckFlags(syncBothCmd)
+	syncBothCmd.Flags().String("state", "", "State file to use instead of one under ~/.config/dbxcli/sync")
+	syncBothCmd.Flags().Int("parallel", defaultTransfers, "Number of files to upload at once (0 means all of them); also --transfers")
+	syncBothCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"transfers": "parallel"}))
+	syncBothCmd.Flags().String("preset", "", "Use the flag values of this preset from the config file")
+}
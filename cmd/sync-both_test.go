@@ -0,0 +1,307 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/testutil"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// A local edit of a file that changed on Dropbox after it was listed is kept
+// as the conflicted copy Dropbox saves it as, instead of replacing the
+// remote edit.
+func TestSyncBothUploadConflict(t *testing.T) {
+	fake := useFakeFiles(t)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "notes.txt")
+	if err := ioutil.WriteFile(local, []byte("local edit"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	st := &syncState{
+		Version: syncStateVersion,
+		Local:   dir,
+		Remote:  "/notes",
+		Cursor:  "c1",
+		Entries: map[string]syncedEntry{
+			"notes.txt": {Rel: "notes.txt", Hash: "old", Rev: "r1", Size: 3, ModTime: time.Unix(0, 0)},
+		},
+	}
+	if err := writeSyncState(stateFile, st); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed remotely as of the cursor, but the upload finds a new
+	// revision.
+	fake.Respond("ListFolderContinue", &files.ListFolderResult{Cursor: "c2"}, nil)
+	fake.Respond("Upload", fileMetadata("/notes/notes (conflicted copy).txt", 10), nil)
+	fake.RespondContent("Download", fileMetadata("/notes/notes.txt", 11), []byte("remote edit"), nil)
+	setFlags(t, syncBothCmd, map[string]string{"state": stateFile})
+
+	if _, stderr, err := testutil.Capture(func() error {
+		return syncBoth(syncBothCmd, []string{dir, "/notes"})
+	}); err != nil {
+		t.Fatalf("sync both failed: %v\n%s", err, stderr)
+	}
+
+	commit := fake.Calls()[1].Arg.(*files.CommitInfo)
+	if commit.Mode.Tag != files.WriteModeUpdate || commit.Mode.Update != "r1" || !commit.Autorename {
+		t.Errorf("uploaded with mode %+v, autorename %v; want an update of r1 with autorename", commit.Mode, commit.Autorename)
+	}
+	for name, want := range map[string]string{"notes.txt": "remote edit", "notes (conflicted copy).txt": "local edit"} {
+		if b, err := ioutil.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v; want %q", name, b, err, want)
+		}
+	}
+
+	got, err := readSyncState(stateFile, dir, "/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cursor != "" {
+		t.Errorf("cursor %q was kept after changing the remote folder", got.Cursor)
+	}
+	var keys []string
+	for k := range got.Entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if want := []string{"notes (conflicted copy).txt", "notes.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("state has %v, want %v", keys, want)
+	}
+	if e := got.Entries["notes.txt"]; e.Rev != "r1" {
+		t.Errorf("notes.txt was recorded as %+v, want it left at r1 for the next run to reconcile", e)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".dbxcli-notes.txt.part")); !os.IsNotExist(err) {
+		t.Error("the partial download was left behind")
+	}
+}
+
+func TestPlanBoth(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  map[string]string
+		remote map[string]string
+		// What was synced last time; nil if nothing ever was.
+		state map[string]string
+		want  []string
+		// The entries of the state once the plan is carried out.
+		next []string
+	}{
+		{
+			name:   "first sync copies both ways and deletes nothing",
+			local:  map[string]string{"mine.txt": "m", "same.txt": "s"},
+			remote: map[string]string{"theirs.txt": "t", "same.txt": "s"},
+			want:   []string{"download theirs.txt", "upload mine.txt (new)"},
+			next:   []string{"mine.txt", "same.txt"},
+		},
+		{
+			name:   "first sync treats differing files as conflicts",
+			local:  map[string]string{"a.txt": "mine"},
+			remote: map[string]string{"a.txt": "theirs"},
+			want:   []string{"conflict a.txt -> a (conflicted copy).txt", "download a.txt", "upload a (conflicted copy).txt (new)"},
+			next:   []string{"a (conflicted copy).txt"},
+		},
+		{
+			name:   "a local edit is uploaded over the listed revision",
+			local:  map[string]string{"a.txt": "new"},
+			remote: map[string]string{"a.txt": "old"},
+			state:  map[string]string{"a.txt": "old"},
+			want:   []string{"upload a.txt (over rev-old)"},
+			next:   []string{"a.txt"},
+		},
+		{
+			name:   "a remote edit is downloaded",
+			local:  map[string]string{"a.txt": "old"},
+			remote: map[string]string{"a.txt": "new"},
+			state:  map[string]string{"a.txt": "old"},
+			want:   []string{"download a.txt"},
+		},
+		{
+			name:   "unchanged files are recorded with their revision",
+			local:  map[string]string{"a.txt": "a"},
+			remote: map[string]string{"a.txt": "a"},
+			state:  map[string]string{"a.txt": "a"},
+			next:   []string{"a.txt"},
+		},
+		{
+			name:   "the same edit on both sides is no conflict",
+			local:  map[string]string{"a.txt": "new"},
+			remote: map[string]string{"a.txt": "new"},
+			state:  map[string]string{"a.txt": "old"},
+			next:   []string{"a.txt"},
+		},
+		{
+			name:   "different edits on both sides conflict",
+			local:  map[string]string{"a.txt": "mine"},
+			remote: map[string]string{"a.txt": "theirs", "a (conflicted copy).txt": "x"},
+			state:  map[string]string{"a.txt": "old"},
+			want:   []string{"conflict a.txt -> a (conflicted copy 2).txt", "download a (conflicted copy).txt", "download a.txt", "upload a (conflicted copy 2).txt (new)"},
+			next:   []string{"a (conflicted copy 2).txt"},
+		},
+		{
+			name:   "deletions are repeated on the other side",
+			local:  map[string]string{"theirs.txt": "t"},
+			remote: map[string]string{"mine.txt": "m"},
+			state:  map[string]string{"mine.txt": "m", "theirs.txt": "t"},
+			want:   []string{"delete local theirs.txt", "delete remote mine.txt"},
+		},
+		{
+			name:   "files deleted on both sides are forgotten",
+			local:  map[string]string{},
+			remote: map[string]string{},
+			state:  map[string]string{"gone.txt": "g"},
+		},
+		{
+			name:   "a local edit wins over a remote deletion",
+			local:  map[string]string{"a.txt": "new"},
+			remote: map[string]string{},
+			state:  map[string]string{"a.txt": "old"},
+			want:   []string{"upload a.txt (new)"},
+			next:   []string{"a.txt"},
+		},
+		{
+			name:   "a remote edit wins over a local deletion",
+			local:  map[string]string{},
+			remote: map[string]string{"a.txt": "new"},
+			state:  map[string]string{"a.txt": "old"},
+			want:   []string{"download a.txt"},
+		},
+		{
+			name:   "a folder deleted remotely is deleted locally as a whole",
+			local:  map[string]string{"d/": "", "d/a.txt": "a", "d/e/": "", "d/e/b.txt": "b"},
+			remote: map[string]string{},
+			state:  map[string]string{"d/": "", "d/a.txt": "a", "d/e/": "", "d/e/b.txt": "b"},
+			want:   []string{"delete local d"},
+		},
+		{
+			name:   "a folder deleted remotely survives a new local file in it",
+			local:  map[string]string{"d/": "", "d/a.txt": "a", "d/new.txt": "n"},
+			remote: map[string]string{},
+			state:  map[string]string{"d/": "", "d/a.txt": "a"},
+			want:   []string{"delete local d/a.txt", "mkdir remote d", "upload d/new.txt (new)"},
+			next:   []string{"d", "d/new.txt"},
+		},
+		{
+			name:   "a folder deleted locally survives a remote edit in it",
+			local:  map[string]string{},
+			remote: map[string]string{"d/": "", "d/a.txt": "edited", "d/b.txt": "b"},
+			state:  map[string]string{"d/": "", "d/a.txt": "a", "d/b.txt": "b"},
+			want:   []string{"delete remote d/b.txt", "download d/a.txt", "mkdir local d"},
+			next:   []string{"d"},
+		},
+		{
+			name:   "a folder deleted locally is deleted remotely as a whole",
+			local:  map[string]string{},
+			remote: map[string]string{"d/": "", "d/a.txt": "a"},
+			state:  map[string]string{"d/": "", "d/a.txt": "a"},
+			want:   []string{"delete remote d"},
+		},
+		{
+			name:   "new folders are made on the other side",
+			local:  map[string]string{"mine/": ""},
+			remote: map[string]string{"theirs/": ""},
+			want:   []string{"mkdir local theirs", "mkdir remote mine"},
+			next:   []string{"mine", "theirs"},
+		},
+		{
+			name:   "a file on one side and a folder on the other is left alone",
+			local:  map[string]string{"x": "file"},
+			remote: map[string]string{"x/": ""},
+			state:  map[string]string{"x": "file"},
+			want:   []string{"blocked"},
+			next:   []string{"x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, local := localSyncTree(t, tt.local)
+			remote := remoteSyncTree(t, "/dst", tt.remote)
+			st := &syncState{Version: syncStateVersion, Local: root, Remote: "/dst", Entries: map[string]syncedEntry{}}
+			for rel, content := range tt.state {
+				if strings.HasSuffix(rel, "/") {
+					rel = strings.TrimSuffix(rel, "/")
+					st.Entries[syncKey(rel)] = syncedEntry{Rel: rel, Dir: true}
+					continue
+				}
+				st.Entries[syncKey(rel)] = syncedEntry{Rel: rel, Hash: hashOf(t, content), Rev: "rev-" + content}
+			}
+
+			plan, err := planBoth(local, remote, st, root, "/dst")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, job := range plan.uploads {
+				rev := "(new)"
+				if r := plan.revs[job.dst]; r != "" {
+					rev = "(over " + r + ")"
+				}
+				got = append(got, fmt.Sprintf("upload %s %s", relTo("/dst", job.dst), rev))
+			}
+			for _, job := range plan.downloads {
+				got = append(got, "download "+relTo(root, job.dst))
+			}
+			for _, c := range plan.conflicts {
+				got = append(got, fmt.Sprintf("conflict %s -> %s", relTo(root, c.local), relTo(root, c.copyPath)))
+			}
+			for _, dir := range plan.localMkdirs {
+				got = append(got, "mkdir local "+relTo(root, dir))
+			}
+			for _, dir := range plan.remoteMkdirs {
+				got = append(got, "mkdir remote "+relTo("/dst", dir.dst))
+			}
+			for _, p := range plan.localDeletes {
+				got = append(got, "delete local "+relTo(root, p))
+			}
+			for _, p := range plan.remoteDeletes {
+				got = append(got, "delete remote "+relTo("/dst", p))
+			}
+			for range plan.blocked {
+				got = append(got, "blocked")
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %q, want %q", got, tt.want)
+			}
+
+			// Downloads are recorded once they're done.
+			var next []string
+			for key := range plan.next {
+				next = append(next, key)
+			}
+			sort.Strings(next)
+			if !reflect.DeepEqual(next, tt.next) {
+				t.Errorf("next state = %q, want %q", next, tt.next)
+			}
+			for key, e := range plan.next {
+				if r, ok := remote[key]; ok && !e.Dir && e.Hash == r.hash && e.Rev != r.rev() {
+					t.Errorf("%s is recorded at %q, want %q", key, e.Rev, r.rev())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// One file `sync pull` downloads.
+type pullJob struct {
+	src    string
+	dst    string
+	update bool
+}
+
+// What `sync pull` does to bring the local directory in line.
+type pullPlan struct {
+	downloads []pullJob
+	updates   int
+	mkdirs    []string
+	deletes   []string
+	unchanged int
+	// Entries which can't be synced, such as a remote file where the local
+	// side has a directory.
+	blocked []error
+}
+
+// Compares the two trees and decides what to download, create and (with
+// `deleteExtra`) delete. Local files are only hashed when the remote file
+// has the same size.
+func planPull(remote map[string]remoteEntry, local map[string]localEntry, remoteRoot string, localRoot string, deleteExtra bool) (*pullPlan, error) {
+	plan := new(pullPlan)
+	keys := make(map[string]bool)
+	for k := range remote {
+		keys[k] = true
+	}
+
+	dsts := make(map[string]string)
+	for _, key := range syncKeys(keys) {
+		r := remote[key]
+		l, exists := local[key]
+		dst := localPathFor(dsts, local, localRoot, key, r.rel)
+		switch {
+		case r.isDir() && exists && !l.info.IsDir():
+			plan.blocked = append(plan.blocked, fmt.Errorf("%s: a file is in the way at %s", remoteRoot+"/"+r.rel, dst))
+		case r.isDir():
+			if !exists {
+				plan.mkdirs = append(plan.mkdirs, dst)
+			}
+		case exists && l.info.IsDir():
+			plan.blocked = append(plan.blocked, fmt.Errorf("%s: a directory is in the way at %s", remoteRoot+"/"+r.rel, dst))
+		default:
+			job := pullJob{src: metadataPath(r.md), dst: dst}
+			if exists {
+				same, err := sameContent(l.path, l.info.Size(), r.md, r.hash)
+				if err != nil {
+					return nil, err
+				}
+				if same {
+					plan.unchanged++
+					continue
+				}
+				job.update = true
+				plan.updates++
+			}
+			plan.downloads = append(plan.downloads, job)
+		}
+	}
+
+	if deleteExtra {
+		extra := make(map[string]bool)
+		for k := range local {
+			if _, ok := remote[k]; !ok {
+				extra[k] = true
+			}
+		}
+		// Deleting a directory deletes everything in it.
+		for _, key := range syncKeys(extra) {
+			if !parentIn(extra, key) {
+				plan.deletes = append(plan.deletes, local[key].path)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Lists what the plan changes, for --dry-run and --check.
+func (plan *pullPlan) changes() (changes []plannedChange) {
+	for _, dir := range plan.mkdirs {
+		changes = append(changes, plannedChange{Dst: dir, Action: "mkdir"})
+	}
+	for _, job := range plan.downloads {
+		action := "download"
+		if job.update {
+			action = "update"
+		}
+		changes = append(changes, plannedChange{Src: job.src, Dst: job.dst, Action: action})
+	}
+	for _, p := range plan.deletes {
+		changes = append(changes, plannedChange{Dst: p, Action: "delete"})
+	}
+	return
+}
+
+// Downloads next to `job.dst` and renames the file into place, so a failed
+// update leaves the old copy alone. Returns the size of the file.
+func pullFile(dbx files.Client, job pullJob) (size uint64, err error) {
+	res, contents, err := dbx.Download(files.NewDownloadArg(job.src))
+	if err != nil {
+		return
+	}
+	defer contents.Close()
+
+	tmp := filepath.Join(filepath.Dir(job.dst), ".dbxcli-"+filepath.Base(job.dst)+".part")
+	if err = writeDownload(cmdCtx, tmp, contents, res.Size, decryptOptions{}); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	return res.Size, os.Rename(tmp, job.dst)
+}
+
+func syncPull(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("`sync pull` requires `remote` and `local` arguments")
+	}
+	remoteRoot, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	localRoot := filepath.Clean(args[1])
+	deleteExtra, _ := cmd.Flags().GetBool("delete")
+
+	remote, err := listRemoteTree(cmdCtx, remoteRoot)
+	if err != nil {
+		return
+	}
+	local := map[string]localEntry{}
+	if _, statErr := os.Stat(localRoot); !os.IsNotExist(statErr) {
+		if local, err = listLocalTree(localRoot); err != nil {
+			return
+		}
+	}
+	filter, err := newPathFilter(cmd)
+	if err == nil {
+		filter, err = filter.withIgnoreFile(localRoot)
+	}
+	if err != nil {
+		return
+	}
+	filterSyncTrees(filter, local, remote)
+	plan, err := planPull(remote, local, remoteRoot, localRoot, deleteExtra)
+	if err != nil {
+		return
+	}
+	for _, err := range plan.blocked {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if check, _ := cmd.Flags().GetBool("check"); check {
+		return checkSync(cmd, plan.changes(), plan.blocked)
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		if err = listChanges(cmd, plan.changes()); err != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Would download %d new, update %d, create %d directories and delete %d; %d unchanged\n",
+			len(plan.downloads)-plan.updates, plan.updates, len(plan.mkdirs), len(plan.deletes), plan.unchanged)
+		return
+	}
+
+	if err = os.MkdirAll(localRoot, 0755); err != nil {
+		return
+	}
+	for _, dir := range plan.mkdirs {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+	}
+
+	failed := len(plan.blocked)
+	dbx := newFilesClient(cmdCtx)
+	for _, job := range plan.downloads {
+		size, err := pullFile(dbx, job)
+		if err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: download failed: %s\n", job.src, explainError(err))
+			failed++
+		}
+		switch {
+		case jsonMode(cmd):
+			rec := downloadRecord{Src: job.src, Dst: job.dst, Size: size}
+			if err != nil {
+				f := errorFields(err)
+				rec.Error = &f
+			}
+			printJSONLine(rec)
+		case err == nil:
+			fmt.Printf("%s -> %s\n", job.src, job.dst)
+		}
+	}
+	deleted := 0
+	for _, p := range plan.deletes {
+		err := os.RemoveAll(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed++
+		} else {
+			deleted++
+		}
+		printDeleted(cmd, p, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Downloaded %d new, updated %d, created %d directories, deleted %d; %d unchanged, %d failed\n",
+		len(plan.downloads)-plan.updates, plan.updates, len(plan.mkdirs), deleted, plan.unchanged, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d changes failed", failed)
+	}
+	return nil
+}
+
+// syncPullCmd represents the sync pull command
+var syncPullCmd = &cobra.Command{
+	Use:   "pull [flags] <remote> <local>",
+	Short: "Mirror a Dropbox folder to a local directory",
+	Long: `Make the local directory <local> a copy of the Dropbox folder <remote>.
+
+Files that are new or whose contents differ (by Dropbox content hash) are
+downloaded, replacing the local ones only once the download is complete;
+unchanged files are left alone. Folders are created, empty ones included.
+With --delete, files and directories under <local> that don't exist under
+<remote> are deleted too.
+
+A summary of what was done is printed at the end. Use --dry-run to only list
+the changes. --check lists them too, and exits with status 0 if there are
+none, 1 if there are some and more than 1 on errors; see "put --help".
+
+With --json, the changes listed by --dry-run and --check are a JSON list,
+and each download or deletion is a line of JSON as it's made, like "get
+--json" prints for downloads.`,
+	Example: `  dbxcli sync pull /Backup/Documents ~/Documents
+  dbxcli sync pull --delete --dry-run /Photos ~/Photos`,
+	RunE: syncPull,
+}
+
+func init() {
+	syncCmd.AddCommand(syncPullCmd)
+	syncPullCmd.Flags().Bool("delete", false, "Delete local files and directories that don't exist on Dropbox")
+	addFilterFlags(syncPullCmd)
+	syncPullCmd.Flags().Bool("dry-run", false, "Only list what would change")
+	addCheckFlags(syncPullCmd)
+	syncPullCmd.Flags().String("preset", "", "Use the flag values of this preset from the config file")
+}
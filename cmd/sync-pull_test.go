@@ -0,0 +1,159 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestPlanPull(t *testing.T) {
+	tests := []struct {
+		name        string
+		remote      map[string]string
+		local       map[string]string
+		deleteExtra bool
+		want        []string
+		unchanged   int
+		blocked     int
+	}{
+		{
+			name:   "new files are downloaded and their folders made",
+			remote: map[string]string{"a.txt": "a", "docs/": "", "docs/b.txt": "b"},
+			local:  map[string]string{},
+			want:   []string{"download a.txt", "download docs/b.txt", "mkdir docs"},
+		},
+		{
+			name:      "files with the same contents are left alone",
+			remote:    map[string]string{"a.txt": "same"},
+			local:     map[string]string{"a.txt": "same"},
+			unchanged: 1,
+		},
+		{
+			name:   "files of the same size are compared by hash",
+			remote: map[string]string{"a.txt": "new"},
+			local:  map[string]string{"a.txt": "old"},
+			want:   []string{"update a.txt"},
+		},
+		{
+			name:   "local names differing in case are kept, for what's beneath them too",
+			remote: map[string]string{"Docs/": "", "Docs/A.txt": "new", "Docs/b.txt": "b"},
+			local:  map[string]string{"docs/a.txt": "old"},
+			want:   []string{"download docs/b.txt", "update docs/a.txt"},
+		},
+		{
+			name:   "existing directories aren't made again",
+			remote: map[string]string{"empty/": ""},
+			local:  map[string]string{"empty/": ""},
+		},
+		{
+			name:    "a file can't replace a directory or the other way round",
+			remote:  map[string]string{"a": "file", "b/": ""},
+			local:   map[string]string{"a/": "", "b": "file"},
+			blocked: 2,
+		},
+		{
+			name:   "extra local files are kept without --delete",
+			remote: map[string]string{},
+			local:  map[string]string{"old.txt": "x", "old/a.txt": "a"},
+		},
+		{
+			name:        "extra local directories are deleted as a whole",
+			remote:      map[string]string{"keep.txt": "k"},
+			local:       map[string]string{"keep.txt": "k", "old.txt": "x", "old/a.txt": "a"},
+			deleteExtra: true,
+			want:        []string{"delete old", "delete old.txt"},
+			unchanged:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, local := localSyncTree(t, tt.local)
+			remote := remoteSyncTree(t, "/src", tt.remote)
+			plan, err := planPull(remote, local, "/src", root, tt.deleteExtra)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, job := range plan.downloads {
+				action := "download "
+				if job.update {
+					action = "update "
+				}
+				got = append(got, action+relTo(root, job.dst))
+			}
+			for _, dir := range plan.mkdirs {
+				got = append(got, "mkdir "+relTo(root, dir))
+			}
+			for _, p := range plan.deletes {
+				got = append(got, "delete "+relTo(root, p))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %q, want %q", got, tt.want)
+			}
+			if plan.unchanged != tt.unchanged || len(plan.blocked) != tt.blocked {
+				t.Errorf("%d unchanged and %d blocked, want %d and %d", plan.unchanged, len(plan.blocked), tt.unchanged, tt.blocked)
+			}
+			updates := 0
+			for _, job := range plan.downloads {
+				if job.update {
+					updates++
+				}
+			}
+			if plan.updates != updates {
+				t.Errorf("%d updates counted, want %d", plan.updates, updates)
+			}
+		})
+	}
+}
+
+// With --json, each download and deletion is a line of JSON.
+func TestSyncPullJSON(t *testing.T) {
+	root, _ := localSyncTree(t, map[string]string{"extra.txt": "x"})
+	api := useFakeAPI(t)
+	api.Respond("files/list_folder", 200, `{"entries": [{".tag": "file", "name": "a.txt", "path_lower": "/src/a.txt",
+		"path_display": "/src/a.txt", "id": "id:a", "rev": "015", "size": 2, "client_modified": "2016-08-01T00:00:00Z",
+		"server_modified": "2016-08-01T00:00:00Z", "content_hash": "h"}], "cursor": "c1", "has_more": false}`)
+	fake := useFakeFiles(t)
+	fake.RespondContent("Download", fileMetadata("/src/a.txt", 2), []byte("hi"), nil)
+	setFlags(t, syncPullCmd, map[string]string{"json": "true", "delete": "true"})
+
+	stdout, stderr, err := testutil.Capture(func() error { return syncPull(syncPullCmd, []string{"/src", root}) })
+	if err != nil {
+		t.Fatalf("sync pull failed: %v\n%s", err, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("printed %q", stdout)
+	}
+	var download downloadRecord
+	var deletion deleteRecord
+	json.Unmarshal([]byte(lines[0]), &download)
+	json.Unmarshal([]byte(lines[1]), &deletion)
+	if want := (downloadRecord{Src: "/src/a.txt", Dst: filepath.Join(root, "a.txt"), Size: 2}); !reflect.DeepEqual(download, want) {
+		t.Errorf("download = %+v, want %+v", download, want)
+	}
+	if want := (deleteRecord{Path: filepath.Join(root, "extra.txt"), Outcome: "deleted"}); !reflect.DeepEqual(deletion, want) {
+		t.Errorf("deletion = %+v, want %+v", deletion, want)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// What `sync push` does to bring the remote folder in line.
+type pushPlan struct {
+	uploads []uploadJob
+	// The local paths of uploads which replace a remote file.
+	updates   map[string]bool
+	mkdirs    []uploadJob
+	deletes   []string
+	unchanged int
+	// Entries which can't be synced, such as a local file where the remote
+	// side has a folder.
+	blocked []error
+}
+
+// Compares the two trees and decides what to upload, create and (with
+// `deleteExtra`) delete. Local files are only hashed when the remote file
+// has the same size.
+func planPush(local map[string]localEntry, remote map[string]remoteEntry, remoteRoot string, deleteExtra bool) (*pushPlan, error) {
+	plan := &pushPlan{updates: make(map[string]bool)}
+	keys := make(map[string]bool)
+	for k := range local {
+		keys[k] = true
+	}
+
+	// Folders only need creating if no upload creates them along the way.
+	covered := make(map[string]bool)
+	for _, key := range syncKeys(keys) {
+		l := local[key]
+		r, exists := remote[key]
+		dst := remoteRoot + "/" + l.rel
+		switch {
+		case l.info.IsDir() && exists && !r.isDir():
+			plan.blocked = append(plan.blocked, fmt.Errorf("%s: a file is in the way at %s", l.path, dst))
+		case l.info.IsDir():
+		case exists && r.isDir():
+			plan.blocked = append(plan.blocked, fmt.Errorf("%s: a folder is in the way at %s", l.path, dst))
+		default:
+			if exists {
+				same, err := sameContent(l.path, l.info.Size(), r.md, r.hash)
+				if err != nil {
+					return nil, err
+				}
+				if same {
+					plan.unchanged++
+					continue
+				}
+				plan.updates[l.path] = true
+			}
+			plan.uploads = append(plan.uploads, uploadJob{l.path, dst})
+			for k := key; strings.Contains(k, "/"); {
+				k = k[:strings.LastIndex(k, "/")]
+				covered[k] = true
+			}
+		}
+	}
+	for _, key := range syncKeys(keys) {
+		l := local[key]
+		if _, exists := remote[key]; l.info.IsDir() && !exists && !covered[key] {
+			plan.mkdirs = append(plan.mkdirs, uploadJob{l.path, remoteRoot + "/" + l.rel})
+		}
+	}
+
+	if deleteExtra {
+		extra := make(map[string]bool)
+		for k := range remote {
+			if _, ok := local[k]; !ok {
+				extra[k] = true
+			}
+		}
+		// Deleting a folder deletes everything in it.
+		for _, key := range syncKeys(extra) {
+			if !parentIn(extra, key) {
+				plan.deletes = append(plan.deletes, remoteRoot+"/"+remote[key].rel)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Lists what the plan changes, for --dry-run and --check.
+func (plan *pushPlan) changes() (changes []plannedChange) {
+	for _, job := range plan.uploads {
+		action := "upload"
+		if plan.updates[job.src] {
+			action = "update"
+		}
+		changes = append(changes, plannedChange{Src: job.src, Dst: job.dst, Action: action})
+	}
+	for _, dir := range plan.mkdirs {
+		changes = append(changes, plannedChange{Dst: dir.dst, Action: "mkdir"})
+	}
+	for _, p := range plan.deletes {
+		changes = append(changes, plannedChange{Dst: p, Action: "delete"})
+	}
+	return
+}
+
+// Deletes `paths` in batches and prints each deletion. Returns the paths
+// which couldn't be deleted.
+func deleteAll(cmd *cobra.Command, paths []string) (failed []string, err error) {
+	for len(paths) > 0 {
+		n := len(paths)
+		if n > maxBatchEntries {
+			n = maxBatchEntries
+		}
+		errs, err := deleteBatch(cmdCtx, paths[:n])
+		if err != nil {
+			return failed, err
+		}
+		for i, p := range paths[:n] {
+			if errs[i] != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", p, errs[i])
+				failed = append(failed, p)
+			}
+			printDeleted(cmd, p, errs[i])
+		}
+		paths = paths[n:]
+	}
+	return
+}
+
+func syncPush(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("`sync push` requires `local` and `remote` arguments")
+	}
+	remoteRoot, err := validatePath(args[1])
+	if err != nil {
+		return
+	}
+	chunkSizeFlag, _ := cmd.Flags().GetString("chunk-size")
+	opts := uploadOptions{strategy: conflictOverwrite, chunkParallelism: 1}
+	if opts.chunkSize, err = parseChunkSize(chunkSizeFlag); err != nil {
+		return
+	}
+	deleteExtra, _ := cmd.Flags().GetBool("delete")
+
+	local, err := listLocalTree(args[0])
+	if err != nil {
+		return
+	}
+	remote, err := listRemoteTree(cmdCtx, remoteRoot)
+	if err != nil {
+		return
+	}
+	filter, err := newPathFilter(cmd)
+	if err == nil {
+		filter, err = filter.withIgnoreFile(args[0])
+	}
+	if err != nil {
+		return
+	}
+	filterSyncTrees(filter, local, remote)
+	plan, err := planPush(local, remote, remoteRoot, deleteExtra)
+	if err != nil {
+		return
+	}
+	for _, err := range plan.blocked {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if check, _ := cmd.Flags().GetBool("check"); check {
+		return checkSync(cmd, plan.changes(), plan.blocked)
+	}
+
+	if len(plan.deletes) > 0 {
+		s, err := readSettings()
+		if err != nil {
+			return err
+		}
+		for _, p := range plan.deletes {
+			if err = checkProtected(s, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		if err = listChanges(cmd, plan.changes()); err != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Would upload %d new, update %d, create %d folders and delete %d; %d unchanged\n",
+			len(plan.uploads)-len(plan.updates), len(plan.updates), len(plan.mkdirs), len(plan.deletes), plan.unchanged)
+		return
+	}
+
+	failed := len(plan.blocked)
+	dbx := newFilesClient(cmdCtx)
+	if len(plan.uploads) > 0 {
+		parallelism, _ := cmd.Flags().GetInt("parallel")
+		failures, _ := printOrderedResults(os.Stdout, uploadAll(cmdCtx, dbx, plan.uploads, opts, parallelism), jsonMode(cmd))
+		failed += len(failures)
+	}
+	failed += createFolders(dbx, plan.mkdirs, jsonMode(cmd))
+	deleted := 0
+	if len(plan.deletes) > 0 {
+		var notDeleted []string
+		if notDeleted, err = deleteAll(cmd, plan.deletes); err != nil {
+			return
+		}
+		failed += len(notDeleted)
+		deleted = len(plan.deletes) - len(notDeleted)
+	}
+
+	fmt.Fprintf(os.Stderr, "Uploaded %d new, updated %d, created %d folders, deleted %d; %d unchanged, %d failed\n",
+		len(plan.uploads)-len(plan.updates), len(plan.updates), len(plan.mkdirs), deleted, plan.unchanged, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d changes failed", failed)
+	}
+	return
+}
+
+// syncPushCmd represents the sync push command
+var syncPushCmd = &cobra.Command{
+	Use:   "push [flags] <local> <remote>",
+	Short: "Mirror a local directory to Dropbox",
+	Long: `Make the Dropbox folder <remote> a copy of the local directory <local>.
+
+Files that are new or whose contents differ (by Dropbox content hash) are
+uploaded, replacing the remote ones; unchanged files are left alone, so
+running the same sync again is cheap. Empty directories become empty
+folders. With --delete, files and folders under <remote> that don't exist
+under <local> are deleted too, except protected paths (see "dbxcli config").
+
+A summary of what was done is printed at the end. Use --dry-run to only list
+the changes. --check lists them too, and exits with status 0 if there are
+none, 1 if there are some and more than 1 on errors; see "put --help".`,
+	Example: `  dbxcli sync push ~/Documents /Backup/Documents
+  dbxcli sync push --delete --dry-run ~/Photos /Photos`,
+	RunE: syncPush,
+}
+
+func init() {
+	syncCmd.AddCommand(syncPushCmd)
+	syncPushCmd.Flags().Bool("delete", false, "Delete remote files and folders that don't exist locally")
+	addFilterFlags(syncPushCmd)
+	syncPushCmd.Flags().Bool("dry-run", false, "Only list what would change")
+	addCheckFlags(syncPushCmd)
+	syncPushCmd.Flags().Int("parallel", defaultTransfers, "Number of files to upload at once (0 means all of them); also --transfers")
+	syncPushCmd.Flags().SetNormalizeFunc(flagAliases(map[string]string{"transfers": "parallel"}))
+	syncPushCmd.Flags().String("chunk-size", "16M", "Size of the chunks large files are uploaded in, up to 150M")
+	syncPushCmd.Flags().String("preset", "", "Use the flag values of this preset from the config file")
+}
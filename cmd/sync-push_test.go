@@ -0,0 +1,151 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func TestPlanPush(t *testing.T) {
+	tests := []struct {
+		name        string
+		local       map[string]string
+		remote      map[string]string
+		deleteExtra bool
+		want        []string
+		unchanged   int
+		blocked     int
+	}{
+		{
+			name:   "new files are uploaded",
+			local:  map[string]string{"a.txt": "a", "docs/b.txt": "b"},
+			remote: map[string]string{},
+			want:   []string{"upload a.txt", "upload docs/b.txt"},
+		},
+		{
+			name:      "files with the same contents are left alone",
+			local:     map[string]string{"a.txt": "same"},
+			remote:    map[string]string{"a.txt": "same"},
+			unchanged: 1,
+		},
+		{
+			name:   "files of the same size are compared by hash",
+			local:  map[string]string{"a.txt": "new"},
+			remote: map[string]string{"a.txt": "old"},
+			want:   []string{"update a.txt"},
+		},
+		{
+			name:   "names differing in case are the same file",
+			local:  map[string]string{"README.md": "new"},
+			remote: map[string]string{"readme.md": "older"},
+			want:   []string{"update README.md"},
+		},
+		{
+			name:   "only folders no upload creates are made",
+			local:  map[string]string{"empty/": "", "full/a.txt": "a"},
+			remote: map[string]string{},
+			want:   []string{"mkdir empty", "upload full/a.txt"},
+		},
+		{
+			name:   "existing folders aren't made again",
+			local:  map[string]string{"empty/": ""},
+			remote: map[string]string{"empty/": ""},
+		},
+		{
+			name:    "a file can't replace a folder or the other way round",
+			local:   map[string]string{"a": "file", "b/": ""},
+			remote:  map[string]string{"a/": "", "b": "file"},
+			blocked: 2,
+		},
+		{
+			name:   "extra remote files are kept without --delete",
+			local:  map[string]string{},
+			remote: map[string]string{"old.txt": "x", "old/": "", "old/a.txt": "a"},
+		},
+		{
+			name:        "extra remote folders are deleted as a whole",
+			local:       map[string]string{"keep.txt": "k"},
+			remote:      map[string]string{"keep.txt": "k", "old.txt": "x", "old/": "", "old/a.txt": "a"},
+			deleteExtra: true,
+			want:        []string{"delete old", "delete old.txt"},
+			unchanged:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, local := localSyncTree(t, tt.local)
+			remote := remoteSyncTree(t, "/dst", tt.remote)
+			plan, err := planPush(local, remote, "/dst", tt.deleteExtra)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, job := range plan.uploads {
+				action := "upload "
+				if plan.updates[job.src] {
+					action = "update "
+				}
+				if src := relTo(root, job.src); src != relTo("/dst", job.dst) {
+					t.Errorf("%s is uploaded to %s", job.src, job.dst)
+				}
+				got = append(got, action+relTo(root, job.src))
+			}
+			for _, dir := range plan.mkdirs {
+				got = append(got, "mkdir "+relTo("/dst", dir.dst))
+			}
+			for _, p := range plan.deletes {
+				got = append(got, "delete "+relTo("/dst", p))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %q, want %q", got, tt.want)
+			}
+			if plan.unchanged != tt.unchanged || len(plan.blocked) != tt.blocked {
+				t.Errorf("%d unchanged and %d blocked, want %d and %d", plan.unchanged, len(plan.blocked), tt.unchanged, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestSyncPushCheck(t *testing.T) {
+	root, _ := localSyncTree(t, map[string]string{"a.txt": "a"})
+	api := useFakeAPI(t)
+	api.Respond("files/list_folder", 200, `{"entries": [], "cursor": "c1", "has_more": false}`)
+	setFlags(t, syncPushCmd, map[string]string{"check": "true"})
+
+	stdout, _, err := testutil.Capture(func() error { return syncPush(syncPushCmd, []string{root, "/dst"}) })
+	if err != exitStatus(1) {
+		t.Errorf("sync push --check returned %v, want exit status 1", err)
+	}
+	if want := "upload " + filepath.Join(root, "a.txt") + " -> /dst/a.txt\n"; stdout != want {
+		t.Errorf("printed %q, want %q", stdout, want)
+	}
+	if routes := api.Routes(); !reflect.DeepEqual(routes, []string{"files/list_folder"}) {
+		t.Errorf("called %v, want only the listing", routes)
+	}
+
+	empty, _ := localSyncTree(t, nil)
+	api.Respond("files/list_folder", 200, `{"entries": [], "cursor": "c1", "has_more": false}`)
+	stdout, _, err = testutil.Capture(func() error { return syncPush(syncPushCmd, []string{empty, "/dst"}) })
+	if err != nil || stdout != "" {
+		t.Errorf("checking an empty tree printed %q, %v", stdout, err)
+	}
+}
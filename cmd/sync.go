@@ -0,0 +1,274 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// One file or folder beneath the remote root of a sync. `rel` is its path
+// relative to the root, with slashes.
+type remoteEntry struct {
+	rel  string
+	md   files.IsMetadata
+	hash string
+}
+
+func (e remoteEntry) isDir() bool {
+	_, ok := e.md.(*files.FolderMetadata)
+	return ok
+}
+
+// The revision of a remote file, or "" for a folder or a missing entry.
+func (e remoteEntry) rev() string {
+	if f, ok := e.md.(*files.FileMetadata); ok {
+		return f.Rev
+	}
+	return ""
+}
+
+// One file or directory beneath the local root of a sync.
+type localEntry struct {
+	rel  string
+	path string
+	info os.FileInfo
+}
+
+// Sync trees are keyed by relative path in lower case, since that's how
+// Dropbox tells names apart.
+func syncKey(rel string) string {
+	return strings.ToLower(rel)
+}
+
+// Returns the keys of a sync tree in order, so parents come before their
+// children.
+func syncKeys(keys map[string]bool) []string {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Whether the parent of `key` is also in `keys`.
+func parentIn(keys map[string]bool, key string) bool {
+	i := strings.LastIndex(key, "/")
+	return i >= 0 && keys[key[:i]]
+}
+
+// Returns where the remote entry `key` at `rel` goes locally, and records it
+// in `dsts`. Local names that only differ in case are kept, for the entries
+// beneath them too, so keys must be visited parents first.
+func localPathFor(dsts map[string]string, local map[string]localEntry, localRoot string, key string, rel string) string {
+	dst := filepath.Join(localRoot, filepath.FromSlash(rel))
+	if i := strings.LastIndex(key, "/"); i >= 0 && dsts[key[:i]] != "" {
+		dst = filepath.Join(dsts[key[:i]], path.Base(rel))
+	}
+	if l, ok := local[key]; ok {
+		dst = l.path
+	}
+	dsts[key] = dst
+	return dst
+}
+
+// Lists everything beneath the remote folder `root` with content hashes. A
+// root that doesn't exist yet is empty.
+func listRemoteTree(ctx context.Context, root string) (map[string]remoteEntry, error) {
+	entries, extras, err := listFolderExtras(ctx, root, true)
+	if isMissing(err) {
+		return map[string]remoteEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.ToLower(root) + "/"
+	tree := make(map[string]remoteEntry)
+	for _, md := range entries {
+		var lower string
+		switch m := md.(type) {
+		case *files.FileMetadata:
+			lower = m.PathLower
+		case *files.FolderMetadata:
+			lower = m.PathLower
+		default:
+			continue
+		}
+		if !strings.HasPrefix(lower, prefix) {
+			if strings.EqualFold(lower, root) {
+				if _, isFile := md.(*files.FileMetadata); isFile {
+					return nil, fmt.Errorf("%s is a file, not a folder", root)
+				}
+			}
+			continue
+		}
+		display := metadataPath(md)
+		rel := lower[len(prefix):]
+		if len(display) == len(lower) {
+			rel = display[len(prefix):]
+		}
+		tree[syncKey(rel)] = remoteEntry{rel: rel, md: md, hash: extras[lower].ContentHash}
+	}
+	return tree, nil
+}
+
+// Lists the files and directories beneath the local directory `root`.
+// Symlinks to files are followed, but not symlinks to directories, which
+// could loop, and special files such as sockets are left out, with a notice
+// on stderr for each.
+func listLocalTree(root string) (map[string]localEntry, error) {
+	return walkLocalTree(root, os.Stderr)
+}
+
+// Does the work of listLocalTree, writing the notices about skipped files to
+// `notices`.
+func walkLocalTree(root string, notices io.Writer) (map[string]localEntry, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	root = filepath.Clean(root)
+	tree := make(map[string]localEntry)
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			if target.IsDir() {
+				fmt.Fprintf(notices, "Skipping %s: symlink to a directory\n", p)
+				return nil
+			}
+			info = target
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			fmt.Fprintf(notices, "Skipping %s: not a regular file\n", p)
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		tree[syncKey(rel)] = localEntry{rel: rel, path: p, info: info}
+		return nil
+	})
+	return tree, err
+}
+
+// One line of JSON output for a deletion. Outcome is "deleted" or "failed".
+type deleteRecord struct {
+	Path    string           `json:"path"`
+	Outcome string           `json:"outcome"`
+	Error   *jsonErrorFields `json:"error,omitempty"`
+}
+
+// One line of JSON output for a move. Outcome is "moved", "archived" for
+// something moved out of the way into the archive folder, or "failed".
+type moveRecord struct {
+	Src     string           `json:"src"`
+	Dst     string           `json:"dst"`
+	Outcome string           `json:"outcome"`
+	Error   *jsonErrorFields `json:"error,omitempty"`
+}
+
+// Reports the move of `src` to `dst` with `outcome`, or its failure if `err`
+// isn't nil. As with printDeleted, failures are only printed in JSON mode.
+func printMoved(cmd *cobra.Command, src string, dst string, outcome string, err error) {
+	switch {
+	case jsonMode(cmd):
+		rec := moveRecord{Src: src, Dst: dst, Outcome: outcome}
+		if err != nil {
+			f := errorFields(err)
+			rec.Outcome, rec.Error = outcomeFailed, &f
+		}
+		printJSONLine(rec)
+	case err == nil:
+		fmt.Printf("%s -> %s\n", src, dst)
+	}
+}
+
+// Reports the deletion of `p`, which failed if `err` isn't nil. Failures are
+// only printed in JSON mode, since they've already been reported on stderr.
+func printDeleted(cmd *cobra.Command, p string, err error) {
+	switch {
+	case jsonMode(cmd):
+		rec := deleteRecord{Path: p, Outcome: "deleted"}
+		if err != nil {
+			f := errorFields(err)
+			rec.Outcome, rec.Error = outcomeFailed, &f
+		}
+		printJSONLine(rec)
+	case err == nil:
+		fmt.Printf("Deleted %s\n", p)
+	}
+}
+
+// Implements `--check` for the sync commands: lists the changes a sync would
+// make, and exits with status 1 if there are any. Entries which can't be
+// synced, already reported on stderr, count as differences too.
+func checkSync(cmd *cobra.Command, changes []plannedChange, blocked []error) error {
+	err := reportCheck(cmd, changes)
+	if err == nil && len(blocked) > 0 {
+		err = exitStatus(exitDifferences)
+	}
+	return err
+}
+
+// Adds --check and the --quiet that goes with it to a sync command.
+func addCheckFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("check", false, "Only list what would change, and exit with status 1 if anything would")
+	cmd.Flags().BoolP("quiet", "q", false, "Don't list the differences found by --check")
+}
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Mirror folders between this machine and Dropbox",
+	Long: `Mirror folders between this machine and Dropbox.
+
+Every sync command takes --exclude and --include, as "put --recursive" does,
+and reads patterns to exclude from a .dbxignore file at the top of the local
+directory. Excluded files are left alone on both sides: they're neither
+transferred nor deleted.
+
+They also take --preset, which picks a named set of flag values from the
+config file as for put and get; see "dbxcli config --help".`,
+}
+
+func init() {
+	RootCmd.AddCommand(syncCmd)
+}
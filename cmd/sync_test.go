@@ -0,0 +1,91 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Sync trees are written in tests as relative paths mapped to contents, with
+// a trailing slash marking a folder.
+
+// Creates `tree` in a new directory and lists it as a sync would.
+func localSyncTree(t *testing.T, tree map[string]string) (string, map[string]localEntry) {
+	root := t.TempDir()
+	for rel, content := range tree {
+		p := filepath.Join(root, filepath.FromSlash(strings.TrimSuffix(rel, "/")))
+		if strings.HasSuffix(rel, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local, err := walkLocalTree(root, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, local
+}
+
+// Builds the listing of a remote folder `root` holding `tree`. Each file's
+// revision is "rev-" followed by its contents.
+func remoteSyncTree(t *testing.T, root string, tree map[string]string) map[string]remoteEntry {
+	remote := make(map[string]remoteEntry)
+	for rel, content := range tree {
+		if strings.HasSuffix(rel, "/") {
+			rel = strings.TrimSuffix(rel, "/")
+			remote[syncKey(rel)] = remoteEntry{rel: rel, md: folderMetadata(root + "/" + rel)}
+			continue
+		}
+		md := fileMetadata(root+"/"+rel, uint64(len(content)))
+		md.Rev = "rev-" + content
+		remote[syncKey(rel)] = remoteEntry{rel: rel, md: md, hash: hashOf(t, content)}
+	}
+	return remote
+}
+
+// The Dropbox content hash of `content`.
+func hashOf(t *testing.T, content string) string {
+	p := filepath.Join(t.TempDir(), "content")
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := contentHash(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// Returns `p` relative to `root`, with slashes, so plans can be compared
+// regardless of where their trees are.
+func relTo(root string, p string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(p))
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
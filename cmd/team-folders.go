@@ -0,0 +1,199 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/spf13/cobra"
+)
+
+// Covers both the result of team_folder/archive and the status of the job it
+// may start.
+type teamFolderArchiveStatus struct {
+	dropbox.Tagged
+	AsyncJobID string `json:"async_job_id,omitempty"`
+	Failed     *struct {
+		dropbox.Tagged
+	} `json:"failed,omitempty"`
+}
+
+// Finds the team folder named `name`, or with the id `name`.
+func findTeamFolder(folders []teamFolder, name string) (teamFolder, error) {
+	for _, f := range folders {
+		if f.TeamFolderID == name || strings.EqualFold(f.Name, name) {
+			return f, nil
+		}
+	}
+	return teamFolder{}, fmt.Errorf("there's no team folder %s", name)
+}
+
+func teamFoldersList(cmd *cobra.Command, args []string) (err error) {
+	folders, err := listTeamFolders(cmdCtx)
+	if err != nil {
+		return
+	}
+	if jsonMode(cmd) {
+		if folders == nil {
+			folders = []teamFolder{}
+		}
+		return printJSON(folders)
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Name\tId\tStatus\n")
+	for _, f := range folders {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Name, f.TeamFolderID, f.Status.Tag)
+	}
+	return w.Flush()
+}
+
+func teamFoldersCreate(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`team folders create` requires a `name` argument")
+	}
+
+	var tf teamFolder
+	err = rpc(cmdCtx, "team", "team_folder/create", struct {
+		Name string `json:"name"`
+	}{args[0]}, &tf)
+	if err != nil {
+		return
+	}
+	if jsonMode(cmd) {
+		return printJSON(tf)
+	}
+	fmt.Printf("Created team folder %s (%s)\n", tf.Name, tf.TeamFolderID)
+	return
+}
+
+func teamFoldersArchive(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`team folders archive` requires a `name` argument")
+	}
+	folders, err := listTeamFolders(cmdCtx)
+	if err != nil {
+		return
+	}
+
+	failed := 0
+	for _, name := range args {
+		if err = archiveTeamFolder(folders, name); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, explainError(err))
+			failed++
+			continue
+		}
+		fmt.Printf("Archived %s\n", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d team folders couldn't be archived", failed, len(args))
+	}
+	return nil
+}
+
+// Archives a team folder, waiting for the archive to finish.
+func archiveTeamFolder(folders []teamFolder, name string) error {
+	f, err := findTeamFolder(folders, name)
+	if err != nil {
+		return err
+	}
+	if f.Status.Tag == "archived" {
+		return errors.New("already archived")
+	}
+
+	var status teamFolderArchiveStatus
+	err = rpc(cmdCtx, "team", "team_folder/archive", struct {
+		TeamFolderID string `json:"team_folder_id"`
+	}{f.TeamFolderID}, &status)
+	if err != nil {
+		return err
+	}
+	if status.Tag == "async_job_id" {
+		jobID := asyncJobIDArg{status.AsyncJobID}
+		err = retry.PollUntil(cmdCtx, batchPollPolicy, func() (bool, error) {
+			status = teamFolderArchiveStatus{}
+			if err := rpc(cmdCtx, "team", "team_folder/archive/check", &jobID, &status); err != nil {
+				return false, err
+			}
+			return status.Tag != "in_progress", nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if status.Tag != "complete" {
+		if status.Failed != nil && status.Failed.Tag != "" {
+			return fmt.Errorf("archiving failed: %s", status.Failed.Tag)
+		}
+		return fmt.Errorf("archiving ended with status %q", status.Tag)
+	}
+	return nil
+}
+
+// teamFoldersCmd represents the team folders command
+var teamFoldersCmd = &cobra.Command{
+	Use:   "folders",
+	Short: "Manage team folders",
+	Long: `Manage the team's folders. Team folders are named by their name or
+their id, as listed by "dbxcli team folders list".`,
+}
+
+// teamFoldersListCmd represents the team folders list command
+var teamFoldersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List team folders, archived ones included",
+	Example: `  dbxcli team folders list
+  dbxcli team folders list --json`,
+	RunE: teamFoldersList,
+}
+
+// teamFoldersCreateCmd represents the team folders create command
+var teamFoldersCreateCmd = &cobra.Command{
+	Use:     "create <name>",
+	Short:   "Create a team folder",
+	Example: `  dbxcli team folders create Marketing`,
+	RunE:    teamFoldersCreate,
+}
+
+// teamFoldersArchiveCmd represents the team folders archive command
+var teamFoldersArchiveCmd = &cobra.Command{
+	Use:   "archive <name>...",
+	Short: "Archive team folders",
+	Long: `Archive team folders, which makes them read-only and takes them out of
+members' Dropboxes. Archived folders can be restored from the admin console.`,
+	Example: `  dbxcli team folders archive "Marketing 2019"
+  dbxcli team folders archive 1234567890`,
+	RunE: teamFoldersArchive,
+}
+
+func init() {
+	teamCmd.AddCommand(teamFoldersCmd)
+	teamFoldersCmd.AddCommand(teamFoldersListCmd)
+	teamFoldersCmd.AddCommand(teamFoldersCreateCmd)
+	teamFoldersCmd.AddCommand(teamFoldersArchiveCmd)
+}
@@ -0,0 +1,260 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/async"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team_common"
+	"github.com/spf13/cobra"
+)
+
+var groupMemberAccessTypes = []string{team.GroupAccessTypeMember, team.GroupAccessTypeOwner}
+
+// Looks up a group by id, or by name ignoring case.
+func findGroup(dbx team.Client, group string) (*team_common.GroupSummary, error) {
+	res, err := allGroups(dbx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range res.Groups {
+		if g.GroupId == group || strings.EqualFold(g.GroupName, group) {
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("no such group: %s; see `dbxcli team groups list`", group)
+}
+
+func groupIDSelector(id string) *team.GroupSelector {
+	return &team.GroupSelector{Tagged: dropbox.Tagged{Tag: team.GroupSelectorGroupId}, GroupId: id}
+}
+
+// Waits for a groups job to finish. An empty `jobID` means the change was
+// made straight away.
+func waitForGroupJob(dbx team.Client, jobID string) error {
+	if jobID == "" {
+		return nil
+	}
+	return retry.PollUntil(cmdCtx, batchPollPolicy, func() (bool, error) {
+		status, err := dbx.GroupsJobStatusGet(async.NewPollArg(jobID))
+		if err != nil {
+			return false, err
+		}
+		return status.Tag == async.PollEmptyResultComplete, nil
+	})
+}
+
+func createGroup(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`team groups create` requires a `name` argument")
+	}
+
+	arg := team.NewGroupCreateArg(args[0])
+	arg.GroupExternalId, _ = cmd.Flags().GetString("external-id")
+	res, err := newTeamClient(cmdCtx).GroupsCreate(arg)
+	if err != nil {
+		return err
+	}
+
+	if jsonMode(cmd) {
+		return printJSON(res)
+	}
+	fmt.Printf("Created group %s (%s)\n", res.GroupName, res.GroupId)
+	return
+}
+
+func deleteGroups(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`team groups delete` requires a `group` argument")
+	}
+
+	dbx := newTeamClient(cmdCtx)
+	failed := 0
+	for _, name := range args {
+		err := func() error {
+			g, err := findGroup(dbx, name)
+			if err != nil {
+				return err
+			}
+			// The vendored SDK drops the id of the job groups/delete starts.
+			var res asyncLaunch
+			if err = rpc(cmdCtx, "team", "groups/delete", groupIDSelector(g.GroupId), &res); err != nil {
+				return err
+			}
+			return waitForGroupJob(dbx, res.AsyncJobID)
+		}()
+		if err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, explainError(err))
+			failed++
+			continue
+		}
+		fmt.Printf("Deleted group %s\n", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d groups couldn't be deleted", failed, len(args))
+	}
+	return
+}
+
+func addGroupMembers(cmd *cobra.Command, args []string) (err error) {
+	if len(args) < 2 {
+		return errors.New("`team groups add-member` requires `group` and `member` arguments")
+	}
+
+	access, _ := cmd.Flags().GetString("access")
+	valid := false
+	for _, a := range groupMemberAccessTypes {
+		valid = valid || a == access
+	}
+	if !valid {
+		return fmt.Errorf("invalid `--access` %q; use one of %s", access, strings.Join(groupMemberAccessTypes, ", "))
+	}
+
+	dbx := newTeamClient(cmdCtx)
+	g, err := findGroup(dbx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var members []*team.MemberAccess
+	for _, member := range args[1:] {
+		members = append(members, team.NewMemberAccess(teamMemberSelector(member), &team.GroupAccessType{Tagged: dropbox.Tagged{Tag: access}}))
+	}
+	arg := team.NewGroupMembersAddArg(groupIDSelector(g.GroupId), members)
+	arg.ReturnMembers = false
+	res, err := dbx.GroupsMembersAdd(arg)
+	if err != nil {
+		return err
+	}
+	if err = waitForGroupJob(dbx, res.AsyncJobId); err != nil {
+		return err
+	}
+	for _, member := range args[1:] {
+		fmt.Printf("Added %s to %s\n", member, g.GroupName)
+	}
+	return
+}
+
+func removeGroupMembers(cmd *cobra.Command, args []string) (err error) {
+	if len(args) < 2 {
+		return errors.New("`team groups remove-member` requires `group` and `member` arguments")
+	}
+
+	dbx := newTeamClient(cmdCtx)
+	g, err := findGroup(dbx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var users []*team.UserSelectorArg
+	for _, member := range args[1:] {
+		users = append(users, teamMemberSelector(member))
+	}
+	arg := team.NewGroupMembersRemoveArg(groupIDSelector(g.GroupId), users)
+	arg.ReturnMembers = false
+	res, err := dbx.GroupsMembersRemove(arg)
+	if err != nil {
+		return err
+	}
+	if err = waitForGroupJob(dbx, res.AsyncJobId); err != nil {
+		return err
+	}
+	for _, member := range args[1:] {
+		fmt.Printf("Removed %s from %s\n", member, g.GroupName)
+	}
+	return
+}
+
+// teamGroupsCmd represents the team groups command
+var teamGroupsCmd = &cobra.Command{
+	Use:   "groups",
+	Short: "Manage team groups",
+	Long: `Manage the groups of a Dropbox team. These commands need a team
+admin to authorize dbxcli.
+
+Groups are named by name, ignoring case, or by group id (g:...) as printed
+by "dbxcli team groups list". Members are named by email address, or by team
+member id (dbmid:...).
+
+Membership changes to a group are all-or-nothing: if one member can't be
+added or removed, none of them are.`,
+}
+
+// teamGroupsListCmd represents the team groups list command
+var teamGroupsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List team groups",
+	Example: `  dbxcli team groups list
+  dbxcli team groups list --json`,
+	RunE: listGroups,
+}
+
+// teamGroupsCreateCmd represents the team groups create command
+var teamGroupsCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a team group",
+	Example: `  dbxcli team groups create Engineering
+  dbxcli team groups create --external-id eng-01 Engineering`,
+	RunE: createGroup,
+}
+
+// teamGroupsDeleteCmd represents the team groups delete command
+var teamGroupsDeleteCmd = &cobra.Command{
+	Use:     "delete <group>...",
+	Short:   "Delete team groups",
+	Example: `  dbxcli team groups delete Engineering`,
+	RunE:    deleteGroups,
+}
+
+// teamGroupsAddMemberCmd represents the team groups add-member command
+var teamGroupsAddMemberCmd = &cobra.Command{
+	Use:   "add-member <group> <member>...",
+	Short: "Add team members to a group",
+	Example: `  dbxcli team groups add-member Engineering ada@example.com grace@example.com
+  dbxcli team groups add-member --access owner Engineering ada@example.com`,
+	RunE: addGroupMembers,
+}
+
+// teamGroupsRemoveMemberCmd represents the team groups remove-member command
+var teamGroupsRemoveMemberCmd = &cobra.Command{
+	Use:     "remove-member <group> <member>...",
+	Short:   "Remove team members from a group",
+	Example: `  dbxcli team groups remove-member Engineering ada@example.com`,
+	RunE:    removeGroupMembers,
+}
+
+func init() {
+	teamCmd.AddCommand(teamGroupsCmd)
+	teamGroupsCmd.AddCommand(teamGroupsListCmd)
+	teamGroupsCmd.AddCommand(teamGroupsCreateCmd)
+	teamGroupsCmd.AddCommand(teamGroupsDeleteCmd)
+	teamGroupsCmd.AddCommand(teamGroupsAddMemberCmd)
+	teamGroupsCmd.AddCommand(teamGroupsRemoveMemberCmd)
+
+	teamGroupsCreateCmd.Flags().String("external-id", "", "An id of your own to attach to the group")
+	teamGroupsAddMemberCmd.Flags().String("access", team.GroupAccessTypeMember, "Access the members get: member or owner")
+
+	listGroupsCmd.Deprecated = `use "team groups list" instead`
+}
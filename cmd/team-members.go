@@ -0,0 +1,175 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/team"
+	"github.com/spf13/cobra"
+)
+
+// Picks out a team member by team member id if `member` is one, and by
+// email address otherwise.
+func teamMemberSelector(member string) *team.UserSelectorArg {
+	if strings.HasPrefix(member, "dbmid:") {
+		return &team.UserSelectorArg{Tagged: dropbox.Tagged{Tag: team.UserSelectorArgTeamMemberId}, TeamMemberId: member}
+	}
+	return &team.UserSelectorArg{Tagged: dropbox.Tagged{Tag: team.UserSelectorArgEmail}, Email: member}
+}
+
+// Returns the team member id to act as for `--as-member`, which takes an
+// email address too.
+func resolveMemberID(member string) (string, error) {
+	if !strings.Contains(member, "@") {
+		return member, nil
+	}
+	arg := team.NewMembersGetInfoArgs([]*team.UserSelectorArg{teamMemberSelector(member)})
+	res, err := newTeamClient(cmdCtx).MembersGetInfo(arg)
+	if err != nil {
+		return "", err
+	}
+	if len(res) != 1 || res[0].MemberInfo == nil {
+		return "", fmt.Errorf("`--as-member` %s isn't a member of the team", member)
+	}
+	return res[0].MemberInfo.Profile.TeamMemberId, nil
+}
+
+// Runs `change` for each member named in `args`, reporting failures as it
+// goes.
+func changeMembers(args []string, done string, change func(*team.UserSelectorArg) error) error {
+	failed := 0
+	for _, member := range args {
+		if err := change(teamMemberSelector(member)); err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", member, explainError(err))
+			failed++
+			continue
+		}
+		fmt.Printf("%s %s\n", done, member)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d members couldn't be changed", failed, len(args))
+	}
+	return nil
+}
+
+func suspendMembers(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`team members suspend` requires a `member` argument")
+	}
+	keepData, _ := cmd.Flags().GetBool("keep-data")
+
+	dbx := newTeamClient(cmdCtx)
+	return changeMembers(args, "Suspended", func(user *team.UserSelectorArg) error {
+		arg := team.NewMembersDeactivateArg(user)
+		arg.WipeData = !keepData
+		return dbx.MembersSuspend(arg)
+	})
+}
+
+func unsuspendMembers(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`team members unsuspend` requires a `member` argument")
+	}
+
+	dbx := newTeamClient(cmdCtx)
+	return changeMembers(args, "Unsuspended", func(user *team.UserSelectorArg) error {
+		return dbx.MembersUnsuspend(team.NewMembersUnsuspendArg(user))
+	})
+}
+
+// teamMembersCmd represents the team members command
+var teamMembersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "Manage team members",
+	Long: `Manage the members of a Dropbox team. These commands need a team
+admin to authorize dbxcli.
+
+Members are named by email address, or by team member id (dbmid:...) as
+printed by "dbxcli team members list --json".`,
+}
+
+// teamMembersListCmd represents the team members list command
+var teamMembersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List team members",
+	Example: `  dbxcli team members list
+  dbxcli team members list --json`,
+	RunE: listMembers,
+}
+
+// teamMembersAddCmd represents the team members add command
+var teamMembersAddCmd = &cobra.Command{
+	Use:     "add <email> <first-name> <last-name>",
+	Short:   "Add a new member to the team",
+	Example: `  dbxcli team members add ada@example.com Ada Lovelace`,
+	RunE:    addMember,
+}
+
+// teamMembersRemoveCmd represents the team members remove command
+var teamMembersRemoveCmd = &cobra.Command{
+	Use:   "remove <member>",
+	Short: "Remove a member from the team",
+	Long: `Remove a member from the team, wiping their data from their linked
+devices. Unless --keep-account is given their account is deleted; with it,
+it becomes a personal Dropbox account.`,
+	Example: `  dbxcli team members remove ada@example.com
+  dbxcli team members remove --keep-account dbmid:AAHhy7WsR0x-u4ZCqiDl5Fz5zvuL3kmspwU`,
+	RunE: removeMember,
+}
+
+// teamMembersSuspendCmd represents the team members suspend command
+var teamMembersSuspendCmd = &cobra.Command{
+	Use:   "suspend <member>...",
+	Short: "Suspend team members",
+	Long: `Suspend team members, so they can't sign in until they're
+unsuspended. Their data is wiped from their linked devices unless --keep-data
+is given.`,
+	Example: `  dbxcli team members suspend ada@example.com
+  dbxcli team members suspend --keep-data ada@example.com grace@example.com`,
+	RunE: suspendMembers,
+}
+
+// teamMembersUnsuspendCmd represents the team members unsuspend command
+var teamMembersUnsuspendCmd = &cobra.Command{
+	Use:     "unsuspend <member>...",
+	Short:   "Unsuspend team members",
+	Example: `  dbxcli team members unsuspend ada@example.com`,
+	RunE:    unsuspendMembers,
+}
+
+func init() {
+	teamCmd.AddCommand(teamMembersCmd)
+	teamMembersCmd.AddCommand(teamMembersListCmd)
+	teamMembersCmd.AddCommand(teamMembersAddCmd)
+	teamMembersCmd.AddCommand(teamMembersRemoveCmd)
+	teamMembersCmd.AddCommand(teamMembersSuspendCmd)
+	teamMembersCmd.AddCommand(teamMembersUnsuspendCmd)
+
+	teamMembersAddCmd.Flags().Bool("no-welcome-email", false, "Don't send the new member a welcome email")
+	teamMembersRemoveCmd.Flags().Bool("keep-account", false, "Turn the member's account into a personal Dropbox account instead of deleting it")
+	teamMembersSuspendCmd.Flags().Bool("keep-data", false, "Don't wipe the members' data from their linked devices")
+
+	addMemberCmd.Deprecated = `use "team members add" instead`
+	removeMemberCmd.Deprecated = `use "team members remove" instead`
+	listMembersCmd.Deprecated = `use "team members list" instead`
+}
@@ -0,0 +1,112 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/spf13/cobra"
+)
+
+// The vendored SDK predates team/namespaces/list.
+type namespaceMetadata struct {
+	Name          string         `json:"name"`
+	NamespaceID   string         `json:"namespace_id"`
+	NamespaceType dropbox.Tagged `json:"namespace_type"`
+	TeamMemberID  string         `json:"team_member_id,omitempty"`
+}
+
+type namespacesListResult struct {
+	Namespaces []namespaceMetadata `json:"namespaces"`
+	Cursor     string              `json:"cursor"`
+	HasMore    bool                `json:"has_more"`
+}
+
+func teamNamespaces(cmd *cobra.Command, args []string) (err error) {
+	kind, _ := cmd.Flags().GetString("type")
+
+	var namespaces []namespaceMetadata
+	var res namespacesListResult
+	err = rpc(cmdCtx, "team", "namespaces/list", struct {
+		Limit uint32 `json:"limit"`
+	}{1000}, &res)
+	for err == nil {
+		for _, ns := range res.Namespaces {
+			if kind == "" || ns.NamespaceType.Tag == kind {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		if !res.HasMore {
+			break
+		}
+		if err = cmdCtx.Err(); err != nil {
+			return
+		}
+		cursor := res.Cursor
+		res = namespacesListResult{}
+		err = rpc(cmdCtx, "team", "namespaces/list/continue", struct {
+			Cursor string `json:"cursor"`
+		}{cursor}, &res)
+	}
+	if err != nil {
+		return
+	}
+
+	if jsonMode(cmd) {
+		if namespaces == nil {
+			namespaces = []namespaceMetadata{}
+		}
+		return printJSON(namespaces)
+	}
+	if len(namespaces) == 0 {
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Id\tType\tName\tMember\n")
+	for _, ns := range namespaces {
+		member := ns.TeamMemberID
+		if member == "" {
+			member = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ns.NamespaceID, ns.NamespaceType.Tag, ns.Name, member)
+	}
+	return w.Flush()
+}
+
+// teamNamespacesCmd represents the team namespaces command
+var teamNamespacesCmd = &cobra.Command{
+	Use:   "namespaces",
+	Short: "List the team's namespaces",
+	Long: `List the team's namespaces: its members' home folders, shared folders,
+team folders and app folders, with their ids. A namespace can be addressed
+by other commands as "ns:<id>", e.g. "dbxcli ls ns:1234567890".
+
+--type limits the list to one kind of namespace: team_member_folder,
+shared_folder, team_folder or app_folder.`,
+	Example: `  dbxcli team namespaces
+  dbxcli team namespaces --type team_folder`,
+	RunE: teamNamespaces,
+}
+
+func init() {
+	teamCmd.AddCommand(teamNamespacesCmd)
+
+	teamNamespacesCmd.Flags().String("type", "", "Only list namespaces of this type")
+}
@@ -0,0 +1,93 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// How long a temporary link works for.
+const tmpLinkLifetime = 4 * time.Hour
+
+type jsonTmpLink struct {
+	Path    string    `json:"path"`
+	Link    string    `json:"link"`
+	Expires time.Time `json:"expires"`
+}
+
+func tmpLink(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`tmplink` requires a `path` argument")
+	}
+
+	dbx := newFilesClient(cmdCtx)
+	failed := 0
+	for _, arg := range args {
+		p, err := validatePath(arg)
+		if err != nil {
+			return err
+		}
+		// Links expire four hours after they're made, so note when before
+		// asking for one.
+		expires := time.Now().Add(tmpLinkLifetime)
+		res, err := dbx.GetTemporaryLink(files.NewGetTemporaryLinkArg(p))
+		if err != nil {
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", arg, explainError(err))
+			failed++
+			continue
+		}
+
+		if jsonMode(cmd) {
+			if err = printJSONLine(jsonTmpLink{Path: res.Metadata.PathDisplay, Link: res.Link, Expires: expires.UTC().Truncate(time.Second)}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(res.Link)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links couldn't be created", failed, len(args))
+	}
+	return
+}
+
+// tmpLinkCmd represents the tmplink command
+var tmpLinkCmd = &cobra.Command{
+	Use:   "tmplink <path>...",
+	Short: "Print a temporary direct download link to a file",
+	Long: `Print a link that downloads a file directly, without signing in, for
+instance with curl or wget on another machine. The link stops working after
+four hours. Unlike a shared link it can't be revoked, so treat it like the
+file itself.
+
+With several paths, a link is printed for each on its own line, in order.
+--json prints each link with its path and when it expires.`,
+	Example: `  dbxcli tmplink /backups/disk.img
+  curl -o file.bin "$(dbxcli tmplink /path/file.bin)"`,
+	RunE: tmpLink,
+}
+
+func init() {
+	RootCmd.AddCommand(tmpLinkCmd)
+}
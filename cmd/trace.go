@@ -0,0 +1,169 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Returns what went wrong with a traced request, or "" if nothing did.
+func traceProblem(rec traceRecord) string {
+	if rec.Error != "" {
+		return rec.Error
+	}
+	if rec.Status < 400 {
+		return ""
+	}
+	var body struct {
+		ErrorSummary string `json:"error_summary"`
+	}
+	if json.Unmarshal(rec.ResponseBody, &body) == nil && body.ErrorSummary != "" {
+		return fmt.Sprintf("%d %s", rec.Status, body.ErrorSummary)
+	}
+	var text string
+	if json.Unmarshal(rec.ResponseBody, &text) == nil && text != "" {
+		return fmt.Sprintf("%d %s", rec.Status, text)
+	}
+	return fmt.Sprintf("%d %s", rec.Status, strings.ToLower(http.StatusText(rec.Status)))
+}
+
+// Shortens an API URL to its route, e.g. "files/list_folder".
+func traceEndpoint(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return strings.TrimPrefix(u.Path, "/2/")
+}
+
+func traceSummarize(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`trace summarize` requires a `file` argument")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var records []traceRecord
+	sc := bufio.NewScanner(f)
+	// Lines hold response bodies of up to maxTracedBody bytes, escaped.
+	sc.Buffer(make([]byte, 64<<10), 8*maxTracedBody)
+	for n := 1; sc.Scan(); n++ {
+		var rec traceRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil || rec.Version < 1 {
+			fmt.Fprintf(os.Stderr, "%s:%d: skipping unreadable record\n", args[0], n)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s has no requests", args[0])
+	}
+
+	// Records are written as responses complete; show them in the order
+	// the requests were sent.
+	for i := 1; i < len(records); i++ {
+		for j := i; j > 0 && records[j].Start.Before(records[j-1].Start); j-- {
+			records[j], records[j-1] = records[j-1], records[j]
+		}
+	}
+
+	start := records[0].Start
+	var end time.Time
+	var failed int
+	slowest := records[0]
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, ' ', 0)
+	fmt.Fprintf(w, " \tAt\tDuration\tStatus\tEndpoint\tProblem\n")
+	for _, rec := range records {
+		problem := traceProblem(rec)
+		mark := " "
+		if problem != "" {
+			mark = "!"
+			failed++
+		}
+		status := "-"
+		if rec.Status != 0 {
+			status = fmt.Sprint(rec.Status)
+		}
+		fmt.Fprintf(w, "%s\t+%.3fs\t%.0fms\t%s\t%s\t%s\n", mark, rec.Start.Sub(start).Seconds(),
+			rec.DurationMs, status, traceEndpoint(rec.URL), problem)
+
+		if rec.DurationMs > slowest.DurationMs {
+			slowest = rec
+		}
+		if e := rec.Start.Add(time.Duration(rec.DurationMs * float64(time.Millisecond))); e.After(end) {
+			end = e
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return
+	}
+
+	fmt.Printf("\n%d requests, %d failed, %.3fs in total; slowest: %s (%.0fms)\n",
+		len(records), failed, end.Sub(start).Seconds(), traceEndpoint(slowest.URL), slowest.DurationMs)
+	return
+}
+
+// traceCmd represents the trace command
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Inspect traces recorded with --record-trace",
+	Long: `Inspect traces recorded with --record-trace.
+
+Any command run with --record-trace <file> writes each API request and its
+response to <file> as a line of JSON: the method, endpoint, headers, the
+arguments of RPC calls, the status, the response and how long it took. The
+access token, credential headers and password fields are redacted, and file
+contents are replaced by their size and SHA-256 hash, so a trace can be
+attached to a bug report. It does include file and folder names.`,
+	// Reading a trace doesn't need a Dropbox account.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+// traceSummarizeCmd represents the trace summarize command
+var traceSummarizeCmd = &cobra.Command{
+	Use:   "summarize <file>",
+	Short: "Print a timeline of a trace",
+	Long: `Print a timeline of the requests in a trace: when each started relative to
+the first, how long it took, and its status. Failed requests are marked with
+"!" and their error is shown.`,
+	Example: `  dbxcli --record-trace trace.jsonl ls /Photos
+  dbxcli trace summarize trace.jsonl`,
+	RunE: traceSummarize,
+}
+
+func init() {
+	RootCmd.AddCommand(traceCmd)
+	traceCmd.AddCommand(traceSummarizeCmd)
+}
@@ -0,0 +1,369 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version of the records written by --record-trace.
+const traceVersion = 1
+
+// Bodies larger than this are summarized even if they're JSON.
+const maxTracedBody = 64 << 10
+
+const redacted = "[REDACTED]"
+
+// One request and its response, written as a line of JSON.
+type traceRecord struct {
+	Version         int               `json:"version"`
+	Seq             int64             `json:"seq"`
+	Start           time.Time         `json:"start"`
+	DurationMs      float64           `json:"duration_ms"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	RequestBody     json.RawMessage   `json:"request_body,omitempty"`
+	Status          int               `json:"status,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    json.RawMessage   `json:"response_body,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// Stands in for a body that isn't recorded, such as file contents.
+type payloadSummary struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Request headers that carry credentials are never recorded.
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// Response headers worth recording; Dropbox-API-Result is the metadata of a
+// content download.
+var tracedResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Dropbox-API-Result",
+	"Retry-After",
+	"X-Dropbox-Request-Id",
+}
+
+// JSON fields that hold credentials, such as the arguments of
+// auth/token/from_oauth1 or a shared link password.
+var secretFields = map[string]bool{
+	"access_token":        true,
+	"refresh_token":       true,
+	"oauth1_token":        true,
+	"oauth1_token_secret": true,
+	"client_secret":       true,
+	"password":            true,
+	"link_password":       true,
+}
+
+// tracer writes a traceRecord for every API request to a file. The records
+// go through several layers of redaction: credential headers and fields are
+// dropped, file contents are replaced by their size and hash, and finally any
+// occurrence of a known secret left anywhere in a line is blanked out.
+type tracer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	seq     int64
+	secrets []string
+}
+
+var activeTracer *tracer
+
+func newTracer(w io.Writer, secrets ...string) *tracer {
+	t := &tracer{w: w}
+	for _, s := range secrets {
+		if s != "" {
+			t.secrets = append(t.secrets, s)
+		}
+	}
+	return t
+}
+
+func startTrace(name string, secrets ...string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	activeTracer = newTracer(f, secrets...)
+	return nil
+}
+
+func (t *tracer) write(rec *traceRecord) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	for _, s := range t.secrets {
+		b = bytes.Replace(b, []byte(s), []byte(redacted), -1)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.Write(append(b, '\n'))
+}
+
+// Removes secretFields from a JSON value, at any depth.
+func redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if secretFields[k] {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactJSON(e)
+		}
+	}
+	return v
+}
+
+func redactedJSON(b []byte) (json.RawMessage, bool) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, false
+	}
+	out, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func summarize(n int64, h hash.Hash) json.RawMessage {
+	b, _ := json.Marshal(payloadSummary{n, hex.EncodeToString(h.Sum(nil))})
+	return b
+}
+
+// A tracedBody passes a body through while keeping either a copy, if it's
+// small JSON, or its size and hash. `done` is called once, when the body is
+// exhausted or closed.
+type tracedBody struct {
+	io.ReadCloser
+	keep bool
+	buf  bytes.Buffer
+	n    int64
+	h    hash.Hash
+	once sync.Once
+	done func(body json.RawMessage)
+}
+
+func newTracedBody(r io.ReadCloser, contentType string, done func(json.RawMessage)) *tracedBody {
+	return &tracedBody{
+		ReadCloser: r,
+		keep:       strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/"),
+		h:          sha256.New(),
+		done:       done,
+	}
+}
+
+func (b *tracedBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	b.n += int64(n)
+	b.h.Write(p[:n])
+	if b.keep && b.buf.Len() < maxTracedBody {
+		b.buf.Write(p[:n])
+	}
+	if err != nil {
+		b.finish()
+	}
+	return
+}
+
+func (b *tracedBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *tracedBody) body() json.RawMessage {
+	if b.keep && b.n <= maxTracedBody {
+		if j, ok := redactedJSON(b.buf.Bytes()); ok {
+			return j
+		}
+		// Non-JSON text, such as a 400 error message.
+		s, _ := json.Marshal(b.buf.String())
+		return s
+	}
+	return summarize(b.n, b.h)
+}
+
+func (b *tracedBody) finish() {
+	b.once.Do(func() { b.done(b.body()) })
+}
+
+// A record that's written once each of its parts is done: the response and,
+// when it's streamed, the request body.
+type pendingRecord struct {
+	t     *tracer
+	rec   *traceRecord
+	mu    sync.Mutex
+	parts int
+}
+
+func (p *pendingRecord) done(fill func(rec *traceRecord)) {
+	p.mu.Lock()
+	fill(p.rec)
+	p.parts--
+	last := p.parts == 0
+	p.mu.Unlock()
+	if last {
+		p.t.write(p.rec)
+	}
+}
+
+func (t *tracer) roundTrip(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	rec := &traceRecord{
+		Version:        traceVersion,
+		Seq:            atomic.AddInt64(&t.seq, 1),
+		Start:          time.Now(),
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: make(map[string]string),
+	}
+	// Everything about the request is redacted before it's sent, so that a
+	// failed request is recorded as carefully as any other.
+	for k, v := range req.Header {
+		if secretHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		rec.RequestHeaders[k] = strings.Join(v, ", ")
+	}
+	argHeader := http.CanonicalHeaderKey("Dropbox-API-Arg")
+	if arg, ok := rec.RequestHeaders[argHeader]; ok {
+		if j, ok := redactedJSON([]byte(arg)); ok {
+			rec.RequestHeaders[argHeader] = string(j)
+		} else {
+			rec.RequestHeaders[argHeader] = redacted
+		}
+	}
+	pending := &pendingRecord{t: t, rec: rec, parts: 1}
+	if req.Body != nil {
+		var err error
+		var streamed bool
+		req, rec.RequestBody, streamed, err = captureRequestBody(req, func(b json.RawMessage) {
+			pending.done(func(rec *traceRecord) { rec.RequestBody = b })
+		})
+		if err != nil {
+			rec.Error = err.Error()
+			t.write(rec)
+			return nil, err
+		}
+		if streamed {
+			pending.parts++
+		}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		pending.done(func(rec *traceRecord) {
+			rec.DurationMs = msSince(rec.Start)
+			rec.Error = err.Error()
+		})
+		return resp, err
+	}
+
+	rec.Status = resp.StatusCode
+	rec.ResponseHeaders = make(map[string]string)
+	for _, k := range tracedResponseHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			rec.ResponseHeaders[k] = v
+		}
+	}
+	if arg, ok := redactedJSON([]byte(rec.ResponseHeaders["Dropbox-API-Result"])); ok {
+		rec.ResponseHeaders["Dropbox-API-Result"] = string(arg)
+	}
+
+	// The record is written once the response body has been read, so that
+	// the duration covers the whole transfer.
+	resp.Body = newTracedBody(resp.Body, resp.Header.Get("Content-Type"), func(b json.RawMessage) {
+		pending.done(func(rec *traceRecord) {
+			rec.DurationMs = msSince(rec.Start)
+			rec.ResponseBody = b
+		})
+	})
+	return resp, nil
+}
+
+// Records the body of `req` before it's sent, where it can be; reading it as
+// the transport writes it would race with the transport, which may still be
+// writing when the response arrives. A body that can be sent again is
+// recorded from a copy. Any other is read into memory only up to
+// maxTracedBody, and a copy of `req` that sends it from there is returned.
+// A longer one is only summarized anyway, so the copy streams it instead,
+// reporting the summary to `streamed` once the transport is done with it.
+func captureRequestBody(req *http.Request, streamed func(json.RawMessage)) (*http.Request, json.RawMessage, bool, error) {
+	contentType := req.Header.Get("Content-Type")
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return req, nil, false, err
+		}
+		defer body.Close()
+		b := newTracedBody(body, contentType, func(json.RawMessage) {})
+		if _, err = io.Copy(ioutil.Discard, b); err != nil {
+			return req, nil, false, err
+		}
+		return req, b.body(), false, nil
+	}
+
+	head, err := ioutil.ReadAll(io.LimitReader(req.Body, maxTracedBody+1))
+	if err != nil {
+		req.Body.Close()
+		return req, nil, false, err
+	}
+	copied := new(http.Request)
+	*copied = *req
+	if len(head) <= maxTracedBody {
+		req.Body.Close()
+		b := newTracedBody(ioutil.NopCloser(bytes.NewReader(head)), contentType, func(json.RawMessage) {})
+		io.Copy(ioutil.Discard, b)
+		copied.Body = ioutil.NopCloser(bytes.NewReader(head))
+		return copied, b.body(), false, nil
+	}
+	rest := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+	copied.Body = newTracedBody(rest, contentType, streamed)
+	return copied, nil, true, nil
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t)) / float64(time.Millisecond)
+}
@@ -0,0 +1,239 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	traceToken  = "sl.TRACE-TOKEN-0123456789"
+	traceSecret = "app-secret-9876543210"
+)
+
+// A RoundTripper made of a function, standing in for the network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// Sends `req` through a tracer and returns what it recorded, after checking
+// that the request reached the network with its body intact.
+func traceRequest(t *testing.T, req *http.Request, resp *http.Response, respErr error) (rec traceRecord, line string) {
+	t.Helper()
+	var sent []byte
+	var buf bytes.Buffer
+	tr := newTracer(&buf, traceToken, traceSecret)
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Body != nil {
+			sent, _ = ioutil.ReadAll(r.Body)
+			r.Body.Close()
+		}
+		return resp, respErr
+	})
+
+	var want []byte
+	if req.GetBody != nil {
+		body, _ := req.GetBody()
+		want, _ = ioutil.ReadAll(body)
+	}
+	got, err := tr.roundTrip(base, req)
+	if err != respErr {
+		t.Fatalf("roundTrip returned %v, want %v", err, respErr)
+	}
+	if got != nil {
+		ioutil.ReadAll(got.Body)
+		got.Body.Close()
+	}
+	if want != nil && !bytes.Equal(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+
+	sc := bufio.NewScanner(&buf)
+	if !sc.Scan() {
+		t.Fatal("nothing was recorded")
+	}
+	line = sc.Text()
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestTraceRedaction(t *testing.T) {
+	rpc := func(route, body string) *http.Request {
+		req, _ := http.NewRequest("POST", "https://api.dropboxapi.com/2/"+route, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+traceToken)
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	content := func(route, arg string, body []byte) *http.Request {
+		req, _ := http.NewRequest("POST", "https://content.dropboxapi.com/2/"+route, nil)
+		// A streamed body, which can't be sent again.
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+traceToken)
+		req.Header.Set("Dropbox-API-Arg", arg)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		resp *http.Response
+		err  error
+	}{
+		{
+			"token exchange",
+			rpc("auth/token/from_oauth1", `{"oauth1_token": "`+traceToken+`", "oauth1_token_secret": "`+traceSecret+`"}`),
+			jsonResponse(200, `{"oauth2_token": "x", "access_token": "`+traceToken+`"}`),
+			nil,
+		},
+		{
+			"link password",
+			rpc("sharing/create_shared_link_with_settings", `{"path": "/a", "settings": {"link_password": "hunter2"}}`),
+			jsonResponse(409, `{"error_summary": "shared_link_already_exists/"}`),
+			nil,
+		},
+		{
+			"failed download",
+			content("sharing/get_shared_link_file", `{"url": "https://www.dropbox.com/s/abc", "link_password": "hunter2"}`, nil),
+			nil,
+			errors.New("connection reset"),
+		},
+		{
+			"upload",
+			content("files/upload", `{"path": "/token.txt"}`, []byte("the token is "+traceToken)),
+			jsonResponse(200, `{"name": "token.txt"}`),
+			nil,
+		},
+		{
+			"failed upload",
+			content("files/upload", `{"path": "/secret.txt"}`, []byte(traceSecret)),
+			nil,
+			errors.New("connection reset"),
+		},
+	}
+	for _, tt := range tests {
+		rec, line := traceRequest(t, tt.req, tt.resp, tt.err)
+		for _, secret := range []string{traceToken, traceSecret, "hunter2"} {
+			if strings.Contains(line, secret) {
+				t.Errorf("%s: the trace contains %q: %s", tt.name, secret, line)
+			}
+		}
+		if _, ok := rec.RequestHeaders["Authorization"]; ok {
+			t.Errorf("%s: recorded the Authorization header", tt.name)
+		}
+		if tt.err != nil && rec.Error != tt.err.Error() {
+			t.Errorf("%s: recorded error %q", tt.name, rec.Error)
+		}
+	}
+}
+
+func TestTraceSummarizesContent(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://content.dropboxapi.com/2/files/upload", nil)
+	req.Body = ioutil.NopCloser(strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Dropbox-API-Arg", `{"path": "/hello.txt"}`)
+	rec, _ := traceRequest(t, req, jsonResponse(200, `{"name": "hello.txt"}`), nil)
+
+	var summary payloadSummary
+	if err := json.Unmarshal(rec.RequestBody, &summary); err != nil {
+		t.Fatal(err)
+	}
+	// The SHA-256 of "hello".
+	if summary.Size != 5 || summary.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("recorded the upload as %+v", summary)
+	}
+	if rec.Status != 200 || string(rec.ResponseBody) != `{"name":"hello.txt"}` {
+		t.Errorf("recorded the response as %d %s", rec.Status, rec.ResponseBody)
+	}
+}
+
+// A reader that counts how much has been read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestTraceStreamsLargeUploads(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), maxTracedBody/4)
+	src := &countingReader{r: bytes.NewReader(content)}
+	req, _ := http.NewRequest("POST", "https://content.dropboxapi.com/2/files/upload", nil)
+	req.Body = ioutil.NopCloser(src)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	var buf bytes.Buffer
+	tr := newTracer(&buf, traceToken, traceSecret)
+	var sent []byte
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if src.n > maxTracedBody+1 {
+			t.Errorf("read %d bytes of the upload before sending it", src.n)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("recorded the request before its body was sent: %s", buf.String())
+		}
+		sent, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		return jsonResponse(200, `{"name": "big.bin"}`), nil
+	})
+	resp, err := tr.roundTrip(base, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(sent, content) {
+		t.Errorf("sent %d bytes, want the %d given", len(sent), len(content))
+	}
+
+	var rec traceRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	var summary payloadSummary
+	if err := json.Unmarshal(rec.RequestBody, &summary); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	if summary.Size != int64(len(content)) || summary.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("recorded the upload as %+v", summary)
+	}
+}
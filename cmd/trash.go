@@ -0,0 +1,226 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Archived items are filed under a folder named for the day they were
+// archived, which is what `trash empty` goes by.
+const archiveDayLayout = "2006-01-02"
+
+// Returns where `p` is archived to on `day`: beneath the day's folder, at the
+// same relative path it had.
+func archivePath(archive string, p string, day time.Time) string {
+	return archive + "/" + day.Format(archiveDayLayout) + p
+}
+
+// Appends a timestamp to a name that's already taken in the archive, keeping
+// the extension, e.g. "report (archived 150405).pdf". Attempts after the
+// first `n` also get a counter, "report (archived 150405 2).pdf", since the
+// same name can be archived more than once a second.
+func archiveCollisionName(p string, now time.Time, n int) string {
+	ext := gopath.Ext(p)
+	if strings.Contains(ext, "/") {
+		ext = ""
+	}
+	suffix := now.Format("150405")
+	if n > 1 {
+		suffix += " " + strconv.Itoa(n)
+	}
+	return strings.TrimSuffix(p, ext) + " (archived " + suffix + ")" + ext
+}
+
+// Reports whether `p` is `dir` or lies beneath it, ignoring case as Dropbox
+// does.
+func isWithin(p string, dir string) bool {
+	p, dir = strings.ToLower(p), strings.ToLower(dir)
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// Moves `p`, a resolved path, into the archive folder instead of deleting it,
+// and prints where it went.
+func archiveRemove(cmd *cobra.Command, dbx files.Client, p string, archive string) (err error) {
+	if isWithin(p, archive) {
+		return fmt.Errorf("rm: %s is already in the archive folder; use `trash empty` to delete it", p)
+	}
+	to, err := archiveMove(dbx, p, archive)
+	if err != nil {
+		return fmt.Errorf("rm: %s: %v", p, err)
+	}
+	printMoved(cmd, p, to, "archived", nil)
+	return
+}
+
+// Moves `p` into the archive folder under the first free name and returns
+// where it ended up.
+func archiveMove(dbx files.Client, p string, archive string) (string, error) {
+	now := time.Now()
+	base := archivePath(archive, p, now)
+	to := base
+	for n := 1; ; n++ {
+		_, err := getFileMetadata(dbx, to)
+		if isMissing(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		to = archiveCollisionName(base, now, n)
+	}
+
+	results, err := relocationBatch(cmdCtx, "move", []relocationPath{{FromPath: p, ToPath: to}}, false)
+	if err != nil {
+		return "", err
+	}
+	if results[0].Err != nil {
+		return "", results[0].Err
+	}
+	return results[0].PathDisplay, nil
+}
+
+// Lengths in days of the units parseAge accepts on top of those of
+// time.ParseDuration. A year is taken to be 365 days.
+var ageUnits = map[string]int{"d": 1, "w": 7, "y": 365}
+
+// Parses an age such as "1y", "30d", "12h" or "90m". Days, weeks and years
+// aren't units of time.ParseDuration, so they're handled here.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.New("empty age")
+	}
+	if days, ok := ageUnits[s[len(s)-1:]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n*days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+func trashEmpty(cmd *cobra.Command, args []string) (err error) {
+	archiveTo, _ := cmd.Flags().GetString("archive-to")
+	if archiveTo == "" {
+		return errors.New("`trash empty` requires `--archive-to`")
+	}
+	archive, err := validatePath(archiveTo)
+	if err != nil {
+		return
+	}
+
+	olderThan, _ := cmd.Flags().GetString("older-than")
+	age, err := parseAge(olderThan)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-age)
+
+	dbx := newFilesClient(cmdCtx)
+	entries, err := listFolder(cmdCtx, dbx, archive)
+	if err != nil {
+		return
+	}
+
+	// Only day folders are candidates; anything else in the archive folder
+	// wasn't put there by `rm --archive-to` and is left alone.
+	var expired []string
+	for _, entry := range entries {
+		folder, ok := entry.(*files.FolderMetadata)
+		if !ok {
+			continue
+		}
+		day, err := time.ParseInLocation(archiveDayLayout, folder.Name, time.Local)
+		if err != nil {
+			continue
+		}
+		if day.AddDate(0, 0, 1).Before(cutoff) {
+			expired = append(expired, folder.PathDisplay)
+		}
+	}
+	if len(expired) == 0 {
+		if !jsonMode(cmd) {
+			fmt.Fprintf(os.Stderr, "Nothing in %s is older than %s\n", archive, olderThan)
+		}
+		return
+	}
+
+	if force, _ := cmd.Flags().GetBool("force"); !force {
+		prompt := fmt.Sprintf("About to permanently delete %d archived day(s) from %s:\n  %s",
+			len(expired), archive, strings.Join(expired, "\n  "))
+		if err = confirmByTyping(prompt, archive); err != nil {
+			return
+		}
+	}
+
+	errs, err := deleteBatch(cmdCtx, expired)
+	if err != nil {
+		return
+	}
+	failed := 0
+	for i, p := range expired {
+		if errs[i] != nil {
+			failed++
+			if !jsonMode(cmd) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", p, errs[i])
+			}
+		}
+		printDeleted(cmd, p, errs[i])
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deletions failed", failed, len(expired))
+	}
+	return
+}
+
+// trashCmd represents the trash command
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage the archive folder used by `rm --archive-to` and `mv --archive-to`",
+}
+
+// trashEmptyCmd represents the trash empty command
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty [flags]",
+	Short: "Permanently delete old items from the archive folder",
+	Long: `Permanently delete items archived by ` + "`rm --archive-to`" + ` or ` + "`mv --archive-to`" + `
+more than --older-than ago. Items are archived under a folder per day, and whole days are deleted.
+You're asked to confirm by typing the archive folder unless --force is given.
+With --json, each deleted day is printed as a line of JSON.`,
+	Example: `  dbxcli trash empty --archive-to /Trash --older-than 30d`,
+	RunE:    trashEmpty,
+}
+
+func init() {
+	RootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashEmptyCmd)
+	trashEmptyCmd.Flags().String("archive-to", "", "Archive folder to empty")
+	trashEmptyCmd.Flags().String("older-than", "30d", "Only delete items archived longer ago than this, e.g. 30d or 12h")
+	trashEmptyCmd.Flags().BoolP("force", "f", false, "Don't ask for confirmation")
+}
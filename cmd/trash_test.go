@@ -0,0 +1,108 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestArchiveCollisionName(t *testing.T) {
+	now := time.Date(2016, 4, 1, 15, 4, 5, 0, time.Local)
+	tests := []struct {
+		p    string
+		n    int
+		want string
+	}{
+		{"/Trash/2016-04-01/report.pdf", 1, "/Trash/2016-04-01/report (archived 150405).pdf"},
+		{"/Trash/2016-04-01/report.pdf", 2, "/Trash/2016-04-01/report (archived 150405 2).pdf"},
+		{"/Trash/2016-04-01/report.tar.gz", 3, "/Trash/2016-04-01/report.tar (archived 150405 3).gz"},
+		{"/Trash/2016-04-01/Photos", 1, "/Trash/2016-04-01/Photos (archived 150405)"},
+		{"/Trash/2016-04-01/v1.2/notes", 1, "/Trash/2016-04-01/v1.2/notes (archived 150405)"},
+	}
+	for _, tt := range tests {
+		if got := archiveCollisionName(tt.p, now, tt.n); got != tt.want {
+			t.Errorf("archiveCollisionName(%q, %d) = %q, want %q", tt.p, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveMoveCollisions(t *testing.T) {
+	fake := useFakeFiles(t)
+	api := useFakeAPI(t)
+	fake.Respond("GetMetadata", fileMetadata("/Trash/x/report.pdf", 1), nil)
+	fake.Respond("GetMetadata", fileMetadata("/Trash/x/report (archived 000000).pdf", 1), nil)
+	fake.Respond("GetMetadata", nil, notFoundError())
+	api.Respond("files/move_batch_v2", 200, `{".tag": "complete", "entries": [{".tag": "success", "success": {".tag": "file", "path_display": "/Trash/x/report (archived 000000 2).pdf"}}]}`)
+
+	to, err := archiveMove(fake, "/docs/report.pdf", "/Trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to != "/Trash/x/report (archived 000000 2).pdf" {
+		t.Errorf("archived to %q", to)
+	}
+
+	// Each name is looked up until a free one is found, which is moved to.
+	base := archivePath("/Trash", "/docs/report.pdf", time.Now())
+	var lookups []string
+	for _, c := range fake.Calls() {
+		lookups = append(lookups, c.Arg.(*files.GetMetadataArg).Path)
+	}
+	if len(lookups) != 3 || lookups[0] != base || !strings.HasSuffix(lookups[1], ").pdf") || !strings.HasSuffix(lookups[2], " 2).pdf") {
+		t.Errorf("looked up %q", lookups)
+	}
+	var arg relocationBatchArg
+	if err := json.Unmarshal([]byte(api.Calls()[0].Arg), &arg); err != nil {
+		t.Fatal(err)
+	}
+	if got := arg.Entries[0]; got.FromPath != "/docs/report.pdf" || got.ToPath != lookups[2] {
+		t.Errorf("moved %+v, want to %s", got, lookups[2])
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"30d", 30 * day, true},
+		{"0d", 0, true},
+		{"2w", 14 * day, true},
+		{"1y", 365 * day, true},
+		{"12h", 12 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"", 0, false},
+		{"d", 0, false},
+		{"-1d", 0, false},
+		{"-1h", 0, false},
+		{"1.5d", 0, false},
+		{"1x", 0, false},
+		{"y1", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v, ok %v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// A file or folder in the tree. A folder's size is that of everything in it.
+type treeNode struct {
+	name     string
+	folder   bool
+	size     uint64
+	children []*treeNode
+}
+
+// Builds the tree of everything beneath `root` from a recursive listing.
+func buildTree(root string, entries []files.IsMetadata) *treeNode {
+	top := &treeNode{name: root, folder: true}
+	rootLower := strings.ToLower(root)
+	if root == "" {
+		top.name, rootLower = "/", "/"
+	}
+	nodes := map[string]*treeNode{rootLower: top}
+	sortByDepth(entries)
+	for _, entry := range entries {
+		lower := metadataPathLower(entry)
+		parent, ok := nodes[path.Dir(lower)]
+		if !ok || lower == rootLower {
+			continue
+		}
+		n := &treeNode{name: path.Base(metadataPath(entry))}
+		switch e := entry.(type) {
+		case *files.FolderMetadata:
+			n.folder = true
+			nodes[lower] = n
+		case *files.FileMetadata:
+			n.size = e.Size
+			// Count the file towards every folder above it.
+			for dir := path.Dir(lower); ; dir = path.Dir(dir) {
+				if f, ok := nodes[dir]; ok {
+					f.size += e.Size
+				}
+				if dir == "/" || dir == "." || dir == rootLower {
+					break
+				}
+			}
+		}
+		parent.children = append(parent.children, n)
+	}
+	return top
+}
+
+func sortTree(n *treeNode) {
+	sort.Slice(n.children, func(i, j int) bool {
+		return strings.ToLower(n.children[i].name) < strings.ToLower(n.children[j].name)
+	})
+}
+
+// Draws the children of `n` down to `depth` levels (all of them if it's 0),
+// counting what's drawn into `folders` and `files`.
+func printTree(w io.Writer, n *treeNode, prefix string, depth int, sizes bool, folders, files *int) {
+	sortTree(n)
+	for i, c := range n.children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		label := c.name
+		if sizes {
+			label = fmt.Sprintf("[%s] %s", humanize.IBytes(c.size), c.name)
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, label)
+		if !c.folder {
+			*files++
+			continue
+		}
+		*folders++
+		if depth != 1 {
+			printTree(w, c, prefix+indent, depth-1, sizes, folders, files)
+		}
+	}
+}
+
+// A file or folder in the tree as printed with --json. `children` is left out
+// for files and for folders below --depth, so an empty folder can be told
+// from one that wasn't drawn.
+type jsonTreeNode struct {
+	Type     string          `json:"type"`
+	Name     string          `json:"name"`
+	Size     uint64          `json:"size"`
+	Children *[]jsonTreeNode `json:"children,omitempty"`
+}
+
+func newJSONTreeNode(n *treeNode) jsonTreeNode {
+	if n.folder {
+		return jsonTreeNode{Type: "folder", Name: n.name, Size: n.size}
+	}
+	return jsonTreeNode{Type: "file", Name: n.name, Size: n.size}
+}
+
+// Converts `n` with its children down to `depth` levels (all of them if it's
+// 0), counting them as printTree does.
+func jsonTree(n *treeNode, depth int, folders, files *int) jsonTreeNode {
+	j := newJSONTreeNode(n)
+	children := []jsonTreeNode{}
+	sortTree(n)
+	for _, c := range n.children {
+		if !c.folder {
+			*files++
+			children = append(children, newJSONTreeNode(c))
+			continue
+		}
+		*folders++
+		if depth == 1 {
+			children = append(children, newJSONTreeNode(c))
+			continue
+		}
+		children = append(children, jsonTree(c, depth-1, folders, files))
+	}
+	j.Children = &children
+	return j
+}
+
+func tree(cmd *cobra.Command, args []string) (err error) {
+	if len(args) > 1 {
+		return errors.New("`tree` takes at most one `path` argument")
+	}
+	root := ""
+	if len(args) == 1 {
+		if root, err = validatePath(args[0]); err != nil {
+			return
+		}
+	}
+	depth, _ := cmd.Flags().GetInt("depth")
+	if depth < 0 {
+		return fmt.Errorf("`--depth` must be 0 or more, not %d", depth)
+	}
+	sizes, _ := cmd.Flags().GetBool("size")
+
+	var entries []files.IsMetadata
+	err = walkFolder(cmdCtx, newFilesClient(cmdCtx), root, "", 0, nil, func(page []files.IsMetadata) {
+		entries = append(entries, page...)
+	})
+	if err != nil {
+		return
+	}
+
+	top := buildTree(root, entries)
+	folders, fileCount := 0, 0
+	if jsonMode(cmd) {
+		return printJSON(struct {
+			jsonTreeNode
+			Folders int `json:"folders"`
+			Files   int `json:"files"`
+		}{jsonTree(top, depth, &folders, &fileCount), folders, fileCount})
+	}
+	label := top.name
+	if sizes {
+		label = fmt.Sprintf("[%s] %s", humanize.IBytes(top.size), top.name)
+	}
+	fmt.Println(label)
+	printTree(os.Stdout, top, "", depth, sizes, &folders, &fileCount)
+	fmt.Printf("\n%d folders, %d files\n", folders, fileCount)
+	return
+}
+
+// treeCmd represents the tree command
+var treeCmd = &cobra.Command{
+	Use:   "tree [flags] [<path>]",
+	Short: "Show a folder and everything in it as a tree",
+	Long: `Show a folder and everything in it as a tree.
+
+Everything beneath <path>, or your whole Dropbox, is listed and drawn as a
+tree, sorted by name. --depth limits how many levels are drawn, and --size
+shows the size of each file and folder, a folder's being that of everything
+in it however deep.
+
+With --json, the tree is printed as nested objects with the fields "type",
+"name", "size" and, for folders, "children"; the outermost one also has
+"folders" and "files", the number of each drawn.`,
+	Example: `  dbxcli tree /Projects
+  dbxcli tree --depth 2 --size /Photos`,
+	RunE: tree,
+}
+
+func init() {
+	RootCmd.AddCommand(treeCmd)
+	treeCmd.Flags().IntP("depth", "L", 0, "Draw at most this many levels (0 means all of them)")
+	treeCmd.Flags().BoolP("size", "s", false, "Show the size of each file and folder")
+}
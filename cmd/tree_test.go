@@ -0,0 +1,68 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+func TestJSONTree(t *testing.T) {
+	entries := []files.IsMetadata{
+		folderMetadata("/p/b"),
+		fileMetadata("/p/b/c.txt", 3),
+		folderMetadata("/p/empty"),
+		fileMetadata("/p/A.txt", 1),
+	}
+	tests := []struct {
+		depth            int
+		want             string
+		folders, fileNum int
+	}{
+		{
+			depth: 0,
+			want: `{"type":"folder","name":"/p","size":4,"children":[` +
+				`{"type":"file","name":"A.txt","size":1},` +
+				`{"type":"folder","name":"b","size":3,"children":[{"type":"file","name":"c.txt","size":3}]},` +
+				`{"type":"folder","name":"empty","size":0,"children":[]}]}`,
+			folders: 2,
+			fileNum: 2,
+		},
+		{
+			depth: 1,
+			want: `{"type":"folder","name":"/p","size":4,"children":[` +
+				`{"type":"file","name":"A.txt","size":1},` +
+				`{"type":"folder","name":"b","size":3},` +
+				`{"type":"folder","name":"empty","size":0}]}`,
+			folders: 2,
+			fileNum: 1,
+		},
+	}
+	for _, tt := range tests {
+		folders, fileNum := 0, 0
+		b, err := json.Marshal(jsonTree(buildTree("/p", entries), tt.depth, &folders, &fileNum))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("depth %d: jsonTree = %s, want %s", tt.depth, b, tt.want)
+		}
+		if folders != tt.folders || fileNum != tt.fileNum {
+			t.Errorf("depth %d: counted %d folders and %d files, want %d and %d", tt.depth, folders, fileNum, tt.folders, tt.fileNum)
+		}
+	}
+}
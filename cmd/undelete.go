@@ -0,0 +1,172 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Returned by undeleteFile for paths it leaves alone.
+var (
+	errNotDeleted = errors.New("it isn't deleted")
+	errNotFile    = errors.New("it's a folder; undelete the files in it, which `ls --deleted` lists, or use `--recursive`")
+)
+
+// Restores the deleted file `p` to its latest revision, returning that
+// revision.
+func undeleteFile(dbx files.Client, p string) (rev string, err error) {
+	res, err := listRevisions(cmdCtx, p)
+	if e, ok := err.(rpcError); ok && strings.HasPrefix(e.ErrorSummary, "path/not_file") {
+		return "", errNotFile
+	}
+	if err != nil {
+		return
+	}
+	if !res.IsDeleted {
+		return "", errNotDeleted
+	}
+	if len(res.Entries) == 0 {
+		return "", errors.New("it has no revisions left to restore")
+	}
+	rev = res.Entries[0].Rev
+	_, err = dbx.Restore(files.NewRestoreArg(p, rev))
+	return
+}
+
+// Lists what's been deleted beneath the folder `p`. A folder that was itself
+// deleted can't be listed, so then the listing starts from its nearest parent
+// that still exists and is narrowed down to `p`.
+func listDeletedBeneath(p string) (paths []string, err error) {
+	root := p
+	var entries []files.IsMetadata
+	for {
+		arg := files.NewListFolderArg(root)
+		arg.Recursive, arg.IncludeDeleted = true, true
+		entries, _, err = listFolderArgExtras(cmdCtx, arg)
+		if !isMissing(err) || !strings.HasPrefix(root, "/") {
+			break
+		}
+		if root = path.Dir(root); root == "/" {
+			root = ""
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	prefix := strings.ToLower(p) + "/"
+	for _, entry := range entries {
+		if d, ok := entry.(*files.DeletedMetadata); ok && strings.HasPrefix(d.PathLower, prefix) {
+			paths = append(paths, d.PathDisplay)
+		}
+	}
+	return
+}
+
+// One line of JSON output for a restored file, or one that couldn't be.
+type undeleteRecord struct {
+	Path  string           `json:"path"`
+	Rev   string           `json:"rev,omitempty"`
+	Error *jsonErrorFields `json:"error,omitempty"`
+}
+
+func undelete(cmd *cobra.Command, args []string) (err error) {
+	if len(args) == 0 {
+		return errors.New("`undelete` requires a `file` argument")
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	dbx := newFilesClient(cmdCtx)
+
+	var paths []string
+	for _, arg := range args {
+		p, err := validatePath(arg)
+		if err != nil {
+			return err
+		}
+		if !recursive {
+			paths = append(paths, p)
+			continue
+		}
+		deleted, err := listDeletedBeneath(p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, deleted...)
+	}
+
+	failed := 0
+	for _, p := range paths {
+		rev, err := undeleteFile(dbx, p)
+		switch {
+		case recursive && (err == errNotFile || err == errNotDeleted):
+			// Deleted folders are listed alongside the files in them, and
+			// a file may have been restored since.
+		case err != nil:
+			if cmdCtx.Err() != nil {
+				return cmdCtx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p, explainError(err))
+			failed++
+			if jsonMode(cmd) {
+				f := errorFields(err)
+				if err = printJSONLine(undeleteRecord{Path: p, Error: &f}); err != nil {
+					return err
+				}
+			}
+		case jsonMode(cmd):
+			if err = printJSONLine(undeleteRecord{Path: p, Rev: rev}); err != nil {
+				return err
+			}
+		default:
+			fmt.Printf("Restored %s (revision %s)\n", p, rev)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files couldn't be undeleted", failed, len(paths))
+	}
+	return
+}
+
+// undeleteCmd represents the undelete command
+var undeleteCmd = &cobra.Command{
+	Use:   "undelete [flags] <file>...",
+	Short: "Bring back deleted files",
+	Long: `Bring back deleted files by restoring each to its latest revision.
+
+"dbxcli ls --deleted" lists what's been deleted from a folder. A deleted
+folder is brought back by undeleting the files in it; with --recursive,
+<file> is a folder and every deleted file beneath it is restored, whether
+or not the folder itself was deleted.
+Dropbox keeps deleted files for a limited time, depending on the plan.
+
+With --json, a line of JSON is printed for each file, with the fields
+"path" and "rev" (the revision restored), or "error" if it couldn't be.`,
+	Example: `  dbxcli ls --deleted /Documents
+  dbxcli undelete /Documents/report.pdf
+  dbxcli undelete --recursive /Documents`,
+	RunE: undelete,
+}
+
+func init() {
+	RootCmd.AddCommand(undeleteCmd)
+	undeleteCmd.Flags().BoolP("recursive", "r", false, "Undelete every deleted file beneath the folder <file>")
+}
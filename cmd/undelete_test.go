@@ -0,0 +1,89 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dropbox/dbxcli/testutil"
+)
+
+func deletedEntry(p string) string {
+	b, _ := json.Marshal(map[string]string{".tag": "deleted", "name": p[len(p)-1:], "path_lower": p, "path_display": p})
+	return string(b)
+}
+
+func TestListDeletedBeneath(t *testing.T) {
+	api := useFakeAPI(t)
+	notFound := `{"error_summary": "path/not_found/..", "error": {".tag": "path", "path": {".tag": "not_found"}}}`
+	api.Respond("files/list_folder", http.StatusConflict, notFound)
+	api.Respond("files/list_folder", http.StatusConflict, notFound)
+	api.Respond("files/list_folder", http.StatusOK, `{"entries": [`+
+		deletedEntry("/docs/old")+`, `+
+		deletedEntry("/docs/old/a")+`, `+
+		deletedEntry("/docs/old/sub/b")+`, `+
+		deletedEntry("/docs/older/c")+`, `+
+		deletedEntry("/docs/d")+`], "cursor": "c1", "has_more": false}`)
+
+	paths, err := listDeletedBeneath("/docs/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/docs/old/a", "/docs/old/sub/b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("listDeletedBeneath = %v, want %v", paths, want)
+	}
+
+	var listed []string
+	for _, c := range api.Calls() {
+		var arg struct{ Path string }
+		json.Unmarshal([]byte(c.Arg), &arg)
+		listed = append(listed, arg.Path)
+	}
+	if want := []string{"/docs/old", "/docs", ""}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("listed %q, want %q", listed, want)
+	}
+}
+
+// With --json, each file gets a line saying what was restored or why not.
+func TestUndeleteJSON(t *testing.T) {
+	api := useFakeAPI(t)
+	api.Respond("files/list_revisions", http.StatusOK, `{"is_deleted": true, "entries": [{"rev": "r2"}]}`)
+	api.Respond("files/list_revisions", http.StatusOK, `{"is_deleted": false, "entries": [{"rev": "r1"}]}`)
+	fake := useFakeFiles(t)
+	fake.Respond("Restore", fileMetadata("/a.txt", 1), nil)
+	setFlags(t, undeleteCmd, map[string]string{"json": "true"})
+
+	stdout, _, err := testutil.Capture(func() error { return undelete(undeleteCmd, []string{"/a.txt", "/b.txt"}) })
+	if err == nil {
+		t.Error("undelete succeeded for a file that isn't deleted")
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("printed %q", stdout)
+	}
+	var restored, failed undeleteRecord
+	json.Unmarshal([]byte(lines[0]), &restored)
+	json.Unmarshal([]byte(lines[1]), &failed)
+	if restored.Path != "/a.txt" || restored.Rev != "r2" || restored.Error != nil {
+		t.Errorf("first line = %s", lines[0])
+	}
+	if failed.Path != "/b.txt" || failed.Error == nil || !strings.Contains(failed.Error.Message, "isn't deleted") {
+		t.Errorf("second line = %s", lines[1])
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Rejects get --verify where there's no local copy of the remote file to
+// check.
+func checkVerify(cmd *cobra.Command, args []string, dec decryptOptions) error {
+	if verify, _ := cmd.Flags().GetBool("verify"); !verify {
+		return nil
+	}
+	zip, _ := cmd.Flags().GetBool("zip")
+	link, _ := cmd.Flags().GetString("link")
+	format, _ := cmd.Flags().GetString("format")
+	switch {
+	case dec.ids != nil:
+		return errors.New("`--verify` can't check decrypted downloads against Dropbox")
+	case zip || format != "":
+		return errors.New("`--verify` can't check archives or exported documents")
+	case link != "" || isSharedLink(args[0]):
+		return errors.New("`--verify` can't check downloads from shared links")
+	case len(args) > 1 && args[len(args)-1] == stdoutDestination:
+		return errors.New("`--verify` needs a local file to check")
+	}
+	return nil
+}
+
+// Implements --verify: checks that the local file `local` has the same
+// content hash as revision `rev` of the remote file `remote`, once it's been
+// transferred. The hash is fetched afresh, so if the file has changed on
+// Dropbox in the meantime it can't be checked and that's an error too.
+func verifyTransfer(ctx context.Context, local string, remote string, rev string) error {
+	md, x, _, err := getMetadataExtras(ctx, remote)
+	if err != nil {
+		return err
+	}
+	f, ok := md.(*files.FileMetadata)
+	if !ok || f.Rev != rev || x.ContentHash == "" {
+		return fmt.Errorf("can't verify %s: it changed on Dropbox during the transfer", remote)
+	}
+	hash, err := contentHash(local)
+	if err != nil {
+		return err
+	}
+	if hash != x.ContentHash {
+		logger.Error("verification failed", "local", local, "remote", remote, "local_hash", hash, "remote_hash", x.ContentHash)
+		return fmt.Errorf("verification failed: %s and %s differ (content hash %s here, %s on Dropbox)", local, f.PathDisplay, hash, x.ContentHash)
+	}
+	logger.Info("verified", "local", local, "remote", remote, "hash", hash)
+	return nil
+}
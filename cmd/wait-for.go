@@ -0,0 +1,253 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+const (
+	waitAppear    = "appear"
+	waitChange    = "change"
+	waitDisappear = "disappear"
+)
+
+// How long each longpoll request asks Dropbox to hold on, in seconds.
+const waitLongpollTimeout = 300
+
+// Polling schedule used when longpoll isn't available, and after errors.
+// It's unbounded; `--timeout` is what limits the wait. When Dropbox is rate
+// limiting us, it waits at least as long as Dropbox asks.
+var waitPollPolicy = retry.HonorRetryAfter{Policy: retry.Exponential{Initial: 2 * time.Second, Max: time.Minute, Jitter: 0.2}}
+
+// What wait-for is waiting for. `rev` is the revision seen at the start, or
+// empty if nothing was there.
+type waitCondition struct {
+	mode    string
+	existed bool
+	rev     string
+}
+
+func (c waitCondition) met(md files.IsMetadata) bool {
+	switch c.mode {
+	case waitAppear:
+		return md != nil
+	case waitDisappear:
+		return md == nil
+	}
+	if md == nil {
+		return c.existed
+	}
+	f, ok := md.(*files.FileMetadata)
+	return !c.existed || (ok && f.Rev != c.rev)
+}
+
+// Fetches the metadata of `p`. A missing path isn't an error; md is nil.
+func waitLookup(ctx context.Context, p string) (md files.IsMetadata, x fileExtras, raw json.RawMessage, err error) {
+	md, x, raw, err = getMetadataExtras(ctx, p)
+	if isMissing(err) {
+		return nil, fileExtras{}, nil, nil
+	}
+	return
+}
+
+// Errors which mean Dropbox wants us to slow down rather than give up.
+func isRateLimited(err error) bool {
+	summary, ok := apiErrorSummary(err)
+	return ok && (strings.HasPrefix(summary, "too_many_requests") || strings.HasPrefix(summary, "too_many_write_operations"))
+}
+
+// Waits until `cond` holds for `p`. Between checks it longpolls the folder
+// containing `p`, so a change is noticed within seconds without polling
+// get_metadata; where that's impossible, e.g. for ids or while the folder
+// doesn't exist, it polls with backoff instead.
+func waitFor(ctx context.Context, dbx files.Client, p string, cond waitCondition) (md files.IsMetadata, x fileExtras, raw json.RawMessage, err error) {
+	folder, canWatch := path.Dir(p), !isIDPath(p)
+	if folder == "/" {
+		folder = ""
+	}
+
+	for attempt := 1; ; {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		// Take the cursor before looking, so that a change in between still
+		// ends the longpoll.
+		var cursor string
+		if canWatch {
+			if res, cursorErr := dbx.ListFolderGetLatestCursor(files.NewListFolderArg(folder)); cursorErr == nil {
+				cursor = res.Cursor
+			}
+		}
+
+		md, x, raw, err = waitLookup(ctx, p)
+		if err == nil && cond.met(md) {
+			return
+		}
+		if err != nil && !isRateLimited(err) {
+			return
+		}
+
+		var wait time.Duration
+		if err == nil && cursor != "" {
+			arg := files.NewListFolderLongpollArg(cursor)
+			arg.Timeout = waitLongpollTimeout
+			res, pollErr := dbx.ListFolderLongpoll(arg)
+			if pollErr == nil {
+				attempt = 1
+				if res.Backoff == 0 {
+					continue
+				}
+				wait = time.Duration(res.Backoff) * time.Second
+			} else if ctx.Err() == nil {
+				logger.Debug("longpoll failed, polling instead", "path", folder, "error", pollErr)
+			}
+		}
+		if wait == 0 {
+			wait, _ = waitPollPolicy.Backoff(attempt, err)
+			attempt++
+		}
+		if err = retry.Sleep(ctx, wait); err != nil {
+			return
+		}
+	}
+}
+
+// Runs `command` with the shell, telling it about the path through the
+// environment.
+func runWaitCommand(command string, p string, md files.IsMetadata) error {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/c", command)
+	} else {
+		c = exec.Command("sh", "-c", command)
+	}
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = os.Environ()
+	if f, ok := md.(*files.FileMetadata); ok {
+		c.Env = append(c.Env, "DBXCLI_PATH="+f.PathDisplay, "DBXCLI_REV="+f.Rev)
+	} else {
+		c.Env = append(c.Env, "DBXCLI_PATH="+p)
+	}
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("`--exec` command failed: %v", err)
+	}
+	return nil
+}
+
+func waitForCmdRun(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("`wait-for` requires a `path` argument")
+	}
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+
+	cond := waitCondition{mode: waitAppear}
+	chosen := 0
+	for _, mode := range []string{waitAppear, waitChange, waitDisappear} {
+		if set, _ := cmd.Flags().GetBool(mode); set {
+			cond.mode = mode
+			chosen++
+		}
+	}
+	if chosen > 1 {
+		return errors.New("only one of `--appear`, `--change` and `--disappear` can be given")
+	}
+
+	if cond.mode == waitChange {
+		md, _, _, err := waitLookup(cmdCtx, p)
+		if err != nil {
+			return err
+		}
+		if _, isFolder := md.(*files.FolderMetadata); isFolder {
+			return fmt.Errorf("%s is a folder; `--change` only works for files, which have revisions", p)
+		}
+		if f, ok := md.(*files.FileMetadata); ok {
+			cond.existed, cond.rev = true, f.Rev
+		}
+	}
+
+	md, x, raw, err := waitFor(cmdCtx, newFilesClient(cmdCtx), p, cond)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case md == nil:
+		fmt.Printf("%s is gone\n", p)
+	case jsonMode(cmd):
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(raw)
+	default:
+		err = printMetadata(md, x, "")
+	}
+	if err != nil {
+		return
+	}
+
+	if command, _ := cmd.Flags().GetString("exec"); command != "" {
+		return runWaitCommand(command, p, md)
+	}
+	return
+}
+
+// waitForCmd represents the wait-for command
+var waitForCmd = &cobra.Command{
+	Use:   "wait-for [flags] <path>",
+	Short: "Wait until a file or folder appears, changes or disappears",
+	Long: `Wait until a file or folder appears, changes or disappears, then print its
+metadata like stat and exit.
+
+By default wait-for waits for <path> to appear; --change waits for a file's
+revision to differ from the one it had at the start (creating or deleting it
+counts too), and --disappear waits for it to be deleted or moved away.
+
+Changes are noticed with a long-lived request to Dropbox on the folder
+containing <path>, falling back to polling with backoff when that folder
+doesn't exist yet. Use the global --timeout to give up after a while, in
+which case wait-for exits with an error.
+
+--exec runs a shell command once the condition is met, with DBXCLI_PATH set
+to the path and, for files, DBXCLI_REV to the revision.`,
+	Example: `  dbxcli wait-for /incoming/data.csv --timeout 2h
+  dbxcli wait-for /incoming/data.csv --change --exec 'make import'
+  dbxcli wait-for /locks/deploy --disappear`,
+	RunE: waitForCmdRun,
+}
+
+func init() {
+	RootCmd.AddCommand(waitForCmd)
+	waitForCmd.Flags().Bool(waitAppear, false, "Wait for <path> to exist (the default)")
+	waitForCmd.Flags().Bool(waitChange, false, "Wait for the file's revision to change")
+	waitForCmd.Flags().Bool(waitDisappear, false, "Wait for <path> to no longer exist")
+	waitForCmd.Flags().String("exec", "", "Shell command to run once the condition is met")
+}
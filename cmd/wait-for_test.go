@@ -0,0 +1,82 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// A clock whose waits end at once, recording how long each was.
+type recordingClock struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time { return time.Unix(0, 0) }
+
+func (c *recordingClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.waits = append(c.waits, d)
+	c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	ch <- time.Unix(0, 0)
+	return ch
+}
+
+func TestDecodeRateLimit(t *testing.T) {
+	body := `{"error_summary": "too_many_requests/..", "error": {"reason": {".tag": "too_many_requests"}, "retry_after": 30}}`
+	err := decodeResponse(429, []byte(body), nil)
+	if !isRateLimited(err) {
+		t.Errorf("%v isn't rate limiting", err)
+	}
+	if ra, ok := err.(retry.RetryAfterer); !ok || ra.RetryAfter() != 30*time.Second {
+		t.Errorf("%#v doesn't ask for 30s", err)
+	}
+	if d, _ := waitPollPolicy.Backoff(1, err); d != 30*time.Second {
+		t.Errorf("waited %v after being asked for 30s", d)
+	}
+	if d, _ := waitPollPolicy.Backoff(1, nil); d > 3*time.Second {
+		t.Errorf("first poll after %v", d)
+	}
+}
+
+func TestWaitForRateLimited(t *testing.T) {
+	api := useFakeAPI(t)
+	clock := &recordingClock{}
+	ctx := retry.WithClock(context.Background(), clock)
+
+	api.Respond("files/get_metadata", 429, `{"error_summary": "too_many_requests/", "error": {"reason": {".tag": "too_many_requests"}, "retry_after": 45}}`)
+	api.Respond("files/get_metadata", 409, `{"error_summary": "path/not_found/", "error": {".tag": "path", "path": {".tag": "not_found"}}}`)
+	api.Respond("files/get_metadata", 200, `{".tag": "file", "name": "a.txt", "id": "id:abc", "path_lower": "/a.txt", "path_display": "/a.txt",
+		"rev": "015f0000000000000001", "size": 1, "client_modified": "2016-06-01T12:00:00Z", "server_modified": "2016-06-01T12:00:00Z"}`)
+
+	// Ids can't be longpolled, so this polls.
+	md, _, _, err := waitFor(ctx, nil, "id:abc", waitCondition{mode: waitAppear})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := md.(*files.FileMetadata); !ok || f.PathDisplay != "/a.txt" {
+		t.Errorf("waited for %#v", md)
+	}
+	if len(clock.waits) != 2 || clock.waits[0] != 45*time.Second || clock.waits[1] > 5*time.Second {
+		t.Errorf("waits = %v, want 45s and then a short poll", clock.waits)
+	}
+}
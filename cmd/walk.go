@@ -0,0 +1,137 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// Bumped whenever the layout of walkState changes, so old state files are
+// rejected instead of misread.
+const walkStateVersion = 1
+
+// What a resumable walk saves between pages: where the listing got to and
+// the caller's running totals.
+type walkState struct {
+	Version int             `json:"version"`
+	Path    string          `json:"path"`
+	Cursor  string          `json:"cursor"`
+	Pages   int             `json:"pages"`
+	Totals  json.RawMessage `json:"totals"`
+}
+
+func readWalkState(stateFile string) (*walkState, error) {
+	b, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s walkState
+	if err = json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %v", stateFile, err)
+	}
+	if s.Version != walkStateVersion {
+		return nil, fmt.Errorf("%s was written by a different version of dbxcli (state version %d, expected %d); delete it to start over",
+			stateFile, s.Version, walkStateVersion)
+	}
+	return &s, nil
+}
+
+func writeWalkState(stateFile string, s *walkState) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(stateFile, b)
+}
+
+// Lists everything beneath `root` recursively, calling `visit` with each
+// page of entries. `visit` accumulates into `totals`, which must be a pointer
+// to something JSON can round-trip.
+//
+// With a `stateFile`, the cursor and `totals` are saved every `every` pages,
+// and a later walk of the same root picks up where the saved one stopped.
+// The file is removed once the walk completes.
+func walkFolder(ctx context.Context, dbx files.Client, root string, stateFile string, every int, totals interface{}, visit func([]files.IsMetadata)) (err error) {
+	var state *walkState
+	if stateFile != "" {
+		if state, err = readWalkState(stateFile); err != nil {
+			return
+		}
+	}
+
+	var res *files.ListFolderResult
+	if state != nil {
+		if state.Path != root {
+			return fmt.Errorf("%s belongs to a walk of %q, not %q", stateFile, state.Path, root)
+		}
+		if err = json.Unmarshal(state.Totals, totals); err != nil {
+			return fmt.Errorf("%s: %v", stateFile, err)
+		}
+		res = &files.ListFolderResult{Cursor: state.Cursor, HasMore: true}
+	} else {
+		state = &walkState{Version: walkStateVersion, Path: root}
+		arg := files.NewListFolderArg(root)
+		arg.Recursive = true
+		if res, err = dbx.ListFolder(arg); err != nil {
+			return
+		}
+		visit(res.Entries)
+		state.Pages++
+	}
+
+	for res.HasMore {
+		if stateFile != "" && every > 0 && state.Pages%every == 0 {
+			state.Cursor = res.Cursor
+			if state.Totals, err = json.Marshal(totals); err != nil {
+				return
+			}
+			if err = writeWalkState(stateFile, state); err != nil {
+				return
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		res, err = dbx.ListFolderContinue(files.NewListFolderContinueArg(res.Cursor))
+		if e, ok := err.(files.ListFolderContinueAPIError); ok && e.EndpointError != nil && e.EndpointError.Tag == files.ListFolderContinueErrorReset {
+			if stateFile != "" {
+				os.Remove(stateFile)
+			}
+			return fmt.Errorf("must restart; remote changed too much (the listing cursor of %q was reset)", root)
+		}
+		if err != nil {
+			return
+		}
+		visit(res.Entries)
+		state.Pages++
+	}
+
+	if stateFile != "" {
+		if err = os.Remove(stateFile); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	return
+}
@@ -0,0 +1,250 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// The size and modification time of a local file, which is all a scan looks
+// at to notice a change.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{info.Size(), info.ModTime()}
+}
+
+// Finds the files to upload after a scan of the watched directory. A file is
+// uploaded once it differs from what was last uploaded (or from what was
+// there at the start) and hasn't changed since the previous scan, so files
+// that are still being written aren't uploaded half-done.
+type localScanner struct {
+	uploaded map[string]fileStamp
+	previous map[string]fileStamp
+}
+
+func newLocalScanner(tree map[string]localEntry) *localScanner {
+	s := &localScanner{uploaded: make(map[string]fileStamp), previous: make(map[string]fileStamp)}
+	for key, e := range tree {
+		if !e.info.IsDir() {
+			s.uploaded[key] = stampOf(e.info)
+			s.previous[key] = stampOf(e.info)
+		}
+	}
+	return s
+}
+
+// Returns the keys of the files in `tree` ready to be uploaded.
+func (s *localScanner) scan(tree map[string]localEntry) (ready []string) {
+	current := make(map[string]fileStamp)
+	for key, e := range tree {
+		if e.info.IsDir() {
+			continue
+		}
+		stamp := stampOf(e.info)
+		current[key] = stamp
+		if last, ok := s.uploaded[key]; ok && last == stamp {
+			continue
+		}
+		if prev, ok := s.previous[key]; ok && prev == stamp {
+			ready = append(ready, key)
+		}
+	}
+	for key := range s.uploaded {
+		if _, ok := current[key]; !ok {
+			// Deleted; if it comes back it's new.
+			delete(s.uploaded, key)
+		}
+	}
+	s.previous = current
+	return
+}
+
+func (s *localScanner) markUploaded(key string, stamp fileStamp) {
+	s.uploaded[key] = stamp
+}
+
+// Returns whether any file has changed since it was last uploaded, either
+// because it's still being written or because its upload failed. Either way
+// it needs another scan.
+func (s *localScanner) pending() bool {
+	for key, stamp := range s.previous {
+		if last, ok := s.uploaded[key]; !ok || last != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+// Watches one local directory and uploads its changes.
+type localWatch struct {
+	cmd        *cobra.Command
+	dbx        files.Client
+	scanner    *localScanner
+	localRoot  string
+	remoteRoot string
+}
+
+// Scans the local directory and uploads the files that are ready.
+func (w *localWatch) upload() (err error) {
+	// The first scan has already said which files are skipped.
+	tree, err := walkLocalTree(w.localRoot, ioutil.Discard)
+	if err != nil {
+		return
+	}
+	ready := w.scanner.scan(tree)
+	if len(ready) == 0 {
+		return
+	}
+
+	sort.Strings(ready)
+	jobs := make([]uploadJob, len(ready))
+	for i, key := range ready {
+		jobs[i] = uploadJob{tree[key].path, w.remoteRoot + "/" + tree[key].rel}
+	}
+	opts := uploadOptions{strategy: conflictOverwrite, chunkSize: defaultChunkSize, chunkParallelism: 1}
+	// Failed uploads aren't marked, so the next scan tries them again.
+	failures, _ := printOrderedResults(os.Stdout, uploadAll(cmdCtx, w.dbx, jobs, opts, defaultTransfers), jsonMode(w.cmd))
+	failed := make(map[int]bool)
+	for _, r := range failures {
+		failed[r.index] = true
+	}
+	for i, key := range ready {
+		if !failed[i] {
+			w.scanner.markUploaded(key, stampOf(tree[key].info))
+		}
+	}
+	return
+}
+
+// Adds `dir` and every directory beneath it to `watcher`.
+func watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		return watcher.Add(p)
+	})
+}
+
+// Uploads changes as fsnotify reports them. A scan is run `interval` after
+// the first event, and again every `interval` while files are pending, so a
+// burst of events costs one scan and a file is only uploaded once it's
+// stable.
+func (w *localWatch) notified(watcher *fsnotify.Watcher, interval time.Duration) (err error) {
+	var scan <-chan time.Time
+	for {
+		select {
+		case <-cmdCtx.Done():
+			return cmdCtx.Err()
+		case event := <-watcher.Events:
+			if event.Op&fsnotify.Create != 0 {
+				// Anything already in a new directory is found by the scan.
+				if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+					if err = watchDirs(watcher, event.Name); err != nil {
+						fmt.Fprintf(os.Stderr, "Watching %s: %v\n", event.Name, err)
+					}
+				}
+			}
+		case err = <-watcher.Errors:
+			// Events may have been lost, but the scan still finds the changes.
+			fmt.Fprintf(os.Stderr, "Watching %s: %v\n", w.localRoot, err)
+		case <-scan:
+			scan = nil
+			if err = w.upload(); err != nil {
+				return
+			}
+			if w.scanner.pending() {
+				scan = time.After(interval)
+			}
+			continue
+		}
+		if scan == nil {
+			scan = time.After(interval)
+		}
+	}
+}
+
+// Uploads changes found by scanning every `interval`, for when fsnotify
+// can't watch the directory.
+func (w *localWatch) polled(interval time.Duration) (err error) {
+	for {
+		if err = retry.Sleep(cmdCtx, interval); err != nil {
+			return
+		}
+		if err = w.upload(); err != nil {
+			return
+		}
+	}
+}
+
+// Implements `watch --local`: uploads new and modified files in `localRoot`
+// to the same relative paths under `remoteRoot`. Changes are picked up with
+// fsnotify, or by scanning every `interval` if the directory can't be
+// watched (for instance when the system's limit on watches is reached).
+func watchLocal(cmd *cobra.Command, localRoot string, remoteRoot string, interval time.Duration) (err error) {
+	tree, err := listLocalTree(localRoot)
+	if err != nil {
+		return
+	}
+	w := &localWatch{cmd, newFilesClient(cmdCtx), newLocalScanner(tree), localRoot, remoteRoot}
+
+	fmt.Fprintf(os.Stderr, "Watching %s for changes to upload to %s\n", localRoot, remoteRoot)
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		defer watcher.Close()
+		err = watchDirs(watcher, localRoot)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't be notified of changes (%v); scanning every %v instead\n", err, interval)
+		return w.polled(interval)
+	}
+	return w.notified(watcher, interval)
+}
+
+// Checks the flags of `watch --local` and runs it.
+func watchLocalCmd(cmd *cobra.Command, args []string) error {
+	localRoot, _ := cmd.Flags().GetString("local")
+	destination, _ := cmd.Flags().GetString("destination")
+	if destination == "" {
+		return errors.New("`watch --local` requires `--destination`")
+	}
+	if len(args) > 0 {
+		return errors.New("`watch --local` takes no `path` argument; use `--destination`")
+	}
+	remoteRoot, err := validatePath(destination)
+	if err != nil {
+		return err
+	}
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("`--interval` must be positive, not %v", interval)
+	}
+	return watchLocal(cmd, localRoot, remoteRoot, interval)
+}
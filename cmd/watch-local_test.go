@@ -0,0 +1,136 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// A file as a scan sees it: only its size and modification time matter.
+type stampInfo struct {
+	os.FileInfo
+	stamp fileStamp
+}
+
+func (i stampInfo) Size() int64        { return i.stamp.size }
+func (i stampInfo) ModTime() time.Time { return i.stamp.modTime }
+func (i stampInfo) IsDir() bool        { return false }
+
+// Builds a scanned tree from file names mapped to their sizes, with `second`
+// as every file's modification time.
+func stampTree(files map[string]int64, second int) map[string]localEntry {
+	tree := make(map[string]localEntry)
+	for key, size := range files {
+		stamp := fileStamp{size, time.Unix(int64(second), 0)}
+		tree[key] = localEntry{rel: key, path: key, info: stampInfo{stamp: stamp}}
+	}
+	return tree
+}
+
+func TestLocalScanner(t *testing.T) {
+	type scan struct {
+		files    map[string]int64
+		ready    []string
+		uploaded []string
+		pending  bool
+	}
+	tests := []struct {
+		name  string
+		start map[string]int64
+		scans []scan
+	}{
+		{
+			name:  "files there at the start aren't uploaded",
+			start: map[string]int64{"a": 1},
+			scans: []scan{
+				{files: map[string]int64{"a": 1}},
+				{files: map[string]int64{"a": 1}},
+			},
+		},
+		{
+			name:  "a new file is uploaded once it's stable",
+			start: map[string]int64{},
+			scans: []scan{
+				{files: map[string]int64{"a": 1}, pending: true},
+				{files: map[string]int64{"a": 2}, pending: true},
+				{files: map[string]int64{"a": 2}, ready: []string{"a"}, uploaded: []string{"a"}},
+				{files: map[string]int64{"a": 2}},
+			},
+		},
+		{
+			name:  "a modified file is uploaded once it's stable",
+			start: map[string]int64{"a": 1, "b": 1},
+			scans: []scan{
+				{files: map[string]int64{"a": 5, "b": 1}, pending: true},
+				{files: map[string]int64{"a": 5, "b": 1}, ready: []string{"a"}, uploaded: []string{"a"}},
+			},
+		},
+		{
+			name:  "a failed upload is tried again on the next scan",
+			start: map[string]int64{},
+			scans: []scan{
+				{files: map[string]int64{"a": 1}, pending: true},
+				{files: map[string]int64{"a": 1}, ready: []string{"a"}, pending: true},
+				{files: map[string]int64{"a": 1}, ready: []string{"a"}, uploaded: []string{"a"}},
+				{files: map[string]int64{"a": 1}},
+			},
+		},
+		{
+			name:  "a deleted file that comes back is new",
+			start: map[string]int64{"a": 1},
+			scans: []scan{
+				{files: map[string]int64{}},
+				{files: map[string]int64{"a": 1}, pending: true},
+				{files: map[string]int64{"a": 1}, ready: []string{"a"}, uploaded: []string{"a"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Modification times stay put, so only sizes tell changes apart.
+			s := newLocalScanner(stampTree(tt.start, 0))
+			for i, sc := range tt.scans {
+				tree := stampTree(sc.files, 0)
+				ready := s.scan(tree)
+				sort.Strings(ready)
+				if !reflect.DeepEqual(ready, sc.ready) {
+					t.Fatalf("scan %d: ready = %v, want %v", i, ready, sc.ready)
+				}
+				for _, key := range sc.uploaded {
+					s.markUploaded(key, stampOf(tree[key].info))
+				}
+				if got := s.pending(); got != sc.pending {
+					t.Fatalf("scan %d: pending() = %v, want %v", i, got, sc.pending)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalScannerModTime(t *testing.T) {
+	s := newLocalScanner(stampTree(map[string]int64{"a": 1}, 0))
+	// Same size, newer modification time: a change.
+	if ready := s.scan(stampTree(map[string]int64{"a": 1}, 1)); len(ready) != 0 {
+		t.Fatalf("ready = %v right after the change", ready)
+	}
+	if ready := s.scan(stampTree(map[string]int64{"a": 1}, 1)); !reflect.DeepEqual(ready, []string{"a"}) {
+		t.Fatalf("ready = %v, want [a]", ready)
+	}
+}
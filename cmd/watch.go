@@ -0,0 +1,235 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dropbox/dbxcli/retry"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/spf13/cobra"
+)
+
+// Kinds of change `watch` reports.
+const (
+	watchAdded    = "added"
+	watchModified = "modified"
+	watchDeleted  = "deleted"
+)
+
+// One line of `watch --json` output. Metadata is passed through exactly as
+// the API returned it.
+type watchEvent struct {
+	Event    string          `json:"event"`
+	Path     string          `json:"path"`
+	Time     time.Time       `json:"time"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// Tells added entries from modified ones by remembering which paths exist.
+type watcher struct {
+	ctx    context.Context
+	cursor string
+	seen   map[string]bool
+}
+
+// Lists `root` to learn what's already there, leaving the cursor at the end
+// of the listing so that only later changes are reported.
+func newWatcher(ctx context.Context, root string, recursive bool) (*watcher, error) {
+	w := &watcher{ctx: ctx, seen: make(map[string]bool)}
+	arg := files.NewListFolderArg(root)
+	arg.Recursive = recursive
+	var res rawListFolderResult
+	if err := rpc(ctx, "files", "list_folder", arg, &res); err != nil {
+		return nil, err
+	}
+	for {
+		for _, raw := range res.Entries {
+			md, _, err := decodeMetadata(raw)
+			if err != nil {
+				return nil, err
+			}
+			w.seen[metadataPathLower(md)] = true
+		}
+		if !res.HasMore {
+			w.cursor = res.Cursor
+			return w, nil
+		}
+		cursor := res.Cursor
+		res = rawListFolderResult{}
+		if err := rpc(ctx, "files", "list_folder/continue", files.NewListFolderContinueArg(cursor), &res); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Fetches the changes since the cursor and hands each to `emit`.
+func (w *watcher) changes(emit func(watchEvent) error) error {
+	for {
+		var res rawListFolderResult
+		err := rpc(w.ctx, "files", "list_folder/continue", files.NewListFolderContinueArg(w.cursor), &res)
+		if e, ok := err.(rpcError); ok && strings.HasPrefix(e.ErrorSummary, "reset") {
+			return errors.New("must restart; the listing cursor was reset by Dropbox")
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, raw := range res.Entries {
+			md, _, err := decodeMetadata(raw)
+			if err != nil {
+				return err
+			}
+			ev := watchEvent{Time: now, Metadata: raw}
+			lower := metadataPathLower(md)
+			switch m := md.(type) {
+			case *files.DeletedMetadata:
+				ev.Event, ev.Path, lower = watchDeleted, m.PathDisplay, m.PathLower
+				delete(w.seen, lower)
+			default:
+				ev.Event, ev.Path = watchAdded, metadataPath(md)
+				if w.seen[lower] {
+					ev.Event = watchModified
+				}
+				w.seen[lower] = true
+			}
+			if err = emit(ev); err != nil {
+				return err
+			}
+		}
+		w.cursor = res.Cursor
+		if !res.HasMore {
+			return nil
+		}
+	}
+}
+
+// Longpolls until something changes beneath the watched folder, backing off
+// as long as Dropbox asks to.
+func (w *watcher) wait(dbx files.Client) error {
+	for attempt := 1; ; {
+		arg := files.NewListFolderLongpollArg(w.cursor)
+		arg.Timeout = waitLongpollTimeout
+		res, err := dbx.ListFolderLongpoll(arg)
+		if err == nil {
+			if res.Backoff > 0 {
+				if err = retry.Sleep(w.ctx, time.Duration(res.Backoff)*time.Second); err != nil {
+					return err
+				}
+			}
+			if res.Changes {
+				return nil
+			}
+			attempt = 1
+			continue
+		}
+		if w.ctx.Err() != nil {
+			return w.ctx.Err()
+		}
+		if e, ok := err.(files.ListFolderLongpollAPIError); ok && e.EndpointError != nil && e.EndpointError.Tag == files.ListFolderLongpollErrorReset {
+			return errors.New("must restart; the listing cursor was reset by Dropbox")
+		}
+		logger.Debug("longpoll failed", "error", err)
+		d, _ := waitPollPolicy.Backoff(attempt, err)
+		attempt++
+		if err = retry.Sleep(w.ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+func watch(cmd *cobra.Command, args []string) (err error) {
+	if cmd.Flags().Changed("local") {
+		return watchLocalCmd(cmd, args)
+	}
+	if len(args) != 1 {
+		return errors.New("`watch` requires a `path` argument")
+	}
+	p, err := validatePath(args[0])
+	if err != nil {
+		return
+	}
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	asJSON := jsonMode(cmd)
+
+	w, err := newWatcher(cmdCtx, p, recursive)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Watching %s for changes\n", args[0])
+
+	enc := json.NewEncoder(os.Stdout)
+	emit := func(ev watchEvent) error {
+		if asJSON {
+			return enc.Encode(ev)
+		}
+		_, err := fmt.Printf("%s\t%s\n", ev.Event, ev.Path)
+		return err
+	}
+
+	dbx := newFilesClient(cmdCtx)
+	for {
+		if err = w.wait(dbx); err != nil {
+			return
+		}
+		if err = w.changes(emit); err != nil {
+			return
+		}
+	}
+}
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch [flags] <path>",
+	Short: "Print changes to a folder as they happen, or upload local ones",
+	Long: `Watch the folder <path> and print a line for each entry that's added,
+modified or deleted, as soon as Dropbox reports it, until interrupted or
+--timeout runs out. With --recursive, changes anywhere beneath the folder
+are reported.
+
+Each line holds the kind of change and the path, separated by a tab. With
+--json, each change is instead a JSON object on its own line, with the
+fields "event", "path", "time" and "metadata" (as returned by the API).
+
+With --local <dir> --destination <folder>, the local directory is watched
+instead, and files created or modified in it (or beneath it) are uploaded to
+the same relative paths under the Dropbox folder, replacing what's there.
+Changes are noticed through the system's file notifications (inotify,
+kqueue and so on); --interval after one arrives the directory is scanned,
+and a file is uploaded once it hasn't changed between two scans, so files
+still being written aren't uploaded half-done. Failed uploads are tried
+again on the next scan. Where notifications aren't available, the
+directory is instead scanned every --interval. Files already there at the
+start aren't uploaded, and deletions aren't passed on; use "dbxcli sync push" for a full mirror.`,
+	Example: `  dbxcli watch /Inbox
+  dbxcli watch --recursive --json /Uploads | jq -r 'select(.event == "added") | .path'
+  dbxcli watch --local /var/backups --destination /Backups/server1`,
+	RunE: watch,
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().BoolP("recursive", "r", false, "Report changes in subfolders too")
+	watchCmd.Flags().String("local", "", "Watch this local directory and upload its changes")
+	watchCmd.Flags().String("destination", "", "Dropbox folder to upload to with --local")
+	watchCmd.Flags().Duration("interval", 2*time.Second, "How long --local waits between scans of the directory")
+}
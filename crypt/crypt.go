@@ -0,0 +1,461 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crypt implements the streaming authenticated encryption used by
+// `put --encrypt` and `get --decrypt`.
+//
+// An encrypted file starts with a header holding a random file key wrapped
+// for each recipient, either an X25519 public key (in the style of age) or a
+// passphrase stretched with scrypt. The header ends with an HMAC of all of it
+// under a key derived from the file key, so recipients, chunk size and nonce
+// can't be swapped or altered once it's written. The payload follows as a sequence of
+// AES-256-GCM sealed chunks, following the STREAM construction: each chunk's
+// nonce holds its index and a flag marking the last chunk, so reordering,
+// dropping or truncating chunks fails authentication just like altering
+// their contents. Since every chunk is verified before any of it is
+// returned, a file of any size can be encrypted or decrypted while holding
+// only one chunk in memory.
+package crypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultChunkSize is the amount of plaintext sealed in each chunk.
+const DefaultChunkSize = 64 << 10
+
+const (
+	magic = "DBXENC\x00\x02"
+
+	kindX25519 = 1
+	kindScrypt = 2
+
+	keySize   = 32
+	tagSize   = 16
+	nonceSize = 16
+	macSize   = 32
+
+	minChunkLog = 4
+	maxChunkLog = 24
+)
+
+var (
+	// ErrAuthentication is returned when a chunk fails to authenticate:
+	// the file was corrupted or tampered with, or it isn't a file written by
+	// this package.
+	ErrAuthentication = errors.New("crypt: authentication failed; the file is corrupt or has been tampered with")
+	// ErrTruncated is returned when the stream ends before its last chunk.
+	ErrTruncated = errors.New("crypt: the file is truncated")
+	// ErrNoIdentity is returned when none of the identities can unwrap the
+	// file key.
+	ErrNoIdentity = errors.New("crypt: no key matches; wrong key or passphrase, or the header has been tampered with")
+
+	// ErrHeader is returned when the header doesn't match its MAC.
+	ErrHeader = errors.New("crypt: the header has been tampered with")
+
+	errNoMatch = errors.New("crypt: identity doesn't match")
+)
+
+// The source of file keys, nonces and salts. Tests replace it to get
+// reproducible output.
+var randReader io.Reader = rand.Reader
+
+// A stanza holds the file key wrapped for one recipient.
+type stanza struct {
+	kind byte
+	// For X25519 the ephemeral public key; for scrypt the salt followed by
+	// log2 of the work factor.
+	body    []byte
+	wrapped []byte
+}
+
+func bodySize(kind byte) int {
+	switch kind {
+	case kindX25519:
+		return 32
+	case kindScrypt:
+		return 17
+	}
+	return -1
+}
+
+func (s stanza) aad() []byte {
+	return append(append([]byte(magic), s.kind), s.body...)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Wrapping keys are used exactly once, so a zero nonce is safe.
+func seal(key, aad, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), plaintext, aad), nil
+}
+
+func open(key, aad, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, aad)
+	if err != nil {
+		return nil, errNoMatch
+	}
+	return plaintext, nil
+}
+
+func payloadKey(fileKey, nonce []byte) (cipher.AEAD, error) {
+	return newGCM(subkey(fileKey, nonce, "dbxenc payload"))
+}
+
+// headerMAC authenticates everything in the header before it.
+func headerMAC(fileKey, header []byte) []byte {
+	mac := hmac.New(sha256.New, subkey(fileKey, nil, "dbxenc header"))
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+func chunkLog(size int) (uint, error) {
+	for l := uint(minChunkLog); l <= maxChunkLog; l++ {
+		if size == 1<<l {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("crypt: chunk size %d isn't a power of two between %d and %d", size, 1<<minChunkLog, 1<<maxChunkLog)
+}
+
+func checkRecipients(rcpts []Recipient) error {
+	if len(rcpts) == 0 {
+		return errors.New("crypt: no recipients")
+	}
+	if len(rcpts) > 255 {
+		return errors.New("crypt: too many recipients")
+	}
+	for _, r := range rcpts {
+		if _, ok := r.(*ScryptRecipient); ok && len(rcpts) > 1 {
+			return errors.New("crypt: a passphrase can't be combined with other recipients")
+		}
+	}
+	return nil
+}
+
+// EncryptedSize returns the size of the output of a Writer with the given
+// chunk size and recipients after n bytes of plaintext are written.
+func EncryptedSize(n int64, chunkSize int, rcpts ...Recipient) int64 {
+	size := int64(len(magic) + 1 + 1 + nonceSize + macSize)
+	for _, r := range rcpts {
+		kind := byte(kindX25519)
+		if _, ok := r.(*ScryptRecipient); ok {
+			kind = kindScrypt
+		}
+		size += int64(1 + bodySize(kind) + keySize + tagSize)
+	}
+	chunks := (n + int64(chunkSize) - 1) / int64(chunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	return size + n + chunks*tagSize
+}
+
+// chunkNonce sets the nonce for the chunk with index i: an 11 byte big-endian
+// counter followed by a byte that's 1 for the last chunk.
+func chunkNonce(nonce []byte, i uint64, last bool) {
+	for k := range nonce {
+		nonce[k] = 0
+	}
+	binary.BigEndian.PutUint64(nonce[3:11], i)
+	if last {
+		nonce[11] = 1
+	}
+}
+
+// A Writer encrypts everything written to it. Close must be called to seal
+// the last chunk; without it the output is truncated.
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	out     []byte
+	nonce   []byte
+	counter uint64
+	err     error
+}
+
+// NewWriter writes the header for the recipients to dst and returns a Writer
+// that encrypts to them in chunks of DefaultChunkSize.
+func NewWriter(dst io.Writer, rcpts ...Recipient) (*Writer, error) {
+	return NewWriterSize(dst, DefaultChunkSize, rcpts...)
+}
+
+// NewWriterSize is like NewWriter with a chunk size, which must be a power of
+// two from 16 bytes to 16 MiB.
+func NewWriterSize(dst io.Writer, chunkSize int, rcpts ...Recipient) (*Writer, error) {
+	if err := checkRecipients(rcpts); err != nil {
+		return nil, err
+	}
+	logSize, err := chunkLog(chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey := make([]byte, keySize)
+	if _, err := io.ReadFull(randReader, fileKey); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(randReader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(magic), byte(len(rcpts)))
+	for _, r := range rcpts {
+		s, err := r.stanza(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, s.kind)
+		header = append(header, s.body...)
+		header = append(header, s.wrapped...)
+	}
+	header = append(header, byte(logSize))
+	header = append(header, nonce...)
+	header = append(header, headerMAC(fileKey, header)...)
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	aead, err := payloadKey(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:     dst,
+		aead:  aead,
+		buf:   make([]byte, 0, chunkSize),
+		out:   make([]byte, 0, chunkSize+tagSize),
+		nonce: make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (w *Writer) flush(last bool) error {
+	chunkNonce(w.nonce, w.counter, last)
+	w.counter++
+	w.out = w.aead.Seal(w.out[:0], w.nonce, w.buf, nil)
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+// Write encrypts p. A full chunk is only sealed once more data arrives, since
+// until then it might turn out to be the last one.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if w.err = w.flush(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return
+}
+
+// Close seals the last chunk. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.flush(true)
+	if w.err == nil {
+		w.err = errors.New("crypt: write to closed Writer")
+		return nil
+	}
+	return w.err
+}
+
+// A Reader decrypts and verifies a stream written by a Writer.
+type Reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	in      []byte
+	plain   []byte
+	nonce   []byte
+	counter uint64
+	err     error
+}
+
+// NewReader reads the header from src and returns a Reader for the payload,
+// or ErrNoIdentity if none of the identities can open it.
+func NewReader(src io.Reader, ids ...Identity) (*Reader, error) {
+	br := bufio.NewReader(src)
+	head := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, head); err != nil || string(head[:len(magic)]) != magic {
+		return nil, errors.New("crypt: not an encrypted file")
+	}
+	count := int(head[len(magic)])
+	if count == 0 {
+		return nil, errors.New("crypt: malformed header")
+	}
+
+	// The header as read so far, for checking its MAC.
+	header := head
+	var stanzas []stanza
+	for i := 0; i < count; i++ {
+		kind, err := br.ReadByte()
+		if err != nil {
+			return nil, ErrTruncated
+		}
+		n := bodySize(kind)
+		if n < 0 {
+			return nil, fmt.Errorf("crypt: unknown key type %d", kind)
+		}
+		b := make([]byte, n+keySize+tagSize)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, ErrTruncated
+		}
+		if kind == kindScrypt && count > 1 {
+			return nil, errors.New("crypt: malformed header")
+		}
+		stanzas = append(stanzas, stanza{kind, b[:n], b[n:]})
+		header = append(append(header, kind), b...)
+	}
+	tail := make([]byte, 1+nonceSize+macSize)
+	if _, err := io.ReadFull(br, tail); err != nil {
+		return nil, ErrTruncated
+	}
+	header = append(header, tail[:1+nonceSize]...)
+	mac := tail[1+nonceSize:]
+	logSize := uint(tail[0])
+	if logSize < minChunkLog || logSize > maxChunkLog {
+		return nil, errors.New("crypt: malformed header")
+	}
+
+	fileKey, err := unwrap(stanzas, ids)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, headerMAC(fileKey, header)) {
+		return nil, ErrHeader
+	}
+	aead, err := payloadKey(fileKey, tail[1:1+nonceSize])
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:     br,
+		aead:  aead,
+		in:    make([]byte, 1<<logSize+tagSize),
+		nonce: make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func unwrap(stanzas []stanza, ids []Identity) ([]byte, error) {
+	for _, id := range ids {
+		for _, s := range stanzas {
+			fileKey, err := id.unwrap(s)
+			if err == errNoMatch {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(fileKey) != keySize {
+				return nil, errors.New("crypt: malformed header")
+			}
+			return fileKey, nil
+		}
+	}
+	return nil, ErrNoIdentity
+}
+
+// Read returns decrypted plaintext. It only returns data from chunks that
+// authenticated, and returns ErrAuthentication or ErrTruncated if the stream
+// was altered.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n = copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return
+}
+
+func (r *Reader) next() error {
+	n, err := io.ReadFull(r.r, r.in)
+	switch err {
+	case nil:
+	case io.EOF:
+		return ErrTruncated
+	case io.ErrUnexpectedEOF:
+		if n < tagSize {
+			return ErrTruncated
+		}
+	default:
+		return err
+	}
+
+	// A short chunk has to be the last one; a full one is the last if
+	// nothing follows it.
+	last := n < len(r.in)
+	if !last {
+		if _, err := r.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	chunkNonce(r.nonce, r.counter, last)
+	plain, err := r.aead.Open(r.in[:0], r.nonce, r.in[:n], nil)
+	if err != nil {
+		// This also catches a stream cut at a chunk boundary, since the
+		// chunk before the cut wasn't sealed as the last one.
+		return ErrAuthentication
+	}
+	// Only an empty file has an empty last chunk.
+	if last && len(plain) == 0 && r.counter > 0 {
+		return ErrAuthentication
+	}
+	r.counter++
+	r.plain = plain
+	if last {
+		return io.EOF
+	}
+	return nil
+}
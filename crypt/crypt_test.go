@@ -0,0 +1,367 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Test vectors from RFC 7914, section 12, with p = 1.
+func TestScryptVectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		logN           uint
+		r              int
+		want           string
+	}{
+		{"", "", 4, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"pleaseletmein", "SodiumChloride", 14, 8, "7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887"},
+	}
+	for _, tt := range tests {
+		got, err := scryptKey([]byte(tt.password), []byte(tt.salt), tt.logN, tt.r, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := unhex(t, tt.want); !bytes.Equal(got, want) {
+			t.Errorf("scryptKey(%q, %q, 2^%d, %d) = %x, want %x", tt.password, tt.salt, tt.logN, tt.r, got, want)
+		}
+	}
+
+	if _, err := scryptKey([]byte("x"), nil, 0, 8, 32); err == nil {
+		t.Error("scryptKey accepted a work factor of 1")
+	}
+	if _, err := scryptKey([]byte("x"), nil, 22, 1024, 32); err == nil {
+		t.Error("scryptKey accepted 256 GiB of memory")
+	}
+	if _, err := scryptKey([]byte("x"), nil, maxScryptLogN+1, 8, 32); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("scryptKey(2^%d) = %v, want the work factor refused", maxScryptLogN+1, err)
+	}
+}
+
+// Test vectors for PBKDF2-HMAC-SHA256, which scrypt is built on, from RFC
+// 7914, section 11.
+func TestPBKDF2Vectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iter           int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		if got, want := pbkdf2.Key([]byte(tt.password), []byte(tt.salt), tt.iter, 64, sha256.New), unhex(t, tt.want); !bytes.Equal(got, want) {
+			t.Errorf("pbkdf2.Key(%q, %q, %d) = %x, want %x", tt.password, tt.salt, tt.iter, got, want)
+		}
+	}
+	// Shorter keys are a prefix of longer ones.
+	if got := pbkdf2.Key([]byte("passwd"), []byte("salt"), 1, 20, sha256.New); !bytes.Equal(got, unhex(t, tests[0].want)[:20]) {
+		t.Errorf("pbkdf2.Key of 20 bytes = %x", got)
+	}
+}
+
+// Test cases 1 and 3 of RFC 5869, cut to the one hash length subkey produces.
+func TestHKDFVectors(t *testing.T) {
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+	tests := []struct {
+		salt, info string
+		want       string
+	}{
+		{"000102030405060708090a0b0c", "f0f1f2f3f4f5f6f7f8f9", "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf"},
+		{"", "", "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d"},
+	}
+	for _, tt := range tests {
+		got := subkey(ikm, unhex(t, tt.salt), string(unhex(t, tt.info)))
+		if want := unhex(t, tt.want); !bytes.Equal(got, want) {
+			t.Errorf("subkey(salt %s, info %s) = %x, want %x", tt.salt, tt.info, got, want)
+		}
+	}
+}
+
+// A reader of the bytes 0, 1, 2, ... standing in for crypto/rand.
+type countingReader struct{ n byte }
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.n
+		r.n++
+	}
+	return len(p), nil
+}
+
+func useCountingRand(t *testing.T) {
+	saved := randReader
+	randReader = &countingReader{}
+	t.Cleanup(func() { randReader = saved })
+}
+
+func fastPassphrase(t *testing.T, passphrase string) (*ScryptRecipient, *ScryptIdentity) {
+	r, err := NewScryptRecipient(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetWorkFactor(4)
+	id, err := NewScryptIdentity(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, id
+}
+
+func encrypt(t *testing.T, plaintext []byte, chunkSize int, rcpts ...Recipient) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterSize(&buf, chunkSize, rcpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(ciphertext []byte, ids ...Identity) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(ciphertext), ids...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// Checks the payload against chunks sealed here straight from the STREAM
+// construction: AES-256-GCM under HKDF(file key, nonce, "dbxenc payload"),
+// with an 11 byte big-endian counter and a last-chunk flag as the nonce.
+func TestStreamKnownAnswer(t *testing.T) {
+	useCountingRand(t)
+	r, _ := fastPassphrase(t, "correct horse")
+	plaintext := []byte("0123456789abcdef0123456789abcdefXYZ")
+	got := encrypt(t, plaintext, 16, r)
+
+	// The file key and the nonce are the first bytes read from randReader.
+	fileKey := make([]byte, keySize)
+	nonce := make([]byte, nonceSize)
+	(&countingReader{}).Read(fileKey)
+	(&countingReader{keySize}).Read(nonce)
+	block, _ := aes.NewCipher(subkey(fileKey, nonce, "dbxenc payload"))
+	aead, _ := cipher.NewGCM(block)
+
+	var want []byte
+	for i, chunkNonce := range []string{
+		"000000000000000000000000",
+		"000000000000000000000100",
+		"000000000000000000000201",
+	} {
+		end := 16 * (i + 1)
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+		want = aead.Seal(want, unhex(t, chunkNonce), plaintext[16*i:end], nil)
+	}
+	if payload := got[len(got)-len(want):]; !bytes.Equal(payload, want) {
+		t.Errorf("payload = %x, want %x", payload, want)
+	}
+	if int64(len(got)) != EncryptedSize(int64(len(plaintext)), 16, r) {
+		t.Errorf("EncryptedSize = %d, but the output is %d bytes", EncryptedSize(int64(len(plaintext)), 16, r), len(got))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	alice, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pid := fastPassphrase(t, "correct horse")
+
+	sizes := []int{0, 1, 15, 16, 17, 64, 1000}
+	for _, size := range sizes {
+		plaintext := bytes.Repeat([]byte("abcdefg"), size)[:size]
+		for _, tt := range []struct {
+			name  string
+			rcpts []Recipient
+			id    Identity
+		}{
+			{"x25519", []Recipient{alice.Recipient()}, alice},
+			{"second recipient", []Recipient{alice.Recipient(), bob.Recipient()}, bob},
+			{"passphrase", []Recipient{pr}, pid},
+		} {
+			ciphertext := encrypt(t, plaintext, 16, tt.rcpts...)
+			if want := EncryptedSize(int64(size), 16, tt.rcpts...); int64(len(ciphertext)) != want {
+				t.Errorf("%s, %d bytes: EncryptedSize = %d, got %d", tt.name, size, want, len(ciphertext))
+			}
+			got, err := decrypt(ciphertext, tt.id)
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Errorf("%s, %d bytes: decrypted %q, %v", tt.name, size, got, err)
+			}
+		}
+	}
+
+	ciphertext := encrypt(t, []byte("secret"), 16, alice.Recipient())
+	if _, err := decrypt(ciphertext, bob); err != ErrNoIdentity {
+		t.Errorf("decrypting with the wrong key: %v, want %v", err, ErrNoIdentity)
+	}
+	_, wrong := fastPassphrase(t, "battery staple")
+	if _, err := decrypt(encrypt(t, []byte("secret"), 16, pr), wrong); err != ErrNoIdentity {
+		t.Errorf("decrypting with the wrong passphrase: %v, want %v", err, ErrNoIdentity)
+	}
+}
+
+func TestTamperedPayload(t *testing.T) {
+	r, id := fastPassphrase(t, "correct horse")
+	plaintext := bytes.Repeat([]byte("x"), 40)
+	ciphertext := encrypt(t, plaintext, 16, r)
+	headerSize := len(ciphertext) - (40 + 3*tagSize)
+	chunk := func(i int) []byte {
+		start := headerSize + i*(16+tagSize)
+		end := start + 16 + tagSize
+		if end > len(ciphertext) {
+			end = len(ciphertext)
+		}
+		return ciphertext[start:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(append([][]byte{ciphertext[:headerSize]}, parts...), nil)
+	}
+
+	flipped := append([]byte(nil), ciphertext...)
+	flipped[headerSize+5] ^= 1
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		err        error
+	}{
+		{"a flipped bit", flipped, ErrAuthentication},
+		{"swapped chunks", join(chunk(1), chunk(0), chunk(2)), ErrAuthentication},
+		{"a dropped chunk", join(chunk(0), chunk(2)), ErrAuthentication},
+		{"cut at a chunk boundary", join(chunk(0), chunk(1)), ErrAuthentication},
+		{"cut inside a tag", ciphertext[:len(ciphertext)-1], ErrAuthentication},
+		{"cut to less than a tag", join(chunk(0), chunk(1), chunk(2)[:tagSize-1]), ErrTruncated},
+		{"no payload", ciphertext[:headerSize], ErrTruncated},
+		{"an extra chunk", join(chunk(0), chunk(1), chunk(2), chunk(2)), ErrAuthentication},
+	}
+	for _, tt := range tests {
+		got, err := decrypt(tt.ciphertext, id)
+		if err != tt.err {
+			t.Errorf("%s: decrypted %d bytes, %v, want %v", tt.name, len(got), err, tt.err)
+		}
+		if bytes.Contains(plaintext, got) && len(got) > 32 {
+			t.Errorf("%s: returned unauthenticated plaintext", tt.name)
+		}
+	}
+}
+
+// Every byte of the header is covered by either a stanza's wrapping or the
+// header MAC, so flipping any of them fails.
+func TestTamperedHeader(t *testing.T) {
+	alice, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := encrypt(t, []byte("secret"), 16, alice.Recipient(), bob.Recipient())
+	headerSize := len(ciphertext) - len("secret") - tagSize
+	for i := 0; i < headerSize; i++ {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[i] ^= 0x40
+		if got, err := decrypt(tampered, alice); err == nil {
+			t.Errorf("flipping header byte %d went unnoticed: decrypted %q", i, got)
+		}
+	}
+
+	// Bob can't drop Alice's stanza or replace it with one of his own:
+	// either changes the header under its MAC.
+	stanzaSize := 1 + 32 + keySize + tagSize
+	start := len(magic) + 1
+	dropped := append([]byte(nil), ciphertext[:start]...)
+	dropped[len(magic)] = 1
+	dropped = append(dropped, ciphertext[start+stanzaSize:]...)
+	if _, err := decrypt(dropped, bob); err != ErrHeader {
+		t.Errorf("dropping a stanza: %v, want %v", err, ErrHeader)
+	}
+
+	eve, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := encrypt(t, []byte("secret"), 16, eve.Recipient(), bob.Recipient())
+	swapped := append(append([]byte(nil), other[:start+stanzaSize]...), ciphertext[start+stanzaSize:]...)
+	if _, err := decrypt(swapped, bob); err != ErrHeader {
+		t.Errorf("swapping a stanza: %v, want %v", err, ErrHeader)
+	}
+	if _, err := decrypt(ciphertext, alice); err != nil {
+		t.Errorf("the untouched file failed: %v", err)
+	}
+}
+
+func TestWriterLimits(t *testing.T) {
+	r, _ := fastPassphrase(t, "correct horse")
+	if _, err := NewWriterSize(ioutil.Discard, 100, r); err == nil {
+		t.Error("a chunk size that isn't a power of two was accepted")
+	}
+	if _, err := NewWriter(ioutil.Discard); err == nil {
+		t.Error("no recipients were accepted")
+	}
+	alice, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWriter(ioutil.Discard, r, alice.Recipient()); err == nil {
+		t.Error("a passphrase was combined with a public key")
+	}
+	if _, err := NewReader(strings.NewReader("DBXENC\x00\x01\x01"), alice); err == nil {
+		t.Error("an unknown version was accepted")
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, alice.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("late")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+	if _, err := io.Copy(ioutil.Discard, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+}
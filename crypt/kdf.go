@@ -0,0 +1,51 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The most memory scrypt may use, which is what the largest accepted work
+// factor takes with r = 8: 4 GiB.
+const maxScryptMemory uint64 = 128 * 8 << maxScryptLogN
+
+// scryptKey derives a key from a passphrase with scrypt (RFC 7914), with
+// p = 1. Work factors are checked here, as scrypt.Key would try to allocate
+// whatever memory they ask for.
+func scryptKey(password, salt []byte, logN uint, r, keyLen int) ([]byte, error) {
+	if logN < 1 || r < 1 {
+		return nil, errors.New("crypt: scrypt parameters out of range")
+	}
+	if logN > maxScryptLogN || uint64(r) > maxScryptMemory/(128<<logN) {
+		return nil, fmt.Errorf("crypt: scrypt work factor 2^%d is too large", logN)
+	}
+	return scrypt.Key(password, salt, 1<<logN, r, 1, keyLen)
+}
+
+// subkey derives a key of one hash length from secret with HKDF-SHA256
+// (RFC 5869).
+func subkey(secret, salt []byte, info string) []byte {
+	key := make([]byte, sha256.Size)
+	// A single hash length can always be read.
+	io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key)
+	return key
+}
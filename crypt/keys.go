@@ -0,0 +1,248 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	secretKeyPrefix = "DBXENC-SECRET-KEY-"
+	publicKeyPrefix = "dbxenc-pub-"
+
+	// Work factor for new passphrase-encrypted files: 2^18 iterations take
+	// about a second and 256 MiB of memory.
+	scryptLogN = 18
+	// Files asking for more work than this are refused, so a crafted header
+	// can't make decryption run for hours.
+	maxScryptLogN = 22
+)
+
+// A Recipient is someone a file can be encrypted to.
+type Recipient interface {
+	stanza(fileKey []byte) (stanza, error)
+}
+
+// An Identity can recover the file key from a header written for a matching
+// Recipient.
+type Identity interface {
+	unwrap(s stanza) ([]byte, error)
+}
+
+// X25519Recipient encrypts to the holder of an X25519 secret key, in the
+// style of age.
+type X25519Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// ParseRecipient parses a public key of the form "dbxenc-pub-...".
+func ParseRecipient(s string) (*X25519Recipient, error) {
+	if !strings.HasPrefix(s, publicKeyPrefix) {
+		return nil, fmt.Errorf("crypt: %q is not a public key", s)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, publicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed public key: %v", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed public key: %v", err)
+	}
+	return &X25519Recipient{key}, nil
+}
+
+func (r *X25519Recipient) String() string {
+	return publicKeyPrefix + base64.RawURLEncoding.EncodeToString(r.key.Bytes())
+}
+
+func (r *X25519Recipient) stanza(fileKey []byte) (s stanza, err error) {
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return
+	}
+	shared, err := eph.ECDH(r.key)
+	if err != nil {
+		return
+	}
+	s.kind = kindX25519
+	s.body = eph.PublicKey().Bytes()
+	wrapKey := subkey(shared, append(s.body[:len(s.body):len(s.body)], r.key.Bytes()...), "dbxenc x25519")
+	s.wrapped, err = seal(wrapKey, s.aad(), fileKey)
+	return
+}
+
+// X25519Identity is an X25519 secret key.
+type X25519Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateX25519Identity creates a new random secret key.
+func GenerateX25519Identity() (*X25519Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{key}, nil
+}
+
+func parseIdentity(s string) (*X25519Identity, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, secretKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed secret key: %v", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: malformed secret key: %v", err)
+	}
+	return &X25519Identity{key}, nil
+}
+
+func (i *X25519Identity) String() string {
+	return secretKeyPrefix + base64.RawURLEncoding.EncodeToString(i.key.Bytes())
+}
+
+// Recipient returns the public key files are encrypted to for this identity.
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{i.key.PublicKey()}
+}
+
+// WriteTo writes the identity in the format read by ParseKeyFile.
+func (i *X25519Identity) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, "# created: %s\n# public key: %s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), i.Recipient(), i)
+	return int64(n), err
+}
+
+func (i *X25519Identity) unwrap(s stanza) ([]byte, error) {
+	if s.kind != kindX25519 {
+		return nil, errNoMatch
+	}
+	eph, err := ecdh.X25519().NewPublicKey(s.body)
+	if err != nil {
+		return nil, errNoMatch
+	}
+	shared, err := i.key.ECDH(eph)
+	if err != nil {
+		return nil, errNoMatch
+	}
+	wrapKey := subkey(shared, append(s.body[:len(s.body):len(s.body)], i.key.PublicKey().Bytes()...), "dbxenc x25519")
+	return open(wrapKey, s.aad(), s.wrapped)
+}
+
+// ScryptRecipient encrypts with a key derived from a passphrase. A file
+// encrypted to a passphrase can't have any other recipients.
+type ScryptRecipient struct {
+	passphrase []byte
+	logN       uint
+}
+
+// NewScryptRecipient returns a recipient for the passphrase.
+func NewScryptRecipient(passphrase string) (*ScryptRecipient, error) {
+	if passphrase == "" {
+		return nil, errors.New("crypt: empty passphrase")
+	}
+	return &ScryptRecipient{[]byte(passphrase), scryptLogN}, nil
+}
+
+// SetWorkFactor sets log2 of the scrypt work factor, which is 18 by default.
+// Lower factors make the passphrase quicker to guess; tests use them to run
+// quickly.
+func (r *ScryptRecipient) SetWorkFactor(logN uint) {
+	r.logN = logN
+}
+
+func (r *ScryptRecipient) stanza(fileKey []byte) (s stanza, err error) {
+	salt := make([]byte, 16)
+	if _, err = io.ReadFull(randReader, salt); err != nil {
+		return
+	}
+	s.kind = kindScrypt
+	s.body = append(salt, byte(r.logN))
+	wrapKey, err := scryptKey(r.passphrase, append([]byte("dbxenc scrypt"), salt...), r.logN, 8, 32)
+	if err != nil {
+		return
+	}
+	s.wrapped, err = seal(wrapKey, s.aad(), fileKey)
+	return
+}
+
+// ScryptIdentity decrypts files encrypted to a passphrase.
+type ScryptIdentity struct {
+	passphrase []byte
+}
+
+// NewScryptIdentity returns an identity for the passphrase.
+func NewScryptIdentity(passphrase string) (*ScryptIdentity, error) {
+	if passphrase == "" {
+		return nil, errors.New("crypt: empty passphrase")
+	}
+	return &ScryptIdentity{[]byte(passphrase)}, nil
+}
+
+func (i *ScryptIdentity) unwrap(s stanza) ([]byte, error) {
+	if s.kind != kindScrypt {
+		return nil, errNoMatch
+	}
+	wrapKey, err := scryptKey(i.passphrase, append([]byte("dbxenc scrypt"), s.body[:16]...), uint(s.body[16]), 8, 32)
+	if err != nil {
+		return nil, err
+	}
+	return open(wrapKey, s.aad(), s.wrapped)
+}
+
+// ParseKeyFile reads a key file. Blank lines and lines starting with # are
+// ignored; every other line holds a secret key ("DBXENC-SECRET-KEY-...") or a
+// public key ("dbxenc-pub-..."). Secret keys are returned both as identities
+// and, through their public half, as recipients, so a file that's only used
+// for encrypting can hold just public keys.
+func ParseKeyFile(r io.Reader) (ids []Identity, rcpts []Recipient, err error) {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, secretKeyPrefix):
+			id, err := parseIdentity(line)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			ids = append(ids, id)
+			rcpts = append(rcpts, id.Recipient())
+		case strings.HasPrefix(line, publicKeyPrefix):
+			r, err := ParseRecipient(line)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			rcpts = append(rcpts, r)
+		default:
+			return nil, nil, fmt.Errorf("line %d: not a key", n)
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if len(rcpts) == 0 {
+		err = errors.New("no keys found")
+	}
+	return
+}
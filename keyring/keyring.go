@@ -0,0 +1,46 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyring stores small secrets in the operating system's credential
+// store: the Keychain on macOS, the Secret Service (through libsecret's
+// secret-tool) on Linux and other Unix systems, and the Credential Manager on
+// Windows. Secrets are identified by a service and a user name.
+package keyring
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when there's no secret for the service and
+	// user.
+	ErrNotFound = errors.New("keyring: secret not found")
+	// ErrUnsupported is returned when the system has no credential store
+	// this package can use, e.g. a Linux container without secret-tool.
+	ErrUnsupported = errors.New("keyring: no credential store available")
+)
+
+// Get returns the secret stored for `service` and `user`.
+func Get(service, user string) (string, error) {
+	return get(service, user)
+}
+
+// Set stores `secret` for `service` and `user`, replacing any secret stored
+// for them before.
+func Set(service, user, secret string) error {
+	return set(service, user, secret)
+}
+
+// Delete removes the secret stored for `service` and `user`.
+func Delete(service, user string) error {
+	return del(service, user)
+}
@@ -0,0 +1,66 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyring
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// security(1) exits with this status when there's no such item.
+const errSecItemNotFound = 44
+
+func get(service, user string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", user, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// The secret is written to security's standard input, hex encoded, so that
+// it neither shows up in the process list nor needs quoting.
+func set(service, user, secret string) error {
+	c := exec.Command("security", "-i")
+	c.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		quote(service), quote(user), hex.EncodeToString([]byte(secret))))
+	if err := c.Run(); err != nil {
+		return securityError(err)
+	}
+	return nil
+}
+
+func del(service, user string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", user).Run(); err != nil {
+		return securityError(err)
+	}
+	return nil
+}
+
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func securityError(err error) error {
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == errSecItemNotFound {
+		return ErrNotFound
+	}
+	if e, ok := err.(*exec.Error); ok && e.Err == exec.ErrNotFound {
+		return ErrUnsupported
+	}
+	return fmt.Errorf("keyring: security: %v", err)
+}
@@ -0,0 +1,77 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package keyring
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Secrets are kept by the Secret Service (GNOME Keyring, KWallet and the
+// like), through the secret-tool program which comes with libsecret.
+const secretTool = "secret-tool"
+
+func get(service, user string) (string, error) {
+	var stderr bytes.Buffer
+	c := exec.Command(secretTool, "lookup", "service", service, "username", user)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		// A lookup which finds nothing fails without saying anything.
+		if _, ok := err.(*exec.ExitError); ok && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", secretToolError(err, &stderr)
+	}
+	return string(out), nil
+}
+
+// The secret is passed on standard input, so it doesn't show up in the
+// process list.
+func set(service, user, secret string) error {
+	var stderr bytes.Buffer
+	c := exec.Command(secretTool, "store", "--label", service+" ("+user+")", "service", service, "username", user)
+	c.Stdin = strings.NewReader(secret)
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return secretToolError(err, &stderr)
+	}
+	return nil
+}
+
+func del(service, user string) error {
+	var stderr bytes.Buffer
+	c := exec.Command(secretTool, "clear", "service", service, "username", user)
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return secretToolError(err, &stderr)
+	}
+	return nil
+}
+
+func secretToolError(err error, stderr *bytes.Buffer) error {
+	if e, ok := err.(*exec.Error); ok && e.Err == exec.ErrNotFound {
+		return ErrUnsupported
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("keyring: %s: %s", secretTool, msg)
+	}
+	return fmt.Errorf("keyring: %s: %v", secretTool, err)
+}
@@ -0,0 +1,128 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyring
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	// The most a generic credential can hold.
+	credMaxBlobSize = 5 * 512
+
+	errorNotFound = syscall.Errno(1168)
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+// CREDENTIALW
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// Credentials are named by a single target, made of both parts.
+func target(service, user string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + ":" + user)
+}
+
+func get(service, user string) (string, error) {
+	t, err := target(service, user)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(t)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return "", credError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	n := int(cred.CredentialBlobSize)
+	if n == 0 {
+		return "", nil
+	}
+	blob := (*[credMaxBlobSize]byte)(unsafe.Pointer(cred.CredentialBlob))[:n:n]
+	return string(blob), nil
+}
+
+func set(service, user, secret string) error {
+	if len(secret) > credMaxBlobSize {
+		return errors.New("keyring: secret is too large for the Credential Manager")
+	}
+	t, err := target(service, user)
+	if err != nil {
+		return err
+	}
+	u, err := syscall.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:       credTypeGeneric,
+		TargetName: t,
+		Persist:    credPersistLocalMachine,
+		UserName:   u,
+	}
+	blob := []byte(secret)
+	if len(blob) > 0 {
+		cred.CredentialBlobSize = uint32(len(blob))
+		cred.CredentialBlob = &blob[0]
+	}
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func del(service, user string) error {
+	t, err := target(service, user)
+	if err != nil {
+		return err
+	}
+	r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(t)), credTypeGeneric, 0)
+	if r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func credError(err error) error {
+	if err == errorNotFound {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,491 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qrcode encodes short strings, such as shared link URLs, as QR codes
+// (ISO/IEC 18004) and renders them as text or images.
+//
+// Only what dbxcli needs is implemented: byte mode, error correction level M
+// and versions 1 to 20, which hold up to 666 bytes.
+package qrcode
+
+import (
+	"errors"
+)
+
+// ErrTooLong is returned by Encode when the data doesn't fit in the largest
+// supported version.
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Error correction block layout of one version at level M.
+type version struct {
+	ecPerBlock int
+	// Blocks in each of the (up to) two groups, and data codewords per block.
+	blocks1, data1 int
+	blocks2, data2 int
+	// Centers of the alignment patterns, along both axes.
+	align []int
+}
+
+var versions = [...]version{
+	1:  {10, 1, 16, 0, 0, nil},
+	2:  {16, 1, 28, 0, 0, []int{6, 18}},
+	3:  {26, 1, 44, 0, 0, []int{6, 22}},
+	4:  {18, 2, 32, 0, 0, []int{6, 26}},
+	5:  {24, 2, 43, 0, 0, []int{6, 30}},
+	6:  {16, 4, 27, 0, 0, []int{6, 34}},
+	7:  {18, 4, 31, 0, 0, []int{6, 22, 38}},
+	8:  {22, 2, 38, 2, 39, []int{6, 24, 42}},
+	9:  {22, 3, 36, 2, 37, []int{6, 26, 46}},
+	10: {26, 4, 43, 1, 44, []int{6, 28, 50}},
+	11: {30, 1, 50, 4, 51, []int{6, 30, 54}},
+	12: {22, 6, 36, 2, 37, []int{6, 32, 58}},
+	13: {22, 8, 37, 1, 38, []int{6, 34, 62}},
+	14: {24, 4, 40, 5, 41, []int{6, 26, 46, 66}},
+	15: {24, 5, 41, 5, 42, []int{6, 26, 48, 70}},
+	16: {28, 7, 45, 3, 46, []int{6, 26, 50, 74}},
+	17: {28, 10, 46, 1, 47, []int{6, 30, 54, 78}},
+	18: {26, 9, 43, 4, 44, []int{6, 30, 56, 82}},
+	19: {26, 3, 44, 11, 45, []int{6, 30, 58, 86}},
+	20: {26, 3, 41, 13, 42, []int{6, 34, 62, 90}},
+}
+
+func (v version) dataCodewords() int {
+	return v.blocks1*v.data1 + v.blocks2*v.data2
+}
+
+// Code is an encoded QR code: a square of dark and light modules, without the
+// quiet zone around it.
+type Code struct {
+	Size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Dark reports whether the module in column x and row y is dark. Coordinates
+// outside the code are light, which makes them part of the quiet zone.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// Encode returns the smallest QR code holding data.
+func Encode(data []byte) (*Code, error) {
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*versions[v].dataCodewords() {
+			return encode(v, countBits, data), nil
+		}
+	}
+	return nil, ErrTooLong
+}
+
+type bitBuffer struct {
+	bytes []byte
+	n     int
+}
+
+func (b *bitBuffer) append(val, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if val>>uint(i)&1 != 0 {
+			b.bytes[b.n/8] |= 0x80 >> uint(b.n%8)
+		}
+		b.n++
+	}
+}
+
+func encode(v, countBits int, data []byte) *Code {
+	ver := versions[v]
+	capacity := ver.dataCodewords()
+
+	// Byte mode segment, terminator and padding.
+	var buf bitBuffer
+	buf.append(0x4, 4)
+	buf.append(len(data), countBits)
+	for _, b := range data {
+		buf.append(int(b), 8)
+	}
+	terminator := 8*capacity - buf.n
+	if terminator > 4 {
+		terminator = 4
+	}
+	buf.append(0, terminator)
+	buf.append(0, (8-buf.n%8)%8)
+	for pad := 0xec; len(buf.bytes) < capacity; pad ^= 0xec ^ 0x11 {
+		buf.append(pad, 8)
+	}
+
+	codewords := interleave(ver, buf.bytes)
+
+	size := 17 + 4*v
+	c := &Code{Size: size}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	c.drawFunctionPatterns(v, ver)
+	c.drawCodewords(codewords)
+
+	// Pick the mask with the lowest penalty, as the standard asks.
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+	return c
+}
+
+// Splits the data into blocks, appends error correction to each and
+// interleaves the result.
+func interleave(ver version, data []byte) []byte {
+	var blocks [][]byte
+	for i := 0; i < ver.blocks1; i++ {
+		blocks = append(blocks, data[:ver.data1])
+		data = data[ver.data1:]
+	}
+	for i := 0; i < ver.blocks2; i++ {
+		blocks = append(blocks, data[:ver.data2])
+		data = data[ver.data2:]
+	}
+
+	divisor := rsDivisor(ver.ecPerBlock)
+	var ec [][]byte
+	for _, b := range blocks {
+		ec = append(ec, rsRemainder(b, divisor))
+	}
+
+	var out []byte
+	longest := ver.data1
+	if ver.data2 > longest {
+		longest = ver.data2
+	}
+	for i := 0; i < longest; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < ver.ecPerBlock; i++ {
+		for _, e := range ec {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+// Multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		carry := z & 0x80
+		z <<= 1
+		if carry != 0 {
+			z ^= 0x1d
+		}
+		if y>>uint(i)&1 != 0 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// Returns the coefficients of the Reed-Solomon generator polynomial of the
+// given degree, highest first and without the leading 1.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(v int, ver version) {
+	n := c.Size
+
+	for i := 0; i < n; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(n-4, 3)
+	c.drawFinder(3, n-4)
+
+	for i, cx := range ver.align {
+		for j, cy := range ver.align {
+			last := len(ver.align) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format areas; drawFormat fills them in.
+	c.drawFormat(0)
+
+	if v >= 7 {
+		rem := v
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := v<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 != 0
+			a, b := n-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// Draws a finder pattern and its separator around the center (x, y).
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// Draws both copies of the format information for level M and `mask`.
+func (c *Code) drawFormat(mask int) {
+	data := 0<<3 | mask // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	n := c.Size
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(n-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, n-15+i, bit(i))
+	}
+	c.set(8, n-8, true)
+}
+
+// Places the codewords in the zigzag order, two columns at a time from the
+// bottom right, skipping function modules.
+func (c *Code) drawCodewords(data []byte) {
+	n := c.Size
+	i := 0
+	for right := n - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < n; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = n - 1 - vert
+				}
+				if c.function[y][x] {
+					continue
+				}
+				if i < len(data)*8 {
+					c.modules[y][x] = data[i/8]>>uint(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// Flips the data modules selected by `mask`. Applying a mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// Scores the code by the four penalty rules of the standard; lower is better.
+func (c *Code) penalty() int {
+	n := c.Size
+	p := 0
+
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i <= n; i++ {
+			if i < n && get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				p += run - 2
+			}
+			run = 1
+		}
+		// Finder-like 1:1:3:1:1 patterns with four light modules on a side.
+		pattern := []bool{true, false, true, true, true, false, true}
+		for i := 0; i+7 <= n; i++ {
+			match := true
+			for k, want := range pattern {
+				if get(i+k) != want {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			lightBefore, lightAfter := true, true
+			for k := 1; k <= 4; k++ {
+				if i-k >= 0 && get(i-k) {
+					lightBefore = false
+				}
+				if i+6+k < n && get(i+6+k) {
+					lightAfter = false
+				}
+			}
+			if lightBefore || lightAfter {
+				p += 40
+			}
+		}
+	}
+	for y := 0; y < n; y++ {
+		line(func(i int) bool { return c.modules[y][i] })
+	}
+	for x := 0; x < n; x++ {
+		line(func(i int) bool { return c.modules[i][x] })
+	}
+
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				m := c.modules[y][x]
+				if c.modules[y][x+1] == m && c.modules[y+1][x] == m && c.modules[y+1][x+1] == m {
+					p += 3
+				}
+			}
+		}
+	}
+
+	// Ten points for every 5% the dark share strays from 50%.
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		p += k * 10
+	}
+	return p
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,305 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		length int
+		size   int
+		err    error
+	}{
+		{0, 21, nil},
+		{14, 21, nil},
+		{15, 25, nil},
+		{26, 25, nil},
+		{27, 29, nil},
+		{666, 97, nil},
+		{667, 0, ErrTooLong},
+	}
+	for _, tt := range tests {
+		c, err := Encode(bytes.Repeat([]byte("a"), tt.length))
+		if err != tt.err {
+			t.Errorf("Encode(%d bytes) failed: %v, want %v", tt.length, err, tt.err)
+			continue
+		}
+		if err == nil && c.Size != tt.size {
+			t.Errorf("Encode(%d bytes) has size %d, want %d", tt.length, c.Size, tt.size)
+		}
+	}
+}
+
+func TestGFMul(t *testing.T) {
+	tests := []struct{ x, y, want byte }{
+		{0, 0x57, 0},
+		{1, 0x57, 0x57},
+		{2, 0x80, 0x1d},
+		{3, 3, 5},
+		{0x80, 0x80, 0x13},
+		{0x8e, 2, 1},
+	}
+	for _, tt := range tests {
+		if got := gfMul(tt.x, tt.y); got != tt.want {
+			t.Errorf("gfMul(%#x, %#x) = %#x, want %#x", tt.x, tt.y, got, tt.want)
+		}
+		if got := gfMul(tt.y, tt.x); got != tt.want {
+			t.Errorf("gfMul(%#x, %#x) = %#x, want %#x", tt.y, tt.x, got, tt.want)
+		}
+	}
+}
+
+// The data and error correction codewords of "HELLO WORLD" in version 1-M,
+// the worked example of the standard's annex.
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	divisor := rsDivisor(len(want))
+	if got := rsRemainder(data, divisor); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+	if got := rsRemainder(append(data, want...), divisor); !bytes.Equal(got, make([]byte, len(want))) {
+		t.Errorf("codeword has syndrome %v", got)
+	}
+}
+
+// Format information for level M and each mask, as tabulated in the standard.
+var formatBits = [8]int{
+	0x5412, 0x5125, 0x5e7c, 0x5b4b, 0x45f9, 0x40ce, 0x4f97, 0x4aa0,
+}
+
+// Reads both copies of the format information.
+func readFormat(c *Code) (first, second int) {
+	n := c.Size
+	var a, b []bool
+	for i := 0; i <= 5; i++ {
+		a = append(a, c.Dark(8, i))
+	}
+	a = append(a, c.Dark(8, 7), c.Dark(8, 8), c.Dark(7, 8))
+	for i := 9; i < 15; i++ {
+		a = append(a, c.Dark(14-i, 8))
+	}
+	for i := 0; i < 8; i++ {
+		b = append(b, c.Dark(n-1-i, 8))
+	}
+	for i := 8; i < 15; i++ {
+		b = append(b, c.Dark(8, n-15+i))
+	}
+	for i := range a {
+		if a[i] {
+			first |= 1 << uint(i)
+		}
+		if b[i] {
+			second |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+// Reads the codewords back in placement order, undoing the mask.
+func readCodewords(c *Code, mask int) []byte {
+	c.applyMask(mask)
+	defer c.applyMask(mask)
+
+	var out []byte
+	i := 0
+	n := c.Size
+	for right := n - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < n; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = n - 1 - vert
+				}
+				if c.function[y][x] {
+					continue
+				}
+				if i%8 == 0 {
+					out = append(out, 0)
+				}
+				if c.modules[y][x] {
+					out[i/8] |= 0x80 >> uint(i%8)
+				}
+				i++
+			}
+		}
+	}
+	return out
+}
+
+// Encodes each input, then reads the modules back and checks that they hold
+// the input in a byte mode segment with valid error correction.
+func TestEncodeRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"https://www.dropbox.com/s/abc123/report.pdf?dl=0",
+		strings.Repeat("https://db.tt/", 20),
+		strings.Repeat("\xff", 400),
+	}
+	for _, in := range inputs {
+		c, err := Encode([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := (c.Size - 17) / 4
+		ver := versions[v]
+
+		first, second := readFormat(c)
+		if first != second {
+			t.Errorf("%d bytes: format copies differ: %#x and %#x", len(in), first, second)
+		}
+		mask := -1
+		for m, bits := range formatBits {
+			if bits == first {
+				mask = m
+			}
+		}
+		if mask < 0 {
+			t.Errorf("%d bytes: invalid format %#x", len(in), first)
+			continue
+		}
+
+		// Deinterleave into blocks of data followed by error correction.
+		codewords := readCodewords(c, mask)
+		var blocks [][]byte
+		for i := 0; i < ver.blocks1; i++ {
+			blocks = append(blocks, nil)
+		}
+		for i := 0; i < ver.blocks2; i++ {
+			blocks = append(blocks, nil)
+		}
+		pos := 0
+		for i := 0; i < ver.data2 || i < ver.data1; i++ {
+			for b := range blocks {
+				if b < ver.blocks1 && i >= ver.data1 || b >= ver.blocks1 && i >= ver.data2 {
+					continue
+				}
+				blocks[b] = append(blocks[b], codewords[pos])
+				pos++
+			}
+		}
+		for i := 0; i < ver.ecPerBlock; i++ {
+			for b := range blocks {
+				blocks[b] = append(blocks[b], codewords[pos])
+				pos++
+			}
+		}
+
+		divisor := rsDivisor(ver.ecPerBlock)
+		var data []byte
+		for b, block := range blocks {
+			if s := rsRemainder(block, divisor); !bytes.Equal(s, make([]byte, ver.ecPerBlock)) {
+				t.Errorf("%d bytes: block %d has syndrome %v", len(in), b, s)
+			}
+			data = append(data, block[:len(block)-ver.ecPerBlock]...)
+		}
+
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		var want bitBuffer
+		want.append(0x4, 4)
+		want.append(len(in), countBits)
+		for _, b := range []byte(in) {
+			want.append(int(b), 8)
+		}
+		if got := data[:len(want.bytes)-1]; !bytes.Equal(got, want.bytes[:len(want.bytes)-1]) {
+			t.Errorf("%d bytes: data codewords %x, want %x", len(in), got, want.bytes)
+		}
+	}
+}
+
+func TestEncodeFunctionPatterns(t *testing.T) {
+	c, err := Encode([]byte("https://www.dropbox.com/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := c.Size
+	// Finder patterns in three corners, each a dark ring around a light
+	// ring around a dark 3x3 square, with a light separator.
+	for _, corner := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				d := max(abs(dx-3), abs(dy-3))
+				if want := d != 2 && d != 4; c.Dark(x, y) != want {
+					t.Errorf("module (%d, %d) of the finder at %v is dark: %t", x, y, corner, !want)
+				}
+			}
+		}
+	}
+	for i := 8; i < n-8; i++ {
+		if c.Dark(i, 6) != (i%2 == 0) || c.Dark(6, i) != (i%2 == 0) {
+			t.Errorf("timing pattern broken at %d", i)
+		}
+	}
+	if !c.Dark(8, n-8) {
+		t.Error("the dark module is light")
+	}
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	side := c.Size + 2*QuietZone
+
+	ascii := strings.Split(strings.TrimSuffix(c.ASCII(), "\n"), "\n")
+	if len(ascii) != side {
+		t.Errorf("ASCII has %d lines, want %d", len(ascii), side)
+	}
+	for y, line := range ascii {
+		if len(line) != 2*side {
+			t.Errorf("ASCII line %d is %d characters, want %d", y, len(line), 2*side)
+		}
+	}
+	if !strings.HasPrefix(ascii[0], strings.Repeat("#", 2*side)) || ascii[QuietZone][2*QuietZone:2*QuietZone+2] != "  " {
+		t.Errorf("ASCII doesn't start with a light quiet zone and a dark finder:\n%s", c.ASCII())
+	}
+
+	blocks := strings.Split(strings.TrimSuffix(c.HalfBlocks(), "\n"), "\n")
+	if len(blocks) != (side+1)/2 {
+		t.Errorf("HalfBlocks has %d lines, want %d", len(blocks), (side+1)/2)
+	}
+	for y, line := range blocks {
+		if n := len([]rune(line)); n != side {
+			t.Errorf("HalfBlocks line %d is %d characters, want %d", y, n, side)
+		}
+	}
+	// The last line only has the top half of its modules.
+	if last := blocks[len(blocks)-1]; strings.ContainsAny(last, "█▄") {
+		t.Errorf("HalfBlocks draws below the quiet zone: %q", last)
+	}
+
+	img := c.Image(3)
+	if b := img.Bounds(); b.Dx() != 3*side || b.Dy() != 3*side {
+		t.Errorf("Image is %v, want %dx%d", b, 3*side, 3*side)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("Image quiet zone isn't white")
+	}
+	if r, _, _, _ := img.At(3*QuietZone, 3*QuietZone).RGBA(); r != 0 {
+		t.Error("Image finder isn't black")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"strings"
+)
+
+// QuietZone is the width, in modules, of the light border scanners need
+// around a code.
+const QuietZone = 4
+
+// HalfBlocks renders the code as text, packing two rows of modules into each
+// line with Unicode half blocks. Light modules are drawn and dark ones left
+// blank, which suits the light-on-dark colors of most terminals.
+func (c *Code) HalfBlocks() string {
+	var b strings.Builder
+	for y := -QuietZone; y < c.Size+QuietZone; y += 2 {
+		for x := -QuietZone; x < c.Size+QuietZone; x++ {
+			top := !c.Dark(x, y)
+			bottom := !c.Dark(x, y+1) && y+1 < c.Size+QuietZone
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ASCII renders the code with one line per row of modules and two characters
+// per module, for terminals without Unicode. Like HalfBlocks, light modules
+// are drawn.
+func (c *Code) ASCII() string {
+	var b strings.Builder
+	for y := -QuietZone; y < c.Size+QuietZone; y++ {
+		for x := -QuietZone; x < c.Size+QuietZone; x++ {
+			if c.Dark(x, y) {
+				b.WriteString("  ")
+			} else {
+				b.WriteString("##")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Image renders the code as black modules on white, `scale` pixels to a
+// module, including the quiet zone.
+func (c *Code) Image(scale int) image.Image {
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for py := 0; py < side; py++ {
+		for px := 0; px < side; px++ {
+			v := color.Gray{Y: 0xff}
+			if c.Dark(px/scale-QuietZone, py/scale-QuietZone) {
+				v = color.Gray{}
+			}
+			img.SetGray(px, py, v)
+		}
+	}
+	return img
+}
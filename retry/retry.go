@@ -0,0 +1,248 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry implements retry and polling loops driven by pluggable
+// backoff policies. All waiting goes through a Clock carried by the context,
+// so callers can substitute a fake clock and run backoffs instantly.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrExhausted is returned by PollUntil when the policy gives up before the
+// condition is met.
+var ErrExhausted = errors.New("retry: gave up waiting")
+
+// Clock is the source of time used while backing off.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the Clock backed by the time package. It's used whenever the
+// context doesn't carry a different one.
+var SystemClock Clock = systemClock{}
+
+type clockKey struct{}
+
+// WithClock returns a copy of ctx which makes Do and PollUntil wait on c.
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+func clockFrom(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return c
+	}
+	return SystemClock
+}
+
+type notifyKey struct{}
+
+// A Notify function is told about each failed attempt that's about to be
+// retried, and how long Do waits before the next one.
+type Notify func(attempt int, err error, wait time.Duration)
+
+// WithNotify returns a copy of ctx which makes Do report retries to n, for
+// example to log them.
+func WithNotify(ctx context.Context, n Notify) context.Context {
+	return context.WithValue(ctx, notifyKey{}, n)
+}
+
+// Policy decides whether to try again and for how long to wait first.
+type Policy interface {
+	// Backoff is called after the attempt numbered `attempt` (starting at 1)
+	// failed with err. It returns the delay before the next attempt, or false
+	// to stop.
+	Backoff(attempt int, err error) (time.Duration, bool)
+}
+
+// Fixed waits the same Delay between attempts.
+type Fixed struct {
+	Delay time.Duration
+	// MaxAttempts bounds the total number of attempts; zero means unbounded.
+	MaxAttempts int
+}
+
+// Backoff implements Policy.
+func (p Fixed) Backoff(attempt int, err error) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	return p.Delay, true
+}
+
+// Exponential doubles the delay after every attempt, starting at Initial and
+// capped at Max, with up to Jitter (a fraction between 0 and 1) of each delay
+// randomized to avoid synchronized retries.
+type Exponential struct {
+	Initial time.Duration
+	Max     time.Duration
+	Jitter  float64
+	// MaxAttempts bounds the total number of attempts; zero means unbounded.
+	MaxAttempts int
+}
+
+// Backoff implements Policy.
+func (p Exponential) Backoff(attempt int, err error) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	d := float64(p.Initial) * math.Pow(2, float64(attempt-1))
+	if p.Max > 0 && d > float64(p.Max) {
+		d = float64(p.Max)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d), true
+}
+
+// RetryAfterer is implemented by errors which carry a server-requested delay,
+// such as one parsed from a Retry-After header.
+type RetryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// HonorRetryAfter wraps a policy so that a delay requested by the server
+// takes precedence over the computed one when it's longer.
+type HonorRetryAfter struct {
+	Policy
+}
+
+// Backoff implements Policy.
+func (p HonorRetryAfter) Backoff(attempt int, err error) (time.Duration, bool) {
+	d, ok := p.Policy.Backoff(attempt, err)
+	if !ok {
+		return 0, false
+	}
+	if ra, isRA := err.(RetryAfterer); isRA && ra.RetryAfter() > d {
+		d = ra.RetryAfter()
+	}
+	return d, true
+}
+
+// ParseRetryAfter interprets the value of a Retry-After header, which is
+// either a number of seconds or an HTTP date, relative to now.
+func ParseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// Permanent marks err as not worth retrying. Do returns the wrapped error
+// immediately.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// Sleep waits for d on the clock of ctx, returning ctx's error if it's done
+// first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clockFrom(ctx).After(d):
+		return nil
+	}
+}
+
+// Do calls fn until it succeeds, returns a Permanent error, the policy gives
+// up or ctx is done. The last error from fn is returned in the first three
+// cases and the context's error in the last.
+func Do(ctx context.Context, p Policy, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if perm, ok := err.(permanentError); ok {
+			return perm.err
+		}
+		d, ok := p.Backoff(attempt, err)
+		if !ok {
+			return err
+		}
+		if n, ok := ctx.Value(notifyKey{}).(Notify); ok {
+			n(attempt, err, d)
+		}
+		if err := Sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// PollUntil calls check until it reports done, returns an error, the policy
+// gives up (ErrExhausted) or ctx is done.
+func PollUntil(ctx context.Context, p Policy, check func() (done bool, err error)) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		d, ok := p.Backoff(attempt, nil)
+		if !ok {
+			return ErrExhausted
+		}
+		if err := Sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock fires every wait straight away and records how long each was.
+type fakeClock struct {
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return time.Unix(0, 0) }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now().Add(d)
+	return ch
+}
+
+// stuckClock never fires, so a wait only ends when the context does.
+type stuckClock struct {
+	waiting chan struct{}
+}
+
+func (c stuckClock) Now() time.Time { return time.Unix(0, 0) }
+
+func (c stuckClock) After(d time.Duration) <-chan time.Time {
+	close(c.waiting)
+	return make(chan time.Time)
+}
+
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string             { return "slow down" }
+func (e retryAfterError) RetryAfter() time.Duration { return time.Duration(e) }
+
+var errFlaky = errors.New("flaky")
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		failures int
+		err      error
+		wantErr  error
+		calls    int
+		waits    []time.Duration
+	}{
+		{
+			name:   "succeeds first time",
+			policy: Fixed{Delay: time.Second, MaxAttempts: 3},
+			calls:  1,
+		},
+		{
+			name:     "succeeds after retries",
+			policy:   Fixed{Delay: time.Second, MaxAttempts: 3},
+			failures: 2,
+			err:      errFlaky,
+			calls:    3,
+			waits:    []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:     "gives up after max attempts",
+			policy:   Fixed{Delay: time.Second, MaxAttempts: 3},
+			failures: 5,
+			err:      errFlaky,
+			wantErr:  errFlaky,
+			calls:    3,
+			waits:    []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:     "exponential doubles up to max",
+			policy:   Exponential{Initial: time.Second, Max: 3 * time.Second, MaxAttempts: 4},
+			failures: 4,
+			err:      errFlaky,
+			wantErr:  errFlaky,
+			calls:    4,
+			waits:    []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:     "permanent errors aren't retried",
+			policy:   Fixed{Delay: time.Second, MaxAttempts: 3},
+			failures: 1,
+			err:      Permanent(errFlaky),
+			wantErr:  errFlaky,
+			calls:    1,
+		},
+		{
+			name:     "longer retry-after wins",
+			policy:   HonorRetryAfter{Fixed{Delay: time.Second, MaxAttempts: 2}},
+			failures: 1,
+			err:      retryAfterError(30 * time.Second),
+			calls:    2,
+			waits:    []time.Duration{30 * time.Second},
+		},
+		{
+			name:     "shorter retry-after loses",
+			policy:   HonorRetryAfter{Fixed{Delay: time.Minute, MaxAttempts: 2}},
+			failures: 1,
+			err:      retryAfterError(time.Second),
+			calls:    2,
+			waits:    []time.Duration{time.Minute},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := new(fakeClock)
+			calls := 0
+			err := Do(WithClock(context.Background(), clock), tt.policy, func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("Do() = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("fn called %d times, want %d", calls, tt.calls)
+			}
+			if !reflect.DeepEqual(clock.waits, tt.waits) {
+				t.Errorf("waited %v, want %v", clock.waits, tt.waits)
+			}
+		})
+	}
+}
+
+func TestDoCanceledMidBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := stuckClock{make(chan struct{})}
+	go func() {
+		<-clock.waiting
+		cancel()
+	}()
+
+	calls := 0
+	err := Do(WithClock(ctx, clock), Fixed{Delay: time.Hour}, func() error {
+		calls++
+		return errFlaky
+	})
+	if err != context.Canceled {
+		t.Errorf("Do() = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+}
+
+func TestPollUntil(t *testing.T) {
+	tests := []struct {
+		name    string
+		doneAt  int
+		wantErr error
+		calls   int
+	}{
+		{"done straight away", 1, nil, 1},
+		{"done after polling", 3, nil, 3},
+		{"exhausted", 10, ErrExhausted, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := PollUntil(WithClock(context.Background(), new(fakeClock)), Fixed{Delay: time.Second, MaxAttempts: 4}, func() (bool, error) {
+				calls++
+				return calls >= tt.doneAt, nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("PollUntil() = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("check called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 7, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{" 5 ", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Sun, 17 Jul 2016 12:01:30 GMT", 90 * time.Second, true},
+		{"Sunday, 17-Jul-16 12:00:10 GMT", 10 * time.Second, true},
+		{"Sun, 17 Jul 2016 11:00:00 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := ParseRetryAfter(tt.in, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseRetryAfter(%q) = %v, %t, want %v, %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
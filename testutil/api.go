@@ -0,0 +1,164 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// APICall is a single recorded HTTP request to the Dropbox API.
+type APICall struct {
+	// Route is the namespace and route, e.g. "files/delete_batch".
+	Route string
+	// Arg is the JSON argument, from the body of RPC requests or the
+	// Dropbox-API-Arg header of content requests.
+	Arg string
+	// Content is the body of upload requests.
+	Content []byte
+	Header  http.Header
+}
+
+type apiResponse struct {
+	status  int
+	body    string
+	content []byte
+	header  http.Header
+}
+
+// FakeAPI is an http.RoundTripper which answers Dropbox API requests from
+// scripted responses, for the routes dbxcli calls without going through the
+// SDK clients. Install it as http.DefaultTransport:
+//
+//	fake := testutil.NewFakeAPI()
+//	fake.Respond("files/delete_batch", 200, `{".tag": "complete", "entries": []}`)
+//	http.DefaultTransport = fake
+type FakeAPI struct {
+	mu        sync.Mutex
+	calls     []APICall
+	responses map[string][]apiResponse
+	header    http.Header
+}
+
+// NewFakeAPI returns a FakeAPI with nothing scripted.
+func NewFakeAPI() *FakeAPI {
+	return &FakeAPI{responses: make(map[string][]apiResponse)}
+}
+
+// Respond queues the status and JSON body of the next request to `route`.
+func (f *FakeAPI) Respond(route string, status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[route] = append(f.responses[route], apiResponse{status: status, body: body})
+}
+
+// SetHeader adds a header to every response from now on, such as the
+// X-Dropbox-Request-Id Dropbox sends with each.
+func (f *FakeAPI) SetHeader(key string, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.header == nil {
+		f.header = make(http.Header)
+	}
+	f.header.Set(key, value)
+}
+
+// RespondContent queues the answer to the next request to the download-style
+// `route`: `result` goes in the Dropbox-API-Result header and `content` in
+// the body.
+func (f *FakeAPI) RespondContent(route string, result string, content []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := http.Header{"Dropbox-Api-Result": {result}}
+	f.responses[route] = append(f.responses[route], apiResponse{status: http.StatusOK, content: content, header: h})
+}
+
+// Calls returns the recorded requests in the order they were made.
+func (f *FakeAPI) Calls() []APICall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]APICall(nil), f.calls...)
+}
+
+// Routes returns the routes of the recorded requests in the order they were
+// made.
+func (f *FakeAPI) Routes() []string {
+	var routes []string
+	for _, c := range f.Calls() {
+		routes = append(routes, c.Route)
+	}
+	return routes
+}
+
+// RoundTrip implements http.RoundTripper. Requests with nothing queued fail,
+// so unexpected API traffic shows up in the command's error.
+func (f *FakeAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	call := APICall{
+		Route:  strings.TrimPrefix(req.URL.Path, "/2/"),
+		Arg:    req.Header.Get("Dropbox-API-Arg"),
+		Header: req.Header.Clone(),
+	}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if call.Arg == "" {
+			call.Arg = string(body)
+		} else {
+			call.Content = body
+		}
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	queue := f.responses[call.Route]
+	if len(queue) == 0 {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("testutil: unexpected request to %s with %s", call.Route, call.Arg)
+	}
+	f.responses[call.Route] = queue[1:]
+	extra := f.header.Clone()
+	f.mu.Unlock()
+
+	r := queue[0]
+	content := r.content
+	if content == nil {
+		content = []byte(r.body)
+	}
+	header := r.header.Clone()
+	if header == nil {
+		header = http.Header{"Content-Type": {"application/json"}}
+	}
+	for k, v := range extra {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       req,
+	}, nil
+}
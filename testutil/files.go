@@ -0,0 +1,238 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil provides fakes of the Dropbox SDK clients and HTTP API so
+// that dbxcli commands can be exercised without talking to Dropbox.
+//
+// Commands never call the SDK constructors directly; they go through the
+// newFilesClient, newSharingClient, newUsersClient and newTeamClient variables
+// in package cmd. A test in that package swaps one for a fake, scripts the
+// responses it should return, runs the command and then asserts on the calls
+// the fake recorded and on what the command printed:
+//
+//	fake := testutil.NewFakeFiles()
+//	fake.Respond("ListFolder", page1, nil)
+//	fake.Respond("ListFolderContinue", page2, nil)
+//
+//	saved := newFilesClient
+//	newFilesClient = func(context.Context) files.Client { return fake }
+//	defer func() { newFilesClient = saved }()
+//
+//	stdout, stderr, err := testutil.Capture(func() error {
+//		return ls(lsCmd, []string{"/photos"})
+//	})
+//	if got := fake.Methods(); !reflect.DeepEqual(got, []string{"ListFolder", "ListFolderContinue"}) {
+//		t.Errorf("unexpected calls %v", got)
+//	}
+//
+// Calling the RunE function directly skips the root command's PersistentPreRunE,
+// which would otherwise try to authenticate.
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+)
+
+// Call is a single recorded call to a fake client.
+type Call struct {
+	Method string
+	Arg    interface{}
+	// Content holds the request body for upload-style routes.
+	Content []byte
+}
+
+type response struct {
+	res     interface{}
+	content []byte
+	err     error
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	calls     []Call
+	responses map[string][]response
+}
+
+// Respond queues the result of the next call to method. `res` must have the
+// type the SDK returns for that method (or be nil). Calls made with nothing
+// queued panic, so unexpected API traffic fails the test loudly.
+func (r *recorder) Respond(method string, res interface{}, err error) {
+	r.RespondContent(method, res, nil, err)
+}
+
+// RespondContent is Respond for download-style routes, which also return the
+// body in `content`.
+func (r *recorder) RespondContent(method string, res interface{}, content []byte, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[method] = append(r.responses[method], response{res, content, err})
+}
+
+// Calls returns the recorded calls in the order they were made.
+func (r *recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Methods returns the names of the recorded calls in the order they were
+// made.
+func (r *recorder) Methods() []string {
+	var methods []string
+	for _, c := range r.Calls() {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
+
+func (r *recorder) record(method string, arg interface{}, content io.Reader) response {
+	var body []byte
+	if content != nil {
+		body, _ = ioutil.ReadAll(content)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{method, arg, body})
+	queue := r.responses[method]
+	if len(queue) == 0 {
+		panic(fmt.Sprintf("testutil: unexpected call to %s(%+v)", method, arg))
+	}
+	r.responses[method] = queue[1:]
+	return queue[0]
+}
+
+// FakeFiles is a scripted files.Client. Only the routes dbxcli uses are
+// implemented; calling any other route panics.
+type FakeFiles struct {
+	files.Client
+	recorder
+}
+
+// NewFakeFiles returns a FakeFiles with nothing scripted.
+func NewFakeFiles() *FakeFiles {
+	return &FakeFiles{recorder: recorder{responses: make(map[string][]response)}}
+}
+
+func (f *FakeFiles) metadata(method string, arg interface{}) (files.IsMetadata, error) {
+	r := f.record(method, arg, nil)
+	res, _ := r.res.(files.IsMetadata)
+	return res, r.err
+}
+
+// Copy implements files.Client.
+func (f *FakeFiles) Copy(arg *files.RelocationArg) (files.IsMetadata, error) {
+	return f.metadata("Copy", arg)
+}
+
+// CreateFolder implements files.Client.
+func (f *FakeFiles) CreateFolder(arg *files.CreateFolderArg) (*files.FolderMetadata, error) {
+	r := f.record("CreateFolder", arg, nil)
+	res, _ := r.res.(*files.FolderMetadata)
+	return res, r.err
+}
+
+// Delete implements files.Client.
+func (f *FakeFiles) Delete(arg *files.DeleteArg) (files.IsMetadata, error) {
+	return f.metadata("Delete", arg)
+}
+
+// Download implements files.Client.
+func (f *FakeFiles) Download(arg *files.DownloadArg) (*files.FileMetadata, io.ReadCloser, error) {
+	r := f.record("Download", arg, nil)
+	res, _ := r.res.(*files.FileMetadata)
+	return res, ioutil.NopCloser(bytes.NewReader(r.content)), r.err
+}
+
+// GetMetadata implements files.Client.
+func (f *FakeFiles) GetMetadata(arg *files.GetMetadataArg) (files.IsMetadata, error) {
+	return f.metadata("GetMetadata", arg)
+}
+
+// ListFolder implements files.Client.
+func (f *FakeFiles) ListFolder(arg *files.ListFolderArg) (*files.ListFolderResult, error) {
+	r := f.record("ListFolder", arg, nil)
+	res, _ := r.res.(*files.ListFolderResult)
+	return res, r.err
+}
+
+// ListFolderContinue implements files.Client.
+func (f *FakeFiles) ListFolderContinue(arg *files.ListFolderContinueArg) (*files.ListFolderResult, error) {
+	r := f.record("ListFolderContinue", arg, nil)
+	res, _ := r.res.(*files.ListFolderResult)
+	return res, r.err
+}
+
+// ListFolderGetLatestCursor implements files.Client.
+func (f *FakeFiles) ListFolderGetLatestCursor(arg *files.ListFolderArg) (*files.ListFolderGetLatestCursorResult, error) {
+	r := f.record("ListFolderGetLatestCursor", arg, nil)
+	res, _ := r.res.(*files.ListFolderGetLatestCursorResult)
+	return res, r.err
+}
+
+// ListRevisions implements files.Client.
+func (f *FakeFiles) ListRevisions(arg *files.ListRevisionsArg) (*files.ListRevisionsResult, error) {
+	r := f.record("ListRevisions", arg, nil)
+	res, _ := r.res.(*files.ListRevisionsResult)
+	return res, r.err
+}
+
+// Move implements files.Client.
+func (f *FakeFiles) Move(arg *files.RelocationArg) (files.IsMetadata, error) {
+	return f.metadata("Move", arg)
+}
+
+// Restore implements files.Client.
+func (f *FakeFiles) Restore(arg *files.RestoreArg) (*files.FileMetadata, error) {
+	r := f.record("Restore", arg, nil)
+	res, _ := r.res.(*files.FileMetadata)
+	return res, r.err
+}
+
+// Upload implements files.Client.
+func (f *FakeFiles) Upload(arg *files.CommitInfo, content io.Reader) (*files.FileMetadata, error) {
+	r := f.record("Upload", arg, content)
+	res, _ := r.res.(*files.FileMetadata)
+	return res, r.err
+}
+
+// UploadSessionStart implements files.Client.
+func (f *FakeFiles) UploadSessionStart(arg *files.UploadSessionStartArg, content io.Reader) (*files.UploadSessionStartResult, error) {
+	r := f.record("UploadSessionStart", arg, content)
+	res, _ := r.res.(*files.UploadSessionStartResult)
+	return res, r.err
+}
+
+// UploadSessionAppend implements files.Client.
+func (f *FakeFiles) UploadSessionAppend(arg *files.UploadSessionCursor, content io.Reader) error {
+	return f.record("UploadSessionAppend", arg, content).err
+}
+
+// UploadSessionAppendV2 implements files.Client.
+func (f *FakeFiles) UploadSessionAppendV2(arg *files.UploadSessionAppendArg, content io.Reader) error {
+	return f.record("UploadSessionAppendV2", arg, content).err
+}
+
+// UploadSessionFinish implements files.Client.
+func (f *FakeFiles) UploadSessionFinish(arg *files.UploadSessionFinishArg, content io.Reader) (*files.FileMetadata, error) {
+	r := f.record("UploadSessionFinish", arg, content)
+	res, _ := r.res.(*files.FileMetadata)
+	return res, r.err
+}
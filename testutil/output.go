@@ -0,0 +1,57 @@
+// Copyright © 2016 Dropbox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Capture runs fn with os.Stdout and os.Stderr redirected, and returns what
+// was written to each along with fn's error. Commands print straight to the
+// process's standard streams, so this is how tests observe their output.
+func Capture(fn func() error) (stdout string, stderr string, err error) {
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		return
+	}
+
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	defer func() {
+		os.Stdout, os.Stderr = savedOut, savedErr
+	}()
+
+	outC := make(chan string)
+	errC := make(chan string)
+	drain := func(r io.Reader, c chan<- string) {
+		var b bytes.Buffer
+		io.Copy(&b, r)
+		c <- b.String()
+	}
+	go drain(outR, outC)
+	go drain(errR, errC)
+
+	err = fn()
+
+	outW.Close()
+	errW.Close()
+	return <-outC, <-errC, err
+}